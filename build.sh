@@ -66,7 +66,7 @@ export CGO_ENABLED=1
 
 # Build
 echo "Building tdclient..."
-go build -v -tags libtdjson -o tdclient ./cmd/tdclient
+go build -v -tags "libtdjson sqlite_fts5" -o tdclient ./cmd/tdclient
 
 echo "✓ Build successful!"
 echo "Run with: ./tdclient -config config.yaml"
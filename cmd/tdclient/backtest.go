@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"tdlib-go/internal/analytics"
+	"tdlib-go/internal/backtest"
+	"tdlib-go/internal/config"
+	"tdlib-go/internal/storage"
+	"tdlib-go/internal/trading"
+	"tdlib-go/pkg/models"
+)
+
+// runBacktest implements the "backtest" subcommand:
+//
+//	tdclient backtest -klines ./klines -channel -1001234567890
+//	tdclient backtest -klines ./klines -messages result.json -target 0.2 -stoploss 0.1
+func runBacktest(args []string) int {
+	fs := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	cfgPath := fs.String("config", "config.yaml", "Path to configuration file")
+	messagesFile := fs.String("messages", "", "Messages file (JSON Lines or Telegram Desktop result.json)")
+	channelID := fs.Int64("channel", 0, "Replay messages stored in the database for this channel")
+	limit := fs.Int("limit", 10000, "Maximum number of stored messages to replay")
+	klinesDir := fs.String("klines", "klines", "Directory with cached 1m kline CSV files")
+	accountID := fs.Int64("account", 0, "Use TP/SL, leverage, amount and timeout of this account")
+	leverage := fs.Int("leverage", 0, "Leverage (overrides account/config)")
+	amount := fs.Float64("amount", 0, "Order amount in USDT (overrides account/config)")
+	target := fs.Float64("target", 0, "Take profit percent, e.g. 0.2 for 20% on margin (overrides account/config)")
+	stopLoss := fs.Float64("stoploss", 0, "Stop loss percent, e.g. 0.1 for 10% on margin (overrides account/config)")
+	timeout := fs.Duration("timeout", -1, "Close at market after this long, 0 to disable (overrides account/config)")
+	cooldown := fs.Duration("cooldown", 48*time.Hour, "Skip repeated signals for a symbol within this window")
+	feeRate := fs.Float64("fee", analytics.EstimatedFeeRate, "Commission per leg as a fraction of notional")
+	csvOut := fs.String("out", "", "Write per-trade results to this CSV file")
+	jsonOut := fs.Bool("json", false, "Print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *messagesFile == "" && *channelID == 0 {
+		fmt.Fprintln(os.Stderr, "backtest: either -messages or -channel is required")
+		fs.Usage()
+		return 2
+	}
+
+	logger := setupLogger("warn")
+
+	cfg, err := config.Load(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backtest: failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	var repo *storage.Repository
+	if *channelID != 0 || *accountID != 0 {
+		dbPath, err := storage.GetDatabasePath(cfg.Database.DSN)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "backtest: failed to get database path: %v\n", err)
+			return 1
+		}
+		repo, err = storage.NewRepository(dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "backtest: failed to open database: %v\n", err)
+			return 1
+		}
+		defer repo.Close()
+	}
+
+	settings := backtest.Settings{
+		Leverage:        cfg.Trading.Leverage,
+		OrderAmount:     cfg.Trading.OrderAmount,
+		TargetPercent:   cfg.Trading.TargetPercent,
+		StopLossPercent: cfg.Trading.StopLossPercent,
+		Timeout:         time.Duration(cfg.Trading.OrderTimeout) * time.Second,
+		Cooldown:        *cooldown,
+		FeeRate:         *feeRate,
+		IsTokenIgnored:  cfg.Trading.IsTokenIgnored,
+	}
+	if *accountID != 0 {
+		account, err := repo.GetAccount(*accountID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "backtest: failed to load account: %v\n", err)
+			return 1
+		}
+		settings.Leverage = account.Leverage
+		settings.OrderAmount = account.OrderAmount
+		settings.TargetPercent = account.TargetPercent
+		settings.StopLossPercent = account.StopLossPercent
+		settings.Timeout = time.Duration(account.OrderTimeout) * time.Second
+	}
+	if *leverage > 0 {
+		settings.Leverage = *leverage
+	}
+	if *amount > 0 {
+		settings.OrderAmount = *amount
+	}
+	if *target > 0 {
+		settings.TargetPercent = *target
+	}
+	if *stopLoss > 0 {
+		settings.StopLossPercent = *stopLoss
+	}
+	if *timeout >= 0 {
+		settings.Timeout = *timeout
+	}
+
+	messages, err := loadBacktestMessages(repo, *messagesFile, *channelID, *limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backtest: %v\n", err)
+		return 1
+	}
+
+	parser, err := trading.NewSignalParser(cfg, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backtest: failed to create signal parser: %v\n", err)
+		return 1
+	}
+
+	simulator, err := backtest.NewSimulator(parser, backtest.NewKlineCache(*klinesDir), settings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backtest: %v\n", err)
+		return 1
+	}
+
+	report, err := simulator.Run(messages)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backtest: %v\n", err)
+		return 1
+	}
+
+	if *csvOut != "" {
+		if err := writeBacktestCSV(*csvOut, report.Trades); err != nil {
+			fmt.Fprintf(os.Stderr, "backtest: %v\n", err)
+			return 1
+		}
+	}
+
+	if *jsonOut {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "backtest: failed to encode report: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	printBacktestReport(os.Stdout, report, settings)
+	return 0
+}
+
+// loadBacktestMessages loads messages from a file or from the database
+func loadBacktestMessages(repo *storage.Repository, path string, channelID int64, limit int) ([]*models.Message, error) {
+	if path != "" {
+		messages, err := backtest.LoadMessagesFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if channelID == 0 {
+			return messages, nil
+		}
+
+		filtered := messages[:0]
+		for _, msg := range messages {
+			if msg.ChannelID == channelID {
+				filtered = append(filtered, msg)
+			}
+		}
+		return filtered, nil
+	}
+
+	messages, err := repo.GetMessagesByChannel(channelID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load messages: %w", err)
+	}
+
+	// Stored messages come newest first
+	backtest.SortMessages(messages)
+	return messages, nil
+}
+
+// writeBacktestCSV writes per-trade results to a CSV file
+func writeBacktestCSV(path string, trades []*backtest.TradeResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create CSV file: %w", err)
+	}
+	defer f.Close()
+
+	if err := backtest.WriteTradesCSV(f, trades); err != nil {
+		return fmt.Errorf("failed to write CSV file: %w", err)
+	}
+	return nil
+}
+
+// printBacktestReport prints per-trade results and aggregate statistics
+func printBacktestReport(out io.Writer, report *backtest.Report, settings backtest.Settings) {
+	fmt.Fprintf(out, "Settings: leverage %dx, amount %.2f USDT, TP %.2f%%, SL %.2f%%, timeout %s, fee %.4f%%\n\n",
+		settings.Leverage, settings.OrderAmount, settings.TargetPercent*100, settings.StopLossPercent*100,
+		formatTimeout(settings.Timeout), settings.FeeRate*100)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SIGNAL TIME\tSYMBOL\tENTRY\tEXIT\tOUTCOME\tHOLD\tNET PNL\tPNL %")
+	for _, t := range report.Trades {
+		fmt.Fprintf(w, "%s\t%s\t%.6g\t%.6g\t%s\t%s\t%.2f\t%.2f\n",
+			t.SignalTime.UTC().Format("2006-01-02 15:04"), t.Symbol, t.EntryPrice, t.ExitPrice,
+			t.Outcome, t.ExitTime.Sub(t.EntryTime).Round(time.Minute), t.PnL-t.Fees, t.PnLPercent)
+	}
+	w.Flush()
+
+	if len(report.Skipped) > 0 {
+		reasons := make(map[string]int)
+		for _, s := range report.Skipped {
+			reasons[s.Reason]++
+		}
+		var parts []string
+		for reason, count := range reasons {
+			parts = append(parts, fmt.Sprintf("%s: %d", reason, count))
+		}
+		sort.Strings(parts)
+		fmt.Fprintf(out, "\nSkipped %d signals (%s)\n", len(report.Skipped), strings.Join(parts, ", "))
+	}
+
+	outcomes := make(map[string]int)
+	for _, t := range report.Trades {
+		outcomes[t.Outcome]++
+	}
+
+	stats := report.Stats
+	fmt.Fprintf(out, "\nMessages: %d  Signals: %d  Trades: %d\n", report.Messages, report.Signals, stats.TotalTrades)
+	fmt.Fprintf(out, "Take profit: %d  Stop loss: %d  Timeout: %d  Incomplete: %d\n",
+		outcomes[backtest.OutcomeTakeProfit], outcomes[backtest.OutcomeStopLoss],
+		outcomes[backtest.OutcomeTimeout], outcomes[backtest.OutcomeIncomplete])
+	fmt.Fprintf(out, "Win rate: %.2f%%  Profit factor: %.2f  Expectancy: %.2f USDT\n",
+		stats.WinRate, stats.ProfitFactor, stats.Expectancy)
+	fmt.Fprintf(out, "Gross PnL: %.2f  Fees: %.2f  Net PnL: %.2f USDT\n", stats.TotalPnL, stats.TotalFees, stats.NetPnL)
+	fmt.Fprintf(out, "Max drawdown: %.2f USDT  Sharpe: %.2f  Avg hold: %s\n",
+		stats.MaxDrawdown, stats.SharpeRatio, (time.Duration(stats.AvgHoldSeconds) * time.Second).Round(time.Minute))
+}
+
+// formatTimeout renders a timeout, where zero means disabled
+func formatTimeout(d time.Duration) string {
+	if d <= 0 {
+		return "none"
+	}
+	return d.String()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"tdlib-go/internal/config"
+	"tdlib-go/internal/storage"
+	"tdlib-go/internal/trading"
+	"tdlib-go/pkg/models"
+)
+
+// runHalt implements the "halt" subcommand. It persists the emergency halt,
+// which a running instance picks up before its next trade, and flattens the
+// accounts itself:
+//
+//	tdclient halt -reason "exchange outage"
+//	tdclient halt -accounts 1,3
+func runHalt(args []string) int {
+	fs := flag.NewFlagSet("halt", flag.ContinueOnError)
+	cfgPath := fs.String("config", "config.yaml", "Path to configuration file")
+	reason := fs.String("reason", "", "Reason for the halt")
+	accountList := fs.String("accounts", "", "Comma-separated account IDs to flatten (default: all active accounts)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var accountIDs []int64
+	for _, field := range strings.Split(*accountList, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "halt: invalid account ID %q\n", field)
+			return 2
+		}
+		accountIDs = append(accountIDs, id)
+	}
+
+	cfg, repo, err := openEmergencyRepository(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "halt: %v\n", err)
+		return 1
+	}
+	defer repo.Close()
+
+	// Flatten even when trading is switched off in the settings
+	cfg.Trading.Enabled = true
+
+	engine, err := trading.NewEngine(repo, cfg, setupLogger("warn"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "halt: %v\n", err)
+		return 1
+	}
+	defer engine.Stop()
+
+	result, err := engine.Halt(*reason, accountIDs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "halt: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Trading halted: %s\n", result.State.Reason)
+	status := 0
+	for _, account := range result.Accounts {
+		fmt.Printf("  %s (ID: %d): %d orders canceled, positions closed: %s\n",
+			account.AccountName, account.AccountID, account.CanceledOrders, strings.Join(account.ClosedPositions, ", "))
+		if account.Error != "" {
+			fmt.Printf("    Error: %s\n", account.Error)
+			status = 1
+		}
+	}
+
+	return status
+}
+
+// runResume implements the "resume" subcommand, lifting the emergency halt
+func runResume(args []string) int {
+	fs := flag.NewFlagSet("resume", flag.ContinueOnError)
+	cfgPath := fs.String("config", "config.yaml", "Path to configuration file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	_, repo, err := openEmergencyRepository(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "resume: %v\n", err)
+		return 1
+	}
+	defer repo.Close()
+
+	state, err := repo.GetHaltState()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "resume: %v\n", err)
+		return 1
+	}
+	if !state.Halted {
+		fmt.Println("Trading is not halted")
+		return 0
+	}
+
+	if err := repo.SaveHaltState(&models.HaltState{}); err != nil {
+		fmt.Fprintf(os.Stderr, "resume: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Trading resumed (halted since %s: %s)\n", state.HaltedAt.Format("2006-01-02 15:04:05"), state.Reason)
+	return 0
+}
+
+// openEmergencyRepository loads the configuration, with the settings stored
+// in the database applied, and opens the database
+func openEmergencyRepository(cfgPath string) (*config.Config, *storage.Repository, error) {
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	dbPath, err := storage.GetDatabasePath(cfg.Database.DSN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get database path: %w", err)
+	}
+
+	repo, err := storage.NewRepository(dbPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	settings, err := repo.GetAllSettings()
+	if err != nil {
+		repo.Close()
+		return nil, nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+	cfg.LoadSettingsFromMap(settings)
+
+	return cfg, repo, nil
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"tdlib-go/internal/cli"
 	"tdlib-go/internal/config"
+	"tdlib-go/internal/events"
 	"tdlib-go/internal/storage"
 	"tdlib-go/internal/telegram"
 	"tdlib-go/internal/trading"
@@ -20,11 +21,25 @@ import (
 )
 
 var (
-	configPath = flag.String("config", "config.yaml", "Path to configuration file")
-	logLevel   = flag.String("log-level", "", "Log level (debug, info, warn, error)")
+	configPath  = flag.String("config", "config.yaml", "Path to configuration file")
+	logLevel    = flag.String("log-level", "", "Log level (debug, info, warn, error)")
+	replayPath  = flag.String("replay", "", "Replay messages from a JSON Lines file (\"-\" for stdin) instead of connecting to Telegram")
+	replaySpeed = flag.Float64("replay-speed", 0, "Replay speed multiplier between message timestamps, 0 for no delay")
 )
 
 func main() {
+	// Subcommands that run without connecting to Telegram
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backtest":
+			os.Exit(runBacktest(os.Args[2:]))
+		case "halt":
+			os.Exit(runHalt(os.Args[2:]))
+		case "resume":
+			os.Exit(runResume(os.Args[2:]))
+		}
+	}
+
 	flag.Parse()
 
 	// Initialize logger
@@ -71,19 +86,35 @@ func main() {
 		logger.Info("Settings loaded from database")
 	}
 
-	// Initialize Telegram client
-	client, err := telegram.NewClient(cfg, logger)
-	if err != nil {
-		logger.Fatalf("Failed to create Telegram client: %v", err)
-	}
-
-	// Start client and authenticate
-	if err := client.Start(); err != nil {
-		logger.Fatalf("Failed to start Telegram client: %v", err)
+	// Initialize message source: TDLib, the HTTP Bot API, or a replay of recorded messages.
+	// The TDLib client is authorized further down, once the CLI and web API
+	// are up to take login codes.
+	var source telegram.MessageSource
+	var tdClient *telegram.Client
+	if *replayPath != "" {
+		replay, err := telegram.OpenReplaySource(*replayPath, *replaySpeed, logger)
+		if err != nil {
+			logger.Fatalf("Failed to open replay source: %v", err)
+		}
+		source = replay
+		logger.Infof("Replaying messages from %s instead of connecting to Telegram", *replayPath)
+	} else if cfg.UsesBotAPI() {
+		bot := telegram.NewBotAPISource(cfg, logger)
+		if err := bot.Start(); err != nil {
+			logger.Fatalf("Failed to start Bot API client: %v", err)
+		}
+		source = bot
+	} else {
+		client, err := telegram.NewClient(cfg, logger)
+		if err != nil {
+			logger.Fatalf("Failed to create Telegram client: %v", err)
+		}
+		tdClient = client
+		source = client
 	}
 
 	// Initialize monitor
-	monitor := telegram.NewMonitor(client, repo, cfg, logger)
+	monitor := telegram.NewMonitor(source, repo, cfg, logger)
 
 	// Initialize trading engine
 	tradingEngine, err := trading.NewEngine(repo, cfg, logger)
@@ -94,22 +125,46 @@ func main() {
 	// Initialize and start web API server
 	webServer := webapi.NewServer(repo, cfg, logger)
 	webServer.SetMonitor(monitor)
+	if tdClient != nil {
+		webServer.SetTelegramAuth(tdClient.Auth())
+	}
 
 	// Connect trading engine to web server
 	tradingEngine.SetWebAPI(webServer)
-
-	// Set message callback for trading
-	monitor.SetMessageCallback(tradingEngine.ProcessMessage)
-
-	// Start trading engine
-	if err := tradingEngine.Start(); err != nil {
-		logger.Fatalf("Failed to start trading engine: %v", err)
+	webServer.SetSignalIngester(tradingEngine)
+	webServer.SetTrader(tradingEngine)
+
+	// Publish trade events, optionally as messages to a Telegram chat
+	eventBus := events.NewBus()
+	tradingEngine.SetEventBus(eventBus)
+
+	var notifier *events.Notifier
+	if cfg.Notifications.Enabled {
+		if sender, ok := source.(events.Sender); ok {
+			notifier, err = events.NewNotifier(sender, &cfg.Notifications, logger)
+			if err != nil {
+				logger.Fatalf("Failed to create notifier: %v", err)
+			}
+			eventBus.Subscribe(notifier.Handle)
+			notifier.Start()
+		} else {
+			logger.Warn("Notifications need a Telegram session and are disabled in replay mode")
+		}
 	}
 
-	// Start monitoring
-	if err := monitor.Start(); err != nil {
-		logger.Fatalf("Failed to start monitor: %v", err)
+	// Deliver trade events to the webhooks managed through /api/webhooks
+	webhooks, err := events.NewWebhookDispatcher(repo, logger)
+	if err != nil {
+		logger.Fatalf("Failed to create webhook dispatcher: %v", err)
 	}
+	eventBus.Subscribe(webhooks.Handle)
+	webServer.SetWebhooks(webhooks)
+	webhooks.Start()
+
+	// Set message callback for trading
+	monitor.SetMessageCallback(tradingEngine.ProcessMessage)
+	monitor.SetSignalFilter(tradingEngine.IsSignalCandidate)
+	monitor.SetHistoricalCallback(tradingEngine.ProcessHistoricalMessage)
 
 	// Start web server in goroutine
 	go func() {
@@ -130,16 +185,56 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 
+	// Start CLI in a goroutine, unless stdin carries the replay
+	if *replayPath != "-" {
+		cliHandler := cli.NewCLI(monitor, logger)
+		cliHandler.SetTradingEngine(tradingEngine)
+		if tdClient != nil {
+			cliHandler.SetAuthenticator(tdClient.Auth())
+		}
+		go func() {
+			cliHandler.Start()
+		}()
+	}
+
+	// Authorize the Telegram client; codes and passwords arrive through the CLI or web API
+	if tdClient != nil {
+		authDone := make(chan error, 1)
+		go func() {
+			authDone <- tdClient.Start()
+		}()
+
+		select {
+		case err := <-authDone:
+			if err != nil {
+				logger.Fatalf("Failed to start Telegram client: %v", err)
+			}
+		case <-sigChan:
+			logger.Info("Shutdown signal received during login")
+			if err := tdClient.Stop(); err != nil {
+				logger.Errorf("Error stopping Telegram client: %v", err)
+			}
+			if err := webServer.Stop(); err != nil {
+				logger.Errorf("Error stopping web server: %v", err)
+			}
+			return
+		}
+	}
+
+	// Start trading engine
+	if err := tradingEngine.Start(); err != nil {
+		logger.Fatalf("Failed to start trading engine: %v", err)
+	}
+
+	// Start monitoring
+	if err := monitor.Start(); err != nil {
+		logger.Fatalf("Failed to start monitor: %v", err)
+	}
+
 	// Start message listener in a goroutine
 	listenerDone := make(chan error, 1)
 	go func() {
-		listenerDone <- client.StartListening()
-	}()
-
-	// Start CLI in a goroutine
-	cliHandler := cli.NewCLI(monitor, logger)
-	go func() {
-		cliHandler.Start()
+		listenerDone <- source.StartListening()
 	}()
 
 	// Wait for shutdown signal or error
@@ -162,14 +257,23 @@ func main() {
 		logger.Errorf("Error stopping web server: %v", err)
 	}
 
+	// Stop monitor
+	monitor.Stop()
+
 	// Stop trading engine
 	if err := tradingEngine.Stop(); err != nil {
 		logger.Errorf("Error stopping trading engine: %v", err)
 	}
 
-	// Stop client
-	if err := client.Stop(); err != nil {
-		logger.Errorf("Error stopping client: %v", err)
+	// Send pending notifications while the session is still open
+	if notifier != nil {
+		notifier.Stop()
+	}
+	webhooks.Stop()
+
+	// Stop message source
+	if err := source.Stop(); err != nil {
+		logger.Errorf("Error stopping message source: %v", err)
 	}
 
 	// Close database
@@ -236,21 +340,3 @@ func createDirectories(cfg *config.Config) error {
 
 	return nil
 }
-
-// reconnect attempts to reconnect to Telegram
-func reconnect(client *telegram.Client, logger *logrus.Logger, maxRetries int) error {
-	for i := 0; i < maxRetries; i++ {
-		logger.Infof("Reconnection attempt %d/%d", i+1, maxRetries)
-
-		if err := client.Start(); err != nil {
-			logger.Errorf("Reconnection failed: %v", err)
-			time.Sleep(time.Duration(i+1) * 5 * time.Second)
-			continue
-		}
-
-		logger.Info("Reconnection successful")
-		return nil
-	}
-
-	return fmt.Errorf("failed to reconnect after %d attempts", maxRetries)
-}
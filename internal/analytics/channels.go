@@ -0,0 +1,114 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+
+	"tdlib-go/pkg/models"
+)
+
+// ScoreParams controls how channels are scored
+type ScoreParams struct {
+	// SlippageRate is the assumed adverse slippage per leg as a fraction of
+	// price (e.g. 0.001 for 0.1%), applied on top of fees
+	SlippageRate float64
+	// MinTrades is the sample size at which a channel's score is fully trusted.
+	// Scores of channels with fewer trades are shrunk toward zero.
+	MinTrades int
+}
+
+// ScoreChannels computes a leaderboard entry for every channel. Channels
+// without trades are included with their signal count and a zero score.
+// The result is sorted by score, best first.
+func ScoreChannels(trades []*Trade, channels []*models.Channel, signalCounts map[int64]int, params ScoreParams) []*models.ChannelScore {
+	scores := make(map[int64]*models.ChannelScore)
+
+	entry := func(channelID int64) *models.ChannelScore {
+		score, exists := scores[channelID]
+		if !exists {
+			score = &models.ChannelScore{ChannelID: channelID, TradingEnabled: true}
+			scores[channelID] = score
+		}
+		return score
+	}
+
+	for _, ch := range channels {
+		score := entry(ch.ChannelID)
+		score.Title = ch.Title
+		score.TradingEnabled = ch.TradingEnabled
+	}
+	for channelID, count := range signalCounts {
+		entry(channelID).SignalCount = count
+	}
+
+	byChannel := make(map[int64][]*Trade)
+	for _, t := range trades {
+		if t.ChannelID == 0 {
+			continue // Not linked to a signal
+		}
+		byChannel[t.ChannelID] = append(byChannel[t.ChannelID], t)
+		if t.ChannelTitle != "" {
+			entry(t.ChannelID).Title = t.ChannelTitle
+		}
+	}
+
+	for channelID, channelTrades := range byChannel {
+		scoreTrades(entry(channelID), channelTrades, params)
+	}
+
+	result := make([]*models.ChannelScore, 0, len(scores))
+	for _, score := range scores {
+		result = append(result, score)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].SignalCount > result[j].SignalCount
+	})
+
+	return result
+}
+
+// scoreTrades fills the trade-derived fields of a channel score
+func scoreTrades(score *models.ChannelScore, trades []*Trade, params ScoreParams) {
+	var wins, adjustedHits, rCount int
+	var sumR, sumAdjustedR float64
+
+	for _, t := range trades {
+		net := t.NetPnL()
+		score.NetPnL += net
+
+		if t.PnL > 0 {
+			wins++
+		}
+
+		slippage := (t.EntryPrice + t.ExitPrice) * t.Quantity * params.SlippageRate
+		if net-slippage > 0 {
+			adjustedHits++
+		}
+
+		if risk := t.Risk(); risk > 0 {
+			sumR += t.PnL / risk
+			sumAdjustedR += (net - slippage) / risk
+			rCount++
+		}
+	}
+
+	n := float64(len(trades))
+	score.Trades = len(trades)
+	score.WinRate = float64(wins) / n * 100
+	score.SlippageAdjustedHitRate = float64(adjustedHits) / n * 100
+
+	if rCount > 0 {
+		score.AvgRMultiple = sumR / float64(rCount)
+
+		// Score: slippage-adjusted expectancy in R, shrunk for small samples
+		confidence := 1.0
+		if params.MinTrades > 0 {
+			confidence = math.Min(1, n/float64(params.MinTrades))
+		}
+		score.Score = sumAdjustedR / float64(rCount) * confidence
+	}
+}
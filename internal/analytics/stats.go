@@ -0,0 +1,239 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"tdlib-go/pkg/models"
+)
+
+// EstimatedFeeRate is the taker fee applied to both legs of a trade when
+// no actual commission is known (Binance USDT-M default taker fee)
+const EstimatedFeeRate = 0.0004
+
+// Supported breakdown dimensions
+const (
+	GroupByAccount = "account"
+	GroupByChannel = "channel"
+	GroupBySymbol  = "symbol"
+	GroupByDay     = "day"
+	GroupByWeek    = "week"
+	GroupByMonth   = "month"
+)
+
+// Trade is a closed position reduced to the fields needed for statistics
+type Trade struct {
+	PositionID    int64
+	AccountID     int64
+	AccountName   string
+	ChannelID     int64
+	ChannelTitle  string
+	Symbol        string
+	Side          string // LONG, SHORT
+	EntryPrice    float64
+	ExitPrice     float64
+	Quantity      float64
+	StopLossPrice float64
+	OpenedAt      time.Time
+	ClosedAt      time.Time
+	PnL           float64 // Gross PnL in USDT
+	PnLPercent    float64
+	Fees          float64 // Commission paid in USDT
+	Funding       float64 // Funding received in USDT, negative when paid
+}
+
+// NetPnL returns the PnL of the trade after fees and funding
+func (t *Trade) NetPnL() float64 {
+	return t.PnL - t.Fees + t.Funding
+}
+
+// Risk returns the initial risk of the trade in USDT: the entry to stop
+// distance times quantity. It is zero when no stop was set.
+func (t *Trade) Risk() float64 {
+	if t.StopLossPrice == 0 {
+		return 0
+	}
+	return math.Abs(t.EntryPrice-t.StopLossPrice) * t.Quantity
+}
+
+// EstimateFees estimates the commission of a round trip at EstimatedFeeRate
+func EstimateFees(entryPrice, exitPrice, quantity float64) float64 {
+	return (entryPrice + exitPrice) * quantity * EstimatedFeeRate
+}
+
+// IsValidGroupBy reports whether groupBy is a supported breakdown dimension
+func IsValidGroupBy(groupBy string) bool {
+	switch groupBy {
+	case GroupByAccount, GroupByChannel, GroupBySymbol, GroupByDay, GroupByWeek, GroupByMonth:
+		return true
+	}
+	return false
+}
+
+// Compute calculates statistics for a set of closed trades.
+// Trades are expected in chronological order of closing. Win/loss
+// classification and all risk metrics use net PnL, after fees and funding;
+// only TotalPnL is gross.
+func Compute(trades []*Trade) *models.TradingStats {
+	stats := &models.TradingStats{}
+	if len(trades) == 0 {
+		return stats
+	}
+
+	var sumWin, sumLoss, grossProfit, grossLoss float64
+	var equity, peak float64
+	var holdTotal time.Duration
+	var holdCount int
+	netValues := make([]float64, 0, len(trades))
+
+	for i, t := range trades {
+		stats.TotalTrades++
+		stats.TotalPnL += t.PnL
+		stats.TotalFees += t.Fees
+		stats.TotalFunding += t.Funding
+
+		net := t.NetPnL()
+		netValues = append(netValues, net)
+		if net > 0 {
+			stats.WinningTrades++
+			sumWin += net
+			grossProfit += net
+		} else {
+			stats.LosingTrades++
+			sumLoss += net
+			grossLoss += -net
+		}
+
+		if i == 0 || net > stats.LargestWin {
+			stats.LargestWin = net
+		}
+		if i == 0 || net < stats.LargestLoss {
+			stats.LargestLoss = net
+		}
+
+		// Drawdown on the cumulative net PnL curve
+		equity += net
+		if equity > peak {
+			peak = equity
+		}
+		if dd := peak - equity; dd > stats.MaxDrawdown {
+			stats.MaxDrawdown = dd
+		}
+
+		if !t.OpenedAt.IsZero() && t.ClosedAt.After(t.OpenedAt) {
+			holdTotal += t.ClosedAt.Sub(t.OpenedAt)
+			holdCount++
+		}
+	}
+
+	n := float64(stats.TotalTrades)
+	stats.NetPnL = stats.TotalPnL - stats.TotalFees + stats.TotalFunding
+	stats.WinRate = float64(stats.WinningTrades) / n * 100
+	stats.Expectancy = stats.NetPnL / n
+	if holdCount > 0 {
+		stats.AvgHoldSeconds = holdTotal.Seconds() / float64(holdCount)
+	}
+
+	if stats.WinningTrades > 0 {
+		stats.AverageWin = sumWin / float64(stats.WinningTrades)
+	}
+	if stats.LosingTrades > 0 {
+		stats.AverageLoss = sumLoss / float64(stats.LosingTrades)
+	}
+	if grossLoss > 0 {
+		stats.ProfitFactor = grossProfit / grossLoss
+	}
+
+	// Sharpe-like ratio: mean / sample stddev of per-trade net PnL
+	if len(netValues) > 1 {
+		mean := stats.Expectancy
+		var variance float64
+		for _, v := range netValues {
+			variance += (v - mean) * (v - mean)
+		}
+		variance /= float64(len(netValues) - 1)
+		if std := math.Sqrt(variance); std > 0 {
+			stats.SharpeRatio = mean / std
+		}
+	}
+
+	return stats
+}
+
+// Group splits trades by the given dimension and computes statistics per bucket.
+// Time buckets (day, week, month) use the UTC close time and are sorted
+// chronologically; other buckets are sorted by net PnL, best first.
+func Group(trades []*Trade, groupBy string) ([]*models.StatsBucket, error) {
+	if !IsValidGroupBy(groupBy) {
+		return nil, fmt.Errorf("unsupported group_by %q", groupBy)
+	}
+
+	groups := make(map[string][]*Trade)
+	labels := make(map[string]string)
+	var keys []string
+
+	for _, t := range trades {
+		key, label := bucketKey(t, groupBy)
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+			labels[key] = label
+		}
+		groups[key] = append(groups[key], t)
+	}
+
+	buckets := make([]*models.StatsBucket, 0, len(keys))
+	for _, key := range keys {
+		buckets = append(buckets, &models.StatsBucket{
+			Key:   key,
+			Label: labels[key],
+			Stats: Compute(groups[key]),
+		})
+	}
+
+	switch groupBy {
+	case GroupByDay, GroupByWeek, GroupByMonth:
+		sort.Slice(buckets, func(i, j int) bool { return buckets[i].Key < buckets[j].Key })
+	default:
+		sort.Slice(buckets, func(i, j int) bool { return buckets[i].Stats.NetPnL > buckets[j].Stats.NetPnL })
+	}
+
+	return buckets, nil
+}
+
+// bucketKey returns the bucket key and display label of a trade for a dimension
+func bucketKey(t *Trade, groupBy string) (string, string) {
+	closed := t.ClosedAt.UTC()
+
+	switch groupBy {
+	case GroupByAccount:
+		key := strconv.FormatInt(t.AccountID, 10)
+		if t.AccountName != "" {
+			return key, t.AccountName
+		}
+		return key, "Account " + key
+	case GroupByChannel:
+		if t.ChannelID == 0 {
+			return "0", "Unknown"
+		}
+		key := strconv.FormatInt(t.ChannelID, 10)
+		if t.ChannelTitle != "" {
+			return key, t.ChannelTitle
+		}
+		return key, key
+	case GroupBySymbol:
+		return t.Symbol, t.Symbol
+	case GroupByDay:
+		key := closed.Format("2006-01-02")
+		return key, key
+	case GroupByWeek:
+		year, week := closed.ISOWeek()
+		key := fmt.Sprintf("%d-W%02d", year, week)
+		return key, key
+	default: // GroupByMonth
+		key := closed.Format("2006-01")
+		return key, key
+	}
+}
@@ -0,0 +1,170 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kline is a single 1-minute candle
+type Kline struct {
+	OpenTime time.Time
+	Open     float64
+	High     float64
+	Low      float64
+	Close    float64
+}
+
+// KlineCache loads 1m klines from a local directory of CSV files.
+//
+// Files are looked up per symbol as either <dir>/<SYMBOL>/*.csv or
+// <dir>/<SYMBOL>-1m*.csv, which matches the layout of the monthly and daily
+// archives published on data.binance.vision. Each row starts with
+// open_time (milliseconds or microseconds), open, high, low, close; extra
+// columns and a header row are ignored. Only CSV is read; other formats
+// such as Parquet must be converted first.
+type KlineCache struct {
+	dir string
+
+	mu     sync.Mutex
+	loaded map[string][]Kline
+}
+
+// NewKlineCache creates a kline cache rooted at dir
+func NewKlineCache(dir string) *KlineCache {
+	return &KlineCache{
+		dir:    dir,
+		loaded: make(map[string][]Kline),
+	}
+}
+
+// Klines returns all cached klines for a symbol, sorted by open time
+func (c *KlineCache) Klines(symbol string) ([]Kline, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if klines, ok := c.loaded[symbol]; ok {
+		return klines, nil
+	}
+
+	files, err := c.files(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	var klines []Kline
+	for _, file := range files {
+		fileKlines, err := readKlineCSV(file)
+		if err != nil {
+			return nil, err
+		}
+		klines = append(klines, fileKlines...)
+	}
+
+	sort.Slice(klines, func(i, j int) bool { return klines[i].OpenTime.Before(klines[j].OpenTime) })
+
+	c.loaded[symbol] = klines
+	return klines, nil
+}
+
+// Range returns the klines of a symbol with from <= open time < to
+func (c *KlineCache) Range(symbol string, from, to time.Time) ([]Kline, error) {
+	klines, err := c.Klines(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	start := sort.Search(len(klines), func(i int) bool { return !klines[i].OpenTime.Before(from) })
+	end := sort.Search(len(klines), func(i int) bool { return !klines[i].OpenTime.Before(to) })
+
+	return klines[start:end], nil
+}
+
+// files lists the kline files available for a symbol
+func (c *KlineCache) files(symbol string) ([]string, error) {
+	patterns := []string{
+		filepath.Join(c.dir, symbol, "*.csv"),
+		filepath.Join(c.dir, symbol+"-1m*.csv"),
+	}
+
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kline path pattern: %w", err)
+		}
+		files = append(files, matches...)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// readKlineCSV parses a Binance kline CSV file
+func readKlineCSV(path string) ([]Kline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open kline file: %w", err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	var klines []Kline
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if len(record) < 5 {
+			return nil, fmt.Errorf("%s:%d: expected at least 5 columns", path, line)
+		}
+
+		openTime, err := strconv.ParseInt(strings.TrimSpace(record[0]), 10, 64)
+		if err != nil {
+			if line == 1 {
+				continue // Header row
+			}
+			return nil, fmt.Errorf("%s:%d: invalid open time: %w", path, line, err)
+		}
+
+		var values [4]float64
+		for i := range values {
+			values[i], err = strconv.ParseFloat(strings.TrimSpace(record[i+1]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid price: %w", path, line, err)
+			}
+		}
+
+		klines = append(klines, Kline{
+			OpenTime: parseKlineTime(openTime),
+			Open:     values[0],
+			High:     values[1],
+			Low:      values[2],
+			Close:    values[3],
+		})
+	}
+
+	return klines, nil
+}
+
+// parseKlineTime converts a millisecond or microsecond epoch timestamp
+func parseKlineTime(value int64) time.Time {
+	// Microsecond timestamps have 16 digits
+	if value > 1e15 {
+		return time.UnixMicro(value).UTC()
+	}
+	return time.UnixMilli(value).UTC()
+}
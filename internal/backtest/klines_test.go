@@ -0,0 +1,74 @@
+package backtest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeKlines writes rows of open time, open, high, low, close to a CSV file
+func writeKlines(t *testing.T, path string, rows ...[5]float64) {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("open_time,open,high,low,close,volume\n")
+	for _, row := range rows {
+		fmt.Fprintf(&b, "%d,%g,%g,%g,%g,1\n", int64(row[0]), row[1], row[2], row[3], row[4])
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKlineCacheMergesFiles(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ms := func(minute int) float64 { return float64(start.Add(time.Duration(minute) * time.Minute).UnixMilli()) }
+
+	writeKlines(t, filepath.Join(dir, "BTCUSDT-1m-2024-01-02.csv"), [5]float64{ms(2), 3, 3, 3, 3})
+	writeKlines(t, filepath.Join(dir, "BTCUSDT", "2024-01-01.csv"),
+		[5]float64{ms(1), 2, 2, 2, 2},
+		[5]float64{ms(0), 1, 1, 1, 1})
+	// Microsecond timestamps, as in the newer archives
+	writeKlines(t, filepath.Join(dir, "BTCUSDT-1m-2024-01-03.csv"), [5]float64{ms(3) * 1000, 4, 4, 4, 4})
+
+	cache := NewKlineCache(dir)
+	klines, err := cache.Klines("BTCUSDT")
+	if err != nil {
+		t.Fatalf("Klines: %v", err)
+	}
+	if len(klines) != 4 {
+		t.Fatalf("got %d klines, want 4", len(klines))
+	}
+	for i, k := range klines {
+		if !k.OpenTime.Equal(start.Add(time.Duration(i)*time.Minute)) || k.Open != float64(i+1) {
+			t.Errorf("kline %d = %+v, want open %d at minute %d", i, k, i+1, i)
+		}
+	}
+
+	klines, err = cache.Range("BTCUSDT", start.Add(time.Minute), start.Add(3*time.Minute))
+	if err != nil {
+		t.Fatalf("Range: %v", err)
+	}
+	if len(klines) != 2 || klines[0].Open != 2 || klines[1].Open != 3 {
+		t.Errorf("Range = %+v, want minutes 1 and 2", klines)
+	}
+}
+
+func TestKlineCacheRejectsInvalidRows(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "BTCUSDT-1m-2024-01.csv")
+	if err := os.WriteFile(path, []byte("1704067200000,100,101,99,100\n1704067260000,100,abc,99,100\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewKlineCache(dir).Klines("BTCUSDT")
+	if err == nil || !strings.Contains(err.Error(), ":2: invalid price") {
+		t.Errorf("Klines error = %v, want an invalid price on line 2", err)
+	}
+}
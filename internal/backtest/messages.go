@@ -0,0 +1,183 @@
+package backtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tdlib-go/pkg/models"
+)
+
+// LoadMessagesFile loads channel messages from an export file.
+//
+// Two formats are supported: JSON Lines with one models.Message per line,
+// and the result.json produced by Telegram Desktop's "Export chat history".
+// Messages are returned in chronological order.
+func LoadMessagesFile(path string) ([]*models.Message, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read messages file: %w", err)
+	}
+
+	var messages []*models.Message
+	if isTelegramExport(data) {
+		messages, err = parseTelegramExport(data)
+	} else {
+		messages, err = parseMessagesJSONL(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	SortMessages(messages)
+	return messages, nil
+}
+
+// SortMessages orders messages chronologically
+func SortMessages(messages []*models.Message) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Timestamp.Before(messages[j].Timestamp)
+	})
+}
+
+// isTelegramExport reports whether data looks like a Telegram Desktop export
+func isTelegramExport(data []byte) bool {
+	var probe struct {
+		Messages json.RawMessage `json:"messages"`
+	}
+	return json.Unmarshal(data, &probe) == nil && len(probe.Messages) > 0
+}
+
+// parseMessagesJSONL parses one models.Message per line
+func parseMessagesJSONL(data []byte) ([]*models.Message, error) {
+	var messages []*models.Message
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		msg := &models.Message{}
+		if err := json.Unmarshal(text, msg); err != nil {
+			return nil, fmt.Errorf("line %d: invalid message: %w", line, err)
+		}
+		messages = append(messages, msg)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// telegramExport is the subset of Telegram Desktop's result.json we use
+type telegramExport struct {
+	Name     string `json:"name"`
+	ID       int64  `json:"id"`
+	Messages []struct {
+		ID            int64           `json:"id"`
+		Type          string          `json:"type"`
+		Date          string          `json:"date"`
+		DateUnixtime  string          `json:"date_unixtime"`
+		From          string          `json:"from"`
+		ForwardedFrom string          `json:"forwarded_from"`
+		Text          json.RawMessage `json:"text"`
+	} `json:"messages"`
+}
+
+// parseTelegramExport converts a Telegram Desktop export to messages
+func parseTelegramExport(data []byte) ([]*models.Message, error) {
+	var export telegramExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("invalid Telegram export: %w", err)
+	}
+
+	// Exports use the bare channel ID; TDLib uses the -100 prefixed form
+	channelID := export.ID
+	if channelID > 0 {
+		channelID = -1000000000000 - channelID
+	}
+
+	messages := make([]*models.Message, 0, len(export.Messages))
+	for _, m := range export.Messages {
+		if m.Type != "message" {
+			continue
+		}
+
+		timestamp, err := parseExportDate(m.DateUnixtime, m.Date)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", m.ID, err)
+		}
+
+		messages = append(messages, &models.Message{
+			MessageID:   m.ID,
+			ChannelID:   channelID,
+			ChannelName: export.Name,
+			SenderName:  m.From,
+			Text:        exportText(m.Text),
+			MediaType:   "text",
+			IsForwarded: m.ForwardedFrom != "",
+			Timestamp:   timestamp,
+		})
+	}
+
+	return messages, nil
+}
+
+// parseExportDate prefers the unix timestamp and falls back to the local date string
+func parseExportDate(unix, date string) (time.Time, error) {
+	if unix != "" {
+		seconds, err := strconv.ParseInt(unix, 10, 64)
+		if err == nil {
+			return time.Unix(seconds, 0).UTC(), nil
+		}
+	}
+
+	t, err := time.Parse("2006-01-02T15:04:05", date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", date)
+	}
+	return t, nil
+}
+
+// exportText flattens the export's text field, which is either a string or
+// an array of strings and formatted entities
+func exportText(raw json.RawMessage) string {
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+
+	var parts []json.RawMessage
+	if json.Unmarshal(raw, &parts) != nil {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, part := range parts {
+		var s string
+		if json.Unmarshal(part, &s) == nil {
+			b.WriteString(s)
+			continue
+		}
+		var entity struct {
+			Text string `json:"text"`
+		}
+		if json.Unmarshal(part, &entity) == nil {
+			b.WriteString(entity.Text)
+		}
+	}
+
+	return b.String()
+}
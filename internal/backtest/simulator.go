@@ -0,0 +1,285 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"tdlib-go/internal/analytics"
+	"tdlib-go/pkg/models"
+)
+
+// Trade outcomes
+const (
+	OutcomeTakeProfit = "take_profit"
+	OutcomeStopLoss   = "stop_loss"
+	OutcomeTimeout    = "timeout"
+	OutcomeIncomplete = "incomplete" // Kline data ended before TP, SL or timeout
+)
+
+// SignalParser extracts trading signals from messages
+type SignalParser interface {
+	Parse(msg *models.Message) (*models.Signal, error)
+	IsValidSymbol(symbol string) bool
+}
+
+// Settings mirrors the account parameters the executor trades with
+type Settings struct {
+	Leverage        int
+	OrderAmount     float64       // Position size in USDT
+	TargetPercent   float64       // Take profit on margin (divided by leverage for price distance)
+	StopLossPercent float64       // Stop loss on margin (divided by leverage for price distance)
+	Timeout         time.Duration // Close at market after this long; zero disables the timeout
+	Cooldown        time.Duration // Skip repeated signals for the same symbol within this window
+	FeeRate         float64       // Commission per leg as a fraction of notional
+	IsTokenIgnored  func(symbol string) bool
+}
+
+// TradeResult is the simulated outcome of one signal
+type TradeResult struct {
+	MessageID  int64     `json:"message_id"`
+	ChannelID  int64     `json:"channel_id"`
+	Symbol     string    `json:"symbol"`
+	SignalTime time.Time `json:"signal_time"`
+	EntryTime  time.Time `json:"entry_time"`
+	EntryPrice float64   `json:"entry_price"`
+	TakeProfit float64   `json:"take_profit"`
+	StopLoss   float64   `json:"stop_loss"`
+	Quantity   float64   `json:"quantity"`
+	ExitTime   time.Time `json:"exit_time"`
+	ExitPrice  float64   `json:"exit_price"`
+	Outcome    string    `json:"outcome"`
+	PnL        float64   `json:"pnl"`
+	PnLPercent float64   `json:"pnl_percent"` // Return on margin
+	Fees       float64   `json:"fees"`
+}
+
+// SkippedSignal records a signal that could not be simulated
+type SkippedSignal struct {
+	MessageID int64     `json:"message_id"`
+	Symbol    string    `json:"symbol"`
+	Time      time.Time `json:"time"`
+	Reason    string    `json:"reason"`
+}
+
+// Report is the result of a backtest run
+type Report struct {
+	Messages int                  `json:"messages"`
+	Signals  int                  `json:"signals"`
+	Trades   []*TradeResult       `json:"trades"`
+	Skipped  []*SkippedSignal     `json:"skipped"`
+	Stats    *models.TradingStats `json:"stats"`
+}
+
+// Simulator replays messages through the signal parser and simulates the
+// executor's market entry, TP/SL and timeout handling on 1m klines
+type Simulator struct {
+	parser   SignalParser
+	klines   *KlineCache
+	settings Settings
+}
+
+// NewSimulator creates a backtest simulator
+func NewSimulator(parser SignalParser, klines *KlineCache, settings Settings) (*Simulator, error) {
+	if settings.Leverage <= 0 || settings.Leverage > 125 {
+		return nil, fmt.Errorf("leverage must be between 1 and 125")
+	}
+	if settings.OrderAmount <= 0 {
+		return nil, fmt.Errorf("order amount must be greater than 0")
+	}
+	if settings.TargetPercent <= 0 || settings.StopLossPercent <= 0 {
+		return nil, fmt.Errorf("target and stop loss percent must be greater than 0")
+	}
+
+	return &Simulator{
+		parser:   parser,
+		klines:   klines,
+		settings: settings,
+	}, nil
+}
+
+// Run simulates all messages, which must be in chronological order
+func (s *Simulator) Run(messages []*models.Message) (*Report, error) {
+	report := &Report{Messages: len(messages)}
+	lastTraded := make(map[string]time.Time)
+
+	for _, msg := range messages {
+		signal, err := s.parser.Parse(msg)
+		if err != nil || signal == nil {
+			continue
+		}
+		report.Signals++
+
+		skip := func(reason string) {
+			report.Skipped = append(report.Skipped, &SkippedSignal{
+				MessageID: msg.MessageID,
+				Symbol:    signal.Symbol,
+				Time:      msg.Timestamp,
+				Reason:    reason,
+			})
+		}
+
+		if !s.parser.IsValidSymbol(signal.Symbol) {
+			skip("invalid symbol")
+			continue
+		}
+		if s.settings.IsTokenIgnored != nil && s.settings.IsTokenIgnored(signal.Symbol) {
+			skip("ignored token")
+			continue
+		}
+		if last, ok := lastTraded[signal.Symbol]; ok && msg.Timestamp.Sub(last) < s.settings.Cooldown {
+			skip("duplicate within cooldown")
+			continue
+		}
+
+		trade, err := s.simulate(msg, signal.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		if trade == nil {
+			skip("no kline data")
+			continue
+		}
+
+		lastTraded[signal.Symbol] = msg.Timestamp
+		report.Trades = append(report.Trades, trade)
+	}
+
+	report.Stats = analytics.Compute(s.analyticsTrades(report.Trades))
+	return report, nil
+}
+
+// simulate enters at the open of the first kline starting at or after the
+// message and walks forward until TP, SL or timeout. A candle gapping past a
+// level fills at its open. When a single candle touches both TP and SL, the
+// stop loss is assumed to fill first.
+func (s *Simulator) simulate(msg *models.Message, symbol string) (*TradeResult, error) {
+	end := time.Now()
+	if s.settings.Timeout > 0 {
+		// One extra candle to price the timeout exit
+		end = msg.Timestamp.Add(s.settings.Timeout + 2*time.Minute)
+	}
+
+	klines, err := s.klines.Range(symbol, msg.Timestamp, end)
+	if err != nil {
+		return nil, err
+	}
+	if len(klines) == 0 {
+		return nil, nil
+	}
+
+	leverage := float64(s.settings.Leverage)
+	entry := klines[0]
+	trade := &TradeResult{
+		MessageID:  msg.MessageID,
+		ChannelID:  msg.ChannelID,
+		Symbol:     symbol,
+		SignalTime: msg.Timestamp,
+		EntryTime:  entry.OpenTime,
+		EntryPrice: entry.Open,
+		TakeProfit: entry.Open * (1 + s.settings.TargetPercent/leverage),
+		StopLoss:   entry.Open * (1 - s.settings.StopLossPercent/leverage),
+		Quantity:   s.settings.OrderAmount / entry.Open,
+	}
+
+	deadline := entry.OpenTime.Add(s.settings.Timeout)
+	for _, k := range klines {
+		if s.settings.Timeout > 0 && !k.OpenTime.Before(deadline) {
+			s.close(trade, k.OpenTime, k.Open, OutcomeTimeout)
+			return trade, nil
+		}
+		// A candle opening past a level fills at the open, as the market TP/SL
+		// orders would
+		if k.Open <= trade.StopLoss {
+			s.close(trade, k.OpenTime, k.Open, OutcomeStopLoss)
+			return trade, nil
+		}
+		if k.Open >= trade.TakeProfit {
+			s.close(trade, k.OpenTime, k.Open, OutcomeTakeProfit)
+			return trade, nil
+		}
+		if k.Low <= trade.StopLoss {
+			s.close(trade, k.OpenTime, trade.StopLoss, OutcomeStopLoss)
+			return trade, nil
+		}
+		if k.High >= trade.TakeProfit {
+			s.close(trade, k.OpenTime, trade.TakeProfit, OutcomeTakeProfit)
+			return trade, nil
+		}
+	}
+
+	last := klines[len(klines)-1]
+	s.close(trade, last.OpenTime.Add(time.Minute), last.Close, OutcomeIncomplete)
+	return trade, nil
+}
+
+// close fills in the exit and PnL of a trade
+func (s *Simulator) close(trade *TradeResult, exitTime time.Time, exitPrice float64, outcome string) {
+	trade.ExitTime = exitTime
+	trade.ExitPrice = exitPrice
+	trade.Outcome = outcome
+	trade.PnL = (exitPrice - trade.EntryPrice) * trade.Quantity
+	trade.PnLPercent = (exitPrice - trade.EntryPrice) / trade.EntryPrice * 100 * float64(s.settings.Leverage)
+	trade.Fees = (trade.EntryPrice + exitPrice) * trade.Quantity * s.settings.FeeRate
+}
+
+// analyticsTrades converts results for the shared statistics code
+func (s *Simulator) analyticsTrades(results []*TradeResult) []*analytics.Trade {
+	trades := make([]*analytics.Trade, 0, len(results))
+	for _, r := range results {
+		trades = append(trades, &analytics.Trade{
+			ChannelID:     r.ChannelID,
+			Symbol:        r.Symbol,
+			Side:          "LONG",
+			EntryPrice:    r.EntryPrice,
+			ExitPrice:     r.ExitPrice,
+			Quantity:      r.Quantity,
+			StopLossPrice: r.StopLoss,
+			OpenedAt:      r.EntryTime,
+			ClosedAt:      r.ExitTime,
+			PnL:           r.PnL,
+			PnLPercent:    r.PnLPercent,
+			Fees:          r.Fees,
+		})
+	}
+	return trades
+}
+
+// WriteTradesCSV writes per-trade results as CSV
+func WriteTradesCSV(w io.Writer, trades []*TradeResult) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"message_id", "symbol", "signal_time", "entry_time", "entry_price", "take_profit",
+		"stop_loss", "quantity", "exit_time", "exit_price", "outcome", "pnl", "pnl_percent", "fees"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, t := range trades {
+		record := []string{
+			strconv.FormatInt(t.MessageID, 10),
+			t.Symbol,
+			t.SignalTime.UTC().Format(time.RFC3339),
+			t.EntryTime.UTC().Format(time.RFC3339),
+			f(t.EntryPrice),
+			f(t.TakeProfit),
+			f(t.StopLoss),
+			f(t.Quantity),
+			t.ExitTime.UTC().Format(time.RFC3339),
+			f(t.ExitPrice),
+			t.Outcome,
+			f(t.PnL),
+			f(t.PnLPercent),
+			f(t.Fees),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
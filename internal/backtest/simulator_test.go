@@ -0,0 +1,98 @@
+package backtest
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tdlib-go/pkg/models"
+)
+
+// symbolParser treats every message text as a signal for that symbol
+type symbolParser struct{}
+
+func (symbolParser) Parse(msg *models.Message) (*models.Signal, error) {
+	return &models.Signal{Symbol: msg.Text}, nil
+}
+
+func (symbolParser) IsValidSymbol(symbol string) bool { return symbol != "" }
+
+// simulateCandles runs one signal over klines opening at 100 followed by
+// candles of open, high, low, close. TP is at 101 and SL at 99.5.
+func simulateCandles(t *testing.T, settings Settings, candles ...[4]float64) *Report {
+	t.Helper()
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rows := [][5]float64{{float64(start.UnixMilli()), 100, 100, 100, 100}}
+	for i, c := range candles {
+		openTime := start.Add(time.Duration(i+1) * time.Minute)
+		rows = append(rows, [5]float64{float64(openTime.UnixMilli()), c[0], c[1], c[2], c[3]})
+	}
+	writeKlines(t, filepath.Join(dir, "BTCUSDT-1m-2024-01.csv"), rows...)
+
+	settings.Leverage = 10
+	settings.OrderAmount = 100
+	settings.TargetPercent = 0.1
+	settings.StopLossPercent = 0.05
+	sim, err := NewSimulator(symbolParser{}, NewKlineCache(dir), settings)
+	if err != nil {
+		t.Fatalf("NewSimulator: %v", err)
+	}
+
+	report, err := sim.Run([]*models.Message{{MessageID: 1, Text: "BTCUSDT", Timestamp: start}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(report.Trades) != 1 {
+		t.Fatalf("got %d trades, want 1", len(report.Trades))
+	}
+	return report
+}
+
+func TestSimulatorExits(t *testing.T) {
+	tests := []struct {
+		name    string
+		candles [][4]float64
+		outcome string
+		exit    float64
+	}{
+		{"take profit", [][4]float64{{100, 100.5, 99.8, 100.2}, {100.2, 101.2, 100, 101}}, OutcomeTakeProfit, 101},
+		{"stop loss", [][4]float64{{100, 100.2, 99.4, 99.6}}, OutcomeStopLoss, 99.5},
+		{"both touched", [][4]float64{{100, 101.5, 99, 100}}, OutcomeStopLoss, 99.5},
+		{"gap below stop loss", [][4]float64{{98, 98.5, 97.5, 98}}, OutcomeStopLoss, 98},
+		{"gap above take profit", [][4]float64{{103, 103.5, 99, 103}}, OutcomeTakeProfit, 103},
+		{"incomplete", [][4]float64{{100, 100.5, 99.8, 100.3}}, OutcomeIncomplete, 100.3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trade := simulateCandles(t, Settings{}, tt.candles...).Trades[0]
+			if trade.Outcome != tt.outcome || math.Abs(trade.ExitPrice-tt.exit) > 1e-9 {
+				t.Errorf("exit %s at %v, want %s at %v", trade.Outcome, trade.ExitPrice, tt.outcome, tt.exit)
+			}
+			if want := (tt.exit - 100); math.Abs(trade.PnL-want) > 1e-9 {
+				t.Errorf("PnL = %v, want %v", trade.PnL, want)
+			}
+		})
+	}
+}
+
+func TestSimulatorTimeout(t *testing.T) {
+	report := simulateCandles(t, Settings{Timeout: 2 * time.Minute, FeeRate: 0.001},
+		[4]float64{100, 100.5, 99.8, 100.2},
+		[4]float64{100.2, 100.5, 99.8, 100.4},
+		[4]float64{100.4, 102, 100, 101.5})
+
+	trade := report.Trades[0]
+	if trade.Outcome != OutcomeTimeout || trade.ExitPrice != 100.2 {
+		t.Errorf("exit %s at %v, want a timeout at the open of 100.2", trade.Outcome, trade.ExitPrice)
+	}
+	if math.Abs(trade.Fees-0.2002) > 1e-9 {
+		t.Errorf("fees = %v, want 0.2002", trade.Fees)
+	}
+	if report.Stats == nil || report.Stats.TotalTrades != 1 {
+		t.Errorf("stats = %+v, want one trade", report.Stats)
+	}
+}
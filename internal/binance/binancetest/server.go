@@ -0,0 +1,1622 @@
+// Package binancetest provides an in-process fake Binance USDT-M Futures
+// server for exercising the binance client, order executor and trading
+// engine without touching the real exchange.
+//
+// The server keeps orders, positions and balances in memory. Market orders
+// fill at the current price; limit, stop and take-profit orders fill when a
+// scripted price move crosses them. Order and position changes are pushed to
+// user-data WebSocket connections like the real exchange does.
+//
+//	srv := binancetest.NewServer()
+//	defer srv.Close()
+//	srv.AddSymbol(binancetest.Symbol{Symbol: "BTCUSDT"})
+//	srv.SetPricePath("BTCUSDT", 100, 101, 103)
+//	client := binance.NewClientWithConfig("key", "secret", srv.URL, srv.WSURL(), logger)
+package binancetest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"tdlib-go/internal/binance"
+)
+
+// Order statuses
+const (
+	StatusNew             = "NEW"
+	StatusPartiallyFilled = "PARTIALLY_FILLED"
+	StatusFilled          = "FILLED"
+	StatusCanceled        = "CANCELED"
+	StatusExpired         = "EXPIRED"
+)
+
+// DefaultCommissionRate is the taker fee charged on fills
+const DefaultCommissionRate = 0.0004
+
+// Symbol describes a tradable contract. Zero values get sensible defaults.
+type Symbol struct {
+	Symbol            string
+	PricePrecision    int     // Default 2
+	QuantityPrecision int     // Default 3
+	TickSize          float64 // Default 0.01
+	StepSize          float64 // Default 0.001
+	MinQty            float64 // Default 0.001
+	MinNotional       float64 // Default 5
+	MaxQty            float64 // Default 1000000
+	Price             float64 // Initial price, default 100
+
+	// Brackets default to DefaultBrackets
+	Brackets []binance.LeverageBracket
+}
+
+// DefaultBrackets are the leverage brackets of symbols added without any
+var DefaultBrackets = []binance.LeverageBracket{
+	{Bracket: 1, InitialLeverage: 125, NotionalCap: 50000, MaintMarginRatio: 0.004},
+	{Bracket: 2, InitialLeverage: 50, NotionalCap: 1000000, NotionalFloor: 50000, MaintMarginRatio: 0.01, Cum: 300},
+	{Bracket: 3, InitialLeverage: 20, NotionalCap: 10000000, NotionalFloor: 1000000, MaintMarginRatio: 0.025, Cum: 15300},
+}
+
+// Order is the server-side state of an order
+type Order struct {
+	OrderID       int64
+	ClientOrderID string
+	Symbol        string
+	Side          string
+	Type          string
+	TimeInForce   string
+	Quantity      float64
+	Price         float64
+	StopPrice     float64
+	ReduceOnly    bool
+	ClosePosition bool
+	Status        string
+	ExecutedQty   float64
+	AvgPrice      float64
+	UpdateTime    time.Time
+}
+
+// Position is the net position of a symbol
+type Position struct {
+	Symbol      string
+	Amount      float64 // Positive for long, negative for short
+	EntryPrice  float64
+	RealizedPnL float64
+	Commission  float64
+	Leverage    int
+	MarginType  string
+}
+
+// Request is a recorded API request
+type Request struct {
+	Method string
+	Path   string
+	Params url.Values
+}
+
+// pricePoint is a price of a symbol from a point in time, for klines
+type pricePoint struct {
+	time  time.Time
+	price float64
+}
+
+// injectedError is returned for matching requests until its count runs out
+type injectedError struct {
+	method     string
+	path       string
+	orderType  string // Only orders of this type, empty for any request
+	status     int
+	err        binance.APIError
+	times      int  // Negative means forever
+	retryAfter int  // Seconds sent as Retry-After, 0 for none
+	executed   bool // The request is executed before the error is returned
+}
+
+// Server is a fake Binance Futures REST and user-data WebSocket server
+type Server struct {
+	*httptest.Server
+
+	mu             sync.Mutex
+	apiKey         string
+	apiSecret      string
+	symbols        map[string]*Symbol
+	prices         map[string]float64
+	pricePaths     map[string][]float64
+	history        map[string][]pricePoint
+	orders         map[int64]*Order
+	nextOrderID    int64
+	trades         []binance.UserTrade
+	nextTradeID    int64
+	income         []binance.Income
+	nextTranID     int64
+	positions      map[string]*Position
+	balance        float64
+	commissionRate float64
+	slippage       map[string]float64
+	holdFills      bool
+	errors         []*injectedError
+	requests       []Request
+	listenKeys     map[string]bool
+	usedWeight     int           // Request weight used in weightWindow, one per request
+	weightWindow   time.Time     // Minute of usedWeight
+	orderCount     int           // Orders placed in orderWindow
+	orderWindow    time.Time     // 10 seconds of orderCount
+	clockSkew      time.Duration // Server time minus real time
+
+	streamMu sync.Mutex
+	streams  map[*websocket.Conn]*sync.Mutex
+	upgrader websocket.Upgrader
+}
+
+// NewServer starts a fake Futures server with a 10000 USDT balance
+func NewServer() *Server {
+	s := &Server{
+		symbols:        make(map[string]*Symbol),
+		prices:         make(map[string]float64),
+		pricePaths:     make(map[string][]float64),
+		history:        make(map[string][]pricePoint),
+		orders:         make(map[int64]*Order),
+		nextOrderID:    1000,
+		nextTradeID:    5000,
+		nextTranID:     9000,
+		positions:      make(map[string]*Position),
+		balance:        10000,
+		commissionRate: DefaultCommissionRate,
+		slippage:       make(map[string]float64),
+		listenKeys:     make(map[string]bool),
+		streams:        make(map[*websocket.Conn]*sync.Mutex),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fapi/v1/time", s.handleTime)
+	mux.HandleFunc("/fapi/v1/exchangeInfo", s.handleExchangeInfo)
+	mux.HandleFunc("/fapi/v1/ticker/price", s.handleTickerPrice)
+	mux.HandleFunc("/fapi/v1/ticker/bookTicker", s.handleBookTicker)
+	mux.HandleFunc("/fapi/v1/klines", s.handleKlines)
+	mux.HandleFunc("/fapi/v1/leverage", s.handleLeverage)
+	mux.HandleFunc("/fapi/v1/leverageBracket", s.handleLeverageBracket)
+	mux.HandleFunc("/fapi/v1/marginType", s.handleMarginType)
+	mux.HandleFunc("/fapi/v1/order", s.handleOrder)
+	mux.HandleFunc("/fapi/v1/openOrders", s.handleOpenOrders)
+	mux.HandleFunc("/fapi/v1/allOpenOrders", s.handleAllOpenOrders)
+	mux.HandleFunc("/fapi/v1/userTrades", s.handleUserTrades)
+	mux.HandleFunc("/fapi/v1/income", s.handleIncome)
+	mux.HandleFunc("/fapi/v2/positionRisk", s.handlePositionRisk)
+	mux.HandleFunc("/fapi/v2/account", s.handleAccount)
+	mux.HandleFunc("/fapi/v1/listenKey", s.handleListenKey)
+	mux.HandleFunc("/ws/", s.handleStream)
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// WSURL returns the WebSocket base URL to pass to binance.NewClientWithConfig
+func (s *Server) WSURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+// Close shuts down the server and all stream connections
+func (s *Server) Close() {
+	s.streamMu.Lock()
+	for conn := range s.streams {
+		conn.Close()
+	}
+	s.streams = make(map[*websocket.Conn]*sync.Mutex)
+	s.streamMu.Unlock()
+
+	s.Server.Close()
+}
+
+// ============= Scripting =============
+
+// SetCredentials enables signature checking of signed requests
+func (s *Server) SetCredentials(apiKey, apiSecret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiKey = apiKey
+	s.apiSecret = apiSecret
+}
+
+// AddSymbol makes a contract tradable
+func (s *Server) AddSymbol(symbol Symbol) {
+	if symbol.PricePrecision == 0 {
+		symbol.PricePrecision = 2
+	}
+	if symbol.QuantityPrecision == 0 {
+		symbol.QuantityPrecision = 3
+	}
+	if symbol.TickSize == 0 {
+		symbol.TickSize = 0.01
+	}
+	if symbol.StepSize == 0 {
+		symbol.StepSize = 0.001
+	}
+	if symbol.MinQty == 0 {
+		symbol.MinQty = 0.001
+	}
+	if symbol.MinNotional == 0 {
+		symbol.MinNotional = 5
+	}
+	if symbol.MaxQty == 0 {
+		symbol.MaxQty = 1000000
+	}
+	if symbol.Brackets == nil {
+		symbol.Brackets = DefaultBrackets
+	}
+	if symbol.Price == 0 {
+		symbol.Price = 100
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.symbols[symbol.Symbol] = &symbol
+	s.prices[symbol.Symbol] = symbol.Price
+	s.recordPrice(symbol.Symbol, time.Now(), symbol.Price)
+}
+
+// SetPrice moves the price of a symbol and triggers any crossed orders
+func (s *Server) SetPrice(symbol string, price float64) {
+	s.mu.Lock()
+	events := s.movePrice(symbol, price)
+	s.mu.Unlock()
+
+	s.publish(events)
+}
+
+// SetPriceHistory records a past price of a symbol for klines, without
+// moving the current price
+func (s *Server) SetPriceHistory(symbol string, at time.Time, price float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordPrice(symbol, at, price)
+}
+
+// recordPrice adds a price to a symbol's history, kept sorted by time
+func (s *Server) recordPrice(symbol string, at time.Time, price float64) {
+	history := append(s.history[symbol], pricePoint{time: at, price: price})
+	sort.SliceStable(history, func(i, j int) bool { return history[i].time.Before(history[j].time) })
+	s.history[symbol] = history
+}
+
+// Price returns the current price of a symbol
+func (s *Server) Price(symbol string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prices[symbol]
+}
+
+// SetPricePath scripts the next prices of a symbol. The first price is
+// applied immediately, the rest one per call to Step.
+func (s *Server) SetPricePath(symbol string, prices ...float64) {
+	if len(prices) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	s.pricePaths[symbol] = append([]float64(nil), prices[1:]...)
+	events := s.movePrice(symbol, prices[0])
+	s.mu.Unlock()
+
+	s.publish(events)
+}
+
+// Step advances every scripted price path by one point. It reports whether
+// any path had a point left.
+func (s *Server) Step() bool {
+	s.mu.Lock()
+	symbols := make([]string, 0, len(s.pricePaths))
+	for symbol := range s.pricePaths {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	var events []interface{}
+	advanced := false
+	for _, symbol := range symbols {
+		path := s.pricePaths[symbol]
+		if len(path) == 0 {
+			continue
+		}
+		s.pricePaths[symbol] = path[1:]
+		events = append(events, s.movePrice(symbol, path[0])...)
+		advanced = true
+	}
+	s.mu.Unlock()
+
+	s.publish(events)
+	return advanced
+}
+
+// RunPricePaths steps all price paths every interval until they are
+// exhausted or stop is closed
+func (s *Server) RunPricePaths(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !s.Step() {
+				return
+			}
+		}
+	}
+}
+
+// SetSlippage makes market orders of a symbol fill worse than the current
+// price by fraction (e.g. 0.001 for 0.1%)
+func (s *Server) SetSlippage(symbol string, fraction float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slippage[symbol] = fraction
+}
+
+// SetCommissionRate sets the fee charged on fills
+func (s *Server) SetCommissionRate(rate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commissionRate = rate
+}
+
+// SetBalance sets the USDT wallet balance
+func (s *Server) SetBalance(balance float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balance = balance
+}
+
+// HoldFills keeps new market orders unfilled until FillOrder is called
+func (s *Server) HoldFills(hold bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.holdFills = hold
+}
+
+// FillOrder fills the remaining quantity of an open order at price
+// (the current price when zero)
+func (s *Server) FillOrder(orderID int64, price float64) error {
+	return s.FillOrderPartially(orderID, 0, price)
+}
+
+// FillOrderPartially fills qty of an open order at price (the remaining
+// quantity when qty is zero, the current price when price is zero)
+func (s *Server) FillOrderPartially(orderID int64, qty, price float64) error {
+	s.mu.Lock()
+	order, ok := s.orders[orderID]
+	if !ok || !isOpen(order) {
+		s.mu.Unlock()
+		return fmt.Errorf("order %d is not open", orderID)
+	}
+	if price == 0 {
+		price = s.prices[order.Symbol]
+	}
+	if qty == 0 {
+		qty = order.Quantity - order.ExecutedQty
+	}
+	events := s.fill(order, qty, price)
+	s.mu.Unlock()
+
+	s.publish(events)
+	return nil
+}
+
+// InjectError makes the next times requests to method and path fail with
+// code and msg (times < 0 fails forever). An empty method matches any method.
+func (s *Server) InjectError(method, path string, times, code int, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = append(s.errors, &injectedError{
+		method: method,
+		path:   path,
+		status: http.StatusBadRequest,
+		err:    binance.APIError{Code: code, Msg: msg},
+		times:  times,
+	})
+}
+
+// InjectOrderError makes the next times new orders of orderType fail with
+// code and msg, while orders of other types are placed
+func (s *Server) InjectOrderError(orderType string, times, code int, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = append(s.errors, &injectedError{
+		method:    http.MethodPost,
+		path:      "/fapi/v1/order",
+		orderType: orderType,
+		status:    http.StatusBadRequest,
+		err:       binance.APIError{Code: code, Msg: msg},
+		times:     times,
+	})
+}
+
+// InjectRateLimit makes the next times requests to method and path fail
+// with a 429 (or 418 for an IP ban) and a Retry-After of retryAfter seconds
+func (s *Server) InjectRateLimit(method, path string, times, status, retryAfter int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = append(s.errors, &injectedError{
+		method:     method,
+		path:       path,
+		status:     status,
+		err:        binance.APIError{Code: -1003, Msg: "Too many requests; current limit of IP is 2400 requests per minute."},
+		times:      times,
+		retryAfter: retryAfter,
+	})
+}
+
+// InjectLostResponse makes the next times requests to method and path
+// execute but fail with an unknown execution status, as when the exchange
+// times out waiting for its backend
+func (s *Server) InjectLostResponse(method, path string, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = append(s.errors, &injectedError{
+		method:   method,
+		path:     path,
+		status:   http.StatusServiceUnavailable,
+		err:      binance.APIError{Code: -1007, Msg: "Timeout waiting for response from backend server. Send status unknown; execution status unknown."},
+		times:    times,
+		executed: true,
+	})
+}
+
+// SetUsedWeight sets the request weight used in the current minute, as
+// reported in the X-MBX-USED-WEIGHT-1M header
+func (s *Server) SetUsedWeight(weight int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usedWeight = weight
+	s.weightWindow = time.Now().Truncate(time.Minute)
+}
+
+// SetClockSkew moves the server time away from the real time, so signed
+// requests timestamped with the local clock fall outside their recvWindow
+func (s *Server) SetClockSkew(skew time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clockSkew = skew
+}
+
+// ClearErrors removes all injected errors
+func (s *Server) ClearErrors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = nil
+}
+
+// Orders returns copies of all orders, oldest first
+func (s *Server) Orders() []Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orders := make([]Order, 0, len(s.orders))
+	for _, o := range s.orders {
+		orders = append(orders, *o)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderID < orders[j].OrderID })
+	return orders
+}
+
+// Order returns a copy of an order
+func (s *Server) Order(orderID int64) (Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[orderID]
+	if !ok {
+		return Order{}, false
+	}
+	return *o, true
+}
+
+// Trades returns all fills, oldest first
+func (s *Server) Trades() []binance.UserTrade {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]binance.UserTrade(nil), s.trades...)
+}
+
+// AddIncome adds an income history entry, e.g. a FUNDING_FEE of a symbol
+// or a TRANSFER (without symbol), and credits it to the balance
+func (s *Server) AddIncome(symbol, incomeType string, amount float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balance += amount
+	s.addIncome(symbol, incomeType, amount, "")
+}
+
+// Income returns the income history, oldest first
+func (s *Server) Income() []binance.Income {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]binance.Income(nil), s.income...)
+}
+
+// Position returns a copy of the position of a symbol
+func (s *Server) Position(symbol string) Position {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.position(symbol)
+}
+
+// Requests returns all recorded API requests
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// ============= REST handlers =============
+
+// begin records a request, checks injected errors and the signature.
+// It returns the request parameters, or nil after writing an error.
+func (s *Server) begin(w http.ResponseWriter, r *http.Request, signed bool) url.Values {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, -1102, "Malformed request")
+		return nil
+	}
+	params := r.Form
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Params: params})
+
+	now := time.Now()
+	if window := now.Truncate(time.Minute); !window.Equal(s.weightWindow) {
+		s.usedWeight, s.weightWindow = 0, window
+	}
+	s.usedWeight++
+	w.Header().Set("X-MBX-USED-WEIGHT-1M", strconv.Itoa(s.usedWeight))
+	if r.URL.Path == "/fapi/v1/order" && r.Method == http.MethodPost {
+		if window := now.Truncate(10 * time.Second); !window.Equal(s.orderWindow) {
+			s.orderCount, s.orderWindow = 0, window
+		}
+		s.orderCount++
+		w.Header().Set("X-MBX-ORDER-COUNT-10S", strconv.Itoa(s.orderCount))
+	}
+
+	if e := s.takeError(r, false); e != nil {
+		s.mu.Unlock()
+		if e.retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(e.retryAfter))
+		}
+		writeError(w, e.status, e.err.Code, e.err.Msg)
+		return nil
+	}
+
+	apiKey, apiSecret := s.apiKey, s.apiSecret
+	serverTime := now.Add(s.clockSkew).UnixMilli()
+	s.mu.Unlock()
+
+	if signed && !validTimestamp(params, serverTime) {
+		writeError(w, http.StatusBadRequest, -1021, "Timestamp for this request is outside of the recvWindow.")
+		return nil
+	}
+
+	if signed && apiSecret != "" {
+		if r.Header.Get("X-MBX-APIKEY") != apiKey {
+			writeError(w, http.StatusUnauthorized, -2015, "Invalid API-key, IP, or permissions for action.")
+			return nil
+		}
+		if !validSignature(r, apiSecret) {
+			writeError(w, http.StatusBadRequest, -1022, "Signature for this request is not valid.")
+			return nil
+		}
+	}
+
+	return params
+}
+
+// takeError returns the next injected error matching a request, or nil.
+// Caller holds mu.
+func (s *Server) takeError(r *http.Request, executed bool) *injectedError {
+	for i, e := range s.errors {
+		if e.path != r.URL.Path || (e.method != "" && e.method != r.Method) || e.times == 0 || e.executed != executed {
+			continue
+		}
+		if e.orderType != "" && e.orderType != r.Form.Get("type") {
+			continue
+		}
+		if e.times > 0 {
+			e.times--
+		}
+		if e.times == 0 {
+			s.errors = append(s.errors[:i], s.errors[i+1:]...)
+		}
+		return e
+	}
+	return nil
+}
+
+func (s *Server) handleTime(w http.ResponseWriter, r *http.Request) {
+	if s.begin(w, r, false) == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, binance.ServerTime{ServerTime: time.Now().Add(s.clockSkew).UnixMilli()})
+}
+
+func (s *Server) handleExchangeInfo(w http.ResponseWriter, r *http.Request) {
+	if s.begin(w, r, false) == nil {
+		return
+	}
+
+	s.mu.Lock()
+	info := binance.ExchangeInfo{}
+	for _, sym := range s.symbols {
+		info.Symbols = append(info.Symbols, binance.SymbolInfo{
+			Symbol:            sym.Symbol,
+			Status:            "TRADING",
+			BaseAsset:         strings.TrimSuffix(sym.Symbol, "USDT"),
+			QuoteAsset:        "USDT",
+			PricePrecision:    sym.PricePrecision,
+			QuantityPrecision: sym.QuantityPrecision,
+			Filters: []binance.FilterInfo{
+				{FilterType: "PRICE_FILTER", TickSize: formatFloat(sym.TickSize), MinPrice: formatFloat(sym.TickSize), MaxPrice: "1000000"},
+				{FilterType: "LOT_SIZE", StepSize: formatFloat(sym.StepSize), MinQty: formatFloat(sym.MinQty), MaxQty: formatFloat(sym.MaxQty)},
+				{FilterType: "MARKET_LOT_SIZE", StepSize: formatFloat(sym.StepSize), MinQty: formatFloat(sym.MinQty), MaxQty: formatFloat(sym.MaxQty)},
+				{FilterType: "MIN_NOTIONAL", Notional: formatFloat(sym.MinNotional)},
+			},
+		})
+	}
+	s.mu.Unlock()
+
+	sort.Slice(info.Symbols, func(i, j int) bool { return info.Symbols[i].Symbol < info.Symbols[j].Symbol })
+	writeJSON(w, info)
+}
+
+func (s *Server) handleTickerPrice(w http.ResponseWriter, r *http.Request) {
+	params := s.begin(w, r, false)
+	if params == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	if symbol := params.Get("symbol"); symbol != "" {
+		price, ok := s.prices[symbol]
+		if !ok {
+			writeError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+			return
+		}
+		writeJSON(w, binance.PriceTicker{Symbol: symbol, Price: formatFloat(price), Time: now})
+		return
+	}
+
+	var tickers []binance.PriceTicker
+	for symbol, price := range s.prices {
+		tickers = append(tickers, binance.PriceTicker{Symbol: symbol, Price: formatFloat(price), Time: now})
+	}
+	sort.Slice(tickers, func(i, j int) bool { return tickers[i].Symbol < tickers[j].Symbol })
+	writeJSON(w, tickers)
+}
+
+// handleKlines builds 1m klines from the recorded price history
+func (s *Server) handleKlines(w http.ResponseWriter, r *http.Request) {
+	params := s.begin(w, r, false)
+	if params == nil {
+		return
+	}
+	if params.Get("interval") != "1m" {
+		writeError(w, http.StatusBadRequest, -1120, "Invalid interval.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbol := params.Get("symbol")
+	history, ok := s.history[symbol]
+	if !ok {
+		writeError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+		return
+	}
+
+	parseMillis := func(key string, def time.Time) time.Time {
+		if ms, err := strconv.ParseInt(params.Get(key), 10, 64); err == nil {
+			return time.UnixMilli(ms)
+		}
+		return def
+	}
+	end := parseMillis("endTime", time.Now())
+	start := parseMillis("startTime", end.Add(-500*time.Minute)).Truncate(time.Minute)
+	limit, err := strconv.Atoi(params.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 500
+	}
+
+	// A kline opens at the price in effect at its start, klines before the
+	// first recorded price are omitted
+	klines := [][]interface{}{}
+	i := 0
+	price, known := 0.0, false
+	for open := start; !open.After(end) && len(klines) < limit; open = open.Add(time.Minute) {
+		for ; i < len(history) && !history[i].time.After(open); i++ {
+			price, known = history[i].price, true
+		}
+		closeTime := open.Add(time.Minute)
+		if !known {
+			if i == len(history) || !history[i].time.Before(closeTime) {
+				continue
+			}
+			price, known = history[i].price, true
+		}
+
+		openPrice, high, low := price, price, price
+		for ; i < len(history) && history[i].time.Before(closeTime); i++ {
+			price = history[i].price
+			high, low = math.Max(high, price), math.Min(low, price)
+		}
+		klines = append(klines, []interface{}{
+			open.UnixMilli(), formatFloat(openPrice), formatFloat(high), formatFloat(low), formatFloat(price),
+			"0", closeTime.UnixMilli() - 1, "0", 0, "0", "0", "0",
+		})
+	}
+
+	writeJSON(w, klines)
+}
+
+// handleBookTicker quotes a book one tick wide on each side of the price
+func (s *Server) handleBookTicker(w http.ResponseWriter, r *http.Request) {
+	params := s.begin(w, r, false)
+	if params == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	ticker := func(symbol string) binance.BookTicker {
+		price, tick := s.prices[symbol], s.symbols[symbol].TickSize
+		return binance.BookTicker{
+			Symbol:   symbol,
+			BidPrice: formatFloat(price - tick),
+			BidQty:   "1000",
+			AskPrice: formatFloat(price + tick),
+			AskQty:   "1000",
+			Time:     now,
+		}
+	}
+
+	if symbol := params.Get("symbol"); symbol != "" {
+		if _, ok := s.prices[symbol]; !ok {
+			writeError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+			return
+		}
+		writeJSON(w, ticker(symbol))
+		return
+	}
+
+	var tickers []binance.BookTicker
+	for symbol := range s.prices {
+		tickers = append(tickers, ticker(symbol))
+	}
+	sort.Slice(tickers, func(i, j int) bool { return tickers[i].Symbol < tickers[j].Symbol })
+	writeJSON(w, tickers)
+}
+
+func (s *Server) handleLeverage(w http.ResponseWriter, r *http.Request) {
+	params := s.begin(w, r, true)
+	if params == nil {
+		return
+	}
+
+	symbol := params.Get("symbol")
+	leverage, err := strconv.Atoi(params.Get("leverage"))
+	if err != nil || leverage < 1 || leverage > 125 {
+		writeError(w, http.StatusBadRequest, -4028, "Leverage is not valid")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.symbols[symbol]; !ok {
+		writeError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+		return
+	}
+	s.position(symbol).Leverage = leverage
+
+	writeJSON(w, map[string]interface{}{
+		"symbol":           symbol,
+		"leverage":         leverage,
+		"maxNotionalValue": "1000000",
+	})
+}
+
+// handleLeverageBracket lists the leverage brackets of one or all symbols
+func (s *Server) handleLeverageBracket(w http.ResponseWriter, r *http.Request) {
+	params := s.begin(w, r, true)
+	if params == nil {
+		return
+	}
+
+	symbol := params.Get("symbol")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if symbol != "" {
+		if _, ok := s.symbols[symbol]; !ok {
+			writeError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+			return
+		}
+	}
+
+	brackets := []binance.SymbolBrackets{}
+	for _, sym := range s.symbols {
+		if symbol == "" || sym.Symbol == symbol {
+			brackets = append(brackets, binance.SymbolBrackets{Symbol: sym.Symbol, Brackets: sym.Brackets})
+		}
+	}
+	sort.Slice(brackets, func(i, j int) bool { return brackets[i].Symbol < brackets[j].Symbol })
+
+	writeJSON(w, brackets)
+}
+
+func (s *Server) handleMarginType(w http.ResponseWriter, r *http.Request) {
+	params := s.begin(w, r, true)
+	if params == nil {
+		return
+	}
+
+	symbol := params.Get("symbol")
+	marginType := params.Get("marginType")
+	if marginType != "ISOLATED" && marginType != "CROSSED" {
+		writeError(w, http.StatusBadRequest, -4044, "The margin type is invalid.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.symbols[symbol]; !ok {
+		writeError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+		return
+	}
+
+	pos := s.position(symbol)
+	if pos.MarginType == marginType {
+		writeError(w, http.StatusBadRequest, -4046, "No need to change margin type.")
+		return
+	}
+	pos.MarginType = marginType
+
+	writeJSON(w, binance.APIError{Code: 200, Msg: "success"})
+}
+
+func (s *Server) handleOrder(w http.ResponseWriter, r *http.Request) {
+	params := s.begin(w, r, true)
+	if params == nil {
+		return
+	}
+
+	// A lost response executes the request but returns an error
+	s.mu.Lock()
+	lost := s.takeError(r, true)
+	s.mu.Unlock()
+	if lost != nil {
+		defer writeError(w, lost.status, lost.err.Code, lost.err.Msg)
+		w = httptest.NewRecorder()
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		s.placeOrder(w, params)
+	case http.MethodDelete:
+		s.cancelOrder(w, params)
+	case http.MethodGet:
+		s.queryOrder(w, params)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, -1000, "Unsupported method")
+	}
+}
+
+// placeOrder validates and accepts a new order, filling it when marketable
+func (s *Server) placeOrder(w http.ResponseWriter, params url.Values) {
+	order := &Order{
+		ClientOrderID: params.Get("newClientOrderId"),
+		Symbol:        params.Get("symbol"),
+		Side:          params.Get("side"),
+		Type:          params.Get("type"),
+		TimeInForce:   params.Get("timeInForce"),
+		ReduceOnly:    params.Get("reduceOnly") == "true",
+		ClosePosition: params.Get("closePosition") == "true",
+		Status:        StatusNew,
+		UpdateTime:    time.Now(),
+	}
+	order.Quantity, _ = strconv.ParseFloat(params.Get("quantity"), 64)
+	order.Price, _ = strconv.ParseFloat(params.Get("price"), 64)
+	order.StopPrice, _ = strconv.ParseFloat(params.Get("stopPrice"), 64)
+
+	if order.Side != "BUY" && order.Side != "SELL" {
+		writeError(w, http.StatusBadRequest, -1117, "Invalid side.")
+		return
+	}
+
+	s.mu.Lock()
+
+	sym, ok := s.symbols[order.Symbol]
+	if !ok {
+		s.mu.Unlock()
+		writeError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+		return
+	}
+
+	if order.ClientOrderID != "" {
+		for _, existing := range s.orders {
+			if existing.ClientOrderID == order.ClientOrderID && isOpen(existing) {
+				s.mu.Unlock()
+				writeError(w, http.StatusBadRequest, -4015, "Client order id is not valid.")
+				return
+			}
+		}
+	}
+
+	switch order.Type {
+	case "MARKET":
+	case "LIMIT":
+		if order.Price <= 0 {
+			s.mu.Unlock()
+			writeError(w, http.StatusBadRequest, -1102, "Mandatory parameter 'price' was not sent, was empty/null, or malformed.")
+			return
+		}
+		if order.TimeInForce == "" {
+			order.TimeInForce = "GTC"
+		}
+	case "STOP_MARKET", "TAKE_PROFIT_MARKET":
+		if order.StopPrice <= 0 {
+			s.mu.Unlock()
+			writeError(w, http.StatusBadRequest, -1102, "Mandatory parameter 'stopPrice' was not sent, was empty/null, or malformed.")
+			return
+		}
+		if s.wouldTrigger(order, s.prices[order.Symbol]) {
+			s.mu.Unlock()
+			writeError(w, http.StatusBadRequest, -2021, "Order would immediately trigger.")
+			return
+		}
+	default:
+		s.mu.Unlock()
+		writeError(w, http.StatusBadRequest, -1116, "Invalid orderType.")
+		return
+	}
+
+	if !order.ClosePosition {
+		if order.Quantity < sym.MinQty {
+			s.mu.Unlock()
+			writeError(w, http.StatusBadRequest, -4003, "Quantity less than or equal to zero.")
+			return
+		}
+		if !order.ReduceOnly && order.Quantity*s.prices[order.Symbol] < sym.MinNotional {
+			s.mu.Unlock()
+			writeError(w, http.StatusBadRequest, -4164, fmt.Sprintf("Order's notional must be no smaller than %s", formatFloat(sym.MinNotional)))
+			return
+		}
+	}
+
+	// Reduce-only orders need a position to reduce
+	if order.ReduceOnly && !order.ClosePosition {
+		if pos := s.position(order.Symbol); pos.Amount == 0 || (pos.Amount > 0) == (order.Side == "BUY") {
+			s.mu.Unlock()
+			writeError(w, http.StatusBadRequest, -2022, "ReduceOnly Order is rejected.")
+			return
+		}
+	}
+
+	if order.Type == "LIMIT" && order.TimeInForce == "GTX" && s.limitMarketable(order, s.prices[order.Symbol]) {
+		s.mu.Unlock()
+		writeError(w, http.StatusBadRequest, -5022, "Due to the order could not be executed as maker, the Post Only order will be rejected.")
+		return
+	}
+
+	s.nextOrderID++
+	order.OrderID = s.nextOrderID
+	if order.ClientOrderID == "" {
+		order.ClientOrderID = fmt.Sprintf("fake-%d", order.OrderID)
+	}
+	s.orders[order.OrderID] = order
+
+	events := []interface{}{s.orderEvent(order, "NEW", 0, 0, 0, 0)}
+
+	price := s.prices[order.Symbol]
+	switch {
+	case order.Type == "MARKET" && !s.holdFills:
+		events = append(events, s.fill(order, order.Quantity, s.marketPrice(order))...)
+	case order.Type == "LIMIT" && s.limitMarketable(order, price):
+		events = append(events, s.fill(order, order.Quantity, order.Price)...)
+	}
+
+	resp := orderResponse(order)
+	s.mu.Unlock()
+
+	s.publish(events)
+	writeJSON(w, resp)
+}
+
+// cancelOrder cancels an open order
+func (s *Server) cancelOrder(w http.ResponseWriter, params url.Values) {
+	s.mu.Lock()
+
+	order := s.findOrder(params)
+	if order == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusBadRequest, -2011, "Unknown order sent.")
+		return
+	}
+	if !isOpen(order) {
+		s.mu.Unlock()
+		writeError(w, http.StatusBadRequest, -2011, "Unknown order sent.")
+		return
+	}
+
+	order.Status = StatusCanceled
+	order.UpdateTime = time.Now()
+	event := s.orderEvent(order, "CANCELED", 0, 0, 0, 0)
+	resp := orderResponse(order)
+	s.mu.Unlock()
+
+	s.publish([]interface{}{event})
+	writeJSON(w, resp)
+}
+
+// queryOrder returns an order
+func (s *Server) queryOrder(w http.ResponseWriter, params url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order := s.findOrder(params)
+	if order == nil {
+		writeError(w, http.StatusBadRequest, -2013, "Order does not exist.")
+		return
+	}
+
+	writeJSON(w, orderResponse(order))
+}
+
+func (s *Server) handleOpenOrders(w http.ResponseWriter, r *http.Request) {
+	params := s.begin(w, r, true)
+	if params == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbol := params.Get("symbol")
+	responses := []binance.OrderResponse{}
+	for _, o := range s.orders {
+		if isOpen(o) && (symbol == "" || o.Symbol == symbol) {
+			responses = append(responses, orderResponse(o))
+		}
+	}
+	sort.Slice(responses, func(i, j int) bool { return responses[i].OrderID < responses[j].OrderID })
+
+	writeJSON(w, responses)
+}
+
+// handleAllOpenOrders cancels all open orders of a symbol
+func (s *Server) handleAllOpenOrders(w http.ResponseWriter, r *http.Request) {
+	params := s.begin(w, r, true)
+	if params == nil {
+		return
+	}
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, -1000, "Unsupported method.")
+		return
+	}
+
+	symbol := params.Get("symbol")
+	if symbol == "" {
+		writeError(w, http.StatusBadRequest, -1102, "Mandatory parameter 'symbol' was not sent.")
+		return
+	}
+
+	s.mu.Lock()
+	var events []interface{}
+	for _, o := range s.orders {
+		if o.Symbol == symbol && isOpen(o) {
+			o.Status = StatusCanceled
+			o.UpdateTime = time.Now()
+			events = append(events, s.orderEvent(o, "CANCELED", 0, 0, 0, 0))
+		}
+	}
+	s.mu.Unlock()
+
+	if len(events) > 0 {
+		s.publish(events)
+	}
+	writeJSON(w, map[string]interface{}{"code": 200, "msg": "The operation of cancel all open order is done."})
+}
+
+// handleUserTrades lists the fills of a symbol, optionally between
+// startTime and endTime
+func (s *Server) handleUserTrades(w http.ResponseWriter, r *http.Request) {
+	params := s.begin(w, r, true)
+	if params == nil {
+		return
+	}
+
+	symbol := params.Get("symbol")
+	if symbol == "" {
+		writeError(w, http.StatusBadRequest, -1102, "Mandatory parameter 'symbol' was not sent.")
+		return
+	}
+	startTime, _ := strconv.ParseInt(params.Get("startTime"), 10, 64)
+	endTime, _ := strconv.ParseInt(params.Get("endTime"), 10, 64)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trades := []binance.UserTrade{}
+	for _, t := range s.trades {
+		if t.Symbol != symbol || (startTime > 0 && t.Time < startTime) || (endTime > 0 && t.Time > endTime) {
+			continue
+		}
+		trades = append(trades, t)
+	}
+
+	writeJSON(w, trades)
+}
+
+// handleIncome lists the income history, optionally of one type and symbol,
+// from startTime on
+func (s *Server) handleIncome(w http.ResponseWriter, r *http.Request) {
+	params := s.begin(w, r, true)
+	if params == nil {
+		return
+	}
+
+	incomeType := params.Get("incomeType")
+	symbol := params.Get("symbol")
+	startTime, _ := strconv.ParseInt(params.Get("startTime"), 10, 64)
+	endTime, _ := strconv.ParseInt(params.Get("endTime"), 10, 64)
+	limit, _ := strconv.Atoi(params.Get("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	income := []binance.Income{}
+	for _, entry := range s.income {
+		if (incomeType != "" && entry.IncomeType != incomeType) || (symbol != "" && entry.Symbol != symbol) ||
+			(startTime > 0 && entry.Time < startTime) || (endTime > 0 && entry.Time > endTime) {
+			continue
+		}
+		income = append(income, entry)
+		if len(income) == limit {
+			break
+		}
+	}
+
+	writeJSON(w, income)
+}
+
+func (s *Server) handlePositionRisk(w http.ResponseWriter, r *http.Request) {
+	params := s.begin(w, r, true)
+	if params == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbol := params.Get("symbol")
+	risks := []binance.PositionRisk{}
+	for _, sym := range s.symbols {
+		if symbol != "" && sym.Symbol != symbol {
+			continue
+		}
+		pos := s.position(sym.Symbol)
+		price := s.prices[sym.Symbol]
+		risks = append(risks, binance.PositionRisk{
+			Symbol:           sym.Symbol,
+			PositionAmt:      formatFloat(pos.Amount),
+			EntryPrice:       formatFloat(pos.EntryPrice),
+			MarkPrice:        formatFloat(price),
+			UnRealizedProfit: formatFloat((price - pos.EntryPrice) * pos.Amount),
+			LiquidationPrice: "0",
+			Leverage:         strconv.Itoa(pos.Leverage),
+			MaxNotionalValue: "1000000",
+			MarginType:       strings.ToLower(pos.MarginType),
+			PositionSide:     "BOTH",
+			Notional:         formatFloat(price * pos.Amount),
+			UpdateTime:       time.Now().UnixMilli(),
+		})
+	}
+	sort.Slice(risks, func(i, j int) bool { return risks[i].Symbol < risks[j].Symbol })
+
+	writeJSON(w, risks)
+}
+
+func (s *Server) handleAccount(w http.ResponseWriter, r *http.Request) {
+	if s.begin(w, r, true) == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var unrealized float64
+	var positions []binance.Position
+	for symbol, pos := range s.positions {
+		price := s.prices[symbol]
+		upnl := (price - pos.EntryPrice) * pos.Amount
+		unrealized += upnl
+		positions = append(positions, binance.Position{
+			Symbol:           symbol,
+			UnrealizedProfit: formatFloat(upnl),
+			Leverage:         strconv.Itoa(pos.Leverage),
+			Isolated:         pos.MarginType == "ISOLATED",
+			EntryPrice:       formatFloat(pos.EntryPrice),
+			PositionSide:     "BOTH",
+			PositionAmt:      formatFloat(pos.Amount),
+			Notional:         formatFloat(price * pos.Amount),
+		})
+	}
+
+	balance := formatFloat(s.balance)
+	writeJSON(w, binance.AccountInfo{
+		Assets: []binance.Asset{{
+			Asset:            "USDT",
+			WalletBalance:    balance,
+			UnrealizedProfit: formatFloat(unrealized),
+			MarginBalance:    formatFloat(s.balance + unrealized),
+			AvailableBalance: balance,
+		}},
+		Positions:             positions,
+		TotalWalletBalance:    balance,
+		TotalUnrealizedProfit: formatFloat(unrealized),
+		TotalMarginBalance:    formatFloat(s.balance + unrealized),
+		AvailableBalance:      balance,
+		CanTrade:              true,
+		UpdateTime:            time.Now().UnixMilli(),
+	})
+}
+
+// handleListenKey manages user-data stream keys. Like the real endpoint it
+// only needs the API key, not a signature.
+func (s *Server) handleListenKey(w http.ResponseWriter, r *http.Request) {
+	params := s.begin(w, r, false)
+	if params == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPost:
+		key := fmt.Sprintf("listenkey-%d", len(s.listenKeys)+1)
+		s.listenKeys[key] = true
+		writeJSON(w, map[string]string{"listenKey": key})
+	case http.MethodPut:
+		if key := params.Get("listenKey"); key != "" && !s.listenKeys[key] {
+			writeError(w, http.StatusBadRequest, -1125, "This listenKey does not exist.")
+			return
+		}
+		writeJSON(w, map[string]string{})
+	case http.MethodDelete:
+		delete(s.listenKeys, params.Get("listenKey"))
+		writeJSON(w, map[string]string{})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, -1000, "Unsupported method")
+	}
+}
+
+// ============= Matching =============
+
+// movePrice sets a price and fills crossed orders. Caller holds mu.
+func (s *Server) movePrice(symbol string, price float64) []interface{} {
+	s.prices[symbol] = price
+	s.recordPrice(symbol, time.Now(), price)
+
+	ids := make([]int64, 0, len(s.orders))
+	for id, o := range s.orders {
+		if o.Symbol == symbol && isOpen(o) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var events []interface{}
+	for _, id := range ids {
+		order := s.orders[id]
+		if !isOpen(order) {
+			continue // Expired by an earlier fill closing the position
+		}
+
+		switch order.Type {
+		case "LIMIT":
+			if s.limitMarketable(order, price) {
+				events = append(events, s.fill(order, order.Quantity-order.ExecutedQty, order.Price)...)
+			}
+		case "STOP_MARKET", "TAKE_PROFIT_MARKET":
+			if s.wouldTrigger(order, price) {
+				qty := order.Quantity - order.ExecutedQty
+				if order.ClosePosition {
+					qty = math.Abs(s.position(symbol).Amount)
+				}
+				events = append(events, s.fill(order, qty, s.marketPrice(order))...)
+			}
+		}
+	}
+
+	return events
+}
+
+// wouldTrigger reports whether a conditional order triggers at price
+func (s *Server) wouldTrigger(order *Order, price float64) bool {
+	switch {
+	case order.Type == "STOP_MARKET" && order.Side == "SELL",
+		order.Type == "TAKE_PROFIT_MARKET" && order.Side == "BUY":
+		return price <= order.StopPrice
+	case order.Type == "STOP_MARKET" && order.Side == "BUY",
+		order.Type == "TAKE_PROFIT_MARKET" && order.Side == "SELL":
+		return price >= order.StopPrice
+	}
+	return false
+}
+
+// limitMarketable reports whether a limit order can fill at price
+func (s *Server) limitMarketable(order *Order, price float64) bool {
+	if order.Side == "BUY" {
+		return price <= order.Price
+	}
+	return price >= order.Price
+}
+
+// marketPrice returns the fill price of a market order including slippage
+func (s *Server) marketPrice(order *Order) float64 {
+	price := s.prices[order.Symbol]
+	slippage := s.slippage[order.Symbol]
+	if order.Side == "BUY" {
+		return price * (1 + slippage)
+	}
+	return price * (1 - slippage)
+}
+
+// fill executes qty of an order at price and updates the position.
+// Reduce-only orders are capped at the position size and expire when
+// there is nothing left to reduce. Caller holds mu.
+func (s *Server) fill(order *Order, qty, price float64) []interface{} {
+	pos := s.position(order.Symbol)
+	signed := qty
+	if order.Side == "SELL" {
+		signed = -qty
+	}
+
+	if order.ReduceOnly || order.ClosePosition {
+		if pos.Amount == 0 || (pos.Amount > 0) == (signed > 0) {
+			order.Status = StatusExpired
+			order.UpdateTime = time.Now()
+			return []interface{}{s.orderEvent(order, "EXPIRED", 0, 0, 0, 0)}
+		}
+		if qty > math.Abs(pos.Amount) {
+			qty = math.Abs(pos.Amount)
+			signed = math.Copysign(qty, signed)
+		}
+	}
+
+	// Realize PnL on the closing part, average the entry on the opening part
+	var realized float64
+	if pos.Amount != 0 && (pos.Amount > 0) != (signed > 0) {
+		closing := math.Min(qty, math.Abs(pos.Amount))
+		realized = (price - pos.EntryPrice) * closing * math.Copysign(1, pos.Amount)
+		pos.Amount += math.Copysign(closing, signed)
+		opening := qty - closing
+		if pos.Amount == 0 {
+			pos.EntryPrice = 0
+		}
+		if opening > 0 {
+			pos.Amount = math.Copysign(opening, signed)
+			pos.EntryPrice = price
+		}
+	} else {
+		total := math.Abs(pos.Amount) + qty
+		pos.EntryPrice = (pos.EntryPrice*math.Abs(pos.Amount) + price*qty) / total
+		pos.Amount += signed
+	}
+	pos.Amount = roundQty(pos.Amount)
+
+	commission := price * qty * s.commissionRate
+	pos.RealizedPnL += realized
+	pos.Commission += commission
+	s.balance += realized - commission
+
+	order.AvgPrice = (order.AvgPrice*order.ExecutedQty + price*qty) / (order.ExecutedQty + qty)
+	order.ExecutedQty = roundQty(order.ExecutedQty + qty)
+	order.Status = StatusFilled
+	if order.ExecutedQty < order.Quantity && !order.ClosePosition {
+		order.Status = StatusPartiallyFilled
+	}
+	order.UpdateTime = time.Now()
+
+	s.nextTradeID++
+	s.trades = append(s.trades, binance.UserTrade{
+		ID:              s.nextTradeID,
+		OrderID:         order.OrderID,
+		Symbol:          order.Symbol,
+		Side:            order.Side,
+		Price:           formatFloat(price),
+		Qty:             formatFloat(qty),
+		QuoteQty:        formatFloat(price * qty),
+		RealizedPnl:     formatFloat(realized),
+		Commission:      formatFloat(commission),
+		CommissionAsset: "USDT",
+		Buyer:           order.Side == "BUY",
+		PositionSide:    "BOTH",
+		Time:            time.Now().UnixMilli(),
+	})
+
+	tradeID := strconv.FormatInt(s.nextTradeID, 10)
+	if realized != 0 {
+		s.addIncome(order.Symbol, "REALIZED_PNL", realized, tradeID)
+	}
+	s.addIncome(order.Symbol, "COMMISSION", -commission, tradeID)
+
+	trade := s.orderEvent(order, "TRADE", qty, price, commission, realized)
+	trade.Order.TradeID = s.nextTradeID
+	events := []interface{}{trade, s.accountEvent(pos)}
+
+	// Reduce-only orders left over once the position is flat expire
+	if pos.Amount == 0 {
+		for _, other := range s.orders {
+			if other != order && other.Symbol == order.Symbol && isOpen(other) && (other.ReduceOnly || other.ClosePosition) {
+				other.Status = StatusExpired
+				other.UpdateTime = time.Now()
+				events = append(events, s.orderEvent(other, "EXPIRED", 0, 0, 0, 0))
+			}
+		}
+	}
+
+	return events
+}
+
+// addIncome records an income history entry. Caller holds mu.
+func (s *Server) addIncome(symbol, incomeType string, amount float64, tradeID string) {
+	s.nextTranID++
+	s.income = append(s.income, binance.Income{
+		Symbol:     symbol,
+		IncomeType: incomeType,
+		Income:     formatFloat(amount),
+		Asset:      "USDT",
+		Time:       time.Now().UnixMilli(),
+		TranID:     s.nextTranID,
+		TradeID:    tradeID,
+	})
+}
+
+// position returns the position of a symbol, creating it if needed. Caller holds mu.
+func (s *Server) position(symbol string) *Position {
+	pos, ok := s.positions[symbol]
+	if !ok {
+		pos = &Position{Symbol: symbol, Leverage: 20, MarginType: "CROSSED"}
+		s.positions[symbol] = pos
+	}
+	return pos
+}
+
+// findOrder looks up an order by orderId or origClientOrderId. Caller holds mu.
+func (s *Server) findOrder(params url.Values) *Order {
+	if id, err := strconv.ParseInt(params.Get("orderId"), 10, 64); err == nil {
+		if o, ok := s.orders[id]; ok && o.Symbol == params.Get("symbol") {
+			return o
+		}
+		return nil
+	}
+
+	// Client order IDs can be reused once an order is closed, the latest wins
+	var found *Order
+	if clientID := params.Get("origClientOrderId"); clientID != "" {
+		for _, o := range s.orders {
+			if o.ClientOrderID == clientID && o.Symbol == params.Get("symbol") && (found == nil || o.OrderID > found.OrderID) {
+				found = o
+			}
+		}
+	}
+
+	return found
+}
+
+// ============= Helpers =============
+
+// isOpen reports whether an order can still fill
+func isOpen(order *Order) bool {
+	return order.Status == StatusNew || order.Status == StatusPartiallyFilled
+}
+
+// orderResponse converts an order to its REST representation
+func orderResponse(o *Order) binance.OrderResponse {
+	return binance.OrderResponse{
+		OrderID:       o.OrderID,
+		Symbol:        o.Symbol,
+		Status:        o.Status,
+		ClientOrderID: o.ClientOrderID,
+		Price:         formatFloat(o.Price),
+		AvgPrice:      formatFloat(o.AvgPrice),
+		OrigQty:       formatFloat(o.Quantity),
+		ExecutedQty:   formatFloat(o.ExecutedQty),
+		CumQty:        formatFloat(o.ExecutedQty),
+		CumQuote:      formatFloat(o.ExecutedQty * o.AvgPrice),
+		TimeInForce:   o.TimeInForce,
+		Type:          o.Type,
+		ReduceOnly:    o.ReduceOnly,
+		Side:          o.Side,
+		StopPrice:     formatFloat(o.StopPrice),
+		WorkingType:   "CONTRACT_PRICE",
+		UpdateTime:    o.UpdateTime.UnixMilli(),
+	}
+}
+
+// validSignature checks the HMAC signature of a signed request, which
+// covers every other parameter of the query string and form body
+func validSignature(r *http.Request, secret string) bool {
+	values := url.Values{}
+	for k, v := range r.Form {
+		values[k] = v
+	}
+	signature := values.Get("signature")
+	values.Del("signature")
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(values.Encode()))
+	return hmac.Equal([]byte(signature), []byte(hex.EncodeToString(mac.Sum(nil))))
+}
+
+// validTimestamp applies Binance's timing rule to a signed request: at most
+// 1s ahead of the server time and at most recvWindow (default 5000ms) behind.
+// Requests without a timestamp pass.
+func validTimestamp(params url.Values, serverTime int64) bool {
+	timestamp, err := strconv.ParseInt(params.Get("timestamp"), 10, 64)
+	if err != nil {
+		return true
+	}
+	recvWindow := int64(5000)
+	if v, err := strconv.ParseInt(params.Get("recvWindow"), 10, 64); err == nil {
+		recvWindow = v
+	}
+	return timestamp < serverTime+1000 && serverTime-timestamp <= recvWindow
+}
+
+// roundQty removes floating point noise from quantities
+func roundQty(v float64) float64 {
+	return math.Round(v*1e8) / 1e8
+}
+
+// formatFloat renders a number the way Binance does, as a plain decimal string
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// writeJSON writes a 200 response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a Binance-style error response
+func writeError(w http.ResponseWriter, status, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(binance.APIError{Code: code, Msg: msg})
+}
@@ -0,0 +1,178 @@
+package binancetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"tdlib-go/internal/binance"
+)
+
+// handleStream upgrades a user-data stream connection for a listen key
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/ws/")
+
+	s.mu.Lock()
+	valid := s.listenKeys[key]
+	s.mu.Unlock()
+	if !valid {
+		writeError(w, http.StatusBadRequest, -1125, "This listenKey does not exist.")
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	s.streamMu.Lock()
+	s.streams[conn] = &sync.Mutex{}
+	s.streamMu.Unlock()
+
+	// Drain client frames so pings and close frames are handled
+	go func() {
+		defer s.dropStream(conn)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// StreamCount returns the number of connected user-data streams
+func (s *Server) StreamCount() int {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+	return len(s.streams)
+}
+
+// DropStreams closes all user-data stream connections, simulating a
+// disconnect by the exchange
+func (s *Server) DropStreams() {
+	s.streamMu.Lock()
+	conns := make([]*websocket.Conn, 0, len(s.streams))
+	for conn := range s.streams {
+		conns = append(conns, conn)
+	}
+	s.streamMu.Unlock()
+
+	for _, conn := range conns {
+		s.dropStream(conn)
+	}
+}
+
+// Publish sends a raw event to all user-data streams, for events the
+// server does not generate itself
+func (s *Server) Publish(event interface{}) {
+	s.publish([]interface{}{event})
+}
+
+// dropStream closes and forgets a stream connection
+func (s *Server) dropStream(conn *websocket.Conn) {
+	s.streamMu.Lock()
+	delete(s.streams, conn)
+	s.streamMu.Unlock()
+	conn.Close()
+}
+
+// publish sends events to all user-data streams in order. It must be
+// called without holding mu.
+func (s *Server) publish(events []interface{}) {
+	if len(events) == 0 {
+		return
+	}
+
+	payloads := make([][]byte, 0, len(events))
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			continue
+		}
+		payloads = append(payloads, data)
+	}
+
+	s.streamMu.Lock()
+	type stream struct {
+		conn *websocket.Conn
+		mu   *sync.Mutex
+	}
+	streams := make([]stream, 0, len(s.streams))
+	for conn, mu := range s.streams {
+		streams = append(streams, stream{conn, mu})
+	}
+	s.streamMu.Unlock()
+
+	for _, st := range streams {
+		st.mu.Lock()
+		for _, data := range payloads {
+			if err := st.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				break
+			}
+		}
+		st.mu.Unlock()
+	}
+}
+
+// orderEvent builds an ORDER_TRADE_UPDATE event. Caller holds mu.
+func (s *Server) orderEvent(order *Order, execType string, lastQty, lastPrice, commission, realized float64) *binance.OrderUpdate {
+	now := time.Now().UnixMilli()
+
+	event := &binance.OrderUpdate{EventType: "ORDER_TRADE_UPDATE", EventTime: now}
+	o := &event.Order
+	o.Symbol = order.Symbol
+	o.ClientOrderID = order.ClientOrderID
+	o.Side = order.Side
+	o.Type = order.Type
+	o.TimeInForce = order.TimeInForce
+	o.OrigQty = formatFloat(order.Quantity)
+	o.Price = formatFloat(order.Price)
+	o.AvgPrice = formatFloat(order.AvgPrice)
+	o.StopPrice = formatFloat(order.StopPrice)
+	o.ExecutionType = execType
+	o.OrderStatus = order.Status
+	o.OrderID = order.OrderID
+	o.LastFilledQty = formatFloat(lastQty)
+	o.FilledQty = formatFloat(order.ExecutedQty)
+	o.LastFilledPrice = formatFloat(lastPrice)
+	o.CommissionAsset = "USDT"
+	o.Commission = formatFloat(commission)
+	o.OrderTradeTime = now
+	o.RealizedProfit = formatFloat(realized)
+	o.ReduceOnly = order.ReduceOnly
+	o.WorkingType = "CONTRACT_PRICE"
+	o.OrigType = order.Type
+	o.PositionSide = "BOTH"
+	o.IsCloseAll = order.ClosePosition
+
+	return event
+}
+
+// accountEvent builds an ACCOUNT_UPDATE event for a fill. Caller holds mu.
+func (s *Server) accountEvent(pos *Position) *binance.AccountUpdate {
+	event := &binance.AccountUpdate{EventType: "ACCOUNT_UPDATE", EventTime: time.Now().UnixMilli()}
+	event.UpdateData.Reason = "ORDER"
+
+	balance := formatFloat(s.balance)
+	event.UpdateData.Balances = []binance.BalanceUpdate{{
+		Asset:              "USDT",
+		WalletBalance:      balance,
+		CrossWalletBalance: balance,
+		BalanceChange:      "0",
+	}}
+	event.UpdateData.Positions = []binance.PositionUpdate{{
+		Symbol:              pos.Symbol,
+		PositionAmount:      formatFloat(pos.Amount),
+		EntryPrice:          formatFloat(pos.EntryPrice),
+		AccumulatedRealized: formatFloat(pos.RealizedPnL),
+		UnrealizedPnL:       formatFloat((s.prices[pos.Symbol] - pos.EntryPrice) * pos.Amount),
+		MarginType:          strings.ToLower(pos.MarginType),
+		IsolatedWallet:      "0",
+		PositionSide:        "BOTH",
+	}}
+
+	return event
+}
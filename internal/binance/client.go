@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -37,6 +39,14 @@ type Client struct {
 	httpClient *http.Client
 	logger     *logrus.Logger
 
+	// Rate limits, the weight is shared with every client of the base URL
+	weights *weightTracker
+	orders  orderTracker
+
+	// Signed request timing
+	timeOffset atomic.Int64 // Server time minus local time, in milliseconds
+	recvWindow int64        // Milliseconds a signed request stays valid, 0 for the exchange default
+
 	// WebSocket
 	wsConn *websocket.Conn
 	wsMu   sync.RWMutex
@@ -65,7 +75,8 @@ func NewClient(apiKey, apiSecret string, isTestnet bool, logger *logrus.Logger)
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		logger: logger,
+		logger:  logger,
+		weights: weightTrackerFor(baseURL),
 	}
 }
 
@@ -79,7 +90,8 @@ func NewClientWithConfig(apiKey, apiSecret string, baseURL, wsBaseURL string, lo
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		logger: logger,
+		logger:  logger,
+		weights: weightTrackerFor(baseURL),
 	}
 }
 
@@ -90,10 +102,65 @@ func (c *Client) sign(params url.Values) string {
 	return hex.EncodeToString(mac.Sum(nil))
 }
 
-// doRequest performs an HTTP request to Binance API
+// doRequest performs an HTTP request to Binance API. Requests wait while
+// the IP or account is near its rate limits, and idempotent requests are
+// retried with jittered backoff on network errors, 429 and 5xx responses.
 func (c *Client) doRequest(method, endpoint string, params url.Values, signed bool) ([]byte, error) {
+	isOrder := endpoint == "/fapi/v1/order" && method == http.MethodPost
+	retry := isIdempotent(method, endpoint)
+	resynced := false
+
+	for attempt := 0; ; attempt++ {
+		if err := c.weights.wait(); err != nil {
+			return nil, err
+		}
+		if isOrder {
+			c.orders.wait()
+		}
+
+		body, resp, err := c.send(method, endpoint, params, signed)
+		if err == nil {
+			return body, nil
+		}
+
+		// A drifted clock is resynced and the request retried once, the
+		// exchange rejects such requests before acting on them
+		var apiErr *APIError
+		if signed && !resynced && errors.As(err, &apiErr) && apiErr.Code == ErrCodeTimestamp {
+			resynced = true
+			if offset, syncErr := c.SyncTime(); syncErr != nil {
+				c.logger.Errorf("Failed to sync with Binance server time: %v", syncErr)
+			} else {
+				c.logger.Warnf("Binance rejected the request timestamp, resynced clock offset to %s and retrying", offset)
+			}
+			continue
+		}
+
+		retryable := resp == nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+		if !retry || !retryable || attempt >= maxRetries {
+			return nil, err
+		}
+
+		// A 429 blocks the tracker until Retry-After, wait() sleeps through it
+		delay := retryDelay(attempt)
+		c.weights.mu.Lock()
+		c.weights.retries++
+		c.weights.mu.Unlock()
+		c.logger.Warnf("Binance request %s %s failed, retrying in %s: %v", method, endpoint, delay.Round(time.Millisecond), err)
+		time.Sleep(delay)
+	}
+}
+
+// send performs a single HTTP request to Binance API and records its rate
+// limit headers. The response is nil when the request did not complete.
+func (c *Client) send(method, endpoint string, params url.Values, signed bool) ([]byte, *http.Response, error) {
 	if signed {
-		params.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+		// Retries are signed again with a fresh timestamp
+		params.Del("signature")
+		if c.recvWindow > 0 {
+			params.Set("recvWindow", strconv.FormatInt(c.recvWindow, 10))
+		}
+		params.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli()+c.timeOffset.Load(), 10))
 		params.Set("signature", c.sign(params))
 	}
 
@@ -111,7 +178,7 @@ func (c *Client) doRequest(method, endpoint string, params url.Values, signed bo
 
 	req, err := http.NewRequest(method, reqURL, reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("X-MBX-APIKEY", c.apiKey)
@@ -121,24 +188,63 @@ func (c *Client) doRequest(method, endpoint string, params url.Values, signed bo
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
+	c.weights.update(resp)
+	c.orders.update(resp)
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		var apiErr APIError
 		if err := json.Unmarshal(body, &apiErr); err == nil {
-			return nil, fmt.Errorf("binance API error [%d]: %s", apiErr.Code, apiErr.Msg)
+			apiErr.StatusCode = resp.StatusCode
+			return nil, resp, &apiErr
 		}
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, resp, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
-	return body, nil
+	return body, resp, nil
+}
+
+// SetRecvWindow sets how long signed requests stay valid after their
+// timestamp, in milliseconds. 0 uses the exchange default of 5000.
+func (c *Client) SetRecvWindow(ms int) {
+	c.recvWindow = int64(ms)
+}
+
+// SyncTime measures the offset of the local clock from the server time and
+// applies it to the timestamps of signed requests
+func (c *Client) SyncTime() (time.Duration, error) {
+	sent := time.Now()
+	body, err := c.doRequest(http.MethodGet, "/fapi/v1/time", url.Values{}, false)
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+
+	var serverTime ServerTime
+	if err := json.Unmarshal(body, &serverTime); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal server time: %w", err)
+	}
+
+	// The server time is taken halfway through the round trip
+	local := sent.Add(received.Sub(sent) / 2).UnixMilli()
+	offset := serverTime.ServerTime - local
+	c.timeOffset.Store(offset)
+
+	return time.Duration(offset) * time.Millisecond, nil
+}
+
+// TimeOffset returns the last measured offset of the server time from the
+// local clock
+func (c *Client) TimeOffset() time.Duration {
+	return time.Duration(c.timeOffset.Load()) * time.Millisecond
 }
 
 // GetExchangeInfo retrieves exchange trading rules and symbol information
@@ -174,6 +280,24 @@ func (c *Client) GetSymbolPriceTicker(symbol string) (*PriceTicker, error) {
 	return &ticker, nil
 }
 
+// GetBookTicker retrieves the best bid and ask of a symbol
+func (c *Client) GetBookTicker(symbol string) (*BookTicker, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+
+	body, err := c.doRequest(http.MethodGet, "/fapi/v1/ticker/bookTicker", params, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var ticker BookTicker
+	if err := json.Unmarshal(body, &ticker); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal book ticker: %w", err)
+	}
+
+	return &ticker, nil
+}
+
 // SetLeverage changes the leverage for a symbol
 func (c *Client) SetLeverage(symbol string, leverage int) error {
 	params := url.Values{}
@@ -189,6 +313,77 @@ func (c *Client) SetLeverage(symbol string, leverage int) error {
 	return nil
 }
 
+// GetKlines retrieves the candlesticks of a symbol at an interval (e.g. 1m)
+// from startTime, oldest first. A zero endTime or limit uses the API default.
+func (c *Client) GetKlines(symbol, interval string, startTime, endTime time.Time, limit int) ([]Kline, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("interval", interval)
+	params.Set("startTime", strconv.FormatInt(startTime.UnixMilli(), 10))
+	if !endTime.IsZero() {
+		params.Set("endTime", strconv.FormatInt(endTime.UnixMilli(), 10))
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+
+	body, err := c.doRequest(http.MethodGet, "/fapi/v1/klines", params, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Klines are arrays of [openTime, open, high, low, close, volume, closeTime, ...]
+	var rows [][]json.RawMessage
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal klines: %w", err)
+	}
+
+	klines := make([]Kline, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 7 {
+			return nil, fmt.Errorf("failed to unmarshal klines: short kline of %d fields", len(row))
+		}
+		var k Kline
+		fields := []interface{}{&k.OpenTime, &k.Open, &k.High, &k.Low, &k.Close, &k.Volume, &k.CloseTime}
+		for i, field := range fields {
+			if err := json.Unmarshal(row[i], field); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal klines: %w", err)
+			}
+		}
+		klines = append(klines, k)
+	}
+
+	return klines, nil
+}
+
+// GetLeverageBrackets retrieves the notional brackets of a symbol
+func (c *Client) GetLeverageBrackets(symbol string) ([]LeverageBracket, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+
+	body, err := c.doRequest(http.MethodGet, "/fapi/v1/leverageBracket", params, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leverage brackets: %w", err)
+	}
+
+	// A list is returned, or a single object for some symbols
+	var list []SymbolBrackets
+	if err := json.Unmarshal(body, &list); err != nil {
+		var single SymbolBrackets
+		if err := json.Unmarshal(body, &single); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal leverage brackets: %w", err)
+		}
+		list = []SymbolBrackets{single}
+	}
+
+	for _, entry := range list {
+		if entry.Symbol == symbol {
+			return entry.Brackets, nil
+		}
+	}
+	return nil, fmt.Errorf("no leverage brackets for %s", symbol)
+}
+
 // SetMarginType sets the margin type for a symbol (ISOLATED or CROSSED)
 func (c *Client) SetMarginType(symbol string, marginType string) error {
 	params := url.Values{}
@@ -208,14 +403,28 @@ func (c *Client) SetMarginType(symbol string, marginType string) error {
 	return nil
 }
 
-// PlaceOrder places a new order
+// ErrOrderUnknown is returned by PlaceOrder when an order's outcome is
+// unknown and it could not be found on the exchange either. It may still
+// have been placed, so it must be reconciled rather than placed again.
+var ErrOrderUnknown = errors.New("order status is unknown")
+
+// Orders whose placement has an unknown status are looked up for
+// orderLookupWindow, as a new order can take a moment to be queryable
+var (
+	orderLookupDelay  = 250 * time.Millisecond
+	orderLookupWindow = 10 * time.Second
+)
+
+// PlaceOrder places a new order. An order with a client order ID whose
+// placement has an unknown status is looked up instead of placed again, and
+// ErrOrderUnknown is returned when it is not found in time.
 func (c *Client) PlaceOrder(order *NewOrder) (*OrderResponse, error) {
 	params := url.Values{}
 	params.Set("symbol", order.Symbol)
 	params.Set("side", order.Side)
 	params.Set("type", order.Type)
 
-	if order.Quantity > 0 {
+	if order.Quantity > 0 && !order.ClosePosition {
 		params.Set("quantity", fmt.Sprintf("%.8f", order.Quantity))
 	}
 
@@ -231,7 +440,9 @@ func (c *Client) PlaceOrder(order *NewOrder) (*OrderResponse, error) {
 		params.Set("timeInForce", order.TimeInForce)
 	}
 
-	if order.ReduceOnly {
+	if order.ClosePosition {
+		params.Set("closePosition", "true")
+	} else if order.ReduceOnly {
 		params.Set("reduceOnly", "true")
 	}
 
@@ -240,6 +451,11 @@ func (c *Client) PlaceOrder(order *NewOrder) (*OrderResponse, error) {
 	}
 
 	body, err := c.doRequest(http.MethodPost, "/fapi/v1/order", params, true)
+	if err != nil && order.NewClientOrderID != "" && isUnknownStatus(err) {
+		// The order may exist, it is looked up by its client order ID rather
+		// than placed a second time
+		return c.lookupUnknownOrder(order, err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to place order: %w", err)
 	}
@@ -260,6 +476,31 @@ func (c *Client) PlaceOrder(order *NewOrder) (*OrderResponse, error) {
 	return &resp, nil
 }
 
+// lookupUnknownOrder looks up an order whose placement failed with an
+// unknown status, retrying with backoff while the exchange does not know it
+func (c *Client) lookupUnknownOrder(order *NewOrder, placeErr error) (*OrderResponse, error) {
+	deadline := time.Now().Add(orderLookupWindow)
+	delay := orderLookupDelay
+
+	for {
+		existing, err := c.QueryOrderByClientID(order.Symbol, order.NewClientOrderID)
+		if err == nil {
+			c.logger.Warnf("Order %s status was unknown, found it on the exchange: %v", order.NewClientOrderID, placeErr)
+			return existing, nil
+		}
+		if !isNoSuchOrder(err) && !isUnknownStatus(err) {
+			return nil, fmt.Errorf("failed to place order %s: %w: %v (lookup: %v)", order.NewClientOrderID, ErrOrderUnknown, placeErr, err)
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return nil, fmt.Errorf("failed to place order %s: %w, not found after %s: %v",
+				order.NewClientOrderID, ErrOrderUnknown, orderLookupWindow, placeErr)
+		}
+
+		time.Sleep(delay)
+		delay = min(delay*2, 2*time.Second)
+	}
+}
+
 // CancelOrder cancels an active order
 func (c *Client) CancelOrder(symbol string, orderID int64) (*OrderResponse, error) {
 	params := url.Values{}
@@ -284,12 +525,103 @@ func (c *Client) CancelOrder(symbol string, orderID int64) (*OrderResponse, erro
 	return &resp, nil
 }
 
+// CancelAllOpenOrders cancels all open orders of a symbol
+func (c *Client) CancelAllOpenOrders(symbol string) error {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+
+	if _, err := c.doRequest(http.MethodDelete, "/fapi/v1/allOpenOrders", params, true); err != nil {
+		return fmt.Errorf("failed to cancel open orders: %w", err)
+	}
+
+	c.logger.WithField("symbol", symbol).Info("All open orders canceled")
+
+	return nil
+}
+
+// GetOpenOrders retrieves the open orders of a symbol, or of all symbols
+// when symbol is empty
+func (c *Client) GetOpenOrders(symbol string) ([]OrderResponse, error) {
+	params := url.Values{}
+	if symbol != "" {
+		params.Set("symbol", symbol)
+	}
+
+	body, err := c.doRequest(http.MethodGet, "/fapi/v1/openOrders", params, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open orders: %w", err)
+	}
+
+	var orders []OrderResponse
+	if err := json.Unmarshal(body, &orders); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal open orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+// GetUserTrades retrieves the account's fills of a symbol between two
+// times (at most 7 days apart), oldest first
+func (c *Client) GetUserTrades(symbol string, startTime, endTime time.Time) ([]UserTrade, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("startTime", strconv.FormatInt(startTime.UnixMilli(), 10))
+	params.Set("endTime", strconv.FormatInt(endTime.UnixMilli(), 10))
+	params.Set("limit", "1000")
+
+	body, err := c.doRequest(http.MethodGet, "/fapi/v1/userTrades", params, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user trades: %w", err)
+	}
+
+	var trades []UserTrade
+	if err := json.Unmarshal(body, &trades); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user trades: %w", err)
+	}
+
+	return trades, nil
+}
+
+// GetIncomeHistory retrieves up to limit income entries of a type (all
+// types when empty) from startTime on, oldest first
+func (c *Client) GetIncomeHistory(incomeType string, startTime time.Time, limit int) ([]Income, error) {
+	params := url.Values{}
+	if incomeType != "" {
+		params.Set("incomeType", incomeType)
+	}
+	params.Set("startTime", strconv.FormatInt(startTime.UnixMilli(), 10))
+	params.Set("limit", strconv.Itoa(limit))
+
+	body, err := c.doRequest(http.MethodGet, "/fapi/v1/income", params, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get income history: %w", err)
+	}
+
+	var income []Income
+	if err := json.Unmarshal(body, &income); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal income history: %w", err)
+	}
+
+	return income, nil
+}
+
 // QueryOrder checks an order's status
 func (c *Client) QueryOrder(symbol string, orderID int64) (*OrderResponse, error) {
 	params := url.Values{}
 	params.Set("symbol", symbol)
 	params.Set("orderId", strconv.FormatInt(orderID, 10))
+	return c.queryOrder(params)
+}
 
+// QueryOrderByClientID checks the status of an order by its client order ID
+func (c *Client) QueryOrderByClientID(symbol, clientOrderID string) (*OrderResponse, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("origClientOrderId", clientOrderID)
+	return c.queryOrder(params)
+}
+
+func (c *Client) queryOrder(params url.Values) (*OrderResponse, error) {
 	body, err := c.doRequest(http.MethodGet, "/fapi/v1/order", params, true)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query order: %w", err)
@@ -385,15 +717,26 @@ func (c *Client) ConnectUserDataStream(listenKey string) error {
 
 	c.logger.Info("Connected to user data stream WebSocket")
 
-	// Start reading messages
-	go c.readWebSocket()
+	// Start reading messages; the keep-alive stops once the connection is gone
+	done := make(chan struct{})
+	go func() {
+		c.readWebSocket()
+		close(done)
+	}()
 
 	// Start keep-alive ticker
-	go c.keepAliveStream(listenKey)
+	go c.keepAliveStream(listenKey, done)
 
 	return nil
 }
 
+// IsStreamConnected reports whether the user data stream is connected
+func (c *Client) IsStreamConnected() bool {
+	c.wsMu.RLock()
+	defer c.wsMu.RUnlock()
+	return c.wsConn != nil
+}
+
 // readWebSocket reads messages from the WebSocket connection
 func (c *Client) readWebSocket() {
 	defer func() {
@@ -426,8 +769,11 @@ func (c *Client) readWebSocket() {
 
 // handleWebSocketMessage processes WebSocket messages
 func (c *Client) handleWebSocketMessage(message []byte) {
+	// Event time is declared so the case-insensitive decoder doesn't
+	// match the numeric "E" against "e"
 	var baseMsg struct {
 		EventType string `json:"e"`
+		EventTime int64  `json:"E"`
 	}
 
 	if err := json.Unmarshal(message, &baseMsg); err != nil {
@@ -465,12 +811,18 @@ func (c *Client) handleWebSocketMessage(message []byte) {
 	}
 }
 
-// keepAliveStream keeps the listen key alive
-func (c *Client) keepAliveStream(listenKey string) {
+// keepAliveStream keeps the listen key alive until done is closed
+func (c *Client) keepAliveStream(listenKey string, done <-chan struct{}) {
 	ticker := time.NewTicker(30 * time.Minute)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+
 		if err := c.KeepAliveUserDataStream(listenKey); err != nil {
 			c.logger.Errorf("Failed to keep alive user data stream: %v", err)
 			return
@@ -0,0 +1,168 @@
+package binance_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"tdlib-go/internal/binance"
+)
+
+// withinDelta reports whether an offset is within a round trip of want
+func withinDelta(offset, want time.Duration) bool {
+	diff := offset - want
+	return diff > -200*time.Millisecond && diff < 200*time.Millisecond
+}
+
+func TestSyncTimeMeasuresServerOffset(t *testing.T) {
+	client, srv := newTestClient(t)
+	srv.SetClockSkew(3 * time.Second)
+
+	offset, err := client.SyncTime()
+	if err != nil {
+		t.Fatalf("SyncTime: %v", err)
+	}
+	if !withinDelta(offset, 3*time.Second) || client.TimeOffset() != offset {
+		t.Errorf("offset = %s (TimeOffset %s), want about 3s", offset, client.TimeOffset())
+	}
+}
+
+func TestRejectedTimestampResyncsClock(t *testing.T) {
+	client, srv := newTestClient(t)
+	srv.SetClockSkew(-10 * time.Second)
+
+	// The local clock is 10s ahead, outside the recvWindow
+	if _, err := client.GetAccount(); err != nil {
+		t.Fatalf("GetAccount: %v", err)
+	}
+
+	if !withinDelta(client.TimeOffset(), -10*time.Second) {
+		t.Errorf("offset = %s, want about -10s", client.TimeOffset())
+	}
+	if n := countRequests(srv, http.MethodGet, "/fapi/v1/time"); n != 1 {
+		t.Errorf("synced %d times, want 1", n)
+	}
+	if n := countRequests(srv, http.MethodGet, "/fapi/v2/account"); n != 2 {
+		t.Errorf("sent %d account requests, want the rejected one retried once", n)
+	}
+}
+
+// entryOrder is a market order with a client order ID
+func entryOrder() *binance.NewOrder {
+	return &binance.NewOrder{
+		Symbol:           "BTCUSDT",
+		Side:             "BUY",
+		Type:             "MARKET",
+		Quantity:         1,
+		NewClientOrderID: "tg-t1-a1-entry",
+	}
+}
+
+func TestLostOrderResponseIsLookedUp(t *testing.T) {
+	client, srv := newTestClient(t)
+	binance.SetOrderLookup(t, 10*time.Millisecond, time.Second)
+	srv.InjectLostResponse(http.MethodPost, "/fapi/v1/order", 1)
+	// The order is not queryable right away
+	srv.InjectError(http.MethodGet, "/fapi/v1/order", 2, binance.ErrCodeNoSuchOrder, "Order does not exist.")
+
+	resp, err := client.PlaceOrder(entryOrder())
+	if err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+
+	orders := srv.Orders()
+	if len(orders) != 1 || resp.OrderID != orders[0].OrderID || resp.ClientOrderID != "tg-t1-a1-entry" {
+		t.Fatalf("PlaceOrder = %+v with %d orders on the exchange, want the one order", resp, len(orders))
+	}
+	if n := countRequests(srv, http.MethodGet, "/fapi/v1/order"); n != 3 {
+		t.Errorf("looked the order up %d times, want 3", n)
+	}
+}
+
+func TestUnknownOrderIsNotPlacedAgain(t *testing.T) {
+	client, srv := newTestClient(t)
+	binance.SetOrderLookup(t, 10*time.Millisecond, 100*time.Millisecond)
+	srv.InjectError(http.MethodPost, "/fapi/v1/order", 1, binance.ErrCodeUnknownStatus, "Timeout waiting for response from backend server.")
+
+	_, err := client.PlaceOrder(entryOrder())
+	if !errors.Is(err, binance.ErrOrderUnknown) {
+		t.Fatalf("PlaceOrder error = %v, want ErrOrderUnknown", err)
+	}
+
+	if n := countRequests(srv, http.MethodPost, "/fapi/v1/order"); n != 1 {
+		t.Errorf("sent %d orders, want the unknown one never placed again", n)
+	}
+	if n := countRequests(srv, http.MethodGet, "/fapi/v1/order"); n < 2 {
+		t.Errorf("looked the order up %d times, want it retried", n)
+	}
+}
+
+func TestServerErrorOrderIsLookedUp(t *testing.T) {
+	client, srv := newTestClient(t)
+	binance.SetOrderLookup(t, 10*time.Millisecond, 100*time.Millisecond)
+	srv.InjectRateLimit(http.MethodPost, "/fapi/v1/order", 1, http.StatusServiceUnavailable, 0)
+
+	_, err := client.PlaceOrder(entryOrder())
+	if !errors.Is(err, binance.ErrOrderUnknown) {
+		t.Fatalf("PlaceOrder error = %v, want ErrOrderUnknown", err)
+	}
+	if n := countRequests(srv, http.MethodGet, "/fapi/v1/order"); n == 0 {
+		t.Error("the order was not looked up")
+	}
+}
+
+func TestOrderOutcomeIsUnknownOnlyWithoutAnAnswer(t *testing.T) {
+	binance.SetOrderLookup(t, 10*time.Millisecond, 50*time.Millisecond)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		unknown bool
+	}{
+		{"proxy rejection", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "<html>Forbidden</html>", http.StatusForbidden)
+		}, false},
+		{"gateway error", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "<html>Bad Gateway</html>", http.StatusBadGateway)
+		}, true},
+		{"connection reset", func(w http.ResponseWriter, r *http.Request) {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lookups atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					lookups.Add(1)
+				}
+				tt.handler(w, r)
+			}))
+			t.Cleanup(srv.Close)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			client := binance.NewClientWithConfig("key", "secret", srv.URL, "", logger)
+
+			_, err := client.PlaceOrder(entryOrder())
+			if err == nil {
+				t.Fatal("PlaceOrder succeeded")
+			}
+			if unknown := errors.Is(err, binance.ErrOrderUnknown); unknown != tt.unknown {
+				t.Errorf("PlaceOrder error = %v, want unknown status %v", err, tt.unknown)
+			}
+			if looked := lookups.Load() > 0; looked != tt.unknown {
+				t.Errorf("looked the order up %d times, want a lookup %v", lookups.Load(), tt.unknown)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package binance
+
+import (
+	"testing"
+	"time"
+)
+
+// SetOrderLookup shortens the lookup of orders with an unknown status for
+// the duration of a test
+func SetOrderLookup(t *testing.T, delay, window time.Duration) {
+	oldDelay, oldWindow := orderLookupDelay, orderLookupWindow
+	orderLookupDelay, orderLookupWindow = delay, window
+	t.Cleanup(func() {
+		orderLookupDelay, orderLookupWindow = oldDelay, oldWindow
+	})
+}
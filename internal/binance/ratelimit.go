@@ -0,0 +1,256 @@
+package binance
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Binance Futures rate limits
+const (
+	WeightLimit1m  = 2400 // Request weight per minute, per IP
+	OrderLimit10s  = 300  // Orders per 10 seconds, per account
+	OrderLimit1m   = 1200 // Orders per minute, per account
+	throttleRatio  = 0.9  // Share of a limit after which requests wait for the next window
+	maxRetries     = 3
+	retryBaseDelay = 250 * time.Millisecond
+	maxRetryDelay  = 5 * time.Second
+	maxBlockWait   = 30 * time.Second // Longer Retry-After waits fail requests instead of waiting
+)
+
+// RateLimitStats is a client's view of its rate limits: the weight used from
+// its IP, the orders placed by its account and how often limits were hit
+type RateLimitStats struct {
+	UsedWeight1m  int       `json:"used_weight_1m"`
+	WeightLimit1m int       `json:"weight_limit_1m"`
+	OrderCount10s int       `json:"order_count_10s"`
+	OrderCount1m  int       `json:"order_count_1m"`
+	Throttled     int64     `json:"throttled"`    // Requests delayed to stay under a limit
+	Retries       int64     `json:"retries"`      // Idempotent requests retried
+	RateLimited   int64     `json:"rate_limited"` // 429 responses
+	Banned        int64     `json:"banned"`       // 418 responses, the IP was banned
+	BlockedUntil  time.Time `json:"blocked_until"`
+}
+
+// weightTracker tracks the request weight used from an IP. It is shared by
+// every client of a base URL, so accounts fanned out on one IP throttle
+// together.
+type weightTracker struct {
+	mu           sync.Mutex
+	usedWeight   int
+	window       time.Time // Minute the used weight was reported in
+	blockedUntil time.Time // Set by 429 and 418 responses
+	throttled    int64
+	retries      int64
+	rateLimited  int64
+	banned       int64
+}
+
+// errBlocked fails requests that were not sent because of a rate limit block
+var errBlocked = errors.New("binance requests are blocked by a rate limit")
+
+var (
+	weightTrackersMu sync.Mutex
+	weightTrackers   = make(map[string]*weightTracker)
+)
+
+// weightTrackerFor returns the shared weight tracker of a base URL
+func weightTrackerFor(baseURL string) *weightTracker {
+	weightTrackersMu.Lock()
+	defer weightTrackersMu.Unlock()
+
+	tracker, exists := weightTrackers[baseURL]
+	if !exists {
+		tracker = &weightTracker{}
+		weightTrackers[baseURL] = tracker
+	}
+	return tracker
+}
+
+// wait delays a request until the IP is under its weight limit and not
+// blocked. Blocks longer than maxBlockWait fail the request.
+func (t *weightTracker) wait() error {
+	t.mu.Lock()
+	now := time.Now()
+	var delay time.Duration
+	if now.Before(t.blockedUntil) {
+		delay = t.blockedUntil.Sub(now)
+		if delay > maxBlockWait {
+			until := t.blockedUntil
+			t.mu.Unlock()
+			return fmt.Errorf("%w until %s", errBlocked, until.Format(time.RFC3339))
+		}
+	} else if t.usedWeight >= int(WeightLimit1m*throttleRatio) && now.Truncate(time.Minute).Equal(t.window) {
+		delay = t.window.Add(time.Minute).Sub(now)
+	}
+	if delay > 0 {
+		t.throttled++
+	}
+	t.mu.Unlock()
+
+	time.Sleep(delay)
+	return nil
+}
+
+// update records the rate limit headers and status of a response
+func (t *weightTracker) update(resp *http.Response) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if weight, err := strconv.Atoi(resp.Header.Get("X-MBX-USED-WEIGHT-1M")); err == nil {
+		t.usedWeight = weight
+		t.window = time.Now().Truncate(time.Minute)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		t.rateLimited++
+	case http.StatusTeapot:
+		t.banned++
+	default:
+		return
+	}
+
+	// Every client on the IP backs off until Retry-After, a minute when unset
+	until := time.Now().Add(retryAfter(resp, time.Minute))
+	if until.After(t.blockedUntil) {
+		t.blockedUntil = until
+	}
+}
+
+// orderTracker tracks the orders an account placed, from the order count
+// headers of its responses
+type orderTracker struct {
+	mu        sync.Mutex
+	count10s  int
+	window10s time.Time
+	count1m   int
+	window1m  time.Time
+	throttled int64
+}
+
+// wait delays an order until the account is under its order limits
+func (t *orderTracker) wait() {
+	t.mu.Lock()
+	now := time.Now()
+	var delay time.Duration
+	if t.count10s >= int(OrderLimit10s*throttleRatio) && now.Truncate(10*time.Second).Equal(t.window10s) {
+		delay = t.window10s.Add(10 * time.Second).Sub(now)
+	}
+	if t.count1m >= int(OrderLimit1m*throttleRatio) && now.Truncate(time.Minute).Equal(t.window1m) {
+		delay = max(delay, t.window1m.Add(time.Minute).Sub(now))
+	}
+	if delay > 0 {
+		t.throttled++
+	}
+	t.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+// update records the order count headers of a response
+func (t *orderTracker) update(resp *http.Response) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if count, err := strconv.Atoi(resp.Header.Get("X-MBX-ORDER-COUNT-10S")); err == nil {
+		t.count10s = count
+		t.window10s = now.Truncate(10 * time.Second)
+	}
+	if count, err := strconv.Atoi(resp.Header.Get("X-MBX-ORDER-COUNT-1M")); err == nil {
+		t.count1m = count
+		t.window1m = now.Truncate(time.Minute)
+	}
+}
+
+// retryAfter returns the Retry-After of a response in seconds, or def
+func retryAfter(resp *http.Response, def time.Duration) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return def
+}
+
+// retryDelay returns the jittered exponential backoff before a retry
+func retryDelay(attempt int) time.Duration {
+	delay := min(retryBaseDelay<<attempt, maxRetryDelay)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// isIdempotent reports whether a request can be retried safely: reads and
+// requests that set state to a given value. Orders are never retried.
+func isIdempotent(method, endpoint string) bool {
+	switch method {
+	case http.MethodGet, http.MethodPut:
+		return true
+	case http.MethodPost:
+		return endpoint == "/fapi/v1/leverage" || endpoint == "/fapi/v1/marginType"
+	case http.MethodDelete:
+		return endpoint == "/fapi/v1/allOpenOrders"
+	}
+	return false
+}
+
+// isUnknownStatus reports whether a failed request may have been executed:
+// its response was lost to a transport error or timeout, the exchange could
+// not tell, or it failed with a 5xx. Other rejections and requests that
+// were never sent were not executed.
+func isUnknownStatus(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == ErrCodeUnknownResponse || apiErr.Code == ErrCodeUnknownStatus ||
+			apiErr.StatusCode >= http.StatusInternalServerError
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	// The HTTP client's errors are net.Errors, a body cut short ends early
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// isNoSuchOrder reports whether a query failed because the order does not exist
+func isNoSuchOrder(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == ErrCodeNoSuchOrder
+}
+
+// RateLimitStats returns the client's rate limit usage
+func (c *Client) RateLimitStats() RateLimitStats {
+	stats := RateLimitStats{WeightLimit1m: WeightLimit1m}
+	now := time.Now()
+
+	c.weights.mu.Lock()
+	if now.Truncate(time.Minute).Equal(c.weights.window) {
+		stats.UsedWeight1m = c.weights.usedWeight
+	}
+	stats.Throttled = c.weights.throttled
+	stats.Retries = c.weights.retries
+	stats.RateLimited = c.weights.rateLimited
+	stats.Banned = c.weights.banned
+	if now.Before(c.weights.blockedUntil) {
+		stats.BlockedUntil = c.weights.blockedUntil
+	}
+	c.weights.mu.Unlock()
+
+	c.orders.mu.Lock()
+	if now.Truncate(10 * time.Second).Equal(c.orders.window10s) {
+		stats.OrderCount10s = c.orders.count10s
+	}
+	if now.Truncate(time.Minute).Equal(c.orders.window1m) {
+		stats.OrderCount1m = c.orders.count1m
+	}
+	stats.Throttled += c.orders.throttled
+	c.orders.mu.Unlock()
+
+	return stats
+}
@@ -0,0 +1,84 @@
+package binance_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"tdlib-go/internal/binance"
+	"tdlib-go/internal/binance/binancetest"
+)
+
+// newTestClient returns a client of a fake exchange trading BTCUSDT at 100
+func newTestClient(t *testing.T) (*binance.Client, *binancetest.Server) {
+	t.Helper()
+	srv := binancetest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.AddSymbol(binancetest.Symbol{Symbol: "BTCUSDT"})
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return binance.NewClientWithConfig("key", "secret", srv.URL, srv.WSURL(), logger), srv
+}
+
+// countRequests returns how many requests were sent to method and path
+func countRequests(srv *binancetest.Server, method, path string) int {
+	var count int
+	for _, req := range srv.Requests() {
+		if req.Method == method && req.Path == path {
+			count++
+		}
+	}
+	return count
+}
+
+func TestRateLimitedReadIsRetried(t *testing.T) {
+	client, srv := newTestClient(t)
+	srv.InjectRateLimit(http.MethodGet, "/fapi/v1/exchangeInfo", 1, http.StatusTooManyRequests, 1)
+
+	if _, err := client.GetExchangeInfo(); err != nil {
+		t.Fatalf("GetExchangeInfo: %v", err)
+	}
+
+	if n := countRequests(srv, http.MethodGet, "/fapi/v1/exchangeInfo"); n != 2 {
+		t.Errorf("sent %d requests, want the rate limited one retried once", n)
+	}
+	stats := client.RateLimitStats()
+	if stats.RateLimited != 1 || stats.Retries != 1 {
+		t.Errorf("stats = %d rate limited, %d retries, want 1 and 1", stats.RateLimited, stats.Retries)
+	}
+}
+
+func TestServerErrorOnReadIsRetried(t *testing.T) {
+	client, srv := newTestClient(t)
+	srv.InjectRateLimit(http.MethodGet, "/fapi/v1/ticker/price", 2, http.StatusServiceUnavailable, 0)
+
+	ticker, err := client.GetSymbolPriceTicker("BTCUSDT")
+	if err != nil {
+		t.Fatalf("GetSymbolPriceTicker: %v", err)
+	}
+	if ticker.Price != "100" {
+		t.Errorf("price = %s, want 100", ticker.Price)
+	}
+	if n := countRequests(srv, http.MethodGet, "/fapi/v1/ticker/price"); n != 3 {
+		t.Errorf("sent %d requests, want 3", n)
+	}
+}
+
+func TestRateLimitedOrderIsNotRetried(t *testing.T) {
+	client, srv := newTestClient(t)
+	srv.InjectRateLimit(http.MethodPost, "/fapi/v1/order", 1, http.StatusTooManyRequests, 1)
+
+	_, err := client.PlaceOrder(&binance.NewOrder{Symbol: "BTCUSDT", Side: "BUY", Type: "MARKET", Quantity: 1})
+	if err == nil {
+		t.Fatal("PlaceOrder succeeded, want the rate limit error")
+	}
+
+	if n := countRequests(srv, http.MethodPost, "/fapi/v1/order"); n != 1 {
+		t.Errorf("sent %d orders, want 1", n)
+	}
+	if orders := srv.Orders(); len(orders) != 0 {
+		t.Errorf("got %d orders, want none", len(orders))
+	}
+}
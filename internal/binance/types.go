@@ -1,9 +1,43 @@
 package binance
 
+import "fmt"
+
 // APIError represents a Binance API error response
 type APIError struct {
-	Code int    `json:"code"`
-	Msg  string `json:"msg"`
+	Code       int    `json:"code"`
+	Msg        string `json:"msg"`
+	StatusCode int    `json:"-"` // HTTP status of the response
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("binance API error [%d]: %s", e.Code, e.Msg)
+}
+
+// HTTPError is a failed response without an API error body, e.g. from a
+// proxy or the exchange's gateway
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// ErrCodeTimestamp is returned for signed requests whose timestamp is outside
+// the recvWindow of the server time
+const ErrCodeTimestamp = -1021
+
+// Error codes of requests whose outcome is unknown, and of a missing order
+const (
+	ErrCodeUnknownResponse = -1006 // Unexpected response from the message bus, execution status unknown
+	ErrCodeUnknownStatus   = -1007 // Timeout waiting for the backend, execution status unknown
+	ErrCodeNoSuchOrder     = -2013
+)
+
+// ServerTime is the exchange's current time
+type ServerTime struct {
+	ServerTime int64 `json:"serverTime"`
 }
 
 // ExchangeInfo represents exchange trading rules and symbol information
@@ -13,69 +47,137 @@ type ExchangeInfo struct {
 
 // SymbolInfo represents information about a trading symbol
 type SymbolInfo struct {
-	Symbol                string       `json:"symbol"`
-	Status                string       `json:"status"`
-	BaseAsset             string       `json:"baseAsset"`
-	QuoteAsset            string       `json:"quoteAsset"`
-	PricePrecision        int          `json:"pricePrecision"`
-	QuantityPrecision     int          `json:"quantityPrecision"`
-	BaseAssetPrecision    int          `json:"baseAssetPrecision"`
-	QuotePrecision        int          `json:"quotePrecision"`
-	Filters               []FilterInfo `json:"filters"`
+	Symbol             string       `json:"symbol"`
+	Status             string       `json:"status"`
+	BaseAsset          string       `json:"baseAsset"`
+	QuoteAsset         string       `json:"quoteAsset"`
+	PricePrecision     int          `json:"pricePrecision"`
+	QuantityPrecision  int          `json:"quantityPrecision"`
+	BaseAssetPrecision int          `json:"baseAssetPrecision"`
+	QuotePrecision     int          `json:"quotePrecision"`
+	Filters            []FilterInfo `json:"filters"`
 }
 
 // FilterInfo represents a filter on a symbol
 type FilterInfo struct {
-	FilterType  string  `json:"filterType"`
-	MinPrice    string  `json:"minPrice,omitempty"`
-	MaxPrice    string  `json:"maxPrice,omitempty"`
-	TickSize    string  `json:"tickSize,omitempty"`
-	MinQty      string  `json:"minQty,omitempty"`
-	MaxQty      string  `json:"maxQty,omitempty"`
-	StepSize    string  `json:"stepSize,omitempty"`
-	Notional    string  `json:"notional,omitempty"` // Used by MIN_NOTIONAL filter
-	MinNotional string  `json:"minNotional,omitempty"` // Fallback field name
+	FilterType  string `json:"filterType"`
+	MinPrice    string `json:"minPrice,omitempty"`
+	MaxPrice    string `json:"maxPrice,omitempty"`
+	TickSize    string `json:"tickSize,omitempty"`
+	MinQty      string `json:"minQty,omitempty"`
+	MaxQty      string `json:"maxQty,omitempty"`
+	StepSize    string `json:"stepSize,omitempty"`
+	Notional    string `json:"notional,omitempty"`    // Used by MIN_NOTIONAL filter
+	MinNotional string `json:"minNotional,omitempty"` // Fallback field name
+}
+
+// LeverageBracket is a notional tier of a symbol's leverage brackets
+type LeverageBracket struct {
+	Bracket          int     `json:"bracket"`
+	InitialLeverage  int     `json:"initialLeverage"`
+	NotionalCap      float64 `json:"notionalCap"`
+	NotionalFloor    float64 `json:"notionalFloor"`
+	MaintMarginRatio float64 `json:"maintMarginRatio"`
+	Cum              float64 `json:"cum"`
+}
+
+// SymbolBrackets holds the leverage brackets of a symbol
+type SymbolBrackets struct {
+	Symbol   string            `json:"symbol"`
+	Brackets []LeverageBracket `json:"brackets"`
 }
 
 // PriceTicker represents a price ticker
 type PriceTicker struct {
-	Symbol string  `json:"symbol"`
-	Price  string  `json:"price"`
-	Time   int64   `json:"time"`
+	Symbol string `json:"symbol"`
+	Price  string `json:"price"`
+	Time   int64  `json:"time"`
+}
+
+// Kline is a candlestick of a symbol
+type Kline struct {
+	OpenTime  int64
+	Open      string
+	High      string
+	Low       string
+	Close     string
+	Volume    string
+	CloseTime int64
+}
+
+// BookTicker is the best bid and ask of a symbol
+type BookTicker struct {
+	Symbol   string `json:"symbol"`
+	BidPrice string `json:"bidPrice"`
+	BidQty   string `json:"bidQty"`
+	AskPrice string `json:"askPrice"`
+	AskQty   string `json:"askQty"`
+	Time     int64  `json:"time"`
 }
 
 // NewOrder represents a new order request
 type NewOrder struct {
 	Symbol           string
-	Side             string  // BUY or SELL
-	Type             string  // MARKET, LIMIT, STOP_MARKET, TAKE_PROFIT_MARKET
+	Side             string // BUY or SELL
+	Type             string // MARKET, LIMIT, STOP_MARKET, TAKE_PROFIT_MARKET
 	Quantity         float64
 	Price            float64
 	StopPrice        float64
-	TimeInForce      string  // GTC, IOC, FOK
+	TimeInForce      string // GTC, IOC, FOK, GTX (post-only)
 	ReduceOnly       bool
+	ClosePosition    bool // Stop orders close the whole position, Quantity and ReduceOnly are not sent
 	NewClientOrderID string
 }
 
 // OrderResponse represents an order response from Binance
 type OrderResponse struct {
-	OrderID       int64   `json:"orderId"`
-	Symbol        string  `json:"symbol"`
-	Status        string  `json:"status"`
-	ClientOrderID string  `json:"clientOrderId"`
-	Price         string  `json:"price"`
-	AvgPrice      string  `json:"avgPrice"`
-	OrigQty       string  `json:"origQty"`
-	ExecutedQty   string  `json:"executedQty"`
-	CumQty        string  `json:"cumQty"`
-	CumQuote      string  `json:"cumQuote"`
-	TimeInForce   string  `json:"timeInForce"`
-	Type          string  `json:"type"`
-	ReduceOnly    bool    `json:"reduceOnly"`
-	Side          string  `json:"side"`
-	StopPrice     string  `json:"stopPrice"`
-	WorkingType   string  `json:"workingType"`
-	UpdateTime    int64   `json:"updateTime"`
+	OrderID       int64  `json:"orderId"`
+	Symbol        string `json:"symbol"`
+	Status        string `json:"status"`
+	ClientOrderID string `json:"clientOrderId"`
+	Price         string `json:"price"`
+	AvgPrice      string `json:"avgPrice"`
+	OrigQty       string `json:"origQty"`
+	ExecutedQty   string `json:"executedQty"`
+	CumQty        string `json:"cumQty"`
+	CumQuote      string `json:"cumQuote"`
+	TimeInForce   string `json:"timeInForce"`
+	Type          string `json:"type"`
+	ReduceOnly    bool   `json:"reduceOnly"`
+	Side          string `json:"side"`
+	StopPrice     string `json:"stopPrice"`
+	WorkingType   string `json:"workingType"`
+	UpdateTime    int64  `json:"updateTime"`
+}
+
+// UserTrade represents a fill from the account trade list
+type UserTrade struct {
+	ID              int64  `json:"id"`
+	OrderID         int64  `json:"orderId"`
+	Symbol          string `json:"symbol"`
+	Side            string `json:"side"`
+	Price           string `json:"price"`
+	Qty             string `json:"qty"`
+	QuoteQty        string `json:"quoteQty"`
+	RealizedPnl     string `json:"realizedPnl"`
+	Commission      string `json:"commission"`
+	CommissionAsset string `json:"commissionAsset"`
+	Buyer           bool   `json:"buyer"`
+	Maker           bool   `json:"maker"`
+	PositionSide    string `json:"positionSide"`
+	Time            int64  `json:"time"`
+}
+
+// Income is an entry of the account's income history
+type Income struct {
+	Symbol     string `json:"symbol"`
+	IncomeType string `json:"incomeType"`
+	Income     string `json:"income"`
+	Asset      string `json:"asset"`
+	Info       string `json:"info"`
+	Time       int64  `json:"time"`
+	TranID     int64  `json:"tranId"`
+	TradeID    string `json:"tradeId"`
 }
 
 // AccountInfo represents account information
@@ -154,20 +256,20 @@ type OrderUpdate struct {
 	EventType string `json:"e"`
 	EventTime int64  `json:"E"`
 	Order     struct {
-		Symbol        string `json:"s"`
-		ClientOrderID string `json:"c"`
-		Side          string `json:"S"`
-		Type          string `json:"o"`
-		TimeInForce   string `json:"f"`
-		OrigQty       string `json:"q"`
-		Price         string `json:"p"`
-		AvgPrice      string `json:"ap"`
-		StopPrice     string `json:"sp"`
-		ExecutionType string `json:"x"`
-		OrderStatus   string `json:"X"`
-		OrderID       int64  `json:"i"`
-		LastFilledQty string `json:"l"`
-		FilledQty     string `json:"z"`
+		Symbol          string `json:"s"`
+		ClientOrderID   string `json:"c"`
+		Side            string `json:"S"`
+		Type            string `json:"o"`
+		TimeInForce     string `json:"f"`
+		OrigQty         string `json:"q"`
+		Price           string `json:"p"`
+		AvgPrice        string `json:"ap"`
+		StopPrice       string `json:"sp"`
+		ExecutionType   string `json:"x"`
+		OrderStatus     string `json:"X"`
+		OrderID         int64  `json:"i"`
+		LastFilledQty   string `json:"l"`
+		FilledQty       string `json:"z"`
 		LastFilledPrice string `json:"L"`
 		CommissionAsset string `json:"N"`
 		Commission      string `json:"n"`
@@ -189,9 +291,9 @@ type AccountUpdate struct {
 	EventType  string `json:"e"`
 	EventTime  int64  `json:"E"`
 	UpdateData struct {
-		Reason    string            `json:"m"`
-		Balances  []BalanceUpdate   `json:"B"`
-		Positions []PositionUpdate  `json:"P"`
+		Reason    string           `json:"m"`
+		Balances  []BalanceUpdate  `json:"B"`
+		Positions []PositionUpdate `json:"P"`
 	} `json:"a"`
 }
 
@@ -205,12 +307,12 @@ type BalanceUpdate struct {
 
 // PositionUpdate represents a position update
 type PositionUpdate struct {
-	Symbol                    string `json:"s"`
-	PositionAmount            string `json:"pa"`
-	EntryPrice                string `json:"ep"`
-	AccumulatedRealized       string `json:"cr"`
-	UnrealizedPnL             string `json:"up"`
-	MarginType                string `json:"mt"`
-	IsolatedWallet            string `json:"iw"`
-	PositionSide              string `json:"ps"`
+	Symbol              string `json:"s"`
+	PositionAmount      string `json:"pa"`
+	EntryPrice          string `json:"ep"`
+	AccumulatedRealized string `json:"cr"`
+	UnrealizedPnL       string `json:"up"`
+	MarginType          string `json:"mt"`
+	IsolatedWallet      string `json:"iw"`
+	PositionSide        string `json:"ps"`
 }
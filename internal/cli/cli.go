@@ -6,14 +6,19 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"tdlib-go/internal/telegram"
+	"tdlib-go/internal/trading"
+	"tdlib-go/pkg/models"
 )
 
 // CLI provides a command-line interface for the application
 type CLI struct {
 	monitor *telegram.Monitor
+	auth    *telegram.Authenticator
+	engine  *trading.Engine
 	logger  *logrus.Logger
 	scanner *bufio.Scanner
 }
@@ -27,6 +32,16 @@ func NewCLI(monitor *telegram.Monitor, logger *logrus.Logger) *CLI {
 	}
 }
 
+// SetAuthenticator enables the Telegram login commands
+func (c *CLI) SetAuthenticator(auth *telegram.Authenticator) {
+	c.auth = auth
+}
+
+// SetTradingEngine enables the emergency halt commands
+func (c *CLI) SetTradingEngine(engine *trading.Engine) {
+	c.engine = engine
+}
+
 // Start starts the interactive CLI
 func (c *CLI) Start() {
 	c.printWelcome()
@@ -72,6 +87,12 @@ func (c *CLI) handleCommand(command string, args []string) error {
 		return c.fetchHistory(args)
 	case "status":
 		c.showStatus()
+	case "phone", "code", "password", "register":
+		return c.submitAuth(command, args)
+	case "halt":
+		return c.halt(args)
+	case "resume":
+		return c.resume()
 	case "quit", "exit":
 		fmt.Println("Exiting...")
 		os.Exit(0)
@@ -98,8 +119,21 @@ func (c *CLI) printHelp() {
 	fmt.Println("  add <identifier>              - Add/subscribe to a channel")
 	fmt.Println("                                  Supports: username, channel ID, or invite link")
 	fmt.Println("  remove <channel_id>           - Remove/unsubscribe from a channel")
-	fmt.Println("  history <channel_id> <limit>  - Fetch historical messages")
+	fmt.Println("  history <channel_id> <limit> [since]")
+	fmt.Println("                                - Import history and record signals (not executed)")
+	fmt.Println("                                  limit 0 imports everything since YYYY-MM-DD")
 	fmt.Println("  status                        - Show connection status")
+	if c.auth != nil {
+		fmt.Println("  phone <number>                - Submit the phone number to log in with")
+		fmt.Println("  code <code>                   - Submit the login code sent by Telegram")
+		fmt.Println("  password <password>           - Submit the 2FA password")
+		fmt.Println("  register <first> [last]       - Register a new account with this name")
+	}
+	if c.engine != nil {
+		fmt.Println("  halt [reason]                 - EMERGENCY: stop trading, cancel all orders and")
+		fmt.Println("                                  close all positions on all active accounts")
+		fmt.Println("  resume                        - Lift the emergency halt")
+	}
 	fmt.Println("  quit, exit                    - Exit the application")
 	fmt.Println("\nExamples:")
 	fmt.Println("  add telegram                              (username)")
@@ -107,6 +141,7 @@ func (c *CLI) printHelp() {
 	fmt.Println("  add https://t.me/+wIr66-O-XaxjOWI0        (invite link)")
 	fmt.Println("  remove 1234567890")
 	fmt.Println("  history 1234567890 50")
+	fmt.Println("  history 1234567890 0 2024-01-01")
 }
 
 // listChannels lists all monitored channels
@@ -128,8 +163,8 @@ func (c *CLI) listChannels() {
 		}
 
 		fmt.Printf("%d. %s\n", i+1, ch.Title)
-		fmt.Printf("   ID: %d | Username: %s | Status: %s\n",
-			ch.ChannelID, ch.Username, status)
+		fmt.Printf("   ID: %d | Username: %s | Status: %s | Last Message: %d\n",
+			ch.ChannelID, ch.Username, status, ch.LastMessageID)
 		fmt.Printf("   Subscribed: %s\n", ch.CreatedAt.Format("2006-01-02 15:04:05"))
 	}
 	fmt.Println("─────────────────────────────────────────────────────────")
@@ -203,7 +238,7 @@ func (c *CLI) addChannel(args []string) error {
 
 				if channelID != 0 {
 					fmt.Printf("Fetching %d messages...\n", limit)
-					if err := c.monitor.FetchHistory(channelID, int32(limit)); err != nil {
+					if _, err := c.monitor.FetchHistory(channelID, models.HistoryOptions{Limit: limit}); err != nil {
 						return fmt.Errorf("failed to fetch history: %w", err)
 					}
 					fmt.Println("✓ History fetched successfully")
@@ -239,10 +274,10 @@ func (c *CLI) removeChannel(args []string) error {
 	return nil
 }
 
-// fetchHistory fetches historical messages from a channel
+// fetchHistory imports historical messages from a channel
 func (c *CLI) fetchHistory(args []string) error {
 	if len(args) < 2 {
-		return fmt.Errorf("usage: history <channel_id> <limit>")
+		return fmt.Errorf("usage: history <channel_id> <limit> [since YYYY-MM-DD]")
 	}
 
 	channelID, err := strconv.ParseInt(args[0], 10, 64)
@@ -251,17 +286,38 @@ func (c *CLI) fetchHistory(args []string) error {
 	}
 
 	limit, err := strconv.Atoi(args[1])
-	if err != nil {
-		return fmt.Errorf("invalid limit: %w", err)
+	if err != nil || limit < 0 {
+		return fmt.Errorf("invalid limit: %s", args[1])
 	}
 
-	fmt.Printf("Fetching %d messages from channel %d...\n", limit, channelID)
+	opts := models.HistoryOptions{Limit: limit}
+	if len(args) > 2 {
+		since, err := time.ParseInLocation("2006-01-02", args[2], time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid since date (expected YYYY-MM-DD): %w", err)
+		}
+		opts.Since = since
+	}
+	if opts.Limit == 0 && opts.Since.IsZero() {
+		return fmt.Errorf("limit 0 (no limit) requires a since date")
+	}
+
+	opts.Progress = func(p *models.HistoryProgress) {
+		if p.Status == models.HistoryRunning {
+			fmt.Printf("  %d messages fetched, %d signals (back to %s)\n",
+				p.Fetched, p.Signals, p.OldestMessageAt.Format("2006-01-02 15:04"))
+		}
+	}
 
-	if err := c.monitor.FetchHistory(channelID, int32(limit)); err != nil {
+	fmt.Printf("Importing history from channel %d...\n", channelID)
+
+	progress, err := c.monitor.FetchHistory(channelID, opts)
+	if err != nil {
 		return fmt.Errorf("failed to fetch history: %w", err)
 	}
 
-	fmt.Println("✓ History fetched successfully")
+	fmt.Printf("✓ History imported: %d messages fetched, %d saved, %d signals recorded\n",
+		progress.Fetched, progress.Saved, progress.Signals)
 
 	return nil
 }
@@ -275,5 +331,86 @@ func (c *CLI) showStatus() {
 	fmt.Println("╚═══════════════════════════════════════════════════════╝")
 	fmt.Printf("  Monitored Channels: %d\n", len(channels))
 	fmt.Println("  Status: Running")
+	if c.auth != nil {
+		authStatus := c.auth.Status()
+		fmt.Printf("  Telegram Login: %s\n", authStatus.State)
+		if authStatus.LastError != "" {
+			fmt.Printf("  Last Login Error: %s\n", authStatus.LastError)
+		}
+	}
+	if c.engine != nil {
+		if state, err := c.engine.HaltStatus(); err == nil && state.Halted {
+			fmt.Printf("  Trading: HALTED since %s (%s)\n", state.HaltedAt.Format("2006-01-02 15:04:05"), state.Reason)
+		} else if err == nil {
+			fmt.Println("  Trading: Active")
+		}
+	}
 	fmt.Println("─────────────────────────────────────────────────────────")
 }
+
+// submitAuth submits a Telegram login step
+func (c *CLI) submitAuth(command string, args []string) error {
+	if c.auth == nil {
+		return fmt.Errorf("telegram login is not used by this message source")
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s <value>", command)
+	}
+
+	var err error
+	switch command {
+	case "phone":
+		err = c.auth.SubmitPhoneNumber(args[0])
+	case "code":
+		err = c.auth.SubmitCode(args[0])
+	case "password":
+		// Passwords may contain spaces
+		err = c.auth.SubmitPassword(strings.Join(args, " "))
+	case "register":
+		err = c.auth.SubmitRegistration(args[0], strings.Join(args[1:], " "))
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("✓ Submitted, check the log for the result")
+	return nil
+}
+
+// halt halts trading and flattens all active accounts
+func (c *CLI) halt(args []string) error {
+	if c.engine == nil {
+		return fmt.Errorf("trading is not available")
+	}
+
+	result, err := c.engine.Halt(strings.Join(args, " "), nil)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Trading halted: %s\n", result.State.Reason)
+	for _, account := range result.Accounts {
+		fmt.Printf("  %s: %d orders canceled, %d positions closed\n",
+			account.AccountName, account.CanceledOrders, len(account.ClosedPositions))
+		if account.Error != "" {
+			fmt.Printf("    Error: %s\n", account.Error)
+		}
+	}
+	fmt.Println("Type 'resume' to resume trading")
+
+	return nil
+}
+
+// resume lifts the emergency halt
+func (c *CLI) resume() error {
+	if c.engine == nil {
+		return fmt.Errorf("trading is not available")
+	}
+
+	if _, err := c.engine.Resume(); err != nil {
+		return err
+	}
+
+	fmt.Println("✓ Trading resumed")
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,19 +15,94 @@ type Config struct {
 	Database DatabaseConfig `yaml:"database"`
 	TDLib    TDLibConfig    `yaml:"tdlib"`
 	Channels []string       `yaml:"channels"`
+	Archive  ArchiveConfig  `yaml:"archive"`
 	Logging  LoggingConfig  `yaml:"logging"`
 	Binance  BinanceConfig  `yaml:"binance"`
 	Trading  TradingConfig  `yaml:"trading"`
 	WebAPI   WebAPIConfig   `yaml:"webapi"`
+
+	Notifications NotificationsConfig `yaml:"notifications"`
 }
 
 // TelegramConfig contains Telegram API credentials
 type TelegramConfig struct {
-	APIID       int32  `yaml:"api_id"`
-	APIHash     string `yaml:"api_hash"`
-	PhoneNumber string `yaml:"phone_number,omitempty"`
-	BotToken    string `yaml:"bot_token,omitempty"`
-	UseTestDC   bool   `yaml:"use_test_dc"`
+	APIID       int32        `yaml:"api_id"`
+	APIHash     string       `yaml:"api_hash"`
+	PhoneNumber string       `yaml:"phone_number,omitempty"`
+	BotToken    string       `yaml:"bot_token,omitempty"`
+	UseTestDC   bool         `yaml:"use_test_dc"`
+	LoginMethod string       `yaml:"login_method"` // phone or qr (default phone)
+	BotAPI      BotAPIConfig `yaml:"bot_api"`
+
+	// Messages missed during a disconnect that are older than this many
+	// seconds are recorded as history instead of traded (default 300)
+	CatchUpMaxAge int `yaml:"catch_up_max_age"`
+}
+
+// CatchUpMaxAgeOrDefault returns the maximum age of a caught-up message
+// that is still processed live, defaulting to 5 minutes
+func (t *TelegramConfig) CatchUpMaxAgeOrDefault() time.Duration {
+	if t.CatchUpMaxAge <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(t.CatchUpMaxAge) * time.Second
+}
+
+// User account login methods
+const (
+	LoginPhone = "phone" // Phone number, code and optional 2FA password
+	LoginQR    = "qr"    // Scan a QR code with a logged-in Telegram app
+)
+
+// LoginMethodOrDefault returns the login method, defaulting to phone
+func (t *TelegramConfig) LoginMethodOrDefault() string {
+	if t.LoginMethod == LoginQR {
+		return LoginQR
+	}
+	return LoginPhone
+}
+
+// Bot ingestion modes
+const (
+	BotModeTDLib   = "tdlib"   // Log in as the bot through TDLib
+	BotModePolling = "polling" // Long-poll getUpdates on the HTTP Bot API
+	BotModeWebhook = "webhook" // Receive updates on an HTTP Bot API webhook
+)
+
+// BotAPIConfig contains settings for reading channel posts as a bot
+type BotAPIConfig struct {
+	Mode          string `yaml:"mode"`           // tdlib, polling, webhook (default tdlib)
+	BaseURL       string `yaml:"base_url"`       // Bot API server (default https://api.telegram.org)
+	PollTimeout   int    `yaml:"poll_timeout"`   // Long-poll timeout in seconds (default 30)
+	WebhookURL    string `yaml:"webhook_url"`    // Public HTTPS URL Telegram delivers updates to
+	WebhookListen string `yaml:"webhook_listen"` // Local address serving the webhook, e.g. ":8443"
+	WebhookSecret string `yaml:"webhook_secret"` // Secret token Telegram sends with each webhook request
+}
+
+// ModeOrDefault returns the bot ingestion mode, defaulting to tdlib
+func (b *BotAPIConfig) ModeOrDefault() string {
+	switch b.Mode {
+	case BotModePolling, BotModeWebhook:
+		return b.Mode
+	default:
+		return BotModeTDLib
+	}
+}
+
+// BaseURLOrDefault returns the Bot API server URL
+func (b *BotAPIConfig) BaseURLOrDefault() string {
+	if b.BaseURL == "" {
+		return "https://api.telegram.org"
+	}
+	return strings.TrimRight(b.BaseURL, "/")
+}
+
+// PollTimeoutOrDefault returns the long-poll timeout, defaulting to 30 seconds
+func (b *BotAPIConfig) PollTimeoutOrDefault() time.Duration {
+	if b.PollTimeout <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(b.PollTimeout) * time.Second
 }
 
 // DatabaseConfig contains database connection settings
@@ -48,6 +124,69 @@ type TDLibConfig struct {
 	AppVersion        string `yaml:"app_version"`
 }
 
+// Message archive modes
+const (
+	ArchiveAll     = "all"     // Store every message from monitored channels
+	ArchiveSignals = "signals" // Store only messages matching the signal pattern
+	ArchiveNone    = "none"    // Do not store messages
+)
+
+// ArchiveConfig contains message archival settings
+type ArchiveConfig struct {
+	Mode          string `yaml:"mode"`           // all, signals, none (default signals)
+	RetentionDays int    `yaml:"retention_days"` // Delete archived messages older than this (0 = keep forever)
+}
+
+// ModeOrDefault returns the archive mode, defaulting to signals
+func (a *ArchiveConfig) ModeOrDefault() string {
+	switch a.Mode {
+	case ArchiveAll, ArchiveNone:
+		return a.Mode
+	default:
+		return ArchiveSignals
+	}
+}
+
+// Retention returns the retention period, or zero when messages are kept forever
+func (a *ArchiveConfig) Retention() time.Duration {
+	if a.RetentionDays <= 0 {
+		return 0
+	}
+	return time.Duration(a.RetentionDays) * 24 * time.Hour
+}
+
+// NotificationsConfig contains settings for trade event messages sent to a Telegram chat
+type NotificationsConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	ChatID      int64             `yaml:"chat_id"`      // Chat that receives the notifications
+	Events      map[string]bool   `yaml:"events"`       // Per-event toggles, events not listed are sent
+	Templates   map[string]string `yaml:"templates"`    // Go text/template per event, overriding the defaults
+	RatePerMin  int               `yaml:"rate_per_min"` // Maximum messages per minute (default 20)
+	BatchWindow int               `yaml:"batch_window"` // Seconds to collect events into one message (default 3)
+}
+
+// EventEnabled reports whether notifications for an event type are sent
+func (n *NotificationsConfig) EventEnabled(eventType string) bool {
+	enabled, ok := n.Events[eventType]
+	return !ok || enabled
+}
+
+// RatePerMinOrDefault returns the message rate limit, defaulting to 20 per minute
+func (n *NotificationsConfig) RatePerMinOrDefault() int {
+	if n.RatePerMin <= 0 {
+		return 20
+	}
+	return n.RatePerMin
+}
+
+// BatchWindowOrDefault returns the batching window, defaulting to 3 seconds
+func (n *NotificationsConfig) BatchWindowOrDefault() time.Duration {
+	if n.BatchWindow <= 0 {
+		return 3 * time.Second
+	}
+	return time.Duration(n.BatchWindow) * time.Second
+}
+
 // LoggingConfig contains logging settings
 type LoggingConfig struct {
 	Level  string `yaml:"level"`  // debug, info, warn, error
@@ -56,30 +195,190 @@ type LoggingConfig struct {
 
 // BinanceConfig contains Binance global settings (accounts are in database)
 type BinanceConfig struct {
-	BaseURL   string `yaml:"base_url"`    // REST API base URL (optional)
-	WSBaseURL string `yaml:"ws_base_url"` // WebSocket base URL (optional)
+	BaseURL          string `yaml:"base_url"`           // REST API base URL (optional)
+	WSBaseURL        string `yaml:"ws_base_url"`        // WebSocket base URL (optional)
+	RecvWindow       int    `yaml:"recv_window"`        // Milliseconds a signed request stays valid (default 5000, max 60000)
+	TimeSyncInterval int    `yaml:"time_sync_interval"` // Seconds between server time syncs (default 300)
+}
+
+// TimeSync returns the server time sync interval, defaulting to 5 minutes
+func (b *BinanceConfig) TimeSync() time.Duration {
+	if b.TimeSyncInterval <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(b.TimeSyncInterval) * time.Second
 }
 
 // TradingConfig contains trading parameters
 type TradingConfig struct {
-	Enabled          bool     `yaml:"enabled"`
-	Leverage         int      `yaml:"leverage"`
-	OrderAmount      float64  `yaml:"order_amount"`       // Position size in USDT
-	TargetPercent    float64  `yaml:"target_percent"`     // Take profit percentage (e.g., 0.02 for 2%)
-	StopLossPercent  float64  `yaml:"stoploss_percent"`   // Stop loss percentage (e.g., 0.01 for 1%)
-	OrderTimeout     int      `yaml:"order_timeout"`      // Timeout in seconds for TP/SL orders
-	SignalPattern    string   `yaml:"signal_pattern"`     // Regex pattern for signal matching
-	MaxPositions     int      `yaml:"max_positions"`      // Maximum concurrent positions
-	DryRun           bool     `yaml:"dry_run"`            // If true, don't execute real orders
-	IgnoreTokens     []string `yaml:"ignore_tokens"`      // List of tokens to ignore (symbols without USDT suffix)
+	Enabled         bool     `yaml:"enabled"`
+	Leverage        int      `yaml:"leverage"`
+	OrderAmount     float64  `yaml:"order_amount"`     // Position size in USDT
+	TargetPercent   float64  `yaml:"target_percent"`   // Take profit percentage (e.g., 0.02 for 2%)
+	StopLossPercent float64  `yaml:"stoploss_percent"` // Stop loss percentage (e.g., 0.01 for 1%)
+	OrderTimeout    int      `yaml:"order_timeout"`    // Timeout in seconds for TP/SL orders
+	SignalPattern   string   `yaml:"signal_pattern"`   // Regex pattern for signal matching
+	MaxPositions    int      `yaml:"max_positions"`    // Maximum concurrent positions
+	DryRun          bool     `yaml:"dry_run"`          // If true, don't execute real orders
+	IgnoreTokens    []string `yaml:"ignore_tokens"`    // List of tokens to ignore (symbols without USDT suffix)
+
+	ChannelScoring ChannelScoringConfig `yaml:"channel_scoring"`
+	IncomeSync     IncomeSyncConfig     `yaml:"income_sync"`
+	RiskBreaker    RiskBreakerConfig    `yaml:"risk_breaker"`
+	PriceGuard     PriceGuardConfig     `yaml:"price_guard"`
+	Protection     ProtectionConfig     `yaml:"protection"`
+}
+
+// ChannelScoringConfig contains channel leaderboard and auto-disable settings
+type ChannelScoringConfig struct {
+	WindowDays      int     `yaml:"window_days"`      // Rolling window for scoring (default 30)
+	MinTrades       int     `yaml:"min_trades"`       // Trades needed before a score is fully trusted (default 10)
+	SlippagePercent float64 `yaml:"slippage_percent"` // Assumed slippage per leg (e.g., 0.001 for 0.1%)
+	AutoDisable     bool    `yaml:"auto_disable"`     // Disable trading (not monitoring) for low-scoring channels
+	MinScore        float64 `yaml:"min_score"`        // Score threshold for auto-disable
+	CheckInterval   int     `yaml:"check_interval"`   // Seconds between auto-disable checks (default 3600)
+}
+
+// Window returns the scoring window, defaulting to 30 days
+func (c *ChannelScoringConfig) Window() time.Duration {
+	days := c.WindowDays
+	if days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// MinTradesOrDefault returns the minimum trade count, defaulting to 10
+func (c *ChannelScoringConfig) MinTradesOrDefault() int {
+	if c.MinTrades <= 0 {
+		return 10
+	}
+	return c.MinTrades
+}
+
+// Interval returns the auto-disable check interval, defaulting to one hour
+func (c *ChannelScoringConfig) Interval() time.Duration {
+	if c.CheckInterval <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.CheckInterval) * time.Second
+}
+
+// IncomeSyncConfig contains settings for pulling the Binance income history
+type IncomeSyncConfig struct {
+	Interval     int `yaml:"interval"`      // Seconds between syncs (default 900)
+	BackfillDays int `yaml:"backfill_days"` // History fetched on the first sync (default 30, Binance keeps 90)
+}
+
+// SyncInterval returns the income sync interval, defaulting to 15 minutes
+func (c *IncomeSyncConfig) SyncInterval() time.Duration {
+	if c.Interval <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(c.Interval) * time.Second
+}
+
+// Backfill returns how far back the first sync reaches, defaulting to 30 days
+func (c *IncomeSyncConfig) Backfill() time.Duration {
+	days := c.BackfillDays
+	if days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// RiskBreakerConfig contains settings for the per-account circuit breakers.
+// The limits themselves are set on each account.
+type RiskBreakerConfig struct {
+	ResetHour     int  `yaml:"reset_hour"`     // UTC hour the daily counters and tripped breakers reset (default 0)
+	Flatten       bool `yaml:"flatten"`        // Close the account's positions when its breaker trips
+	CheckInterval int  `yaml:"check_interval"` // Seconds between breaker checks (default 60)
+}
+
+// WindowStart returns the last reset at or before now
+func (c *RiskBreakerConfig) WindowStart(now time.Time) time.Time {
+	hour := c.ResetHour
+	if hour < 0 || hour > 23 {
+		hour = 0
+	}
+	now = now.UTC()
+	start := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, time.UTC)
+	if start.After(now) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start
+}
+
+// Interval returns the breaker check interval, defaulting to one minute
+func (c *RiskBreakerConfig) Interval() time.Duration {
+	if c.CheckInterval <= 0 {
+		return time.Minute
+	}
+	return time.Duration(c.CheckInterval) * time.Second
+}
+
+// Price guard actions
+const (
+	PriceGuardSkip     = "skip"     // Reject the signal
+	PriceGuardDownsize = "downsize" // Shrink the position by how far the price ran
+)
+
+// PriceGuardConfig contains the checks a signal passes before its entry, so
+// stale signals and pumps that already happened are not chased
+type PriceGuardConfig struct {
+	MaxMessageAge int     `yaml:"max_message_age"` // Seconds after which a signal's message is too old to trade (0 disables)
+	MaxMove       float64 `yaml:"max_move"`        // Price move in the trade's direction since the message or from the signal's entry price (e.g., 0.03 for 3%, 0 disables)
+	Action        string  `yaml:"action"`          // skip (default) or downsize when the move exceeds max_move
+}
+
+// MaxAge returns the max message age, 0 when disabled
+func (c *PriceGuardConfig) MaxAge() time.Duration {
+	if c.MaxMessageAge <= 0 {
+		return 0
+	}
+	return time.Duration(c.MaxMessageAge) * time.Second
+}
+
+// ProtectionConfig contains how a filled entry gets its take profit and stop
+// loss. A position whose protection cannot be placed is flattened.
+type ProtectionConfig struct {
+	ClosePosition bool `yaml:"close_position"` // TP/SL close the whole position (closePosition=true) instead of the filled quantity
+	Retries       int  `yaml:"retries"`        // Retries of a failed TP/SL order before flattening (default 2)
+	FillTimeout   int  `yaml:"fill_timeout"`   // Seconds to wait for a market entry to report its fill (default 10)
+}
+
+// Attempts returns how many times a TP/SL order is placed before giving up
+func (c *ProtectionConfig) Attempts() int {
+	if c.Retries <= 0 {
+		return 3
+	}
+	return c.Retries + 1
+}
+
+// FillWait returns how long a market entry may take to fill, defaulting to 10 seconds
+func (c *ProtectionConfig) FillWait() time.Duration {
+	if c.FillTimeout <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(c.FillTimeout) * time.Second
 }
 
 // WebAPIConfig contains web API server settings
 type WebAPIConfig struct {
-	Enabled bool   `yaml:"enabled"`
-	Host    string `yaml:"host"`
-	Port    int    `yaml:"port"`
+	Enabled     bool     `yaml:"enabled"`
+	Host        string   `yaml:"host"`
+	Port        int      `yaml:"port"`
 	CORSOrigins []string `yaml:"cors_origins"`
+	AuthToken   string   `yaml:"auth_token"`   // Bearer token for protected endpoints (disabled when empty)
+	IngestToken string   `yaml:"ingest_token"` // Token for /api/signals/ingest, defaults to auth_token
+}
+
+// IngestTokenOrDefault returns the signal ingest token, falling back to the auth token
+func (w *WebAPIConfig) IngestTokenOrDefault() string {
+	if w.IngestToken == "" {
+		return w.AuthToken
+	}
+	return w.IngestToken
 }
 
 // Load reads and parses the configuration file
@@ -104,23 +403,49 @@ func Load(path string) (*Config, error) {
 
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
-	if c.Telegram.APIID == 0 {
-		return fmt.Errorf("telegram.api_id is required")
+	switch c.Telegram.LoginMethod {
+	case "", LoginPhone, LoginQR:
+	default:
+		return fmt.Errorf("telegram.login_method must be one of phone, qr")
 	}
-	if c.Telegram.APIHash == "" {
-		return fmt.Errorf("telegram.api_hash is required")
+	switch c.Telegram.BotAPI.Mode {
+	case "", BotModeTDLib, BotModePolling:
+	case BotModeWebhook:
+		if c.Telegram.BotAPI.WebhookURL == "" || c.Telegram.BotAPI.WebhookListen == "" {
+			return fmt.Errorf("telegram.bot_api.webhook_url and webhook_listen are required in webhook mode")
+		}
+	default:
+		return fmt.Errorf("telegram.bot_api.mode must be one of tdlib, polling, webhook")
 	}
-	if c.Telegram.PhoneNumber == "" && c.Telegram.BotToken == "" {
-		return fmt.Errorf("either telegram.phone_number or telegram.bot_token must be provided")
+	if c.UsesTDLib() {
+		if c.Telegram.APIID == 0 {
+			return fmt.Errorf("telegram.api_id is required")
+		}
+		if c.Telegram.APIHash == "" {
+			return fmt.Errorf("telegram.api_hash is required")
+		}
+		if c.TDLib.DatabaseDirectory == "" {
+			return fmt.Errorf("tdlib.database_directory is required")
+		}
+		if c.TDLib.FilesDirectory == "" {
+			return fmt.Errorf("tdlib.files_directory is required")
+		}
 	}
 	if c.Database.DSN == "" {
 		return fmt.Errorf("database.dsn is required")
 	}
-	if c.TDLib.DatabaseDirectory == "" {
-		return fmt.Errorf("tdlib.database_directory is required")
+	switch c.Archive.Mode {
+	case "", ArchiveAll, ArchiveSignals, ArchiveNone:
+	default:
+		return fmt.Errorf("archive.mode must be one of all, signals, none")
 	}
-	if c.TDLib.FilesDirectory == "" {
-		return fmt.Errorf("tdlib.files_directory is required")
+
+	if c.Binance.RecvWindow < 0 || c.Binance.RecvWindow > 60000 {
+		return fmt.Errorf("binance.recv_window must be between 0 and 60000")
+	}
+
+	if c.Notifications.Enabled && c.Notifications.ChatID == 0 {
+		return fmt.Errorf("notifications.chat_id is required when notifications are enabled")
 	}
 
 	// Validate trading config if trading is enabled
@@ -140,6 +465,14 @@ func (c *Config) Validate() error {
 		if c.Trading.SignalPattern == "" {
 			return fmt.Errorf("trading.signal_pattern is required when trading is enabled")
 		}
+		switch c.Trading.PriceGuard.Action {
+		case "", PriceGuardSkip, PriceGuardDownsize:
+		default:
+			return fmt.Errorf("trading.price_guard.action must be one of skip, downsize")
+		}
+		if c.Trading.PriceGuard.MaxMove < 0 {
+			return fmt.Errorf("trading.price_guard.max_move must not be negative")
+		}
 	}
 
 	return nil
@@ -150,6 +483,16 @@ func (c *Config) IsBot() bool {
 	return c.Telegram.BotToken != ""
 }
 
+// UsesBotAPI returns true if channel posts are read through the HTTP Bot API
+func (c *Config) UsesBotAPI() bool {
+	return c.IsBot() && c.Telegram.BotAPI.ModeOrDefault() != BotModeTDLib
+}
+
+// UsesTDLib returns true if messages are read through a TDLib session
+func (c *Config) UsesTDLib() bool {
+	return !c.UsesBotAPI()
+}
+
 // Save writes the configuration to a file
 func (c *Config) Save(path string) error {
 	data, err := yaml.Marshal(c)
@@ -222,7 +565,7 @@ func parseIgnoreTokens(val string) []string {
 	if val == "" {
 		return nil
 	}
-	
+
 	// Split by comma and clean up
 	tokens := make([]string, 0)
 	parts := strings.Split(val, ",")
@@ -245,16 +588,16 @@ func (tc *TradingConfig) IsTokenIgnored(symbol string) bool {
 	if len(tc.IgnoreTokens) == 0 {
 		return false
 	}
-	
+
 	// Normalize symbol to uppercase
 	symbol = strings.ToUpper(symbol)
-	
+
 	// Check if symbol matches any ignored token
 	for _, ignoredToken := range tc.IgnoreTokens {
 		if symbol == ignoredToken {
 			return true
 		}
 	}
-	
+
 	return false
 }
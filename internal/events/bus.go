@@ -0,0 +1,92 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Trade event types
+const (
+	SignalDetected  = "signal_detected"
+	OrdersPlaced    = "orders_placed"
+	PartialFill     = "partial_fill"
+	TakeProfitHit   = "take_profit_hit"
+	StopLossHit     = "stop_loss_hit"
+	TimeoutClosed   = "timeout_closed"
+	ExecutionError  = "error"
+	PositionOpened  = "position_opened"
+	PositionClosed  = "position_closed"
+	RiskGateTripped = "risk_gate_tripped"
+)
+
+// Types lists all event types
+var Types = []string{
+	SignalDetected, OrdersPlaced, PartialFill, TakeProfitHit, StopLossHit, TimeoutClosed, ExecutionError,
+	PositionOpened, PositionClosed, RiskGateTripped,
+}
+
+// Event describes something that happened while trading. Fields that do
+// not apply to an event type are left empty.
+type Event struct {
+	Type        string    `json:"type"`
+	Time        time.Time `json:"time"`
+	AccountID   int64     `json:"account_id,omitempty"`
+	AccountName string    `json:"account_name,omitempty"`
+	ChannelID   int64     `json:"channel_id,omitempty"`
+	ChannelName string    `json:"channel_name,omitempty"`
+	Symbol      string    `json:"symbol,omitempty"`
+	Side        string    `json:"side,omitempty"`
+	OrderID     int64     `json:"order_id,omitempty"`
+	Price       float64   `json:"price,omitempty"`
+	Quantity    float64   `json:"quantity,omitempty"`
+	TakeProfit  float64   `json:"take_profit,omitempty"`
+	StopLoss    float64   `json:"stop_loss,omitempty"`
+	PnL         float64   `json:"pnl,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Handler receives published events. Handlers are called synchronously
+// from the publishing goroutine and must not block.
+type Handler func(event *Event)
+
+// Bus fans trade events out to subscribers such as the notifier
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+// NewBus creates an event bus without subscribers
+func NewBus() *Bus {
+	return &Bus{
+		handlers: make([]Handler, 0),
+	}
+}
+
+// Subscribe adds a handler for all events
+func (b *Bus) Subscribe(handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// Publish delivers an event to all subscribers, setting its time when
+// missing. Publishing on a nil bus is a no-op.
+func (b *Bus) Publish(event *Event) {
+	if b == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	handlers := make([]Handler, len(b.handlers))
+	copy(handlers, b.handlers)
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
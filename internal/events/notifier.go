@@ -0,0 +1,297 @@
+package events
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"tdlib-go/internal/config"
+)
+
+// Notifier queue and message limits
+const (
+	notifyQueueSize   = 500
+	maxMessageLength  = 4096 // Telegram's limit for a text message
+	notifyRetryDelay  = 5 * time.Second
+	notifySendRetries = 3
+)
+
+// defaultTemplates render each event type when no template is configured
+var defaultTemplates = map[string]string{
+	SignalDetected:  `📡 Signal {{.Symbol}}{{if .ChannelName}} from {{.ChannelName}}{{end}}`,
+	OrdersPlaced:    `🟢 {{.AccountName}}: {{.Side}} {{qty .Quantity}} {{.Symbol}} @ {{price .Price}} (TP {{price .TakeProfit}}, SL {{price .StopLoss}})`,
+	PartialFill:     `◐ {{.AccountName}}: {{.Symbol}} order {{.OrderID}} partially filled, {{qty .Quantity}} @ {{price .Price}}`,
+	TakeProfitHit:   `✅ {{.AccountName}}: {{.Symbol}} take profit hit @ {{price .Price}}, PnL {{pnl .PnL}} USDT`,
+	StopLossHit:     `🛑 {{.AccountName}}: {{.Symbol}} stop loss hit @ {{price .Price}}, PnL {{pnl .PnL}} USDT`,
+	TimeoutClosed:   `⏱ {{.AccountName}}: {{.Symbol}} closed after order timeout ({{qty .Quantity}})`,
+	ExecutionError:  `⚠️ {{if .AccountName}}{{.AccountName}}: {{end}}{{if .Symbol}}{{.Symbol}}: {{end}}{{.Error}}`,
+	PositionOpened:  `📈 {{.AccountName}}: {{.Side}} {{qty .Quantity}} {{.Symbol}} opened @ {{price .Price}}`,
+	PositionClosed:  `📉 {{.AccountName}}: {{.Symbol}} closed ({{.Reason}}){{if .PnL}}, PnL {{pnl .PnL}} USDT{{end}}`,
+	RiskGateTripped: `🚧 Risk gate tripped{{if .ChannelName}} for {{.ChannelName}}{{else if .AccountName}} on {{.AccountName}}{{end}}: {{.Reason}}`,
+}
+
+// chatQuietEvents repeat other events (orders_placed and the TP/SL/timeout
+// events), so the chat only gets them when a template is configured
+var chatQuietEvents = map[string]bool{
+	PositionOpened: true,
+	PositionClosed: true,
+}
+
+// parseTemplate parses an event template with the number helpers
+func parseTemplate(eventType, text string) (*template.Template, error) {
+	return template.New(eventType).Funcs(templateFuncs).Parse(text)
+}
+
+// templateFuncs format numbers in templates
+var templateFuncs = template.FuncMap{
+	"price": func(v float64) string { return fmt.Sprintf("%.8g", v) },
+	"qty":   func(v float64) string { return fmt.Sprintf("%.8g", v) },
+	"pnl":   func(v float64) string { return fmt.Sprintf("%+.2f", v) },
+}
+
+// Sender sends a text message to a Telegram chat
+type Sender interface {
+	SendText(chatID int64, text string) error
+}
+
+// Notifier renders trade events and sends them to a Telegram chat. Events
+// arriving within the batch window are joined into one message, and
+// messages are rate limited so a burst of signals doesn't flood the chat.
+type Notifier struct {
+	sender    Sender
+	config    *config.NotificationsConfig
+	logger    *logrus.Logger
+	templates map[string]*template.Template
+
+	queue   chan string
+	dropped int
+	mu      sync.Mutex
+
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewNotifier creates a notifier sending to cfg.ChatID through sender
+func NewNotifier(sender Sender, cfg *config.NotificationsConfig, logger *logrus.Logger) (*Notifier, error) {
+	templates := make(map[string]*template.Template, len(defaultTemplates))
+	for _, eventType := range Types {
+		text := defaultTemplates[eventType]
+		if custom, ok := cfg.Templates[eventType]; ok {
+			text = custom
+		} else if chatQuietEvents[eventType] {
+			continue
+		}
+
+		tmpl, err := parseTemplate(eventType, text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse notification template %s: %w", eventType, err)
+		}
+		templates[eventType] = tmpl
+	}
+
+	for eventType := range cfg.Templates {
+		if _, ok := defaultTemplates[eventType]; !ok {
+			logger.Warnf("Ignoring notification template for unknown event %s", eventType)
+		}
+	}
+
+	return &Notifier{
+		sender:    sender,
+		config:    cfg,
+		logger:    logger,
+		templates: templates,
+		queue:     make(chan string, notifyQueueSize),
+		stopCh:    make(chan struct{}),
+		doneCh:    make(chan struct{}),
+	}, nil
+}
+
+// Start starts sending queued notifications
+func (n *Notifier) Start() {
+	n.logger.Infof("Sending trade notifications to chat %d", n.config.ChatID)
+	go n.run()
+}
+
+// Stop sends what is still queued and stops the notifier
+func (n *Notifier) Stop() {
+	n.stopOnce.Do(func() {
+		close(n.stopCh)
+	})
+	<-n.doneCh
+}
+
+// Handle renders and queues an event; it is meant to subscribe to a Bus
+func (n *Notifier) Handle(event *Event) {
+	if !n.config.EventEnabled(event.Type) {
+		return
+	}
+
+	tmpl, ok := n.templates[event.Type]
+	if !ok {
+		return
+	}
+
+	text, err := render(tmpl, event)
+	if err != nil {
+		n.logger.Errorf("Failed to render %s notification: %v", event.Type, err)
+		return
+	}
+	if text == "" {
+		return
+	}
+
+	select {
+	case n.queue <- text:
+	default:
+		// Never block trading on a slow chat
+		n.mu.Lock()
+		n.dropped++
+		n.mu.Unlock()
+	}
+}
+
+// render executes an event template, returning trimmed text
+func render(tmpl *template.Template, event *Event) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, event); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// run batches queued notifications and sends them within the rate limit
+func (n *Notifier) run() {
+	defer close(n.doneCh)
+
+	interval := time.Minute / time.Duration(n.config.RatePerMinOrDefault())
+	var lastSent time.Time
+
+	for {
+		var first string
+		select {
+		case first = <-n.queue:
+		case <-n.stopCh:
+			n.flush()
+			return
+		}
+
+		// Collect everything arriving within the batch window
+		batch := []string{first}
+		window := time.After(n.config.BatchWindowOrDefault())
+	collect:
+		for {
+			select {
+			case text := <-n.queue:
+				batch = append(batch, text)
+			case <-window:
+				break collect
+			case <-n.stopCh:
+				batch = append(batch, n.drain()...)
+				break collect
+			}
+		}
+
+		for _, message := range n.compose(batch) {
+			if wait := interval - time.Since(lastSent); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-n.stopCh:
+				}
+			}
+			n.send(message)
+			lastSent = time.Now()
+		}
+	}
+}
+
+// flush sends the remaining queued notifications on shutdown
+func (n *Notifier) flush() {
+	for _, message := range n.compose(n.drain()) {
+		n.send(message)
+	}
+}
+
+// drain takes all queued notifications without waiting
+func (n *Notifier) drain() []string {
+	var batch []string
+	for {
+		select {
+		case text := <-n.queue:
+			batch = append(batch, text)
+		default:
+			return batch
+		}
+	}
+}
+
+// compose joins notifications into messages within Telegram's length
+// limit, noting how many were dropped because the queue was full
+func (n *Notifier) compose(batch []string) []string {
+	n.mu.Lock()
+	dropped := n.dropped
+	n.dropped = 0
+	n.mu.Unlock()
+
+	if dropped > 0 {
+		batch = append(batch, fmt.Sprintf("… %d more notifications dropped", dropped))
+	}
+
+	var messages []string
+	var current strings.Builder
+	for _, text := range batch {
+		text = truncate(text, maxMessageLength)
+		if current.Len() > 0 && current.Len()+2+len(text) > maxMessageLength {
+			messages = append(messages, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(text)
+	}
+	if current.Len() > 0 {
+		messages = append(messages, current.String())
+	}
+
+	return messages
+}
+
+// truncate shortens text to at most limit bytes without splitting a character
+func truncate(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+
+	cut := limit - len("…")
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "…"
+}
+
+// send delivers one message, retrying a few times on failure
+func (n *Notifier) send(message string) {
+	for attempt := 1; ; attempt++ {
+		err := n.sender.SendText(n.config.ChatID, message)
+		if err == nil {
+			return
+		}
+		if attempt >= notifySendRetries {
+			n.logger.Errorf("Failed to send notification to chat %d: %v", n.config.ChatID, err)
+			return
+		}
+
+		n.logger.Warnf("Failed to send notification (attempt %d/%d): %v", attempt, notifySendRetries, err)
+		select {
+		case <-time.After(notifyRetryDelay):
+		case <-n.stopCh:
+			return
+		}
+	}
+}
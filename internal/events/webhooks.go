@@ -0,0 +1,385 @@
+package events
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"tdlib-go/internal/storage"
+	"tdlib-go/pkg/models"
+)
+
+// Webhook delivery limits
+const (
+	webhookPollInterval   = 2 * time.Second
+	webhookQueueSize      = 256 // Events waiting to be saved as deliveries
+	webhookBatchSize      = 50
+	webhookTimeout        = 10 * time.Second
+	webhookMaxAttempts    = 8
+	webhookRetryBaseDelay = 10 * time.Second
+	webhookRetryMaxDelay  = time.Hour
+	webhookRetention      = 7 * 24 * time.Hour // Finished deliveries are kept this long
+	webhookMaxErrorLength = 500
+)
+
+// WebhookTest is the event type of test deliveries
+const WebhookTest = "test"
+
+// WebhookPayload is the body of json-format webhooks
+type WebhookPayload struct {
+	Event     string    `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      *Event    `json:"data"`
+}
+
+// WebhookDispatcher queues trade events for webhook subscriptions in the
+// database and delivers them in the background, retrying failed requests
+// with exponential backoff. Events are saved by a worker, so publishers
+// never wait on the database. Requests carry an X-Webhook-Signature header,
+// sha256=HMAC-SHA256(secret, timestamp + "." + body), so receivers can
+// verify them.
+type WebhookDispatcher struct {
+	repo      *storage.Repository
+	logger    *logrus.Logger
+	client    *http.Client
+	templates map[string]*template.Template
+
+	queue    chan *Event
+	wakeCh   chan struct{}
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	savedCh  chan struct{} // Closed once queued events are saved on stop
+	stopOnce sync.Once
+}
+
+// NewWebhookDispatcher creates a webhook dispatcher
+func NewWebhookDispatcher(repo *storage.Repository, logger *logrus.Logger) (*WebhookDispatcher, error) {
+	// Chat-style formats use the default notification texts
+	templates := make(map[string]*template.Template, len(defaultTemplates)+1)
+	texts := map[string]string{WebhookTest: `🔔 Test notification from tdlib-go`}
+	for eventType, text := range defaultTemplates {
+		texts[eventType] = text
+	}
+	for eventType, text := range texts {
+		tmpl, err := parseTemplate(eventType, text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse webhook template %s: %w", eventType, err)
+		}
+		templates[eventType] = tmpl
+	}
+
+	return &WebhookDispatcher{
+		repo:      repo,
+		logger:    logger,
+		client:    &http.Client{Timeout: webhookTimeout},
+		templates: templates,
+		queue:     make(chan *Event, webhookQueueSize),
+		wakeCh:    make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+		doneCh:    make(chan struct{}),
+		savedCh:   make(chan struct{}),
+	}, nil
+}
+
+// Start starts delivering queued webhooks, including those left over from
+// a previous run
+func (d *WebhookDispatcher) Start() {
+	go d.saveEvents()
+	go d.run()
+}
+
+// Stop stops delivering; undelivered webhooks stay queued in the database
+func (d *WebhookDispatcher) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stopCh)
+	})
+	<-d.savedCh
+	<-d.doneCh
+}
+
+// Handle queues an event for every active webhook subscribed to it; it is
+// meant to subscribe to a Bus
+func (d *WebhookDispatcher) Handle(event *Event) {
+	select {
+	case d.queue <- event:
+	default:
+		// Never block trading on a slow database
+		d.logger.Warnf("Webhook queue is full, dropping %s event", event.Type)
+	}
+}
+
+// saveEvents saves handled events as deliveries until stopped, then saves
+// the events still queued
+func (d *WebhookDispatcher) saveEvents() {
+	defer close(d.savedCh)
+
+	for {
+		select {
+		case event := <-d.queue:
+			d.enqueue(event)
+		case <-d.stopCh:
+			for {
+				select {
+				case event := <-d.queue:
+					d.enqueue(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// enqueue saves a delivery of an event for every active webhook subscribed
+// to it and wakes the delivery loop
+func (d *WebhookDispatcher) enqueue(event *Event) {
+	webhooks, err := d.repo.GetWebhooks(true)
+	if err != nil {
+		d.logger.Errorf("Failed to load webhooks: %v", err)
+		return
+	}
+
+	queued := false
+	for _, webhook := range webhooks {
+		if !webhook.Subscribes(event.Type) {
+			continue
+		}
+
+		payload, err := d.payload(webhook, event)
+		if err != nil {
+			d.logger.Errorf("Failed to build %s payload for webhook %d: %v", event.Type, webhook.ID, err)
+			continue
+		}
+
+		delivery := &models.WebhookDelivery{
+			WebhookID: webhook.ID,
+			EventType: event.Type,
+			Payload:   string(payload),
+		}
+		if err := d.repo.EnqueueWebhookDelivery(delivery); err != nil {
+			d.logger.Errorf("Failed to queue webhook %d: %v", webhook.ID, err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		select {
+		case d.wakeCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// SendTest sends a test event to a webhook right away, bypassing the queue
+func (d *WebhookDispatcher) SendTest(webhookID int64) error {
+	webhook, err := d.repo.GetWebhook(webhookID)
+	if err != nil {
+		return err
+	}
+	if webhook == nil {
+		return fmt.Errorf("webhook %d not found", webhookID)
+	}
+
+	event := &Event{Type: WebhookTest, Time: time.Now()}
+	payload, err := d.payload(webhook, event)
+	if err != nil {
+		return fmt.Errorf("failed to build test payload: %w", err)
+	}
+
+	return d.post(webhook, &models.WebhookDelivery{EventType: WebhookTest, Payload: string(payload)})
+}
+
+// payload renders an event in the webhook's format
+func (d *WebhookDispatcher) payload(webhook *models.Webhook, event *Event) ([]byte, error) {
+	if webhook.Format == models.WebhookFormatJSON || webhook.Format == "" {
+		return json.Marshal(&WebhookPayload{Event: event.Type, Timestamp: event.Time, Data: event})
+	}
+
+	text := event.Type
+	if tmpl, ok := d.templates[event.Type]; ok {
+		rendered, err := render(tmpl, event)
+		if err != nil {
+			return nil, err
+		}
+		text = rendered
+	}
+
+	switch webhook.Format {
+	case models.WebhookFormatDiscord:
+		// Discord rejects content over 2000 characters
+		return json.Marshal(map[string]string{"content": truncate(text, 2000)})
+	case models.WebhookFormatSlack:
+		return json.Marshal(map[string]string{"text": text})
+	default:
+		return nil, fmt.Errorf("unknown webhook format %q", webhook.Format)
+	}
+}
+
+// run delivers due webhooks whenever woken and at every poll interval
+func (d *WebhookDispatcher) run() {
+	defer close(d.doneCh)
+
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	var lastCleanup time.Time
+	for {
+		d.deliverDue()
+
+		if time.Since(lastCleanup) > time.Hour {
+			if removed, err := d.repo.DeleteWebhookDeliveriesBefore(time.Now().Add(-webhookRetention)); err != nil {
+				d.logger.Errorf("Failed to clean up webhook deliveries: %v", err)
+			} else if removed > 0 {
+				d.logger.Debugf("Removed %d old webhook deliveries", removed)
+			}
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-d.wakeCh:
+		case <-d.stopCh:
+			return
+		}
+	}
+}
+
+// deliverDue attempts every delivery whose next attempt is due
+func (d *WebhookDispatcher) deliverDue() {
+	for {
+		deliveries, err := d.repo.GetDueWebhookDeliveries(time.Now(), webhookBatchSize)
+		if err != nil {
+			d.logger.Errorf("Failed to load webhook deliveries: %v", err)
+			return
+		}
+		if len(deliveries) == 0 {
+			return
+		}
+
+		webhooks := make(map[int64]*models.Webhook)
+		for _, delivery := range deliveries {
+			select {
+			case <-d.stopCh:
+				return
+			default:
+			}
+
+			webhook, ok := webhooks[delivery.WebhookID]
+			if !ok {
+				webhook, err = d.repo.GetWebhook(delivery.WebhookID)
+				if err != nil {
+					d.logger.Errorf("Failed to load webhook %d: %v", delivery.WebhookID, err)
+					return
+				}
+				webhooks[delivery.WebhookID] = webhook
+			}
+
+			d.attempt(webhook, delivery)
+		}
+
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// attempt sends one delivery and records the outcome
+func (d *WebhookDispatcher) attempt(webhook *models.Webhook, delivery *models.WebhookDelivery) {
+	var err error
+	switch {
+	case webhook == nil:
+		err = fmt.Errorf("webhook was deleted")
+		delivery.Attempts = webhookMaxAttempts
+	case !webhook.IsActive:
+		err = fmt.Errorf("webhook is disabled")
+		delivery.Attempts = webhookMaxAttempts
+	default:
+		delivery.Attempts++
+		err = d.post(webhook, delivery)
+	}
+
+	now := time.Now()
+	if err == nil {
+		delivery.Status = models.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	} else {
+		delivery.LastError = truncate(err.Error(), webhookMaxErrorLength)
+		if delivery.Attempts >= webhookMaxAttempts {
+			delivery.Status = models.DeliveryFailed
+			d.logger.Errorf("Giving up on webhook %d delivery %d after %d attempts: %v",
+				delivery.WebhookID, delivery.ID, delivery.Attempts, err)
+		} else {
+			delivery.NextAttemptAt = now.Add(webhookRetryDelay(delivery.Attempts))
+			d.logger.Warnf("Webhook %d delivery %d failed (attempt %d/%d), retrying at %s: %v",
+				delivery.WebhookID, delivery.ID, delivery.Attempts, webhookMaxAttempts,
+				delivery.NextAttemptAt.Format(time.RFC3339), err)
+		}
+	}
+
+	if err := d.repo.UpdateWebhookDelivery(delivery); err != nil {
+		d.logger.Errorf("Failed to update webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// webhookRetryDelay returns the backoff after a number of failed attempts
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := 1; i < attempts && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookRetryMaxDelay)
+}
+
+// post sends a signed delivery; any non-2xx response is an error
+func (d *WebhookDispatcher) post(webhook *models.Webhook, delivery *models.WebhookDelivery) error {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tdlib-go-webhooks")
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	if webhook.Secret != "" {
+		req.Header.Set("X-Webhook-Signature", "sha256="+SignWebhook(webhook.Secret, timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of timestamp + "." + body
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,58 @@
+package events
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"tdlib-go/internal/storage"
+	"tdlib-go/pkg/models"
+)
+
+func TestWebhookEventsAreSavedOffThePublisher(t *testing.T) {
+	received := make(chan string, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Webhook-Event")
+	}))
+	t.Cleanup(receiver.Close)
+
+	repo, err := storage.NewRepository(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewRepository: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	webhook := &models.Webhook{Name: "test", URL: receiver.URL, Format: models.WebhookFormatJSON, IsActive: true}
+	if err := repo.SaveWebhook(webhook); err != nil {
+		t.Fatalf("SaveWebhook: %v", err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	dispatcher, err := NewWebhookDispatcher(repo, logger)
+	if err != nil {
+		t.Fatalf("NewWebhookDispatcher: %v", err)
+	}
+
+	// Handling only queues the event, the worker saves it once started
+	dispatcher.Handle(&Event{Type: PositionOpened, Symbol: "BTCUSDT", Time: time.Now()})
+	deliveries, err := repo.GetWebhookDeliveries(webhook.ID, 10)
+	if err != nil || len(deliveries) != 0 {
+		t.Fatalf("deliveries before start = %d, %v, want none", len(deliveries), err)
+	}
+
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	select {
+	case eventType := <-received:
+		if eventType != PositionOpened {
+			t.Errorf("delivered a %s event, want %s", eventType, PositionOpened)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the event was not delivered")
+	}
+}
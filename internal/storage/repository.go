@@ -2,18 +2,25 @@ package storage
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+	"tdlib-go/internal/analytics"
 	"tdlib-go/pkg/models"
 )
 
 // Repository handles database operations
 type Repository struct {
 	db *sql.DB
+
+	// ftsEnabled is set when SQLite has FTS5 and messages_fts is maintained
+	ftsEnabled bool
 }
 
 // NewRepository creates a new repository instance
@@ -41,13 +48,149 @@ func NewRepository(dsn string) (*Repository, error) {
 // migrate runs database migrations
 func (r *Repository) migrate() error {
 	schema, err := os.ReadFile("migrations/001_initial_schema.sql")
+	if err == nil {
+		if _, err := r.db.Exec(string(schema)); err != nil {
+			return fmt.Errorf("failed to execute migration: %w", err)
+		}
+	}
+
+	// The inline schema is idempotent and also covers the trading tables
+	// that are not part of the migration files
+	if err := r.createSchemaInline(); err != nil {
+		return err
+	}
+
+	if err := r.upgradeSchema(); err != nil {
+		return err
+	}
+
+	return r.setupMessageSearch()
+}
+
+// setupMessageSearch maintains an FTS5 index over message text when SQLite
+// was built with FTS5 (build tag sqlite_fts5). Without it, message search
+// falls back to LIKE matching.
+func (r *Repository) setupMessageSearch() error {
+	triggers := []string{"messages_fts_insert", "messages_fts_delete", "messages_fts_update"}
+
+	var fts5 int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM pragma_compile_options WHERE compile_options = 'ENABLE_FTS5'`).Scan(&fts5); err != nil {
+		return fmt.Errorf("failed to check FTS5 support: %w", err)
+	}
+
+	if fts5 == 0 {
+		// Drop triggers left by an FTS5-enabled build, they would break inserts
+		for _, name := range triggers {
+			if _, err := r.db.Exec("DROP TRIGGER IF EXISTS " + name); err != nil {
+				return fmt.Errorf("failed to drop trigger %s: %w", name, err)
+			}
+		}
+		r.ftsEnabled = false
+		return nil
+	}
+
+	var existing int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'messages_fts_%'`).Scan(&existing); err != nil {
+		return fmt.Errorf("failed to inspect triggers: %w", err)
+	}
+
+	schema := `
+	CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(text, content='messages', content_rowid='id');
+
+	CREATE TRIGGER IF NOT EXISTS messages_fts_insert AFTER INSERT ON messages BEGIN
+		INSERT INTO messages_fts(rowid, text) VALUES (new.id, new.text);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS messages_fts_delete AFTER DELETE ON messages BEGIN
+		INSERT INTO messages_fts(messages_fts, rowid, text) VALUES ('delete', old.id, old.text);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS messages_fts_update AFTER UPDATE OF text ON messages BEGIN
+		INSERT INTO messages_fts(messages_fts, rowid, text) VALUES ('delete', old.id, old.text);
+		INSERT INTO messages_fts(rowid, text) VALUES (new.id, new.text);
+	END;
+	`
+
+	if _, err := r.db.Exec(schema); err != nil {
+		return fmt.Errorf("failed to create message search index: %w", err)
+	}
+
+	// The index is stale when it is new or the triggers were dropped by a build without FTS5
+	if existing < len(triggers) {
+		if _, err := r.db.Exec(`INSERT INTO messages_fts(messages_fts) VALUES ('rebuild')`); err != nil {
+			return fmt.Errorf("failed to rebuild message search index: %w", err)
+		}
+	}
+
+	r.ftsEnabled = true
+	return nil
+}
+
+// upgradeSchema adds columns introduced after the initial schema to existing databases
+func (r *Repository) upgradeSchema() error {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"channels", "trading_enabled", "BOOLEAN DEFAULT 1"},
+		{"channels", "last_message_id", "INTEGER DEFAULT 0"},
+		{"signals", "source", "TEXT DEFAULT 'telegram'"},
+		{"positions", "realized_pnl", "REAL"},
+		{"positions", "commission", "REAL DEFAULT 0"},
+		{"positions", "funding", "REAL DEFAULT 0"},
+		{"binance_accounts", "sizing_mode", "TEXT DEFAULT ''"},
+		{"binance_accounts", "balance_percent", "REAL DEFAULT 0"},
+		{"binance_accounts", "risk_amount", "REAL DEFAULT 0"},
+		{"positions", "sizing_mode", "TEXT DEFAULT ''"},
+		{"positions", "sizing_input", "REAL DEFAULT 0"},
+		{"positions", "sizing_balance", "REAL DEFAULT 0"},
+		{"binance_accounts", "max_daily_loss", "REAL DEFAULT 0"},
+		{"binance_accounts", "max_loss_streak", "INTEGER DEFAULT 0"},
+		{"binance_accounts", "max_drawdown", "REAL DEFAULT 0"},
+		{"binance_accounts", "entry_mode", "TEXT DEFAULT ''"},
+		{"binance_accounts", "entry_chases", "INTEGER DEFAULT 0"},
+		{"binance_accounts", "entry_slippage", "REAL DEFAULT 0"},
+		{"binance_accounts", "entry_timeout", "INTEGER DEFAULT 0"},
+		{"signals", "entry_price", "REAL DEFAULT 0"},
+		{"signals", "posted_at", "TIMESTAMP"},
+		{"orders", "client_order_id", "TEXT DEFAULT ''"},
+		{"positions", "closed_quantity", "REAL DEFAULT 0"},
+		{"positions", "closed_pnl", "REAL DEFAULT 0"},
+	}
+
+	for _, c := range columns {
+		if err := r.addColumnIfMissing(c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addColumnIfMissing adds a column to a table unless it already exists
+func (r *Repository) addColumnIfMissing(table, column, definition string) error {
+	rows, err := r.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
 	if err != nil {
-		// If migrations file doesn't exist, create schema inline
-		return r.createSchemaInline()
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("failed to scan column info: %w", err)
+		}
+		if name == column {
+			return nil
+		}
 	}
+	rows.Close()
 
-	if _, err := r.db.Exec(string(schema)); err != nil {
-		return fmt.Errorf("failed to execute migration: %w", err)
+	if _, err := r.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
 	}
 
 	return nil
@@ -66,6 +209,16 @@ func (r *Repository) createSchemaInline() error {
 		is_default BOOLEAN DEFAULT 0,
 		leverage INTEGER DEFAULT 10,
 		order_amount REAL DEFAULT 100,
+		sizing_mode TEXT DEFAULT '',
+		balance_percent REAL DEFAULT 0,
+		risk_amount REAL DEFAULT 0,
+		max_daily_loss REAL DEFAULT 0,
+		max_loss_streak INTEGER DEFAULT 0,
+		max_drawdown REAL DEFAULT 0,
+		entry_mode TEXT DEFAULT '',
+		entry_chases INTEGER DEFAULT 0,
+		entry_slippage REAL DEFAULT 0,
+		entry_timeout INTEGER DEFAULT 0,
 		target_percent REAL DEFAULT 0.02,
 		stoploss_percent REAL DEFAULT 0.01,
 		order_timeout INTEGER DEFAULT 600,
@@ -82,6 +235,8 @@ func (r *Repository) createSchemaInline() error {
 		username TEXT,
 		title TEXT NOT NULL,
 		is_active BOOLEAN DEFAULT 1,
+		trading_enabled BOOLEAN DEFAULT 1,
+		last_message_id INTEGER DEFAULT 0,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);
@@ -114,6 +269,9 @@ func (r *Repository) createSchemaInline() error {
 		channel_id INTEGER NOT NULL,
 		symbol TEXT NOT NULL,
 		raw_message TEXT NOT NULL,
+		entry_price REAL DEFAULT 0,
+		source TEXT DEFAULT 'telegram',
+		posted_at TIMESTAMP,
 		parsed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 		processed_at TIMESTAMP,
 		status TEXT DEFAULT 'pending',
@@ -142,6 +300,14 @@ func (r *Repository) createSchemaInline() error {
 		exit_price REAL,
 		pnl REAL,
 		pnl_percent REAL,
+		realized_pnl REAL,
+		commission REAL DEFAULT 0,
+		funding REAL DEFAULT 0,
+		sizing_mode TEXT DEFAULT '',
+		sizing_input REAL DEFAULT 0,
+		sizing_balance REAL DEFAULT 0,
+		closed_quantity REAL DEFAULT 0,
+		closed_pnl REAL DEFAULT 0,
 		FOREIGN KEY (signal_id) REFERENCES signals(id),
 		FOREIGN KEY (account_id) REFERENCES binance_accounts(id)
 	);
@@ -155,6 +321,7 @@ func (r *Repository) createSchemaInline() error {
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		position_id INTEGER NOT NULL,
 		binance_order_id TEXT NOT NULL UNIQUE,
+		client_order_id TEXT DEFAULT '',
 		symbol TEXT NOT NULL,
 		side TEXT NOT NULL,
 		type TEXT NOT NULL,
@@ -185,6 +352,90 @@ func (r *Repository) createSchemaInline() error {
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_settings_key ON settings(key);
+
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		url TEXT NOT NULL,
+		format TEXT NOT NULL DEFAULT 'json',
+		secret TEXT NOT NULL DEFAULT '',
+		events TEXT NOT NULL DEFAULT '',
+		is_active BOOLEAN DEFAULT 1,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL,
+		event_type TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER DEFAULT 0,
+		next_attempt_at TIMESTAMP NOT NULL,
+		last_error TEXT DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		delivered_at TIMESTAMP,
+		FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id);
+
+	CREATE TABLE IF NOT EXISTS fills (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		account_id INTEGER NOT NULL,
+		position_id INTEGER NOT NULL DEFAULT 0,
+		binance_order_id TEXT NOT NULL,
+		trade_id INTEGER NOT NULL,
+		symbol TEXT NOT NULL,
+		side TEXT NOT NULL,
+		price REAL NOT NULL,
+		quantity REAL NOT NULL,
+		realized_pnl REAL DEFAULT 0,
+		commission REAL DEFAULT 0,
+		commission_asset TEXT DEFAULT '',
+		trade_time TIMESTAMP NOT NULL,
+		UNIQUE (account_id, symbol, trade_id),
+		FOREIGN KEY (account_id) REFERENCES binance_accounts(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_fills_position_id ON fills(position_id);
+	CREATE INDEX IF NOT EXISTS idx_fills_binance_order_id ON fills(binance_order_id);
+
+	CREATE TABLE IF NOT EXISTS income (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		account_id INTEGER NOT NULL,
+		position_id INTEGER NOT NULL DEFAULT 0,
+		tran_id INTEGER NOT NULL,
+		income_type TEXT NOT NULL,
+		symbol TEXT DEFAULT '',
+		income REAL NOT NULL,
+		asset TEXT NOT NULL,
+		info TEXT DEFAULT '',
+		trade_id TEXT DEFAULT '',
+		time TIMESTAMP NOT NULL,
+		UNIQUE (account_id, income_type, tran_id),
+		FOREIGN KEY (account_id) REFERENCES binance_accounts(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_income_account_time ON income(account_id, income_type, time);
+	CREATE INDEX IF NOT EXISTS idx_income_position_id ON income(position_id);
+
+	CREATE TABLE IF NOT EXISTS risk_breakers (
+		account_id INTEGER PRIMARY KEY,
+		tripped BOOLEAN DEFAULT 0,
+		reason TEXT DEFAULT '',
+		tripped_at TIMESTAMP,
+		window_start TIMESTAMP NOT NULL,
+		daily_pnl REAL DEFAULT 0,
+		loss_streak INTEGER DEFAULT 0,
+		equity REAL DEFAULT 0,
+		peak_equity REAL DEFAULT 0,
+		drawdown REAL DEFAULT 0,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (account_id) REFERENCES binance_accounts(id)
+	);
 	`
 
 	if _, err := r.db.Exec(schema); err != nil {
@@ -243,6 +494,105 @@ func (r *Repository) GetMessagesByChannel(channelID int64, limit int) ([]*models
 	}
 	defer rows.Close()
 
+	return scanMessages(rows)
+}
+
+// SearchMessages returns archived messages matching a filter, newest first
+func (r *Repository) SearchMessages(filter models.MessageFilter) ([]*models.Message, error) {
+	query := `
+		SELECT id, message_id, channel_id, channel_name, sender_id, sender_name,
+		       text, media_type, is_forwarded, timestamp, created_at
+		FROM messages
+	`
+
+	var conditions []string
+	var args []interface{}
+
+	if filter.Query != "" {
+		if r.ftsEnabled {
+			conditions = append(conditions, "id IN (SELECT rowid FROM messages_fts WHERE messages_fts MATCH ?)")
+			args = append(args, ftsQuery(filter.Query))
+		} else {
+			conditions = append(conditions, `text LIKE ? ESCAPE '\'`)
+			args = append(args, "%"+escapeLike(filter.Query)+"%")
+		}
+	}
+	if filter.ChannelID != 0 {
+		conditions = append(conditions, "channel_id = ?")
+		args = append(args, filter.ChannelID)
+	}
+	// julianday normalizes the timezone offsets stored by the driver
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "julianday(timestamp) >= julianday(?)")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "julianday(timestamp) < julianday(?)")
+		args = append(args, filter.To)
+	}
+
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	query += " ORDER BY julianday(timestamp) DESC, message_id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, filter.Offset)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMessages(rows)
+}
+
+// DeleteMessagesBefore deletes archived messages posted before cutoff.
+// Messages referenced by a signal are kept.
+func (r *Repository) DeleteMessagesBefore(cutoff time.Time) (int64, error) {
+	query := `
+		DELETE FROM messages
+		WHERE julianday(timestamp) < julianday(?)
+		  AND NOT EXISTS (
+			SELECT 1 FROM signals s
+			WHERE s.message_id = messages.message_id AND s.channel_id = messages.channel_id
+		  )
+	`
+
+	result, err := r.db.Exec(query, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete messages: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// IsMessageSearchIndexed reports whether message search uses the FTS5 index
+func (r *Repository) IsMessageSearchIndexed() bool {
+	return r.ftsEnabled
+}
+
+// ftsQuery quotes each search term so user input is never parsed as FTS5 syntax
+func ftsQuery(input string) string {
+	terms := strings.Fields(input)
+	for i, term := range terms {
+		terms[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	return strings.Join(terms, " ")
+}
+
+// escapeLike escapes LIKE wildcards in user input
+func escapeLike(input string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return replacer.Replace(input)
+}
+
+// scanMessages scans message rows
+func scanMessages(rows *sql.Rows) ([]*models.Message, error) {
 	var messages []*models.Message
 	for rows.Next() {
 		msg := &models.Message{}
@@ -296,7 +646,7 @@ func (r *Repository) SaveChannel(channel *models.Channel) error {
 // GetChannel retrieves a channel by ID or username
 func (r *Repository) GetChannel(identifier string) (*models.Channel, error) {
 	query := `
-		SELECT id, channel_id, username, title, is_active, created_at, updated_at
+		SELECT id, channel_id, username, title, is_active, trading_enabled, last_message_id, created_at, updated_at
 		FROM channels
 		WHERE channel_id = ? OR username = ?
 		LIMIT 1
@@ -309,6 +659,8 @@ func (r *Repository) GetChannel(identifier string) (*models.Channel, error) {
 		&channel.Username,
 		&channel.Title,
 		&channel.IsActive,
+		&channel.TradingEnabled,
+		&channel.LastMessageID,
 		&channel.CreatedAt,
 		&channel.UpdatedAt,
 	)
@@ -326,7 +678,7 @@ func (r *Repository) GetChannel(identifier string) (*models.Channel, error) {
 // GetAllChannels retrieves all active channels
 func (r *Repository) GetAllChannels() ([]*models.Channel, error) {
 	query := `
-		SELECT id, channel_id, username, title, is_active, created_at, updated_at
+		SELECT id, channel_id, username, title, is_active, trading_enabled, last_message_id, created_at, updated_at
 		FROM channels
 		WHERE is_active = 1
 		ORDER BY created_at DESC
@@ -347,6 +699,8 @@ func (r *Repository) GetAllChannels() ([]*models.Channel, error) {
 			&channel.Username,
 			&channel.Title,
 			&channel.IsActive,
+			&channel.TradingEnabled,
+			&channel.LastMessageID,
 			&channel.CreatedAt,
 			&channel.UpdatedAt,
 		)
@@ -369,6 +723,81 @@ func (r *Repository) DeactivateChannel(channelID int64) error {
 	return nil
 }
 
+// SetChannelLastMessageID records the newest message seen in a channel.
+// Older IDs are ignored so out-of-order deliveries cannot move it back.
+func (r *Repository) SetChannelLastMessageID(channelID, messageID int64) error {
+	query := `UPDATE channels SET last_message_id = ? WHERE channel_id = ? AND last_message_id < ?`
+	_, err := r.db.Exec(query, messageID, channelID, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to update channel last message: %w", err)
+	}
+	return nil
+}
+
+// SetChannelTradingEnabled enables or disables trading on a channel's signals.
+// Monitoring of the channel is not affected.
+func (r *Repository) SetChannelTradingEnabled(channelID int64, enabled bool) error {
+	query := `UPDATE channels SET trading_enabled = ?, updated_at = ? WHERE channel_id = ?`
+	_, err := r.db.Exec(query, enabled, time.Now(), channelID)
+	if err != nil {
+		return fmt.Errorf("failed to update channel trading state: %w", err)
+	}
+	return nil
+}
+
+// IsChannelTradingEnabled reports whether signals from a channel may be traded.
+// Unknown channels are treated as enabled.
+func (r *Repository) IsChannelTradingEnabled(channelID int64) (bool, error) {
+	var enabled bool
+	err := r.db.QueryRow(`SELECT trading_enabled FROM channels WHERE channel_id = ?`, channelID).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get channel trading state: %w", err)
+	}
+	return enabled, nil
+}
+
+// GetChannelLeaderboard scores every channel on the positions closed within the window
+func (r *Repository) GetChannelLeaderboard(window time.Duration, params analytics.ScoreParams) ([]*models.ChannelScore, error) {
+	since := time.Now().Add(-window)
+
+	trades, err := r.GetClosedTrades(models.StatsFilter{From: since})
+	if err != nil {
+		return nil, err
+	}
+
+	channels, err := r.GetAllChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	// Count live signals per channel within the window, manual signals have no channel
+	rows, err := r.db.Query(`SELECT channel_id, COUNT(*) FROM signals
+		WHERE status != 'historical' AND channel_id != 0 AND julianday(parsed_at) >= julianday(?)
+		GROUP BY channel_id`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query signals: %w", err)
+	}
+	defer rows.Close()
+
+	signalCounts := make(map[int64]int)
+	for rows.Next() {
+		var channelID int64
+		var count int
+		if err := rows.Scan(&channelID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan signal count: %w", err)
+		}
+		signalCounts[channelID] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read signal counts: %w", err)
+	}
+
+	return analytics.ScoreChannels(trades, channels, signalCounts, params), nil
+}
+
 // GetDatabasePath returns the full path to the database file
 func GetDatabasePath(dsn string) (string, error) {
 	dir := filepath.Dir(dsn)
@@ -382,12 +811,52 @@ func GetDatabasePath(dsn string) (string, error) {
 
 // ============= Binance Account Methods =============
 
+// accountColumns are the binance_accounts columns read by scanAccount
+const accountColumns = `id, name, api_key, api_secret, is_testnet, is_active, is_default,
+			leverage, order_amount, sizing_mode, balance_percent, risk_amount,
+			target_percent, stoploss_percent, order_timeout, max_daily_loss, max_loss_streak, max_drawdown,
+			entry_mode, entry_chases, entry_slippage, entry_timeout, created_at, updated_at`
+
+// scanAccount reads a Binance account row selected with accountColumns
+func scanAccount(row rowScanner) (*models.BinanceAccount, error) {
+	account := &models.BinanceAccount{}
+	err := row.Scan(
+		&account.ID,
+		&account.Name,
+		&account.APIKey,
+		&account.APISecret,
+		&account.IsTestnet,
+		&account.IsActive,
+		&account.IsDefault,
+		&account.Leverage,
+		&account.OrderAmount,
+		&account.SizingMode,
+		&account.BalancePercent,
+		&account.RiskAmount,
+		&account.TargetPercent,
+		&account.StopLossPercent,
+		&account.OrderTimeout,
+		&account.MaxDailyLoss,
+		&account.MaxLossStreak,
+		&account.MaxDrawdown,
+		&account.EntryMode,
+		&account.EntryChases,
+		&account.EntrySlippage,
+		&account.EntryTimeout,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+	)
+	return account, err
+}
+
 // SaveAccount saves a Binance account to the database
 func (r *Repository) SaveAccount(account *models.BinanceAccount) error {
 	query := `
 		INSERT INTO binance_accounts (name, api_key, api_secret, is_testnet, is_active, is_default,
-			leverage, order_amount, target_percent, stoploss_percent, order_timeout)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			leverage, order_amount, sizing_mode, balance_percent, risk_amount,
+			target_percent, stoploss_percent, order_timeout, max_daily_loss, max_loss_streak, max_drawdown,
+			entry_mode, entry_chases, entry_slippage, entry_timeout)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	result, err := r.db.Exec(query,
 		account.Name,
@@ -398,9 +867,19 @@ func (r *Repository) SaveAccount(account *models.BinanceAccount) error {
 		account.IsDefault,
 		account.Leverage,
 		account.OrderAmount,
+		account.SizingMode,
+		account.BalancePercent,
+		account.RiskAmount,
 		account.TargetPercent,
 		account.StopLossPercent,
 		account.OrderTimeout,
+		account.MaxDailyLoss,
+		account.MaxLossStreak,
+		account.MaxDrawdown,
+		account.EntryMode,
+		account.EntryChases,
+		account.EntrySlippage,
+		account.EntryTimeout,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to save account: %w", err)
@@ -428,8 +907,10 @@ func (r *Repository) UpdateAccount(account *models.BinanceAccount) error {
 	query := `
 		UPDATE binance_accounts
 		SET name = ?, api_key = ?, api_secret = ?, is_testnet = ?, is_active = ?, is_default = ?,
-			leverage = ?, order_amount = ?, target_percent = ?, stoploss_percent = ?, order_timeout = ?,
-			updated_at = ?
+			leverage = ?, order_amount = ?, sizing_mode = ?, balance_percent = ?, risk_amount = ?,
+			target_percent = ?, stoploss_percent = ?, order_timeout = ?,
+			max_daily_loss = ?, max_loss_streak = ?, max_drawdown = ?,
+			entry_mode = ?, entry_chases = ?, entry_slippage = ?, entry_timeout = ?, updated_at = ?
 		WHERE id = ?
 	`
 	_, err := r.db.Exec(query,
@@ -441,9 +922,19 @@ func (r *Repository) UpdateAccount(account *models.BinanceAccount) error {
 		account.IsDefault,
 		account.Leverage,
 		account.OrderAmount,
+		account.SizingMode,
+		account.BalancePercent,
+		account.RiskAmount,
 		account.TargetPercent,
 		account.StopLossPercent,
 		account.OrderTimeout,
+		account.MaxDailyLoss,
+		account.MaxLossStreak,
+		account.MaxDrawdown,
+		account.EntryMode,
+		account.EntryChases,
+		account.EntrySlippage,
+		account.EntryTimeout,
 		time.Now(),
 		account.ID,
 	)
@@ -465,29 +956,11 @@ func (r *Repository) UpdateAccount(account *models.BinanceAccount) error {
 // GetAccount retrieves an account by ID
 func (r *Repository) GetAccount(id int64) (*models.BinanceAccount, error) {
 	query := `
-		SELECT id, name, api_key, api_secret, is_testnet, is_active, is_default,
-			leverage, order_amount, target_percent, stoploss_percent, order_timeout,
-			created_at, updated_at
+		SELECT ` + accountColumns + `
 		FROM binance_accounts
 		WHERE id = ?
 	`
-	account := &models.BinanceAccount{}
-	err := r.db.QueryRow(query, id).Scan(
-		&account.ID,
-		&account.Name,
-		&account.APIKey,
-		&account.APISecret,
-		&account.IsTestnet,
-		&account.IsActive,
-		&account.IsDefault,
-		&account.Leverage,
-		&account.OrderAmount,
-		&account.TargetPercent,
-		&account.StopLossPercent,
-		&account.OrderTimeout,
-		&account.CreatedAt,
-		&account.UpdatedAt,
-	)
+	account, err := scanAccount(r.db.QueryRow(query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -500,9 +973,7 @@ func (r *Repository) GetAccount(id int64) (*models.BinanceAccount, error) {
 // GetAllAccounts retrieves all Binance accounts
 func (r *Repository) GetAllAccounts() ([]*models.BinanceAccount, error) {
 	query := `
-		SELECT id, name, api_key, api_secret, is_testnet, is_active, is_default,
-			leverage, order_amount, target_percent, stoploss_percent, order_timeout,
-			created_at, updated_at
+		SELECT ` + accountColumns + `
 		FROM binance_accounts
 		ORDER BY is_default DESC, name ASC
 	`
@@ -514,23 +985,7 @@ func (r *Repository) GetAllAccounts() ([]*models.BinanceAccount, error) {
 
 	var accounts []*models.BinanceAccount
 	for rows.Next() {
-		account := &models.BinanceAccount{}
-		err := rows.Scan(
-			&account.ID,
-			&account.Name,
-			&account.APIKey,
-			&account.APISecret,
-			&account.IsTestnet,
-			&account.IsActive,
-			&account.IsDefault,
-			&account.Leverage,
-			&account.OrderAmount,
-			&account.TargetPercent,
-			&account.StopLossPercent,
-			&account.OrderTimeout,
-			&account.CreatedAt,
-			&account.UpdatedAt,
-		)
+		account, err := scanAccount(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan account: %w", err)
 		}
@@ -543,9 +998,7 @@ func (r *Repository) GetAllAccounts() ([]*models.BinanceAccount, error) {
 // GetActiveAccounts retrieves all active Binance accounts
 func (r *Repository) GetActiveAccounts() ([]*models.BinanceAccount, error) {
 	query := `
-		SELECT id, name, api_key, api_secret, is_testnet, is_active, is_default,
-			leverage, order_amount, target_percent, stoploss_percent, order_timeout,
-			created_at, updated_at
+		SELECT ` + accountColumns + `
 		FROM binance_accounts
 		WHERE is_active = 1
 		ORDER BY is_default DESC, name ASC
@@ -558,23 +1011,7 @@ func (r *Repository) GetActiveAccounts() ([]*models.BinanceAccount, error) {
 
 	var accounts []*models.BinanceAccount
 	for rows.Next() {
-		account := &models.BinanceAccount{}
-		err := rows.Scan(
-			&account.ID,
-			&account.Name,
-			&account.APIKey,
-			&account.APISecret,
-			&account.IsTestnet,
-			&account.IsActive,
-			&account.IsDefault,
-			&account.Leverage,
-			&account.OrderAmount,
-			&account.TargetPercent,
-			&account.StopLossPercent,
-			&account.OrderTimeout,
-			&account.CreatedAt,
-			&account.UpdatedAt,
-		)
+		account, err := scanAccount(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan account: %w", err)
 		}
@@ -587,30 +1024,12 @@ func (r *Repository) GetActiveAccounts() ([]*models.BinanceAccount, error) {
 // GetDefaultAccount retrieves the default Binance account
 func (r *Repository) GetDefaultAccount() (*models.BinanceAccount, error) {
 	query := `
-		SELECT id, name, api_key, api_secret, is_testnet, is_active, is_default,
-			leverage, order_amount, target_percent, stoploss_percent, order_timeout,
-			created_at, updated_at
+		SELECT ` + accountColumns + `
 		FROM binance_accounts
 		WHERE is_default = 1 AND is_active = 1
 		LIMIT 1
 	`
-	account := &models.BinanceAccount{}
-	err := r.db.QueryRow(query).Scan(
-		&account.ID,
-		&account.Name,
-		&account.APIKey,
-		&account.APISecret,
-		&account.IsTestnet,
-		&account.IsActive,
-		&account.IsDefault,
-		&account.Leverage,
-		&account.OrderAmount,
-		&account.TargetPercent,
-		&account.StopLossPercent,
-		&account.OrderTimeout,
-		&account.CreatedAt,
-		&account.UpdatedAt,
-	)
+	account, err := scanAccount(r.db.QueryRow(query))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -632,6 +1051,10 @@ func (r *Repository) DeleteAccount(id int64) error {
 		return fmt.Errorf("cannot delete account with open positions")
 	}
 
+	if _, err := r.db.Exec(`DELETE FROM risk_breakers WHERE account_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete risk breaker: %w", err)
+	}
+
 	query := `DELETE FROM binance_accounts WHERE id = ?`
 	_, err = r.db.Exec(query, id)
 	if err != nil {
@@ -645,14 +1068,20 @@ func (r *Repository) DeleteAccount(id int64) error {
 // SaveSignal saves a trading signal to the database
 func (r *Repository) SaveSignal(signal *models.Signal) error {
 	query := `
-		INSERT INTO signals (message_id, channel_id, symbol, raw_message, parsed_at, status)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO signals (message_id, channel_id, symbol, raw_message, entry_price, source, posted_at, parsed_at, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
+	if signal.Source == "" {
+		signal.Source = models.SignalSourceTelegram
+	}
 	result, err := r.db.Exec(query,
 		signal.MessageID,
 		signal.ChannelID,
 		signal.Symbol,
 		signal.RawMessage,
+		signal.EntryPrice,
+		signal.Source,
+		signal.PostedAt,
 		signal.ParsedAt,
 		signal.Status,
 	)
@@ -669,6 +1098,16 @@ func (r *Repository) SaveSignal(signal *models.Signal) error {
 	return nil
 }
 
+// SignalExists reports whether a signal was already recorded for a message
+func (r *Repository) SignalExists(channelID, messageID int64) (bool, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM signals WHERE channel_id = ? AND message_id = ?`, channelID, messageID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check signal: %w", err)
+	}
+	return count > 0, nil
+}
+
 // UpdateSignalStatus updates the status of a signal
 func (r *Repository) UpdateSignalStatus(signalID int64, status string, processedAt *time.Time, errorMsg string) error {
 	query := `UPDATE signals SET status = ?, processed_at = ?, error = ? WHERE id = ?`
@@ -679,12 +1118,66 @@ func (r *Repository) UpdateSignalStatus(signalID int64, status string, processed
 	return nil
 }
 
+// GetRecentSignals returns the most recent signals, optionally only those from one source
+func (r *Repository) GetRecentSignals(source string, limit int) ([]*models.Signal, error) {
+	query := `
+		SELECT id, message_id, channel_id, symbol, raw_message, entry_price, COALESCE(source, 'telegram'),
+			posted_at, parsed_at, processed_at, COALESCE(status, ''), COALESCE(error, '')
+		FROM signals
+	`
+	args := []interface{}{}
+	if source != "" {
+		query += " WHERE source = ?"
+		args = append(args, source)
+	}
+	query += " ORDER BY parsed_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query signals: %w", err)
+	}
+	defer rows.Close()
+
+	signals := make([]*models.Signal, 0)
+	for rows.Next() {
+		signal := &models.Signal{}
+		var postedAt sql.NullTime
+		err := rows.Scan(
+			&signal.ID,
+			&signal.MessageID,
+			&signal.ChannelID,
+			&signal.Symbol,
+			&signal.RawMessage,
+			&signal.EntryPrice,
+			&signal.Source,
+			&postedAt,
+			&signal.ParsedAt,
+			&signal.ProcessedAt,
+			&signal.Status,
+			&signal.Error,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan signal: %w", err)
+		}
+		// Signals saved before posted_at was recorded date from parsing
+		signal.PostedAt = signal.ParsedAt
+		if postedAt.Valid {
+			signal.PostedAt = postedAt.Time
+		}
+		signals = append(signals, signal)
+	}
+
+	return signals, nil
+}
+
 // SavePosition saves a trading position to the database
 func (r *Repository) SavePosition(pos *models.Position) error {
 	query := `
 		INSERT INTO positions (signal_id, account_id, symbol, side, entry_price, quantity, leverage,
-		                       take_profit_price, stop_loss_price, status, opened_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		                       take_profit_price, stop_loss_price, status, opened_at,
+		                       sizing_mode, sizing_input, sizing_balance)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	result, err := r.db.Exec(query,
 		pos.SignalID,
@@ -698,6 +1191,9 @@ func (r *Repository) SavePosition(pos *models.Position) error {
 		pos.StopLossPrice,
 		pos.Status,
 		pos.OpenedAt,
+		pos.SizingMode,
+		pos.SizingInput,
+		pos.SizingBalance,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to save position: %w", err)
@@ -712,50 +1208,446 @@ func (r *Repository) SavePosition(pos *models.Position) error {
 	return nil
 }
 
-// ClosePosition closes a position and calculates PnL
+// ClosePosition closes a position. Its PnL comes from the recorded fills
+// when its closing fills are recorded, otherwise it is estimated from the
+// entry and exit prices plus the partial closes; closing fills recorded
+// later replace the estimate.
 func (r *Repository) ClosePosition(positionID int64, exitPrice float64, closedAt time.Time) error {
 	// First get the position to calculate PnL
 	pos, err := r.GetPosition(positionID)
 	if err != nil {
 		return fmt.Errorf("failed to get position: %w", err)
 	}
-
-	// Calculate PnL
-	var pnl, pnlPercent float64
-	if pos.Side == "LONG" {
-		pnl = (exitPrice - pos.EntryPrice) * pos.Quantity * float64(pos.Leverage)
-		pnlPercent = ((exitPrice - pos.EntryPrice) / pos.EntryPrice) * 100 * float64(pos.Leverage)
-	} else { // SHORT
-		pnl = (pos.EntryPrice - exitPrice) * pos.Quantity * float64(pos.Leverage)
-		pnlPercent = ((pos.EntryPrice - exitPrice) / pos.EntryPrice) * 100 * float64(pos.Leverage)
+	if pos == nil {
+		return fmt.Errorf("position %d not found", positionID)
 	}
 
+	pnl := pos.ClosedPnL + estimatePnL(pos, exitPrice, pos.Quantity)
+
 	query := `
 		UPDATE positions
 		SET status = 'closed', exit_price = ?, closed_at = ?, pnl = ?, pnl_percent = ?
 		WHERE id = ?
 	`
-	_, err = r.db.Exec(query, exitPrice, closedAt, pnl, pnlPercent, positionID)
+	_, err = r.db.Exec(query, exitPrice, closedAt, pnl, pnlPercent(pos, pnl), positionID)
 	if err != nil {
 		return fmt.Errorf("failed to close position: %w", err)
 	}
 
-	return nil
+	return r.refreshPositionPnL(positionID)
 }
 
-// GetPosition retrieves a position by ID
-func (r *Repository) GetPosition(positionID int64) (*models.Position, error) {
+// PartiallyClosePosition records a partial close of a position at an exit
+// price, leaving quantity open. The closed part's estimated PnL is kept
+// for when the position closes.
+func (r *Repository) PartiallyClosePosition(positionID int64, quantity, exitPrice float64) error {
+	pos, err := r.GetPosition(positionID)
+	if err != nil {
+		return fmt.Errorf("failed to get position: %w", err)
+	}
+	if pos == nil {
+		return fmt.Errorf("position %d not found", positionID)
+	}
+
+	closed := pos.Quantity - quantity
 	query := `
-		SELECT id, signal_id, account_id, symbol, side, entry_price, quantity, leverage,
-		       take_profit_price, stop_loss_price, status, opened_at, closed_at,
-		       exit_price, pnl, pnl_percent
-		FROM positions
+		UPDATE positions
+		SET quantity = ?, closed_quantity = closed_quantity + ?, closed_pnl = closed_pnl + ?
 		WHERE id = ?
 	`
-	pos := &models.Position{}
-	err := r.db.QueryRow(query, positionID).Scan(
-		&pos.ID,
-		&pos.SignalID,
+	if _, err := r.db.Exec(query, quantity, closed, estimatePnL(pos, exitPrice, closed), positionID); err != nil {
+		return fmt.Errorf("failed to update position quantity: %w", err)
+	}
+	return nil
+}
+
+// estimatePnL returns the PnL of closing quantity of a position at an exit
+// price. Quantity is the full size, so leverage only scales the return on
+// margin, not the PnL itself.
+func estimatePnL(pos *models.Position, exitPrice, quantity float64) float64 {
+	direction := 1.0
+	if pos.Side == "SHORT" {
+		direction = -1.0
+	}
+	return (exitPrice - pos.EntryPrice) * quantity * direction
+}
+
+// pnlPercent returns PnL as a percentage of the position's initial margin
+func pnlPercent(pos *models.Position, pnl float64) float64 {
+	margin := pos.EntryPrice * (pos.Quantity + pos.ClosedQuantity) / float64(max(pos.Leverage, 1))
+	if margin == 0 {
+		return 0
+	}
+	return pnl / margin * 100
+}
+
+// SaveFill records a fill, ignoring trades that are already recorded. A
+// recorded fill without a position is attributed to the fill's position.
+// The position's realized PnL and commission are updated from its fills.
+func (r *Repository) SaveFill(fill *models.Fill) error {
+	query := `
+		INSERT INTO fills (account_id, position_id, binance_order_id, trade_id, symbol, side, price,
+		                   quantity, realized_pnl, commission, commission_asset, trade_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, symbol, trade_id) DO UPDATE SET
+			position_id = excluded.position_id
+		WHERE fills.position_id = 0 AND excluded.position_id != 0
+	`
+	_, err := r.db.Exec(query,
+		fill.AccountID,
+		fill.PositionID,
+		fill.BinanceOrderID,
+		fill.TradeID,
+		fill.Symbol,
+		fill.Side,
+		fill.Price,
+		fill.Quantity,
+		fill.RealizedPnL,
+		fill.Commission,
+		fill.CommissionAsset,
+		fill.TradeTime,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save fill: %w", err)
+	}
+
+	if fill.PositionID == 0 {
+		return nil
+	}
+	return r.refreshPositionPnL(fill.PositionID)
+}
+
+// AssignFillsToPosition attributes an order's unattributed fills to a
+// position, for fills that arrived before the position was recorded
+func (r *Repository) AssignFillsToPosition(accountID int64, binanceOrderID string, positionID int64) error {
+	query := `UPDATE fills SET position_id = ? WHERE account_id = ? AND binance_order_id = ? AND position_id = 0`
+	result, err := r.db.Exec(query, positionID, accountID, binanceOrderID)
+	if err != nil {
+		return fmt.Errorf("failed to assign fills: %w", err)
+	}
+
+	if assigned, _ := result.RowsAffected(); assigned == 0 {
+		return nil
+	}
+	return r.refreshPositionPnL(positionID)
+}
+
+// SaveIncome records income history entries, ignoring entries that are
+// already recorded, and returns how many were new
+func (r *Repository) SaveIncome(entries []*models.Income) (int, error) {
+	query := `
+		INSERT INTO income (account_id, position_id, tran_id, income_type, symbol, income, asset, info, trade_id, time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, income_type, tran_id) DO NOTHING
+	`
+
+	saved := 0
+	for _, entry := range entries {
+		result, err := r.db.Exec(query,
+			entry.AccountID,
+			entry.PositionID,
+			entry.TranID,
+			entry.IncomeType,
+			entry.Symbol,
+			entry.Income,
+			entry.Asset,
+			entry.Info,
+			entry.TradeID,
+			entry.Time.UTC(),
+		)
+		if err != nil {
+			return saved, fmt.Errorf("failed to save income: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			saved++
+		}
+	}
+
+	return saved, nil
+}
+
+// GetLatestIncomeTime returns the time of an account's latest income entry
+// of a type, or the zero time when there is none
+func (r *Repository) GetLatestIncomeTime(accountID int64, incomeType string) (time.Time, error) {
+	var latest time.Time
+	query := `SELECT time FROM income WHERE account_id = ? AND income_type = ? ORDER BY julianday(time) DESC LIMIT 1`
+	err := r.db.QueryRow(query, accountID, incomeType).Scan(&latest)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get latest income: %w", err)
+	}
+	return latest, nil
+}
+
+// AttributeIncome attributes an account's unattributed income entries to
+// positions: through the fill of the entry's trade when recorded, otherwise
+// to the position of the symbol that was open at the entry's time (with a
+// minute of slack around the open and close). Positions' funding is then
+// recomputed from their FUNDING_FEE entries.
+func (r *Repository) AttributeIncome(accountID int64) error {
+	query := `
+		UPDATE income SET position_id = COALESCE(
+			(SELECT f.position_id FROM fills f
+			 WHERE f.account_id = income.account_id AND f.symbol = income.symbol
+			   AND income.trade_id != '' AND CAST(f.trade_id AS TEXT) = income.trade_id AND f.position_id != 0
+			 LIMIT 1),
+			(SELECT p.id FROM positions p
+			 WHERE p.account_id = income.account_id AND p.symbol = income.symbol
+			   AND julianday(income.time) >= julianday(p.opened_at) - 60.0 / 86400
+			   AND (p.closed_at IS NULL OR julianday(income.time) <= julianday(p.closed_at) + 60.0 / 86400)
+			 ORDER BY julianday(p.opened_at) DESC
+			 LIMIT 1),
+			0)
+		WHERE account_id = ? AND position_id = 0 AND symbol != ''
+	`
+	if _, err := r.db.Exec(query, accountID); err != nil {
+		return fmt.Errorf("failed to attribute income: %w", err)
+	}
+
+	query = `
+		UPDATE positions SET funding = (
+			SELECT COALESCE(SUM(i.income), 0) FROM income i
+			WHERE i.position_id = positions.id AND i.income_type = ?
+		)
+		WHERE account_id = ? AND id IN (SELECT position_id FROM income WHERE income_type = ? AND position_id != 0)
+	`
+	if _, err := r.db.Exec(query, models.IncomeFundingFee, accountID, models.IncomeFundingFee); err != nil {
+		return fmt.Errorf("failed to update position funding: %w", err)
+	}
+
+	return nil
+}
+
+// incomeFilterClause builds the WHERE clause for an income filter
+func incomeFilterClause(accountID int64, incomeType, symbol string, from, to time.Time) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if accountID != 0 {
+		conditions = append(conditions, "account_id = ?")
+		args = append(args, accountID)
+	}
+	if incomeType != "" {
+		conditions = append(conditions, "income_type = ?")
+		args = append(args, incomeType)
+	}
+	if symbol != "" {
+		conditions = append(conditions, "symbol = ?")
+		args = append(args, symbol)
+	}
+	// julianday normalizes the timezone offsets stored by the driver
+	if !from.IsZero() {
+		conditions = append(conditions, "julianday(time) >= julianday(?)")
+		args = append(args, from)
+	}
+	if !to.IsZero() {
+		conditions = append(conditions, "julianday(time) < julianday(?)")
+		args = append(args, to)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// GetIncome retrieves income history entries matching the filter, newest first
+func (r *Repository) GetIncome(filter models.IncomeFilter) ([]*models.Income, error) {
+	query := `
+		SELECT id, account_id, position_id, tran_id, income_type, symbol, income, asset, info, trade_id, time
+		FROM income
+	`
+	where, args := incomeFilterClause(filter.AccountID, filter.IncomeType, filter.Symbol, filter.From, filter.To)
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	query += where + " ORDER BY julianday(time) DESC, tran_id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, filter.Offset)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query income: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*models.Income{}
+	for rows.Next() {
+		entry := &models.Income{}
+		err := rows.Scan(
+			&entry.ID,
+			&entry.AccountID,
+			&entry.PositionID,
+			&entry.TranID,
+			&entry.IncomeType,
+			&entry.Symbol,
+			&entry.Income,
+			&entry.Asset,
+			&entry.Info,
+			&entry.TradeID,
+			&entry.Time,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan income: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// GetIncomeSummary totals the income history matching a stats filter by
+// type. The channel of the filter is ignored.
+func (r *Repository) GetIncomeSummary(filter models.StatsFilter) (*models.IncomeSummary, error) {
+	query := `SELECT income_type, COALESCE(SUM(income), 0) FROM income`
+	where, args := incomeFilterClause(filter.AccountID, "", filter.Symbol, filter.From, filter.To)
+
+	rows, err := r.db.Query(query+where+" GROUP BY income_type", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum income: %w", err)
+	}
+	defer rows.Close()
+
+	summary := &models.IncomeSummary{}
+	for rows.Next() {
+		var incomeType string
+		var total float64
+		if err := rows.Scan(&incomeType, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan income total: %w", err)
+		}
+
+		switch incomeType {
+		case models.IncomeRealizedPnL:
+			summary.RealizedPnL = total
+		case models.IncomeCommission:
+			summary.Commission = -total
+		case models.IncomeFundingFee:
+			summary.Funding = total
+		case models.IncomeTransfer:
+			summary.Transfers = total
+		}
+	}
+	summary.NetPnL = summary.RealizedPnL - summary.Commission + summary.Funding
+
+	return summary, nil
+}
+
+// GetFillsByPosition retrieves the fills of a position, oldest first
+func (r *Repository) GetFillsByPosition(positionID int64) ([]*models.Fill, error) {
+	query := `
+		SELECT id, account_id, position_id, binance_order_id, trade_id, symbol, side, price,
+		       quantity, realized_pnl, commission, commission_asset, trade_time
+		FROM fills
+		WHERE position_id = ?
+		ORDER BY trade_time ASC, trade_id ASC
+	`
+	rows, err := r.db.Query(query, positionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fills: %w", err)
+	}
+	defer rows.Close()
+
+	var fills []*models.Fill
+	for rows.Next() {
+		fill := &models.Fill{}
+		err := rows.Scan(
+			&fill.ID,
+			&fill.AccountID,
+			&fill.PositionID,
+			&fill.BinanceOrderID,
+			&fill.TradeID,
+			&fill.Symbol,
+			&fill.Side,
+			&fill.Price,
+			&fill.Quantity,
+			&fill.RealizedPnL,
+			&fill.Commission,
+			&fill.CommissionAsset,
+			&fill.TradeTime,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan fill: %w", err)
+		}
+		fills = append(fills, fill)
+	}
+
+	return fills, nil
+}
+
+// refreshPositionPnL sums a position's fills into its realized PnL and
+// commission. A closed position's estimated PnL is replaced by the realized
+// PnL once its closing fills cover the position's full size, partial closes
+// included; until then the entry fills alone would report a PnL of 0.
+// Commission paid in other assets (e.g. BNB) is not converted and left out.
+func (r *Repository) refreshPositionPnL(positionID int64) error {
+	pos, err := r.GetPosition(positionID)
+	if err != nil || pos == nil {
+		return err
+	}
+
+	exitSide := "SELL"
+	if pos.Side == "SHORT" {
+		exitSide = "BUY"
+	}
+
+	var count int
+	var realized, commission, closedQty float64
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(realized_pnl), 0),
+		       COALESCE(SUM(CASE WHEN commission_asset IN ('USDT', 'USDC', '') THEN commission ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN side = ? THEN quantity ELSE 0 END), 0)
+		FROM fills
+		WHERE position_id = ?
+	`
+	if err := r.db.QueryRow(query, exitSide, positionID).Scan(&count, &realized, &commission, &closedQty); err != nil {
+		return fmt.Errorf("failed to sum fills: %w", err)
+	}
+	if count == 0 {
+		return nil
+	}
+
+	// Quantities are rounded to the lot size, the tolerance absorbs float error.
+	// Partial closes count towards the closing fills of the full size.
+	if pos.Status != "closed" || closedQty < (pos.Quantity+pos.ClosedQuantity)*(1-1e-9) {
+		_, err = r.db.Exec(`UPDATE positions SET realized_pnl = ?, commission = ? WHERE id = ?`, realized, commission, positionID)
+	} else {
+		query := `UPDATE positions SET realized_pnl = ?, commission = ?, pnl = ?, pnl_percent = ? WHERE id = ?`
+		_, err = r.db.Exec(query, realized, commission, realized, pnlPercent(pos, realized), positionID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update position PnL: %w", err)
+	}
+
+	return nil
+}
+
+// UpdatePositionTPSL sets the take profit and stop loss prices of a position
+func (r *Repository) UpdatePositionTPSL(positionID int64, takeProfitPrice, stopLossPrice float64) error {
+	query := `UPDATE positions SET take_profit_price = ?, stop_loss_price = ? WHERE id = ?`
+	_, err := r.db.Exec(query, takeProfitPrice, stopLossPrice, positionID)
+	if err != nil {
+		return fmt.Errorf("failed to update position TP/SL: %w", err)
+	}
+	return nil
+}
+
+// GetPosition retrieves a position by ID
+func (r *Repository) GetPosition(positionID int64) (*models.Position, error) {
+	query := `
+		SELECT id, signal_id, account_id, symbol, side, entry_price, quantity, leverage,
+		       take_profit_price, stop_loss_price, status, opened_at, closed_at,
+		       exit_price, pnl, pnl_percent, realized_pnl, commission, funding,
+		       sizing_mode, sizing_input, sizing_balance, closed_quantity, closed_pnl
+		FROM positions
+		WHERE id = ?
+	`
+	pos := &models.Position{}
+	err := r.db.QueryRow(query, positionID).Scan(
+		&pos.ID,
+		&pos.SignalID,
 		&pos.AccountID,
 		&pos.Symbol,
 		&pos.Side,
@@ -770,6 +1662,14 @@ func (r *Repository) GetPosition(positionID int64) (*models.Position, error) {
 		&pos.ExitPrice,
 		&pos.PnL,
 		&pos.PnLPercent,
+		&pos.RealizedPnL,
+		&pos.Commission,
+		&pos.Funding,
+		&pos.SizingMode,
+		&pos.SizingInput,
+		&pos.SizingBalance,
+		&pos.ClosedQuantity,
+		&pos.ClosedPnL,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -785,7 +1685,8 @@ func (r *Repository) GetOpenPositions() ([]*models.Position, error) {
 	query := `
 		SELECT id, signal_id, account_id, symbol, side, entry_price, quantity, leverage,
 		       take_profit_price, stop_loss_price, status, opened_at, closed_at,
-		       exit_price, pnl, pnl_percent
+		       exit_price, pnl, pnl_percent, realized_pnl, commission, funding,
+		       sizing_mode, sizing_input, sizing_balance, closed_quantity, closed_pnl
 		FROM positions
 		WHERE status = 'open'
 		ORDER BY opened_at DESC
@@ -798,7 +1699,8 @@ func (r *Repository) GetAllPositions(limit int) ([]*models.Position, error) {
 	query := `
 		SELECT id, signal_id, account_id, symbol, side, entry_price, quantity, leverage,
 		       take_profit_price, stop_loss_price, status, opened_at, closed_at,
-		       exit_price, pnl, pnl_percent
+		       exit_price, pnl, pnl_percent, realized_pnl, commission, funding,
+		       sizing_mode, sizing_input, sizing_balance, closed_quantity, closed_pnl
 		FROM positions
 		ORDER BY opened_at DESC
 		LIMIT ?
@@ -806,6 +1708,26 @@ func (r *Repository) GetAllPositions(limit int) ([]*models.Position, error) {
 	return r.queryPositions(query, limit)
 }
 
+// GetSignalPosition retrieves the latest position an account opened for a
+// signal, or nil
+func (r *Repository) GetSignalPosition(accountID, signalID int64) (*models.Position, error) {
+	query := `
+		SELECT id, signal_id, account_id, symbol, side, entry_price, quantity, leverage,
+		       take_profit_price, stop_loss_price, status, opened_at, closed_at,
+		       exit_price, pnl, pnl_percent, realized_pnl, commission, funding,
+		       sizing_mode, sizing_input, sizing_balance, closed_quantity, closed_pnl
+		FROM positions
+		WHERE account_id = ? AND signal_id = ?
+		ORDER BY id DESC
+		LIMIT 1
+	`
+	positions, err := r.queryPositions(query, accountID, signalID)
+	if err != nil || len(positions) == 0 {
+		return nil, err
+	}
+	return positions[0], nil
+}
+
 // queryPositions is a helper function to query positions
 func (r *Repository) queryPositions(query string, args ...interface{}) ([]*models.Position, error) {
 	rows, err := r.db.Query(query, args...)
@@ -834,6 +1756,14 @@ func (r *Repository) queryPositions(query string, args ...interface{}) ([]*model
 			&pos.ExitPrice,
 			&pos.PnL,
 			&pos.PnLPercent,
+			&pos.RealizedPnL,
+			&pos.Commission,
+			&pos.Funding,
+			&pos.SizingMode,
+			&pos.SizingInput,
+			&pos.SizingBalance,
+			&pos.ClosedQuantity,
+			&pos.ClosedPnL,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan position: %w", err)
@@ -847,13 +1777,14 @@ func (r *Repository) queryPositions(query string, args ...interface{}) ([]*model
 // SaveOrder saves an order to the database
 func (r *Repository) SaveOrder(order *models.Order) error {
 	query := `
-		INSERT INTO orders (position_id, binance_order_id, symbol, side, type, orig_qty,
+		INSERT INTO orders (position_id, binance_order_id, client_order_id, symbol, side, type, orig_qty,
 		                   executed_qty, price, stop_price, status, time_in_force, order_purpose)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	result, err := r.db.Exec(query,
 		order.PositionID,
 		order.BinanceOrderID,
+		order.ClientOrderID,
 		order.Symbol,
 		order.Side,
 		order.Type,
@@ -901,10 +1832,35 @@ func (r *Repository) UpdateOrderStatus(binanceOrderID string, status string, exe
 	return nil
 }
 
+// OrderExists reports whether an order is recorded
+func (r *Repository) OrderExists(binanceOrderID string) (bool, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM orders WHERE binance_order_id = ?`, binanceOrderID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check order: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetOrderPositionID returns the position an order belongs to, or 0 when the
+// order is not recorded or has no position
+func (r *Repository) GetOrderPositionID(binanceOrderID string) (int64, error) {
+	var positionID int64
+	query := `SELECT position_id FROM orders WHERE binance_order_id = ? AND position_id != 0 ORDER BY id DESC LIMIT 1`
+	err := r.db.QueryRow(query, binanceOrderID).Scan(&positionID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get order position: %w", err)
+	}
+	return positionID, nil
+}
+
 // GetOrdersByPosition retrieves all orders for a position
 func (r *Repository) GetOrdersByPosition(positionID int64) ([]*models.Order, error) {
 	query := `
-		SELECT id, position_id, binance_order_id, symbol, side, type, orig_qty,
+		SELECT id, position_id, binance_order_id, COALESCE(client_order_id, ''), symbol, side, type, orig_qty,
 		       executed_qty, price, stop_price, status, time_in_force, created_at,
 		       updated_at, filled_at, canceled_at, order_purpose
 		FROM orders
@@ -924,6 +1880,7 @@ func (r *Repository) GetOrdersByPosition(positionID int64) ([]*models.Order, err
 			&order.ID,
 			&order.PositionID,
 			&order.BinanceOrderID,
+			&order.ClientOrderID,
 			&order.Symbol,
 			&order.Side,
 			&order.Type,
@@ -948,85 +1905,163 @@ func (r *Repository) GetOrdersByPosition(positionID int64) ([]*models.Order, err
 	return orders, nil
 }
 
-// GetTradingStats calculates trading statistics
+// GetTradingStats calculates trading statistics across all accounts and channels
 func (r *Repository) GetTradingStats() (*models.TradingStats, error) {
-	stats := &models.TradingStats{}
+	return r.GetTradingStatsFiltered(models.StatsFilter{})
+}
 
-	// Count positions
-	err := r.db.QueryRow(`
-		SELECT COUNT(*) FROM positions WHERE status = 'closed'
-	`).Scan(&stats.TotalTrades)
+// GetTradingStatsFiltered calculates trading statistics for the positions matching the filter
+func (r *Repository) GetTradingStatsFiltered(filter models.StatsFilter) (*models.TradingStats, error) {
+	trades, err := r.GetClosedTrades(filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to count total trades: %w", err)
+		return nil, err
 	}
 
-	// Count winning/losing trades
-	err = r.db.QueryRow(`
-		SELECT COUNT(*) FROM positions WHERE status = 'closed' AND pnl > 0
-	`).Scan(&stats.WinningTrades)
-	if err != nil {
-		return nil, fmt.Errorf("failed to count winning trades: %w", err)
-	}
+	stats := analytics.Compute(trades)
 
-	err = r.db.QueryRow(`
-		SELECT COUNT(*) FROM positions WHERE status = 'closed' AND pnl <= 0
-	`).Scan(&stats.LosingTrades)
-	if err != nil {
-		return nil, fmt.Errorf("failed to count losing trades: %w", err)
+	if filter.AccountID != 0 && filter.ChannelID == 0 {
+		if stats.Income, err = r.GetIncomeSummary(filter); err != nil {
+			return nil, err
+		}
 	}
 
-	// Calculate total PnL
-	err = r.db.QueryRow(`
-		SELECT COALESCE(SUM(pnl), 0) FROM positions WHERE status = 'closed'
-	`).Scan(&stats.TotalPnL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to calculate total PnL: %w", err)
+	// Count open positions
+	query := `
+		SELECT COUNT(*)
+		FROM positions p
+		LEFT JOIN signals s ON s.id = p.signal_id
+		WHERE p.status = 'open'
+	`
+	where, args := statsFilterClause(filter)
+	if err := r.db.QueryRow(query+where, args...).Scan(&stats.OpenPositions); err != nil {
+		return nil, fmt.Errorf("failed to count open positions: %w", err)
 	}
 
-	// Calculate average win/loss
-	err = r.db.QueryRow(`
-		SELECT COALESCE(AVG(pnl), 0) FROM positions WHERE status = 'closed' AND pnl > 0
-	`).Scan(&stats.AverageWin)
+	return stats, nil
+}
+
+// GetTradingStatsBreakdown calculates statistics grouped by account, channel, symbol or time bucket
+func (r *Repository) GetTradingStatsBreakdown(filter models.StatsFilter, groupBy string) ([]*models.StatsBucket, error) {
+	trades, err := r.GetClosedTrades(filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to calculate average win: %w", err)
+		return nil, err
 	}
 
-	err = r.db.QueryRow(`
-		SELECT COALESCE(AVG(pnl), 0) FROM positions WHERE status = 'closed' AND pnl <= 0
-	`).Scan(&stats.AverageLoss)
-	if err != nil {
-		return nil, fmt.Errorf("failed to calculate average loss: %w", err)
+	buckets, err := analytics.Group(trades, groupBy)
+	if err != nil || groupBy != analytics.GroupByAccount || filter.ChannelID != 0 {
+		return buckets, err
 	}
 
-	// Find largest win/loss
-	err = r.db.QueryRow(`
-		SELECT COALESCE(MAX(pnl), 0) FROM positions WHERE status = 'closed'
-	`).Scan(&stats.LargestWin)
-	if err != nil {
-		return nil, fmt.Errorf("failed to find largest win: %w", err)
+	for _, bucket := range buckets {
+		accountFilter := filter
+		accountFilter.AccountID, _ = strconv.ParseInt(bucket.Key, 10, 64)
+		if bucket.Stats.Income, err = r.GetIncomeSummary(accountFilter); err != nil {
+			return nil, err
+		}
 	}
 
-	err = r.db.QueryRow(`
-		SELECT COALESCE(MIN(pnl), 0) FROM positions WHERE status = 'closed'
-	`).Scan(&stats.LargestLoss)
+	return buckets, nil
+}
+
+// GetClosedTrades loads closed positions matching the filter, ordered by close time.
+// Each trade is linked back to its source channel through its signal.
+func (r *Repository) GetClosedTrades(filter models.StatsFilter) ([]*analytics.Trade, error) {
+	query := `
+		SELECT p.id, p.account_id, COALESCE(a.name, ''), COALESCE(s.channel_id, 0), COALESCE(c.title, ''),
+		       p.symbol, p.side, p.entry_price, p.quantity, p.stop_loss_price, p.opened_at, p.closed_at,
+		       p.exit_price, p.pnl, p.pnl_percent, p.realized_pnl, p.commission, p.funding
+		FROM positions p
+		LEFT JOIN signals s ON s.id = p.signal_id
+		LEFT JOIN channels c ON c.channel_id = s.channel_id
+		LEFT JOIN binance_accounts a ON a.id = p.account_id
+		WHERE p.status = 'closed'
+	`
+	where, args := statsFilterClause(filter)
+
+	rows, err := r.db.Query(query+where+" ORDER BY p.closed_at ASC", args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find largest loss: %w", err)
+		return nil, fmt.Errorf("failed to query closed trades: %w", err)
 	}
+	defer rows.Close()
 
-	// Count open positions
-	err = r.db.QueryRow(`
-		SELECT COUNT(*) FROM positions WHERE status = 'open'
-	`).Scan(&stats.OpenPositions)
-	if err != nil {
-		return nil, fmt.Errorf("failed to count open positions: %w", err)
+	var trades []*analytics.Trade
+	for rows.Next() {
+		t := &analytics.Trade{}
+		var closedAt sql.NullTime
+		var exitPrice, pnl, pnlPercent, realizedPnL sql.NullFloat64
+		var commission float64
+
+		err := rows.Scan(
+			&t.PositionID,
+			&t.AccountID,
+			&t.AccountName,
+			&t.ChannelID,
+			&t.ChannelTitle,
+			&t.Symbol,
+			&t.Side,
+			&t.EntryPrice,
+			&t.Quantity,
+			&t.StopLossPrice,
+			&t.OpenedAt,
+			&closedAt,
+			&exitPrice,
+			&pnl,
+			&pnlPercent,
+			&realizedPnL,
+			&commission,
+			&t.Funding,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trade: %w", err)
+		}
+
+		t.ClosedAt = closedAt.Time
+		t.PnL = pnl.Float64
+		t.PnLPercent = pnlPercent.Float64
+		if exitPrice.Valid {
+			t.ExitPrice = exitPrice.Float64
+		}
+		// Actual commission when the fills are known, estimated otherwise
+		if realizedPnL.Valid {
+			t.Fees = commission
+		} else if exitPrice.Valid {
+			t.Fees = analytics.EstimateFees(t.EntryPrice, t.ExitPrice, t.Quantity)
+		}
+
+		// Time range is applied here since timestamps are stored as driver-formatted text
+		if !filter.From.IsZero() && t.ClosedAt.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !t.ClosedAt.Before(filter.To) {
+			continue
+		}
+
+		trades = append(trades, t)
 	}
 
-	// Calculate win rate
-	if stats.TotalTrades > 0 {
-		stats.WinRate = (float64(stats.WinningTrades) / float64(stats.TotalTrades)) * 100
+	return trades, nil
+}
+
+// statsFilterClause builds the extra WHERE conditions for a stats filter.
+// The query must alias positions as p and signals as s.
+func statsFilterClause(filter models.StatsFilter) (string, []interface{}) {
+	var clause string
+	var args []interface{}
+
+	if filter.AccountID != 0 {
+		clause += " AND p.account_id = ?"
+		args = append(args, filter.AccountID)
+	}
+	if filter.ChannelID != 0 {
+		clause += " AND s.channel_id = ?"
+		args = append(args, filter.ChannelID)
+	}
+	if filter.Symbol != "" {
+		clause += " AND p.symbol = ?"
+		args = append(args, filter.Symbol)
 	}
 
-	return stats, nil
+	return clause, args
 }
 
 // SaveSetting saves or updates a setting
@@ -1084,3 +2119,453 @@ func (r *Repository) GetAllSettings() (map[string]string, error) {
 
 	return settings, nil
 }
+
+// haltSettingKey is the setting the emergency halt state is stored under
+const haltSettingKey = "emergency.halt"
+
+// GetHaltState returns the persisted emergency halt state
+func (r *Repository) GetHaltState() (*models.HaltState, error) {
+	state := &models.HaltState{}
+
+	var value string
+	err := r.db.QueryRow(`SELECT value FROM settings WHERE key = ?`, haltSettingKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get halt state: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(value), state); err != nil {
+		return nil, fmt.Errorf("failed to decode halt state: %w", err)
+	}
+
+	return state, nil
+}
+
+// SaveHaltState persists the emergency halt state
+func (r *Repository) SaveHaltState(state *models.HaltState) error {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode halt state: %w", err)
+	}
+
+	return r.SaveSetting(haltSettingKey, string(value))
+}
+
+// ============= Risk Breaker Methods =============
+
+// riskBreakerColumns are the risk_breakers columns read by scanRiskBreaker
+const riskBreakerColumns = `b.account_id, b.tripped, b.reason, b.tripped_at, b.window_start,
+		b.daily_pnl, b.loss_streak, b.equity, b.peak_equity, b.drawdown, b.updated_at`
+
+// scanRiskBreaker reads a risk breaker row selected with riskBreakerColumns
+// and the account name
+func scanRiskBreaker(row rowScanner) (*models.RiskBreaker, error) {
+	breaker := &models.RiskBreaker{}
+	var accountName sql.NullString
+	err := row.Scan(
+		&breaker.AccountID,
+		&breaker.Tripped,
+		&breaker.Reason,
+		&breaker.TrippedAt,
+		&breaker.WindowStart,
+		&breaker.DailyPnL,
+		&breaker.LossStreak,
+		&breaker.Equity,
+		&breaker.PeakEquity,
+		&breaker.Drawdown,
+		&breaker.UpdatedAt,
+		&accountName,
+	)
+	breaker.AccountName = accountName.String
+	return breaker, err
+}
+
+// GetRiskBreaker returns an account's risk breaker, or nil if it was never
+// checked
+func (r *Repository) GetRiskBreaker(accountID int64) (*models.RiskBreaker, error) {
+	query := `
+		SELECT ` + riskBreakerColumns + `, a.name
+		FROM risk_breakers b
+		LEFT JOIN binance_accounts a ON a.id = b.account_id
+		WHERE b.account_id = ?
+	`
+	breaker, err := scanRiskBreaker(r.db.QueryRow(query, accountID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get risk breaker: %w", err)
+	}
+	return breaker, nil
+}
+
+// GetRiskBreakers returns the risk breakers of all accounts
+func (r *Repository) GetRiskBreakers() ([]*models.RiskBreaker, error) {
+	query := `
+		SELECT ` + riskBreakerColumns + `, a.name
+		FROM risk_breakers b
+		LEFT JOIN binance_accounts a ON a.id = b.account_id
+		ORDER BY b.account_id
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query risk breakers: %w", err)
+	}
+	defer rows.Close()
+
+	breakers := []*models.RiskBreaker{}
+	for rows.Next() {
+		breaker, err := scanRiskBreaker(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan risk breaker: %w", err)
+		}
+		breakers = append(breakers, breaker)
+	}
+
+	return breakers, rows.Err()
+}
+
+// SaveRiskBreaker creates or replaces an account's risk breaker
+func (r *Repository) SaveRiskBreaker(breaker *models.RiskBreaker) error {
+	query := `
+		INSERT INTO risk_breakers (account_id, tripped, reason, tripped_at, window_start,
+			daily_pnl, loss_streak, equity, peak_equity, drawdown, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (account_id) DO UPDATE SET
+			tripped = excluded.tripped, reason = excluded.reason, tripped_at = excluded.tripped_at,
+			window_start = excluded.window_start, daily_pnl = excluded.daily_pnl,
+			loss_streak = excluded.loss_streak, equity = excluded.equity,
+			peak_equity = excluded.peak_equity, drawdown = excluded.drawdown, updated_at = excluded.updated_at
+	`
+	_, err := r.db.Exec(query,
+		breaker.AccountID,
+		breaker.Tripped,
+		breaker.Reason,
+		breaker.TrippedAt,
+		breaker.WindowStart.UTC(),
+		breaker.DailyPnL,
+		breaker.LossStreak,
+		breaker.Equity,
+		breaker.PeakEquity,
+		breaker.Drawdown,
+		breaker.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save risk breaker: %w", err)
+	}
+	return nil
+}
+
+// GetRiskCounters returns the net PnL of an account's positions closed since
+// a time, plus funding not attributed to any position, and the number of
+// losing positions in a row among them, latest first
+func (r *Repository) GetRiskCounters(accountID int64, since time.Time) (float64, int, error) {
+	query := `
+		SELECT pnl - commission + funding
+		FROM positions
+		WHERE account_id = ? AND status = 'closed' AND pnl IS NOT NULL
+		  AND julianday(closed_at) >= julianday(?)
+		ORDER BY closed_at DESC, id DESC
+	`
+	rows, err := r.db.Query(query, accountID, since.UTC())
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query closed positions: %w", err)
+	}
+	defer rows.Close()
+
+	var pnl float64
+	streak, streakOver := 0, false
+	for rows.Next() {
+		var net float64
+		if err := rows.Scan(&net); err != nil {
+			return 0, 0, fmt.Errorf("failed to scan closed position: %w", err)
+		}
+		pnl += net
+		if net < 0 && !streakOver {
+			streak++
+		} else {
+			streakOver = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("failed to query closed positions: %w", err)
+	}
+
+	var funding float64
+	err = r.db.QueryRow(`
+		SELECT COALESCE(SUM(income), 0)
+		FROM income
+		WHERE account_id = ? AND position_id = 0 AND income_type = ?
+		  AND julianday(time) >= julianday(?)
+	`, accountID, models.IncomeFundingFee, since.UTC()).Scan(&funding)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to sum funding: %w", err)
+	}
+
+	return pnl + funding, streak, nil
+}
+
+// ============= Webhook Methods =============
+
+// SaveWebhook creates a webhook subscription
+func (r *Repository) SaveWebhook(webhook *models.Webhook) error {
+	query := `
+		INSERT INTO webhooks (name, url, format, secret, events, is_active)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	result, err := r.db.Exec(query,
+		webhook.Name,
+		webhook.URL,
+		webhook.Format,
+		webhook.Secret,
+		strings.Join(webhook.Events, ","),
+		webhook.IsActive,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save webhook: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get webhook ID: %w", err)
+	}
+	webhook.ID = id
+
+	return nil
+}
+
+// UpdateWebhook updates a webhook subscription
+func (r *Repository) UpdateWebhook(webhook *models.Webhook) error {
+	query := `
+		UPDATE webhooks
+		SET name = ?, url = ?, format = ?, secret = ?, events = ?, is_active = ?, updated_at = ?
+		WHERE id = ?
+	`
+	_, err := r.db.Exec(query,
+		webhook.Name,
+		webhook.URL,
+		webhook.Format,
+		webhook.Secret,
+		strings.Join(webhook.Events, ","),
+		webhook.IsActive,
+		time.Now(),
+		webhook.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+	return nil
+}
+
+// GetWebhook retrieves a webhook by ID, or nil when it doesn't exist
+func (r *Repository) GetWebhook(id int64) (*models.Webhook, error) {
+	query := `
+		SELECT id, name, url, format, secret, events, is_active, created_at, updated_at
+		FROM webhooks
+		WHERE id = ?
+	`
+
+	webhook, err := scanWebhook(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// GetWebhooks retrieves all webhooks, or only the active ones
+func (r *Repository) GetWebhooks(activeOnly bool) ([]*models.Webhook, error) {
+	query := `
+		SELECT id, name, url, format, secret, events, is_active, created_at, updated_at
+		FROM webhooks
+	`
+	if activeOnly {
+		query += " WHERE is_active = 1"
+	}
+	query += " ORDER BY id"
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := make([]*models.Webhook, 0)
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanWebhook reads a webhook row
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	var events string
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.Name,
+		&webhook.URL,
+		&webhook.Format,
+		&webhook.Secret,
+		&events,
+		&webhook.IsActive,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.Events = []string{}
+	if events != "" {
+		webhook.Events = strings.Split(events, ",")
+	}
+
+	return webhook, nil
+}
+
+// DeleteWebhook deletes a webhook and its delivery history
+func (r *Repository) DeleteWebhook(id int64) error {
+	if _, err := r.db.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+	if _, err := r.db.Exec(`DELETE FROM webhooks WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+// EnqueueWebhookDelivery queues a payload for delivery as soon as possible
+func (r *Repository) EnqueueWebhookDelivery(delivery *models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_type, payload, status, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	if delivery.NextAttemptAt.IsZero() {
+		delivery.NextAttemptAt = time.Now()
+	}
+	delivery.Status = models.DeliveryPending
+
+	result, err := r.db.Exec(query,
+		delivery.WebhookID,
+		delivery.EventType,
+		delivery.Payload,
+		delivery.Status,
+		delivery.NextAttemptAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue webhook delivery: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get webhook delivery ID: %w", err)
+	}
+	delivery.ID = id
+
+	return nil
+}
+
+// GetDueWebhookDeliveries returns pending deliveries whose next attempt is due, oldest first
+func (r *Repository) GetDueWebhookDeliveries(now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	query := `
+		SELECT id, webhook_id, event_type, payload, status, attempts, next_attempt_at,
+			COALESCE(last_error, ''), created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at, id
+		LIMIT ?
+	`
+	return r.queryWebhookDeliveries(query, models.DeliveryPending, now, limit)
+}
+
+// GetWebhookDeliveries returns the most recent deliveries of a webhook
+func (r *Repository) GetWebhookDeliveries(webhookID int64, limit int) ([]*models.WebhookDelivery, error) {
+	query := `
+		SELECT id, webhook_id, event_type, payload, status, attempts, next_attempt_at,
+			COALESCE(last_error, ''), created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE webhook_id = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`
+	return r.queryWebhookDeliveries(query, webhookID, limit)
+}
+
+// queryWebhookDeliveries runs a delivery query
+func (r *Repository) queryWebhookDeliveries(query string, args ...interface{}) ([]*models.WebhookDelivery, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*models.WebhookDelivery, 0)
+	for rows.Next() {
+		delivery := &models.WebhookDelivery{}
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.WebhookID,
+			&delivery.EventType,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&delivery.LastError,
+			&delivery.CreatedAt,
+			&delivery.DeliveredAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// UpdateWebhookDelivery records the outcome of a delivery attempt
+func (r *Repository) UpdateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ?, delivered_at = ?
+		WHERE id = ?
+	`
+	_, err := r.db.Exec(query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.LastError,
+		delivery.DeliveredAt,
+		delivery.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// DeleteWebhookDeliveriesBefore removes finished deliveries created before cutoff
+func (r *Repository) DeleteWebhookDeliveriesBefore(cutoff time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM webhook_deliveries WHERE status != ? AND created_at < ?`,
+		models.DeliveryPending, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old webhook deliveries: %w", err)
+	}
+	return result.RowsAffected()
+}
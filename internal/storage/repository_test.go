@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tdlib-go/internal/analytics"
+	"tdlib-go/pkg/models"
+)
+
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+	repo, err := NewRepository(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewRepository: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+func TestClosedPositionPnLWaitsForClosingFills(t *testing.T) {
+	repo := newTestRepository(t)
+
+	pos := &models.Position{
+		AccountID:  1,
+		Symbol:     "BTCUSDT",
+		Side:       "LONG",
+		EntryPrice: 100,
+		Quantity:   2,
+		Leverage:   10,
+		Status:     "open",
+		OpenedAt:   time.Now(),
+	}
+	if err := repo.SavePosition(pos); err != nil {
+		t.Fatalf("SavePosition: %v", err)
+	}
+
+	fill := func(tradeID int64, side string, qty, pnl float64) {
+		t.Helper()
+		err := repo.SaveFill(&models.Fill{
+			AccountID:       1,
+			PositionID:      pos.ID,
+			BinanceOrderID:  "1",
+			TradeID:         tradeID,
+			Symbol:          "BTCUSDT",
+			Side:            side,
+			Price:           100,
+			Quantity:        qty,
+			RealizedPnL:     pnl,
+			Commission:      0.08,
+			CommissionAsset: "USDT",
+			TradeTime:       time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("SaveFill: %v", err)
+		}
+	}
+	pnl := func() float64 {
+		t.Helper()
+		got, err := repo.GetPosition(pos.ID)
+		if err != nil || got == nil || got.PnL == nil {
+			t.Fatalf("GetPosition: %v, %+v", err, got)
+		}
+		return *got.PnL
+	}
+
+	// Only the entry is recorded when the position closes at 110
+	fill(1, "BUY", 2, 0)
+	if err := repo.ClosePosition(pos.ID, 110, time.Now()); err != nil {
+		t.Fatalf("ClosePosition: %v", err)
+	}
+	if got := pnl(); got != 20 {
+		t.Fatalf("PnL with entry fills only = %v, want the estimate 20", got)
+	}
+
+	// A partial close does not cover the position yet
+	fill(2, "SELL", 1, 9.5)
+	if got := pnl(); got != 20 {
+		t.Fatalf("PnL with half the closing fills = %v, want the estimate 20", got)
+	}
+
+	fill(3, "SELL", 1, 9.5)
+	if got := pnl(); got != 19 {
+		t.Fatalf("PnL with all closing fills = %v, want the realized 19", got)
+	}
+}
+
+func TestPartialCloseKeepsItsPnL(t *testing.T) {
+	repo := newTestRepository(t)
+
+	newPosition := func() *models.Position {
+		t.Helper()
+		pos := &models.Position{
+			AccountID:  1,
+			Symbol:     "BTCUSDT",
+			Side:       "SHORT",
+			EntryPrice: 100,
+			Quantity:   2,
+			Leverage:   10,
+			Status:     "open",
+			OpenedAt:   time.Now(),
+		}
+		if err := repo.SavePosition(pos); err != nil {
+			t.Fatalf("SavePosition: %v", err)
+		}
+		return pos
+	}
+	get := func(id int64) *models.Position {
+		t.Helper()
+		pos, err := repo.GetPosition(id)
+		if err != nil || pos == nil {
+			t.Fatalf("GetPosition: %v", err)
+		}
+		return pos
+	}
+	fill := func(positionID, tradeID int64, side string, qty, pnl float64) {
+		t.Helper()
+		err := repo.SaveFill(&models.Fill{
+			AccountID:       1,
+			PositionID:      positionID,
+			BinanceOrderID:  fmt.Sprint(tradeID),
+			TradeID:         tradeID,
+			Symbol:          "BTCUSDT",
+			Side:            side,
+			Price:           100,
+			Quantity:        qty,
+			RealizedPnL:     pnl,
+			CommissionAsset: "USDT",
+			TradeTime:       time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("SaveFill: %v", err)
+		}
+	}
+
+	// Without fills, both legs are estimated: 0.5 closed 10 down, 1.5 closed 4 down
+	pos := newPosition()
+	if err := repo.PartiallyClosePosition(pos.ID, 1.5, 90); err != nil {
+		t.Fatalf("PartiallyClosePosition: %v", err)
+	}
+	if got := get(pos.ID); got.Quantity != 1.5 || got.ClosedQuantity != 0.5 {
+		t.Fatalf("quantity = %v closed %v, want 1.5 closed 0.5", got.Quantity, got.ClosedQuantity)
+	}
+	if err := repo.ClosePosition(pos.ID, 96, time.Now()); err != nil {
+		t.Fatalf("ClosePosition: %v", err)
+	}
+	got := get(pos.ID)
+	if got.PnL == nil || *got.PnL != 11 {
+		t.Errorf("estimated PnL = %v, want 11 from both legs", got.PnL)
+	}
+	// On the 10 USDT margin of the full size
+	if got.PnLPercent == nil || math.Abs(*got.PnLPercent-55) > 1e-9 {
+		t.Errorf("PnL percent = %v, want 55", got.PnLPercent)
+	}
+
+	// The partial close's fills alone do not cover the full size
+	pos = newPosition()
+	fill(pos.ID, 10, "SELL", 2, 0)
+	if err := repo.PartiallyClosePosition(pos.ID, 1.5, 90); err != nil {
+		t.Fatalf("PartiallyClosePosition: %v", err)
+	}
+	fill(pos.ID, 11, "BUY", 0.5, 4.9)
+	if err := repo.ClosePosition(pos.ID, 96, time.Now()); err != nil {
+		t.Fatalf("ClosePosition: %v", err)
+	}
+	if got := get(pos.ID); got.PnL == nil || *got.PnL != 11 || got.RealizedPnL == nil || *got.RealizedPnL != 4.9 {
+		t.Errorf("PnL = %v realized %v, want the estimate 11 with 4.9 realized", got.PnL, got.RealizedPnL)
+	}
+
+	fill(pos.ID, 12, "BUY", 1.5, 5.9)
+	if got := get(pos.ID); got.PnL == nil || math.Abs(*got.PnL-10.8) > 1e-9 {
+		t.Errorf("PnL = %v, want the realized 10.8 of both legs", got.PnL)
+	}
+}
+
+func TestChannelLeaderboardCountsChannelSignals(t *testing.T) {
+	repo := newTestRepository(t)
+
+	now := time.Now()
+	signal := func(channelID int64, parsedAt time.Time, status string) {
+		t.Helper()
+		err := repo.SaveSignal(&models.Signal{
+			ChannelID: channelID,
+			Symbol:    "BTCUSDT",
+			PostedAt:  parsedAt,
+			ParsedAt:  parsedAt,
+			Status:    status,
+		})
+		if err != nil {
+			t.Fatalf("SaveSignal: %v", err)
+		}
+	}
+	signal(7, now.Add(-time.Hour), "executed")
+	// Stored with another offset, still within the window
+	signal(7, now.Add(-2*time.Hour).In(time.FixedZone("UTC+5", 5*3600)), "executed")
+	signal(7, now.Add(-48*time.Hour), "executed")
+	signal(7, now.Add(-time.Hour), "historical")
+	// Manual signals have no channel
+	signal(0, now.Add(-time.Hour), "executed")
+
+	scores, err := repo.GetChannelLeaderboard(24*time.Hour, analytics.ScoreParams{})
+	if err != nil {
+		t.Fatalf("GetChannelLeaderboard: %v", err)
+	}
+	if len(scores) != 1 || scores[0].ChannelID != 7 || scores[0].SignalCount != 2 {
+		t.Fatalf("scores = %+v, want channel 7 with 2 signals", scores)
+	}
+}
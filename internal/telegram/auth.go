@@ -0,0 +1,324 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zelenin/go-tdlib/client"
+	"tdlib-go/pkg/models"
+)
+
+// qrPollInterval is how often the QR login state is re-checked
+const qrPollInterval = time.Second
+
+// authInput is a value submitted for an authorization step
+type authInput struct {
+	state    string
+	value    string
+	lastName string
+}
+
+// Authenticator drives the TDLib login of a user or bot account. It
+// implements client.AuthorizationStateHandler; phone numbers, codes, 2FA
+// passwords and registration details are submitted from the CLI or web API
+// while it waits for them, so headless servers can log in without a QR scan.
+type Authenticator struct {
+	params      *client.SetTdlibParametersRequest
+	phoneNumber string // Configured number, tried once before asking
+	botToken    string
+	useQR       bool
+	logger      *logrus.Logger
+
+	mu         sync.RWMutex
+	status     models.AuthStatus
+	lastQRLink string
+
+	input     chan authInput
+	cancelCh  chan struct{}
+	cancelled sync.Once
+}
+
+// NewAuthenticator creates an authenticator. A bot token takes precedence;
+// otherwise the account logs in by phone number, or by QR code when useQR is set.
+func NewAuthenticator(params *client.SetTdlibParametersRequest, phoneNumber, botToken string, useQR bool, logger *logrus.Logger) *Authenticator {
+	return &Authenticator{
+		params:      params,
+		phoneNumber: phoneNumber,
+		botToken:    botToken,
+		useQR:       useQR,
+		logger:      logger,
+		status:      models.AuthStatus{State: models.AuthStateStarting},
+		input:       make(chan authInput, 1),
+		cancelCh:    make(chan struct{}),
+	}
+}
+
+// Handle performs the action required by an authorization state. Wrong
+// codes and passwords are reported in the status and asked for again
+// instead of failing the login.
+func (a *Authenticator) Handle(c *client.Client, state client.AuthorizationState) error {
+	switch s := state.(type) {
+	case *client.AuthorizationStateWaitTdlibParameters:
+		_, err := c.SetTdlibParameters(a.params)
+		return err
+
+	case *client.AuthorizationStateWaitPhoneNumber:
+		return a.handlePhoneNumber(c)
+
+	case *client.AuthorizationStateWaitCode:
+		a.setStatus(func(st *models.AuthStatus) {
+			st.State = models.AuthStateWaitCode
+			if s.CodeInfo != nil {
+				st.PhoneNumber = maskPhoneNumber(s.CodeInfo.PhoneNumber)
+				st.CodeType = codeTypeName(s.CodeInfo.Type)
+			}
+		})
+		a.logger.Infof("Telegram login code sent (%s), submit it with 'code <code>' or POST /api/telegram/auth/code", a.Status().CodeType)
+
+		in, err := a.waitInput(models.AuthStateWaitCode)
+		if err != nil {
+			return err
+		}
+		_, err = c.CheckAuthenticationCode(&client.CheckAuthenticationCodeRequest{Code: in.value})
+		a.recordResult("code", err)
+		return nil
+
+	case *client.AuthorizationStateWaitPassword:
+		a.setStatus(func(st *models.AuthStatus) {
+			st.State = models.AuthStateWaitPassword
+			st.PasswordHint = s.PasswordHint
+		})
+		a.logger.Info("Telegram 2FA password required, submit it with 'password <password>' or POST /api/telegram/auth/password")
+
+		in, err := a.waitInput(models.AuthStateWaitPassword)
+		if err != nil {
+			return err
+		}
+		_, err = c.CheckAuthenticationPassword(&client.CheckAuthenticationPasswordRequest{Password: in.value})
+		a.recordResult("password", err)
+		return nil
+
+	case *client.AuthorizationStateWaitRegistration:
+		a.setStatus(func(st *models.AuthStatus) { st.State = models.AuthStateWaitRegistration })
+		a.logger.Info("Phone number is not registered, submit a name with 'register <first> [last]' or POST /api/telegram/auth/registration")
+
+		in, err := a.waitInput(models.AuthStateWaitRegistration)
+		if err != nil {
+			return err
+		}
+		_, err = c.RegisterUser(&client.RegisterUserRequest{FirstName: in.value, LastName: in.lastName})
+		a.recordResult("registration", err)
+		return nil
+
+	case *client.AuthorizationStateWaitOtherDeviceConfirmation:
+		a.handleQRLink(s.Link)
+
+		// The state only changes once the code is scanned
+		select {
+		case <-a.cancelCh:
+			return fmt.Errorf("authorization cancelled")
+		case <-time.After(qrPollInterval):
+		}
+		return nil
+
+	case *client.AuthorizationStateReady:
+		a.setStatus(func(st *models.AuthStatus) {
+			st.State = models.AuthStateReady
+			st.QRLink = ""
+			st.LastError = ""
+		})
+		return nil
+
+	case *client.AuthorizationStateLoggingOut, *client.AuthorizationStateClosing, *client.AuthorizationStateClosed:
+		a.setStatus(func(st *models.AuthStatus) { st.State = models.AuthStateClosed })
+		select {
+		case <-a.cancelCh:
+		case <-time.After(qrPollInterval):
+		}
+		return nil
+	}
+
+	return client.NotSupportedAuthorizationState(state)
+}
+
+// handlePhoneNumber starts the login with a bot token, a QR code or a phone number
+func (a *Authenticator) handlePhoneNumber(c *client.Client) error {
+	if a.botToken != "" {
+		_, err := c.CheckAuthenticationBotToken(&client.CheckAuthenticationBotTokenRequest{Token: a.botToken})
+		return err
+	}
+
+	if a.useQR {
+		_, err := c.RequestQrCodeAuthentication(&client.RequestQrCodeAuthenticationRequest{})
+		return err
+	}
+
+	a.mu.Lock()
+	phoneNumber := a.phoneNumber
+	a.phoneNumber = "" // Ask for another number if this one is rejected
+	a.mu.Unlock()
+
+	if phoneNumber == "" {
+		a.setStatus(func(st *models.AuthStatus) { st.State = models.AuthStateWaitPhoneNumber })
+		a.logger.Info("Telegram phone number required, submit it with 'phone <number>' or POST /api/telegram/auth/phone")
+
+		in, err := a.waitInput(models.AuthStateWaitPhoneNumber)
+		if err != nil {
+			return err
+		}
+		phoneNumber = in.value
+	}
+
+	a.logger.Infof("Requesting Telegram login code for %s", maskPhoneNumber(phoneNumber))
+	_, err := c.SetAuthenticationPhoneNumber(&client.SetAuthenticationPhoneNumberRequest{
+		PhoneNumber: phoneNumber,
+		Settings:    &client.PhoneNumberAuthenticationSettings{},
+	})
+	a.recordResult("phone number", err)
+	return nil
+}
+
+// handleQRLink publishes a new QR login link
+func (a *Authenticator) handleQRLink(link string) {
+	a.mu.Lock()
+	changed := link != a.lastQRLink
+	a.lastQRLink = link
+	a.status.State = models.AuthStateWaitQRScan
+	a.status.QRLink = link
+	a.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	a.logger.Infof("\n========================================")
+	a.logger.Infof("QR Code Authentication")
+	a.logger.Infof("========================================")
+	a.logger.Infof("Please scan this QR code with your Telegram app:")
+	a.logger.Infof("\nLink: %s", link)
+	a.logger.Infof("\nOr open this link in your browser:")
+	a.logger.Infof("%s", link)
+	a.logger.Infof("========================================\n")
+}
+
+// Close is called by TDLib once the authorization flow ends
+func (a *Authenticator) Close() {}
+
+// Cancel aborts a login that is waiting for input
+func (a *Authenticator) Cancel() {
+	a.cancelled.Do(func() {
+		close(a.cancelCh)
+	})
+}
+
+// Status returns the current authorization status
+func (a *Authenticator) Status() models.AuthStatus {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.status
+}
+
+// SubmitPhoneNumber provides the phone number to log in with
+func (a *Authenticator) SubmitPhoneNumber(phoneNumber string) error {
+	return a.submit(authInput{state: models.AuthStateWaitPhoneNumber, value: strings.TrimSpace(phoneNumber)})
+}
+
+// SubmitCode provides the login code sent by Telegram
+func (a *Authenticator) SubmitCode(code string) error {
+	return a.submit(authInput{state: models.AuthStateWaitCode, value: strings.TrimSpace(code)})
+}
+
+// SubmitPassword provides the 2FA password
+func (a *Authenticator) SubmitPassword(password string) error {
+	return a.submit(authInput{state: models.AuthStateWaitPassword, value: password})
+}
+
+// SubmitRegistration provides the name for a new account
+func (a *Authenticator) SubmitRegistration(firstName, lastName string) error {
+	return a.submit(authInput{state: models.AuthStateWaitRegistration, value: strings.TrimSpace(firstName), lastName: strings.TrimSpace(lastName)})
+}
+
+// submit hands a value to the waiting authorization step
+func (a *Authenticator) submit(in authInput) error {
+	if in.value == "" {
+		return fmt.Errorf("value is required")
+	}
+
+	current := a.Status().State
+	if current != in.state {
+		return fmt.Errorf("login is not waiting for this step (current state: %s)", current)
+	}
+
+	select {
+	case a.input <- in:
+		return nil
+	default:
+		return fmt.Errorf("a value is already being checked, try again shortly")
+	}
+}
+
+// waitInput blocks until a value for state is submitted or the login is cancelled
+func (a *Authenticator) waitInput(state string) (authInput, error) {
+	for {
+		select {
+		case <-a.cancelCh:
+			return authInput{}, fmt.Errorf("authorization cancelled")
+		case in := <-a.input:
+			if in.state == state {
+				return in, nil
+			}
+		}
+	}
+}
+
+// recordResult logs the outcome of a submitted value and keeps the error
+// in the status so the user can retry
+func (a *Authenticator) recordResult(step string, err error) {
+	if err != nil {
+		a.logger.Warnf("Telegram rejected the %s: %v", step, err)
+		a.setStatus(func(st *models.AuthStatus) { st.LastError = err.Error() })
+		return
+	}
+
+	a.setStatus(func(st *models.AuthStatus) { st.LastError = "" })
+}
+
+// setStatus updates the status under the lock
+func (a *Authenticator) setStatus(update func(*models.AuthStatus)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	update(&a.status)
+}
+
+// codeTypeName returns a short name for how a login code was sent
+func codeTypeName(codeType client.AuthenticationCodeType) string {
+	if codeType == nil {
+		return ""
+	}
+
+	switch codeType.(type) {
+	case *client.AuthenticationCodeTypeTelegramMessage:
+		return "app"
+	case *client.AuthenticationCodeTypeSms, *client.AuthenticationCodeTypeSmsWord, *client.AuthenticationCodeTypeSmsPhrase:
+		return "sms"
+	case *client.AuthenticationCodeTypeCall:
+		return "call"
+	case *client.AuthenticationCodeTypeFlashCall, *client.AuthenticationCodeTypeMissedCall:
+		return "flash_call"
+	case *client.AuthenticationCodeTypeFragment:
+		return "fragment"
+	default:
+		return "other"
+	}
+}
+
+// maskPhoneNumber hides all but the first and last two digits of a phone number
+func maskPhoneNumber(phoneNumber string) string {
+	if len(phoneNumber) <= 4 {
+		return strings.Repeat("*", len(phoneNumber))
+	}
+	return phoneNumber[:2] + strings.Repeat("*", len(phoneNumber)-4) + phoneNumber[len(phoneNumber)-2:]
+}
@@ -0,0 +1,448 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"tdlib-go/internal/config"
+	"tdlib-go/pkg/models"
+)
+
+// botAPIRetryDelay is the wait after a failed getUpdates call
+const botAPIRetryDelay = 5 * time.Second
+
+// BotAPISource reads channel posts through the HTTP Bot API, either by
+// long-polling getUpdates or by serving a webhook. The bot must be an admin
+// of every monitored channel. It needs no TDLib session, but the Bot API
+// cannot read message history.
+type BotAPISource struct {
+	config     *config.BotAPIConfig
+	token      string
+	baseURL    string
+	httpClient *http.Client
+	logger     *logrus.Logger
+
+	handlers  []MessageHandler
+	mu        sync.RWMutex
+	connected bool
+	offset    int64
+
+	server *http.Server
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// botAPIResponse is the envelope of every Bot API response
+type botAPIResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+	Parameters  *struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// botAPIError is a failed Bot API call
+type botAPIError struct {
+	Code        int
+	Description string
+	RetryAfter  time.Duration
+}
+
+func (e *botAPIError) Error() string {
+	return fmt.Sprintf("bot API error [%d]: %s", e.Code, e.Description)
+}
+
+// botUpdate is the subset of a Bot API update we handle
+type botUpdate struct {
+	UpdateID    int64       `json:"update_id"`
+	ChannelPost *botMessage `json:"channel_post"`
+}
+
+// botChat is a Bot API chat
+type botChat struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Username string `json:"username"`
+}
+
+// botMessage is the subset of a Bot API message we convert
+type botMessage struct {
+	MessageID       int64           `json:"message_id"`
+	Date            int64           `json:"date"`
+	Chat            botChat         `json:"chat"`
+	SenderChat      *botChat        `json:"sender_chat"`
+	AuthorSignature string          `json:"author_signature"`
+	Text            string          `json:"text"`
+	Caption         string          `json:"caption"`
+	ForwardOrigin   json.RawMessage `json:"forward_origin"`
+	Photo           json.RawMessage `json:"photo"`
+	Video           json.RawMessage `json:"video"`
+	Document        json.RawMessage `json:"document"`
+	Animation       json.RawMessage `json:"animation"`
+	Voice           json.RawMessage `json:"voice"`
+	Audio           json.RawMessage `json:"audio"`
+	Sticker         json.RawMessage `json:"sticker"`
+}
+
+// NewBotAPISource creates a Bot API message source from the telegram config
+func NewBotAPISource(cfg *config.Config, logger *logrus.Logger) *BotAPISource {
+	ctx, cancel := context.WithCancel(context.Background())
+	botCfg := &cfg.Telegram.BotAPI
+
+	return &BotAPISource{
+		config:  botCfg,
+		token:   cfg.Telegram.BotToken,
+		baseURL: botCfg.BaseURLOrDefault(),
+		httpClient: &http.Client{
+			Timeout: botCfg.PollTimeoutOrDefault() + 10*time.Second,
+		},
+		logger:   logger,
+		handlers: make([]MessageHandler, 0),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start verifies the bot token and configures update delivery
+func (b *BotAPISource) Start() error {
+	b.logger.Info("Starting Bot API client...")
+
+	var me struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	}
+	if err := b.call("getMe", nil, &me); err != nil {
+		return fmt.Errorf("failed to verify bot token: %w", err)
+	}
+
+	switch b.config.ModeOrDefault() {
+	case config.BotModeWebhook:
+		params := map[string]interface{}{
+			"url":             b.config.WebhookURL,
+			"allowed_updates": []string{"channel_post"},
+		}
+		if b.config.WebhookSecret != "" {
+			params["secret_token"] = b.config.WebhookSecret
+		}
+		if err := b.call("setWebhook", params, nil); err != nil {
+			return fmt.Errorf("failed to set webhook: %w", err)
+		}
+	default:
+		// getUpdates is rejected while a webhook is set
+		if err := b.call("deleteWebhook", nil, nil); err != nil {
+			return fmt.Errorf("failed to delete webhook: %w", err)
+		}
+	}
+
+	b.setConnected(true)
+	b.logger.Infof("Bot API client started as @%s (%s mode)", me.Username, b.config.ModeOrDefault())
+
+	return nil
+}
+
+// AddMessageHandler adds a handler for incoming messages
+func (b *BotAPISource) AddMessageHandler(handler MessageHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// ResolveChannel looks up a channel by username or ID. Bots cannot join
+// channels themselves; they have to be added as an admin.
+func (b *BotAPISource) ResolveChannel(identifier string) (*ChatInfo, error) {
+	if isInviteLink(identifier) {
+		return nil, fmt.Errorf("bots cannot join by invite link, add the bot to the channel as an admin and use its ID")
+	}
+
+	chatID := identifier
+	if !isChatID(identifier) {
+		chatID = "@" + normalizeUsername(identifier)
+	}
+
+	var chat botChat
+	if err := b.call("getChat", map[string]interface{}{"chat_id": chatID}, &chat); err != nil {
+		return nil, fmt.Errorf("failed to get chat: %w", err)
+	}
+
+	return &ChatInfo{ID: chat.ID, Title: chat.Title}, nil
+}
+
+// SendText sends a plain text message to a chat the bot can write to
+func (b *BotAPISource) SendText(chatID int64, text string) error {
+	if err := b.call("sendMessage", map[string]interface{}{"chat_id": chatID, "text": text}, nil); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return nil
+}
+
+// GetChatHistory is not supported: the Bot API only delivers new posts
+func (b *BotAPISource) GetChatHistory(chatID, fromMessageID int64, limit int32) ([]*models.Message, error) {
+	return nil, fmt.Errorf("message history is not available through the Bot API")
+}
+
+// StartListening receives channel posts until Stop is called
+func (b *BotAPISource) StartListening() error {
+	if b.config.ModeOrDefault() == config.BotModeWebhook {
+		return b.serveWebhook()
+	}
+	return b.poll()
+}
+
+// poll long-polls getUpdates
+func (b *BotAPISource) poll() error {
+	b.logger.Info("Starting Bot API long polling...")
+
+	timeout := int(b.config.PollTimeoutOrDefault().Seconds())
+	for {
+		params := map[string]interface{}{
+			"offset":          b.offset,
+			"timeout":         timeout,
+			"allowed_updates": []string{"channel_post"},
+		}
+
+		var updates []botUpdate
+		err := b.call("getUpdates", params, &updates)
+		if b.ctx.Err() != nil {
+			b.logger.Info("Stopping Bot API polling...")
+			return nil
+		}
+		if err != nil {
+			delay := botAPIRetryDelay
+			if apiErr, ok := err.(*botAPIError); ok && apiErr.RetryAfter > 0 {
+				delay = apiErr.RetryAfter
+			}
+			b.logger.Errorf("Failed to get updates, retrying in %s: %v", delay, err)
+			b.setConnected(false)
+
+			select {
+			case <-b.ctx.Done():
+				return nil
+			case <-time.After(delay):
+			}
+			continue
+		}
+
+		b.setConnected(true)
+		for _, update := range updates {
+			b.offset = update.UpdateID + 1
+			b.handleUpdate(&update)
+		}
+	}
+}
+
+// serveWebhook serves webhook deliveries on the configured address
+func (b *BotAPISource) serveWebhook() error {
+	path := "/"
+	if u, err := url.Parse(b.config.WebhookURL); err == nil && u.Path != "" {
+		path = u.Path
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, b.handleWebhook)
+
+	b.mu.Lock()
+	b.server = &http.Server{
+		Addr:         b.config.WebhookListen,
+		Handler:      mux,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+	}
+	server := b.server
+	b.mu.Unlock()
+
+	b.logger.Infof("Serving Bot API webhook on %s%s", b.config.WebhookListen, path)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("webhook server failed: %w", err)
+	}
+
+	return nil
+}
+
+// handleWebhook handles one update delivered by Telegram
+func (b *BotAPISource) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if b.config.WebhookSecret != "" && r.Header.Get("X-Telegram-Bot-Api-Secret-Token") != b.config.WebhookSecret {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var update botUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "invalid update", http.StatusBadRequest)
+		return
+	}
+
+	b.handleUpdate(&update)
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleUpdate converts a channel post and calls the handlers
+func (b *BotAPISource) handleUpdate(update *botUpdate) {
+	if update.ChannelPost == nil {
+		return
+	}
+
+	message := convertBotMessage(update.ChannelPost)
+
+	b.mu.RLock()
+	handlers := make([]MessageHandler, len(b.handlers))
+	copy(handlers, b.handlers)
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(message); err != nil {
+			b.logger.Errorf("Message handler error: %v", err)
+		}
+	}
+}
+
+// convertBotMessage converts a Bot API message to our model
+func convertBotMessage(msg *botMessage) *models.Message {
+	message := &models.Message{
+		MessageID:   msg.MessageID,
+		ChannelID:   msg.Chat.ID,
+		ChannelName: msg.Chat.Title,
+		SenderID:    msg.Chat.ID,
+		SenderName:  msg.Chat.Title,
+		Text:        msg.Text,
+		MediaType:   "text",
+		IsForwarded: len(msg.ForwardOrigin) > 0,
+		Timestamp:   time.Unix(msg.Date, 0),
+	}
+
+	if msg.SenderChat != nil {
+		message.SenderID = msg.SenderChat.ID
+	}
+	if msg.AuthorSignature != "" {
+		message.SenderName = msg.AuthorSignature
+	}
+
+	media := []struct {
+		raw       json.RawMessage
+		mediaType string
+		label     string
+	}{
+		{msg.Photo, "photo", "[Photo]"},
+		{msg.Video, "video", "[Video]"},
+		{msg.Document, "document", "[Document]"},
+		{msg.Animation, "animation", "[Animation]"},
+		{msg.Voice, "voice", "[Voice Note]"},
+		{msg.Audio, "audio", "[Audio]"},
+		{msg.Sticker, "sticker", "[Sticker]"},
+	}
+	for _, m := range media {
+		if len(m.raw) == 0 {
+			continue
+		}
+		message.MediaType = m.mediaType
+		message.Text = msg.Caption
+		if message.Text == "" {
+			message.Text = m.label
+		}
+		break
+	}
+
+	return message
+}
+
+// call invokes a Bot API method and decodes its result into result
+func (b *BotAPISource) call(method string, params map[string]interface{}, result interface{}) error {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(b.ctx, http.MethodPost, b.baseURL+"/bot"+b.token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		// Never log the URL, it contains the bot token
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var envelope botAPIResponse
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if !envelope.OK {
+		apiErr := &botAPIError{Code: envelope.ErrorCode, Description: envelope.Description}
+		if envelope.Parameters != nil {
+			apiErr.RetryAfter = time.Duration(envelope.Parameters.RetryAfter) * time.Second
+		}
+		return apiErr
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(envelope.Result, result); err != nil {
+			return fmt.Errorf("failed to unmarshal %s result: %w", method, err)
+		}
+	}
+
+	return nil
+}
+
+// setConnected updates the connection state
+func (b *BotAPISource) setConnected(connected bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.connected = connected
+}
+
+// IsConnected returns whether updates are being received
+func (b *BotAPISource) IsConnected() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.connected
+}
+
+// Stop stops receiving updates
+func (b *BotAPISource) Stop() error {
+	b.logger.Info("Stopping Bot API client...")
+	b.cancel()
+
+	b.mu.RLock()
+	server := b.server
+	b.mu.RUnlock()
+
+	if server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to stop webhook server: %w", err)
+		}
+	}
+
+	b.setConnected(false)
+	return nil
+}
@@ -0,0 +1,178 @@
+package telegram
+
+import (
+	"fmt"
+	"time"
+
+	"tdlib-go/pkg/models"
+)
+
+// Reconnect catch-up limits
+const (
+	catchUpMaxMessages = 500  // Per channel and reconnect
+	seenWindowSize     = 1000 // Recent message IDs remembered per channel
+)
+
+// seenWindow remembers the most recent message IDs of a channel, so a
+// message delivered both live and by a catch-up is processed only once
+type seenWindow struct {
+	ids   map[int64]struct{}
+	order []int64
+}
+
+// add records a message ID and reports whether it was new
+func (w *seenWindow) add(id int64) bool {
+	if _, ok := w.ids[id]; ok {
+		return false
+	}
+
+	w.ids[id] = struct{}{}
+	w.order = append(w.order, id)
+	if len(w.order) > seenWindowSize {
+		delete(w.ids, w.order[0])
+		w.order = w.order[1:]
+	}
+	return true
+}
+
+// markSeen records a message and reports whether it was seen for the first
+// time. The channel's last seen message ID is advanced and persisted.
+func (m *Monitor) markSeen(msg *models.Message) bool {
+	m.seenMu.Lock()
+	window, ok := m.seen[msg.ChannelID]
+	if !ok {
+		window = &seenWindow{ids: make(map[int64]struct{})}
+		m.seen[msg.ChannelID] = window
+	}
+	if !window.add(msg.MessageID) {
+		m.seenMu.Unlock()
+		return false
+	}
+	advanced := msg.MessageID > m.lastSeen[msg.ChannelID]
+	if advanced {
+		m.lastSeen[msg.ChannelID] = msg.MessageID
+	}
+	m.seenMu.Unlock()
+
+	if advanced {
+		if err := m.repo.SetChannelLastMessageID(msg.ChannelID, msg.MessageID); err != nil {
+			m.logger.Errorf("Failed to save last message of channel %d: %v", msg.ChannelID, err)
+		}
+	}
+	return true
+}
+
+// LastSeenMessageIDs returns the newest message ID seen in each monitored channel
+func (m *Monitor) LastSeenMessageIDs() map[int64]int64 {
+	m.seenMu.Lock()
+	defer m.seenMu.Unlock()
+
+	ids := make(map[int64]int64, len(m.lastSeen))
+	for channelID, messageID := range m.lastSeen {
+		ids[channelID] = messageID
+	}
+	return ids
+}
+
+// CatchUp fetches the messages posted in monitored channels after the last
+// seen ones, e.g. while the connection was down, and processes those not
+// delivered yet, oldest first. Messages older than telegram.catch_up_max_age
+// are archived and recorded like imported history instead of being traded.
+func (m *Monitor) CatchUp() {
+	// One catch-up at a time; a concurrent one covers the same gap
+	if !m.catchUpMu.TryLock() {
+		return
+	}
+	defer m.catchUpMu.Unlock()
+
+	lastSeen := m.LastSeenMessageIDs()
+	maxAge := m.config.Telegram.CatchUpMaxAgeOrDefault()
+
+	for _, channel := range m.ListChannels() {
+		since := lastSeen[channel.ChannelID]
+		if since == 0 {
+			continue // Nothing seen yet to catch up from
+		}
+
+		missed, err := m.fetchSince(channel.ChannelID, since)
+		if err != nil {
+			m.logger.Errorf("Failed to catch up on channel %d: %v", channel.ChannelID, err)
+			continue
+		}
+
+		delivered, recorded := 0, 0
+		for i := len(missed) - 1; i >= 0; i-- {
+			msg := missed[i]
+			if time.Since(msg.Timestamp) <= maxAge {
+				if m.deliverMessage(msg) {
+					delivered++
+				}
+				continue
+			}
+
+			if m.recordMissedMessage(msg) {
+				recorded++
+			}
+		}
+
+		if delivered > 0 || recorded > 0 {
+			m.logger.Infof("Caught up on channel %s: %d missed messages processed, %d too old to trade recorded as history",
+				channel.Title, delivered, recorded)
+		}
+	}
+}
+
+// fetchSince pages back through a channel's history until the message
+// after sinceMessageID, returning the newer messages newest first
+func (m *Monitor) fetchSince(channelID, sinceMessageID int64) ([]*models.Message, error) {
+	var missed []*models.Message
+	fromMessageID := int64(0)
+
+	for {
+		page, err := m.source.GetChatHistory(channelID, fromMessageID, historyPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch history: %w", err)
+		}
+		if len(page) == 0 {
+			return missed, nil
+		}
+
+		for _, msg := range page {
+			if msg.MessageID <= sinceMessageID {
+				return missed, nil
+			}
+			if len(missed) >= catchUpMaxMessages {
+				m.logger.Warnf("More than %d messages missed in channel %d, older ones are skipped", catchUpMaxMessages, channelID)
+				return missed, nil
+			}
+			missed = append(missed, msg)
+		}
+
+		fromMessageID = page[len(page)-1].MessageID
+
+		select {
+		case <-m.stopCh:
+			return nil, fmt.Errorf("monitor stopped")
+		case <-time.After(historyPageDelay):
+		}
+	}
+}
+
+// recordMissedMessage archives a caught-up message and records its signal
+// without executing it, like imported history
+func (m *Monitor) recordMissedMessage(msg *models.Message) bool {
+	if !m.markSeen(msg) {
+		return false
+	}
+
+	m.deliverMu.Lock()
+	defer m.deliverMu.Unlock()
+
+	m.archiveMessage(msg)
+	if m.onHistoricalMessage != nil {
+		if _, err := m.onHistoricalMessage(msg); err != nil {
+			m.logger.Errorf("Historical message callback error: %v", err)
+		}
+	}
+	return true
+}
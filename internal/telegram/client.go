@@ -14,26 +14,45 @@ import (
 	"tdlib-go/pkg/models"
 )
 
+// Reconnect backoff after the TDLib session closes
+const (
+	reconnectInitialDelay = 2 * time.Second
+	reconnectMaxDelay     = 2 * time.Minute
+)
+
+// TDLib network connection states
+const (
+	ConnectionWaitingForNetwork = "waiting_for_network"
+	ConnectionConnectingToProxy = "connecting_to_proxy"
+	ConnectionConnecting        = "connecting"
+	ConnectionUpdating          = "updating"
+	ConnectionReady             = "ready"
+)
+
 // Client wraps the TDLib client with additional functionality
 type Client struct {
-	tdClient  *client.Client
-	config    *config.Config
-	logger    *logrus.Logger
-	handlers  []MessageHandler
-	mu        sync.RWMutex
-	ctx       context.Context
-	cancel    context.CancelFunc
-	connected bool
+	tdClient    *client.Client
+	auth        *Authenticator
+	config      *config.Config
+	logger      *logrus.Logger
+	handlers    []MessageHandler
+	onReconnect []func()
+	mu          sync.RWMutex
+	ctx         context.Context
+	cancel      context.CancelFunc
+	connected   bool   // Session is authorized and open
+	connState   string // Network state reported by TDLib
+	wasReady    bool   // The network was ready at least once in this session
 }
 
 // MessageHandler is a function that processes incoming messages
 type MessageHandler func(msg *models.Message) error
 
-// NewClient creates a new Telegram client
+// NewClient creates a new Telegram client. The TDLib session is created
+// and authorized by Start.
 func NewClient(cfg *config.Config, logger *logrus.Logger) (*Client, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	// Prepare TDLib parameters for QR authorizer
 	tdlibCfg := cfg.TDLib
 	tdlibParams := &client.SetTdlibParametersRequest{
 		UseTestDc:           cfg.Telegram.UseTestDC,
@@ -51,31 +70,14 @@ func NewClient(cfg *config.Config, logger *logrus.Logger) (*Client, error) {
 		ApplicationVersion:  tdlibCfg.AppVersion,
 	}
 
-	// Use QR code authorizer
-	authorizer := client.QrAuthorizer(tdlibParams, func(link string) error {
-		logger.Infof("\n========================================")
-		logger.Infof("QR Code Authentication")
-		logger.Infof("========================================")
-		logger.Infof("Please scan this QR code with your Telegram app:")
-		logger.Infof("\nLink: %s", link)
-		logger.Infof("\nOr open this link in your browser:")
-		logger.Infof("%s", link)
-		logger.Infof("========================================\n")
-		return nil
-	})
-
-	// Create TDLib client
-	tdClient, err := client.NewClient(authorizer, client.WithLogVerbosity(&client.SetLogVerbosityLevelRequest{
-		NewVerbosityLevel: 1,
-	}))
-
-	if err != nil {
-		cancel()
-		return nil, fmt.Errorf("failed to create TDLib client: %w", err)
+	if cfg.IsBot() {
+		logger.Info("Authorizing as bot via TDLib")
 	}
+	auth := NewAuthenticator(tdlibParams, cfg.Telegram.PhoneNumber, cfg.Telegram.BotToken,
+		cfg.Telegram.LoginMethodOrDefault() == config.LoginQR, logger)
 
 	c := &Client{
-		tdClient:  tdClient,
+		auth:      auth,
 		config:    cfg,
 		logger:    logger,
 		handlers:  make([]MessageHandler, 0),
@@ -87,72 +89,65 @@ func NewClient(cfg *config.Config, logger *logrus.Logger) (*Client, error) {
 	return c, nil
 }
 
-// Start initializes and authenticates the client
+// Start creates the TDLib session and blocks until the account is
+// authorized. Codes and passwords are submitted through Auth meanwhile.
 func (c *Client) Start() error {
 	c.logger.Info("Starting Telegram client...")
 
-	// TDLib parameters are already set by QrAuthorizer
-	// Wait for authentication to complete
-	if err := c.waitForAuthentication(); err != nil {
-		return fmt.Errorf("authentication failed: %w", err)
+	if err := c.connect(); err != nil {
+		return err
 	}
 
-	c.connected = true
+	c.logger.Info("✓ Authorization successful!")
 	c.logger.Info("Telegram client started successfully")
 
 	return nil
 }
 
-// setTDLibParameters sets TDLib configuration parameters
-func (c *Client) setTDLibParameters() error {
-	tdlibCfg := c.config.TDLib
-
-	params := &client.SetTdlibParametersRequest{
-		UseTestDc:           c.config.Telegram.UseTestDC,
-		DatabaseDirectory:   tdlibCfg.DatabaseDirectory,
-		FilesDirectory:      tdlibCfg.FilesDirectory,
-		UseFileDatabase:     tdlibCfg.UseFileDatabase,
-		UseChatInfoDatabase: tdlibCfg.UseChatInfoDB,
-		UseMessageDatabase:  tdlibCfg.UseMessageDB,
-		UseSecretChats:      false,
-		ApiId:               c.config.Telegram.APIID,
-		ApiHash:             c.config.Telegram.APIHash,
-		SystemLanguageCode:  tdlibCfg.SystemLanguage,
-		DeviceModel:         tdlibCfg.DeviceModel,
-		SystemVersion:       tdlibCfg.SystemVersion,
-		ApplicationVersion:  tdlibCfg.AppVersion,
+// connect creates and authorizes a new TDLib session
+func (c *Client) connect() error {
+	tdClient, err := client.NewClient(c.auth, client.WithLogVerbosity(&client.SetLogVerbosityLevelRequest{
+		NewVerbosityLevel: 1,
+	}))
+	if err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
 	}
 
-	_, err := c.tdClient.SetTdlibParameters(params)
-	return err
+	c.mu.Lock()
+	c.tdClient = tdClient
+	c.connected = true
+	c.connState = ""
+	c.wasReady = false
+	c.mu.Unlock()
+
+	return nil
 }
 
-// waitForAuthentication waits for the authentication flow to complete
-func (c *Client) waitForAuthentication() error {
-	// Authentication is handled by the QrAuthorizer
-	// User needs to scan the QR code with their Telegram app
-	maxRetries := 60 // 2 minutes total for QR code scanning
-	for i := 0; i < maxRetries; i++ {
-		state, err := c.tdClient.GetAuthorizationState()
-		if err != nil {
-			return fmt.Errorf("failed to get authorization state: %w", err)
-		}
+// td returns the current TDLib session, which changes on reconnect
+func (c *Client) td() *client.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tdClient
+}
 
-		if _, ok := state.(*client.AuthorizationStateReady); ok {
-			c.logger.Info("✓ Authorization successful!")
-			return nil
-		}
+// OnReconnect registers a function called after the session is re-created
+// or the network connection comes back
+func (c *Client) OnReconnect(handler func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onReconnect = append(c.onReconnect, handler)
+}
 
-		if i == 0 {
-			c.logger.Infof("Current authorization state: %T", state)
-		}
-		if i%5 == 0 && i > 0 {
-			c.logger.Debugf("Waiting for authorization... (%d seconds elapsed)", i*2)
-		}
-		time.Sleep(2 * time.Second)
-	}
+// ConnectionState returns the network state last reported by TDLib
+func (c *Client) ConnectionState() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.connState
+}
 
-	return fmt.Errorf("authorization timeout after %d seconds", maxRetries*2)
+// Auth returns the authenticator driving the login
+func (c *Client) Auth() *Authenticator {
+	return c.auth
 }
 
 // GetChat retrieves chat information by username or ID
@@ -162,7 +157,7 @@ func (c *Client) GetChat(identifier string) (*client.Chat, error) {
 		Username: identifier,
 	}
 
-	chat, err := c.tdClient.SearchPublicChat(req)
+	chat, err := c.td().SearchPublicChat(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search chat: %w", err)
 	}
@@ -170,22 +165,39 @@ func (c *Client) GetChat(identifier string) (*client.Chat, error) {
 	return chat, nil
 }
 
-// GetChatHistory retrieves message history from a chat
-func (c *Client) GetChatHistory(chatID int64, limit int32) ([]*client.Message, error) {
+// GetChatHistory retrieves a page of message history from a chat, newest
+// first, starting before fromMessageID (0 for the latest message). TDLib may
+// return fewer messages than requested; an empty page means the start of the
+// history was reached.
+func (c *Client) GetChatHistory(chatID, fromMessageID int64, limit int32) ([]*models.Message, error) {
+	if !c.IsConnected() {
+		return nil, fmt.Errorf("telegram client is not authorized yet")
+	}
+
+	chat, err := c.td().GetChat(&client.GetChatRequest{ChatId: chatID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat info: %w", err)
+	}
+
 	req := &client.GetChatHistoryRequest{
 		ChatId:        chatID,
-		FromMessageId: 0,
+		FromMessageId: fromMessageID,
 		Offset:        0,
 		Limit:         limit,
 		OnlyLocal:     false,
 	}
 
-	history, err := c.tdClient.GetChatHistory(req)
+	history, err := c.td().GetChatHistory(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get chat history: %w", err)
 	}
 
-	return history.Messages, nil
+	messages := make([]*models.Message, 0, len(history.Messages))
+	for _, msg := range history.Messages {
+		messages = append(messages, c.convertMessage(msg, chat))
+	}
+
+	return messages, nil
 }
 
 // JoinChat joins a channel by username, ID, or invite link
@@ -222,7 +234,7 @@ func (c *Client) JoinChat(identifier string) (*client.Chat, error) {
 			ChatId: chat.Id,
 		}
 
-		_, err = c.tdClient.JoinChat(joinReq)
+		_, err = c.td().JoinChat(joinReq)
 		if err != nil {
 			c.logger.Warnf("Join chat returned error (might already be member): %v", err)
 		}
@@ -231,6 +243,38 @@ func (c *Client) JoinChat(identifier string) (*client.Chat, error) {
 	return chat, nil
 }
 
+// SendText sends a plain text message to a chat
+func (c *Client) SendText(chatID int64, text string) error {
+	if !c.IsConnected() {
+		return fmt.Errorf("telegram client is not authorized yet")
+	}
+
+	_, err := c.td().SendMessage(&client.SendMessageRequest{
+		ChatId: chatID,
+		InputMessageContent: &client.InputMessageText{
+			Text: &client.FormattedText{Text: text},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return nil
+}
+
+// ResolveChannel joins a channel and returns its ID and title
+func (c *Client) ResolveChannel(identifier string) (*ChatInfo, error) {
+	if !c.IsConnected() {
+		return nil, fmt.Errorf("telegram client is not authorized yet")
+	}
+
+	chat, err := c.JoinChat(identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChatInfo{ID: chat.Id, Title: chat.Title}, nil
+}
+
 // isInviteLink checks if the identifier is an invite link
 func isInviteLink(identifier string) bool {
 	return strings.Contains(identifier, "t.me/+") ||
@@ -259,7 +303,7 @@ func (c *Client) joinByInviteLink(link string) (*client.Chat, error) {
 		InviteLink: link,
 	}
 
-	linkInfo, err := c.tdClient.CheckChatInviteLink(checkReq)
+	linkInfo, err := c.td().CheckChatInviteLink(checkReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check invite link: %w", err)
 	}
@@ -271,7 +315,7 @@ func (c *Client) joinByInviteLink(link string) (*client.Chat, error) {
 		InviteLink: link,
 	}
 
-	chat, err := c.tdClient.JoinChatByInviteLink(joinReq)
+	chat, err := c.td().JoinChatByInviteLink(joinReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to join by invite link: %w", err)
 	}
@@ -290,7 +334,7 @@ func (c *Client) joinByChatID(chatID int64) (*client.Chat, error) {
 		ChatId: chatID,
 	}
 
-	chat, err := c.tdClient.GetChat(getReq)
+	chat, err := c.td().GetChat(getReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get chat: %w", err)
 	}
@@ -300,7 +344,7 @@ func (c *Client) joinByChatID(chatID int64) (*client.Chat, error) {
 		ChatId: chatID,
 	}
 
-	_, err = c.tdClient.JoinChat(joinReq)
+	_, err = c.td().JoinChat(joinReq)
 	if err != nil {
 		c.logger.Warnf("Join chat returned error (might already be member): %v", err)
 	}
@@ -317,20 +361,36 @@ func (c *Client) AddMessageHandler(handler MessageHandler) {
 	c.handlers = append(c.handlers, handler)
 }
 
-// StartListening starts listening for new messages
+// StartListening listens for new messages until Stop is called. When
+// Telegram closes the session it is re-created with backoff, and the
+// reconnect handlers are notified so missed messages can be caught up.
 func (c *Client) StartListening() error {
 	c.logger.Info("Starting message listener...")
 
-	listener := c.tdClient.GetListener()
+	for {
+		if !c.listen() {
+			c.logger.Info("Stopping message listener...")
+			return nil
+		}
+
+		if !c.reconnect() {
+			return nil
+		}
+		c.notifyReconnect()
+	}
+}
+
+// listen handles updates of the current session. It returns true when the
+// session was closed and false when the client is stopping.
+func (c *Client) listen() bool {
+	listener := c.td().GetListener()
 	defer listener.Close()
 
 	for {
 		select {
 		case <-c.ctx.Done():
-			c.logger.Info("Stopping message listener...")
-			return nil
-		default:
-			update := <-listener.Updates
+			return false
+		case update := <-listener.Updates:
 			if update == nil {
 				continue
 			}
@@ -341,11 +401,54 @@ func (c *Client) StartListening() error {
 				c.handleNewMessage(update.(*client.UpdateNewMessage))
 			case client.TypeUpdateMessageContent:
 				c.logger.Debug("Message content updated")
+			case client.TypeUpdateConnectionState:
+				c.handleConnectionStateUpdate(update.(*client.UpdateConnectionState))
 			case client.TypeUpdateAuthorizationState:
 				authUpdate := update.(*client.UpdateAuthorizationState)
-				c.handleAuthorizationStateUpdate(authUpdate)
+				if c.handleAuthorizationStateUpdate(authUpdate) {
+					return true
+				}
+			}
+		}
+	}
+}
+
+// reconnect re-creates the TDLib session, doubling the delay after each
+// failed attempt. It returns false if the client is stopped meanwhile.
+func (c *Client) reconnect() bool {
+	delay := reconnectInitialDelay
+	for attempt := 1; ; attempt++ {
+		c.logger.Infof("Reconnecting to Telegram in %s (attempt %d)", delay, attempt)
+
+		select {
+		case <-c.ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+
+		if err := c.connect(); err != nil {
+			if c.ctx.Err() != nil {
+				return false
 			}
+			c.logger.Errorf("Reconnection failed: %v", err)
+			delay = min(delay*2, reconnectMaxDelay)
+			continue
 		}
+
+		c.logger.Info("Reconnection successful")
+		return true
+	}
+}
+
+// notifyReconnect calls the reconnect handlers
+func (c *Client) notifyReconnect() {
+	c.mu.RLock()
+	handlers := make([]func(), len(c.onReconnect))
+	copy(handlers, c.onReconnect)
+	c.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler()
 	}
 }
 
@@ -354,7 +457,7 @@ func (c *Client) handleNewMessage(update *client.UpdateNewMessage) {
 	msg := update.Message
 
 	// Get chat info to determine if it's a channel
-	chat, err := c.tdClient.GetChat(&client.GetChatRequest{ChatId: msg.ChatId})
+	chat, err := c.td().GetChat(&client.GetChatRequest{ChatId: msg.ChatId})
 	if err != nil {
 		c.logger.Errorf("Failed to get chat info: %v", err)
 		return
@@ -381,13 +484,63 @@ func (c *Client) handleNewMessage(update *client.UpdateNewMessage) {
 	}
 }
 
-// handleAuthorizationStateUpdate handles authorization state changes
-func (c *Client) handleAuthorizationStateUpdate(update *client.UpdateAuthorizationState) {
+// handleAuthorizationStateUpdate handles authorization state changes and
+// reports whether the session was closed
+func (c *Client) handleAuthorizationStateUpdate(update *client.UpdateAuthorizationState) bool {
 	c.logger.Infof("Authorization state changed to: %T", update.AuthorizationState)
 
-	if _, ok := update.AuthorizationState.(*client.AuthorizationStateClosed); ok {
-		c.connected = false
-		c.logger.Warn("Connection closed by Telegram")
+	if _, ok := update.AuthorizationState.(*client.AuthorizationStateClosed); !ok {
+		return false
+	}
+
+	c.mu.Lock()
+	c.connected = false
+	c.mu.Unlock()
+
+	c.logger.Warn("Connection closed by Telegram")
+	return true
+}
+
+// handleConnectionStateUpdate tracks the network state. Coming back to
+// ready after an outage counts as a reconnect.
+func (c *Client) handleConnectionStateUpdate(update *client.UpdateConnectionState) {
+	var state string
+	switch update.State.(type) {
+	case *client.ConnectionStateWaitingForNetwork:
+		state = ConnectionWaitingForNetwork
+	case *client.ConnectionStateConnectingToProxy:
+		state = ConnectionConnectingToProxy
+	case *client.ConnectionStateConnecting:
+		state = ConnectionConnecting
+	case *client.ConnectionStateUpdating:
+		state = ConnectionUpdating
+	case *client.ConnectionStateReady:
+		state = ConnectionReady
+	default:
+		return
+	}
+
+	c.mu.Lock()
+	previous := c.connState
+	c.connState = state
+	recovered := state == ConnectionReady && c.wasReady && previous != ConnectionReady
+	if state == ConnectionReady {
+		c.wasReady = true
+	}
+	c.mu.Unlock()
+
+	if state == previous {
+		return
+	}
+
+	if state == ConnectionReady {
+		c.logger.Info("Telegram connection ready")
+	} else {
+		c.logger.Warnf("Telegram connection state: %s", state)
+	}
+
+	if recovered {
+		c.notifyReconnect()
 	}
 }
 
@@ -519,14 +672,23 @@ func (c *Client) IsConnected() bool {
 func (c *Client) Stop() error {
 	c.logger.Info("Stopping Telegram client...")
 	c.cancel()
+	c.auth.Cancel()
+
+	tdClient := c.td()
+	if tdClient == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	c.connected = false
+	c.mu.Unlock()
 
 	// Close TDLib client
-	_, err := c.tdClient.Close()
+	_, err := tdClient.Close()
 	if err != nil {
 		return fmt.Errorf("failed to close TDLib client: %w", err)
 	}
 
-	c.connected = false
 	c.logger.Info("Telegram client stopped")
 	return nil
 }
@@ -0,0 +1,222 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tdlib-go/pkg/models"
+)
+
+// FakeSource is an in-memory MessageSource. Messages passed to Emit are
+// delivered to the handlers synchronously and kept as channel history.
+type FakeSource struct {
+	mu          sync.RWMutex
+	handlers    []MessageHandler
+	onReconnect []func()
+	channels    map[int64]*ChatInfo
+	usernames   map[string]int64
+	history     map[int64][]*models.Message // Oldest first
+	connected   bool
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewFakeSource creates a connected in-memory message source
+func NewFakeSource() *FakeSource {
+	return &FakeSource{
+		handlers:  make([]MessageHandler, 0),
+		channels:  make(map[int64]*ChatInfo),
+		usernames: make(map[string]int64),
+		history:   make(map[int64][]*models.Message),
+		connected: true,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// AddChannel registers a channel that ResolveChannel finds by ID or by any
+// of the given usernames
+func (f *FakeSource) AddChannel(id int64, title string, usernames ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.channels[id] = &ChatInfo{ID: id, Title: title}
+	for _, username := range usernames {
+		f.usernames[normalizeUsername(username)] = id
+	}
+}
+
+// AddMessageHandler adds a handler for incoming messages
+func (f *FakeSource) AddMessageHandler(handler MessageHandler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers = append(f.handlers, handler)
+}
+
+// ResolveChannel finds a registered channel. Unknown numeric IDs are
+// accepted and registered on the fly.
+func (f *FakeSource) ResolveChannel(identifier string) (*ChatInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if isChatID(identifier) {
+		chatID, err := parseChatID(identifier)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chat ID: %w", err)
+		}
+		chat, ok := f.channels[chatID]
+		if !ok {
+			chat = &ChatInfo{ID: chatID, Title: identifier}
+			f.channels[chatID] = chat
+		}
+		return &ChatInfo{ID: chat.ID, Title: chat.Title}, nil
+	}
+
+	chatID, ok := f.usernames[normalizeUsername(identifier)]
+	if !ok {
+		return nil, fmt.Errorf("failed to find chat: unknown channel %s", identifier)
+	}
+	chat := f.channels[chatID]
+	return &ChatInfo{ID: chat.ID, Title: chat.Title}, nil
+}
+
+// GetChatHistory returns emitted and recorded messages of a chat, newest first
+func (f *FakeSource) GetChatHistory(chatID, fromMessageID int64, limit int32) ([]*models.Message, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	history := f.history[chatID]
+	messages := make([]*models.Message, 0, limit)
+	for i := len(history) - 1; i >= 0 && int32(len(messages)) < limit; i-- {
+		if fromMessageID != 0 && history[i].MessageID >= fromMessageID {
+			continue
+		}
+		messages = append(messages, history[i])
+	}
+
+	return messages, nil
+}
+
+// AddHistory records messages as past channel history without delivering them
+func (f *FakeSource) AddHistory(messages ...*models.Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, msg := range messages {
+		f.record(msg)
+	}
+}
+
+// Emit delivers a message to all handlers as if it had just been posted.
+// Missing message IDs and timestamps are filled in.
+func (f *FakeSource) Emit(msg *models.Message) error {
+	f.mu.Lock()
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	if msg.MediaType == "" {
+		msg.MediaType = "text"
+	}
+	f.record(msg)
+	handlers := make([]MessageHandler, len(f.handlers))
+	copy(handlers, f.handlers)
+	f.mu.Unlock()
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// record appends a message to its channel history, registering the
+// channel's name and assigning the next message ID when missing. Caller holds mu.
+func (f *FakeSource) record(msg *models.Message) {
+	chat, ok := f.channels[msg.ChannelID]
+	if !ok {
+		chat = &ChatInfo{ID: msg.ChannelID, Title: strconv.FormatInt(msg.ChannelID, 10)}
+		f.channels[msg.ChannelID] = chat
+	}
+	if msg.ChannelName != "" {
+		// Channels resolved by ID learn their name from the first message
+		if chat.Title == strconv.FormatInt(chat.ID, 10) {
+			chat.Title = msg.ChannelName
+		}
+		f.usernames[normalizeUsername(msg.ChannelName)] = msg.ChannelID
+	}
+
+	history := f.history[msg.ChannelID]
+	if msg.MessageID == 0 {
+		msg.MessageID = 1
+		if len(history) > 0 {
+			msg.MessageID = history[len(history)-1].MessageID + 1
+		}
+	}
+	f.history[msg.ChannelID] = append(history, msg)
+}
+
+// StartListening blocks until Stop is called
+func (f *FakeSource) StartListening() error {
+	<-f.stopCh
+	return nil
+}
+
+// SetConnected changes the reported connection state, e.g. to simulate an outage
+func (f *FakeSource) SetConnected(connected bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connected = connected
+}
+
+// OnReconnect registers a function called by Reconnect
+func (f *FakeSource) OnReconnect(handler func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.onReconnect = append(f.onReconnect, handler)
+}
+
+// Reconnect marks the source connected again and notifies the reconnect
+// handlers. Messages added with AddHistory meanwhile are the ones missed.
+func (f *FakeSource) Reconnect() {
+	f.mu.Lock()
+	f.connected = true
+	handlers := make([]func(), len(f.onReconnect))
+	copy(handlers, f.onReconnect)
+	f.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler()
+	}
+}
+
+// IsConnected returns whether the source is connected
+func (f *FakeSource) IsConnected() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.connected
+}
+
+// Stop stops listening
+func (f *FakeSource) Stop() error {
+	f.stopOnce.Do(func() {
+		close(f.stopCh)
+	})
+	f.SetConnected(false)
+	return nil
+}
+
+// normalizeUsername strips the @ and t.me prefixes from a channel username
+func normalizeUsername(username string) string {
+	username = strings.TrimSpace(strings.ToLower(username))
+	for _, prefix := range []string{"https://", "http://", "t.me/", "telegram.me/", "@"} {
+		username = strings.TrimPrefix(username, prefix)
+	}
+	return username
+}
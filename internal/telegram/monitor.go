@@ -3,6 +3,7 @@ package telegram
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"tdlib-go/internal/config"
@@ -10,9 +11,15 @@ import (
 	"tdlib-go/pkg/models"
 )
 
+// History import paging
+const (
+	historyPageSize  = 100
+	historyPageDelay = 500 * time.Millisecond
+)
+
 // Monitor manages channel subscriptions and message monitoring
 type Monitor struct {
-	client     *Client
+	source     MessageSource
 	repo       *storage.Repository
 	config     *config.Config
 	logger     *logrus.Logger
@@ -21,16 +28,36 @@ type Monitor struct {
 
 	// Trading engine callback
 	onMessage func(*models.Message) error
+
+	// Records signals from imported history without executing them
+	onHistoricalMessage func(*models.Message) (bool, error)
+
+	// Selects messages to archive in "signals" archive mode
+	isSignalCandidate func(*models.Message) bool
+
+	// Last seen message per channel and recently delivered IDs
+	lastSeen map[int64]int64
+	seen     map[int64]*seenWindow
+	seenMu   sync.Mutex
+
+	deliverMu sync.Mutex // Serializes live and caught-up deliveries
+	catchUpMu sync.Mutex
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
 }
 
-// NewMonitor creates a new channel monitor
-func NewMonitor(client *Client, repo *storage.Repository, cfg *config.Config, logger *logrus.Logger) *Monitor {
+// NewMonitor creates a new channel monitor reading from source
+func NewMonitor(source MessageSource, repo *storage.Repository, cfg *config.Config, logger *logrus.Logger) *Monitor {
 	return &Monitor{
-		client:   client,
+		source:   source,
 		repo:     repo,
 		config:   cfg,
 		logger:   logger,
 		channels: make(map[int64]*models.Channel),
+		lastSeen: make(map[int64]int64),
+		seen:     make(map[int64]*seenWindow),
+		stopCh:   make(chan struct{}),
 	}
 }
 
@@ -39,7 +66,14 @@ func (m *Monitor) Start() error {
 	m.logger.Info("Starting channel monitor...")
 
 	// Register message handler
-	m.client.AddMessageHandler(m.handleMessage)
+	m.source.AddMessageHandler(m.handleMessage)
+
+	// Catch up on messages missed while the source was disconnected
+	if reconnecting, ok := m.source.(ReconnectingSource); ok {
+		reconnecting.OnReconnect(func() {
+			go m.CatchUp()
+		})
+	}
 
 	// Subscribe to channels from config
 	for _, channelIdentifier := range m.config.Channels {
@@ -58,30 +92,48 @@ func (m *Monitor) Start() error {
 			m.channelsMu.Lock()
 			m.channels[ch.ChannelID] = ch
 			m.channelsMu.Unlock()
+
+			if ch.LastMessageID > 0 {
+				m.seenMu.Lock()
+				m.lastSeen[ch.ChannelID] = max(m.lastSeen[ch.ChannelID], ch.LastMessageID)
+				m.seenMu.Unlock()
+			}
 		}
 	}
 
 	m.logger.Infof("Monitoring %d channels", len(m.channels))
 
+	if retention := m.config.Archive.Retention(); retention > 0 {
+		go m.runRetention(retention)
+	}
+
 	return nil
 }
 
+// Stop stops background archive maintenance
+func (m *Monitor) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stopCh)
+	})
+}
+
 // SubscribeChannel subscribes to a channel and optionally fetches history
 func (m *Monitor) SubscribeChannel(identifier string) error {
 	m.logger.Infof("Subscribing to channel: %s", identifier)
 
 	// Join the channel
-	chat, err := m.client.JoinChat(identifier)
+	chat, err := m.source.ResolveChannel(identifier)
 	if err != nil {
 		return fmt.Errorf("failed to join channel: %w", err)
 	}
 
 	// Create channel model
 	channel := &models.Channel{
-		ChannelID: chat.Id,
-		Username:  identifier,
-		Title:     chat.Title,
-		IsActive:  true,
+		ChannelID:      chat.ID,
+		Username:       identifier,
+		Title:          chat.Title,
+		IsActive:       true,
+		TradingEnabled: true,
 	}
 
 	// Save to database
@@ -91,27 +143,110 @@ func (m *Monitor) SubscribeChannel(identifier string) error {
 
 	// Add to memory
 	m.channelsMu.Lock()
-	m.channels[chat.Id] = channel
+	m.channels[chat.ID] = channel
 	m.channelsMu.Unlock()
 
-	m.logger.Infof("Successfully subscribed to channel: %s (ID: %d)", chat.Title, chat.Id)
+	m.logger.Infof("Successfully subscribed to channel: %s (ID: %d)", chat.Title, chat.ID)
 
 	return nil
 }
 
-// FetchHistory fetches historical messages from a channel
-func (m *Monitor) FetchHistory(channelID int64, limit int32) error {
-	m.logger.Infof("Fetching history for channel %d (limit: %d)", channelID, limit)
+// FetchHistory imports a channel's history, paging back from the latest
+// message until the count or date limit is reached. Fetched messages are
+// archived like live ones and passed to the historical callback so signals
+// can be recorded without being executed.
+func (m *Monitor) FetchHistory(channelID int64, opts models.HistoryOptions) (*models.HistoryProgress, error) {
+	m.logger.Infof("Importing history for channel %d (limit: %d, since: %s)", channelID, opts.Limit, formatSince(opts.Since))
+
+	progress := &models.HistoryProgress{
+		ChannelID: channelID,
+		Status:    models.HistoryRunning,
+		StartedAt: time.Now(),
+	}
+
+	err := m.importHistory(channelID, opts, progress)
 
-	messages, err := m.client.GetChatHistory(channelID, limit)
+	finished := time.Now()
+	progress.FinishedAt = &finished
 	if err != nil {
-		return fmt.Errorf("failed to fetch history: %w", err)
+		progress.Status = models.HistoryFailed
+		progress.Error = err.Error()
+	} else {
+		progress.Status = models.HistoryCompleted
+	}
+	if opts.Progress != nil {
+		opts.Progress(progress)
 	}
 
-	m.logger.Infof("Fetched %d messages from channel %d (skipping history save)", len(messages), channelID)
+	if err != nil {
+		return progress, err
+	}
 
-	// Skip saving message history - we only need real-time processing
-	return nil
+	m.logger.Infof("Imported %d messages from channel %d (%d saved, %d signals)",
+		progress.Fetched, channelID, progress.Saved, progress.Signals)
+
+	return progress, nil
+}
+
+// importHistory pages through a channel's history and updates progress
+func (m *Monitor) importHistory(channelID int64, opts models.HistoryOptions, progress *models.HistoryProgress) error {
+	fromMessageID := int64(0)
+
+	for {
+		page, err := m.source.GetChatHistory(channelID, fromMessageID, historyPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to fetch history: %w", err)
+		}
+		if len(page) == 0 {
+			return nil
+		}
+
+		for _, msg := range page {
+			if opts.Limit > 0 && progress.Fetched >= opts.Limit {
+				return nil
+			}
+			if !opts.Since.IsZero() && msg.Timestamp.Before(opts.Since) {
+				return nil
+			}
+
+			progress.Fetched++
+			progress.OldestMessageAt = msg.Timestamp
+
+			if m.archiveMessage(msg) {
+				progress.Saved++
+			}
+
+			if m.onHistoricalMessage != nil {
+				recorded, err := m.onHistoricalMessage(msg)
+				if err != nil {
+					m.logger.Errorf("Historical message callback error: %v", err)
+				} else if recorded {
+					progress.Signals++
+				}
+			}
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+
+		fromMessageID = page[len(page)-1].MessageID
+
+		// Stay well below Telegram's flood limits on long imports
+		select {
+		case <-m.stopCh:
+			return fmt.Errorf("monitor stopped")
+		case <-time.After(historyPageDelay):
+		}
+	}
+}
+
+// formatSince renders an optional date limit for logging
+func formatSince(since time.Time) string {
+	if since.IsZero() {
+		return "none"
+	}
+	return since.Format("2006-01-02")
 }
 
 // UnsubscribeChannel unsubscribes from a channel
@@ -133,14 +268,18 @@ func (m *Monitor) UnsubscribeChannel(channelID int64) error {
 	return nil
 }
 
-// ListChannels returns all monitored channels
+// ListChannels returns all monitored channels with their last seen message
 func (m *Monitor) ListChannels() []*models.Channel {
+	lastSeen := m.LastSeenMessageIDs()
+
 	m.channelsMu.RLock()
 	defer m.channelsMu.RUnlock()
 
 	channels := make([]*models.Channel, 0, len(m.channels))
 	for _, ch := range m.channels {
-		channels = append(channels, ch)
+		channel := *ch
+		channel.LastMessageID = lastSeen[ch.ChannelID]
+		channels = append(channels, &channel)
 	}
 
 	return channels
@@ -150,7 +289,7 @@ func (m *Monitor) ListChannels() []*models.Channel {
 func (m *Monitor) handleMessage(msg *models.Message) error {
 	// Check if we're monitoring this channel
 	m.channelsMu.RLock()
-	channel, exists := m.channels[msg.ChannelID]
+	_, exists := m.channels[msg.ChannelID]
 	m.channelsMu.RUnlock()
 
 	if !exists {
@@ -158,9 +297,33 @@ func (m *Monitor) handleMessage(msg *models.Message) error {
 		return nil
 	}
 
+	m.deliverMessage(msg)
+	return nil
+}
+
+// deliverMessage logs, archives and passes on a message of a monitored
+// channel unless it was delivered before. It reports whether it was new.
+func (m *Monitor) deliverMessage(msg *models.Message) bool {
+	if !m.markSeen(msg) {
+		m.logger.Debugf("Skipping already delivered message %d from channel %d", msg.MessageID, msg.ChannelID)
+		return false
+	}
+
+	m.deliverMu.Lock()
+	defer m.deliverMu.Unlock()
+
+	m.channelsMu.RLock()
+	channel, exists := m.channels[msg.ChannelID]
+	m.channelsMu.RUnlock()
+
+	title := msg.ChannelName
+	if exists {
+		title = channel.Title
+	}
+
 	// Log the message
 	m.logger.WithFields(logrus.Fields{
-		"channel":    channel.Title,
+		"channel":    title,
 		"channel_id": msg.ChannelID,
 		"message_id": msg.MessageID,
 		"sender_id":  msg.SenderID,
@@ -180,17 +343,71 @@ func (m *Monitor) handleMessage(msg *models.Message) error {
 	}
 	fmt.Println("---")
 
-	// Call message callback (for trading engine) - skip saving to database
+	// Archive before the callback so signals can reference the stored message
+	m.archiveMessage(msg)
+
+	// Call message callback (for trading engine)
 	if m.onMessage != nil {
 		if err := m.onMessage(msg); err != nil {
 			m.logger.Errorf("Message callback error: %v", err)
 		}
 	}
 
-	return nil
+	return true
 }
 
 // SetMessageCallback sets the message callback function
 func (m *Monitor) SetMessageCallback(callback func(*models.Message) error) {
 	m.onMessage = callback
 }
+
+// SetHistoricalCallback sets the function that processes imported history messages.
+// It reports whether a signal was recorded.
+func (m *Monitor) SetHistoricalCallback(callback func(*models.Message) (bool, error)) {
+	m.onHistoricalMessage = callback
+}
+
+// SetSignalFilter sets the function that selects messages to archive in "signals" mode
+func (m *Monitor) SetSignalFilter(filter func(*models.Message) bool) {
+	m.isSignalCandidate = filter
+}
+
+// archiveMessage stores a message according to the configured archive mode
+// and reports whether it was stored
+func (m *Monitor) archiveMessage(msg *models.Message) bool {
+	switch m.config.Archive.ModeOrDefault() {
+	case config.ArchiveNone:
+		return false
+	case config.ArchiveSignals:
+		if m.isSignalCandidate == nil || !m.isSignalCandidate(msg) {
+			return false
+		}
+	}
+
+	if err := m.repo.SaveMessage(msg); err != nil {
+		m.logger.Errorf("Failed to archive message %d from channel %d: %v", msg.MessageID, msg.ChannelID, err)
+		return false
+	}
+	return true
+}
+
+// runRetention periodically deletes archived messages older than the retention period
+func (m *Monitor) runRetention(retention time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := m.repo.DeleteMessagesBefore(time.Now().Add(-retention))
+		if err != nil {
+			m.logger.Errorf("Failed to apply message retention: %v", err)
+		} else if deleted > 0 {
+			m.logger.Infof("Deleted %d archived messages older than %s", deleted, retention)
+		}
+
+		select {
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package telegram
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"tdlib-go/pkg/models"
+)
+
+// ReplaySource replays a JSON Lines stream of models.Message, such as a file
+// or stdin, as live channel messages. It runs without a Telegram session, so
+// channels must be subscribed by chat ID or by a name seen in the stream.
+type ReplaySource struct {
+	*FakeSource
+
+	reader io.Reader
+	closer io.Closer
+	speed  float64
+	logger *logrus.Logger
+
+	closeOnce sync.Once
+}
+
+// NewReplaySource creates a replay source reading from r. Speed scales the
+// gaps between message timestamps (2 replays twice as fast); 0 replays
+// without delay.
+func NewReplaySource(r io.Reader, speed float64, logger *logrus.Logger) *ReplaySource {
+	return &ReplaySource{
+		FakeSource: NewFakeSource(),
+		reader:     r,
+		speed:      speed,
+		logger:     logger,
+	}
+}
+
+// OpenReplaySource creates a replay source reading from a file, or from
+// stdin when path is "-"
+func OpenReplaySource(path string, speed float64, logger *logrus.Logger) (*ReplaySource, error) {
+	if path == "-" {
+		return NewReplaySource(os.Stdin, speed, logger), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replay file: %w", err)
+	}
+
+	source := NewReplaySource(f, speed, logger)
+	source.closer = f
+	return source, nil
+}
+
+// StartListening delivers the replayed messages, then blocks until Stop is called
+func (r *ReplaySource) StartListening() error {
+	r.logger.Info("Starting message replay...")
+
+	count, err := r.replay()
+	if err != nil {
+		return err
+	}
+
+	r.logger.Infof("Replay finished after %d messages", count)
+	<-r.stopCh
+	return nil
+}
+
+// replay reads and emits messages until the end of the stream or Stop
+func (r *ReplaySource) replay() (int, error) {
+	scanner := bufio.NewScanner(r.reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	var last time.Time
+	count := 0
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Bytes()
+		if len(text) == 0 {
+			continue
+		}
+
+		msg := &models.Message{}
+		if err := json.Unmarshal(text, msg); err != nil {
+			r.logger.Warnf("Skipping replay line %d: invalid message: %v", line, err)
+			continue
+		}
+
+		if r.speed > 0 && !last.IsZero() && msg.Timestamp.After(last) {
+			select {
+			case <-r.stopCh:
+				return count, nil
+			case <-time.After(time.Duration(float64(msg.Timestamp.Sub(last)) / r.speed)):
+			}
+		}
+		if !msg.Timestamp.IsZero() {
+			last = msg.Timestamp
+		}
+
+		select {
+		case <-r.stopCh:
+			return count, nil
+		default:
+		}
+
+		if err := r.Emit(msg); err != nil {
+			r.logger.Errorf("Message handler error: %v", err)
+		}
+		count++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("failed to read replay stream: %w", err)
+	}
+
+	return count, nil
+}
+
+// Stop stops the replay and closes the replay file
+func (r *ReplaySource) Stop() error {
+	r.FakeSource.Stop()
+
+	var err error
+	if r.closer != nil {
+		r.closeOnce.Do(func() {
+			err = r.closer.Close()
+		})
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package telegram
+
+import "tdlib-go/pkg/models"
+
+// MessageSource delivers channel messages to the monitor. The TDLib client
+// is the production implementation; ReplaySource and FakeSource run the
+// pipeline without a Telegram session.
+type MessageSource interface {
+	// AddMessageHandler registers a handler for new channel messages
+	AddMessageHandler(handler MessageHandler)
+
+	// ResolveChannel joins a channel by username, invite link or ID
+	ResolveChannel(identifier string) (*ChatInfo, error)
+
+	// GetChatHistory returns a page of messages, newest first, starting
+	// before fromMessageID (0 for the latest message)
+	GetChatHistory(chatID, fromMessageID int64, limit int32) ([]*models.Message, error)
+
+	// StartListening delivers messages until Stop is called
+	StartListening() error
+
+	// Stop stops listening and releases the source
+	Stop() error
+
+	// IsConnected returns whether the source is delivering messages
+	IsConnected() bool
+}
+
+// ChatInfo identifies a resolved channel
+type ChatInfo struct {
+	ID    int64
+	Title string
+}
+
+// ReconnectingSource is a MessageSource that can lose its connection and
+// recover it. Messages posted meanwhile may not have been delivered, so the
+// monitor catches up on channel history after each reconnect.
+type ReconnectingSource interface {
+	MessageSource
+
+	// OnReconnect registers a function called after the source reconnects
+	OnReconnect(handler func())
+}
@@ -0,0 +1,228 @@
+package trading
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"tdlib-go/internal/binance"
+	"tdlib-go/internal/events"
+	"tdlib-go/pkg/models"
+)
+
+// runRiskBreakers periodically checks the risk breakers of every active
+// account, so a breached limit trips the breaker before the next signal
+func (e *Engine) runRiskBreakers() {
+	ticker := time.NewTicker(e.config.Trading.RiskBreaker.Interval())
+	defer ticker.Stop()
+
+	for {
+		e.checkRiskBreakers()
+
+		select {
+		case <-ticker.C:
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+// checkRiskBreakers checks the risk breakers of every active account
+func (e *Engine) checkRiskBreakers() {
+	accounts, err := e.repo.GetActiveAccounts()
+	if err != nil {
+		e.logger.Errorf("Failed to get active accounts: %v", err)
+		return
+	}
+
+	// Checks are serialized with signal execution so a breaker that trips
+	// here is seen by the next signal
+	e.executeMu.Lock()
+	defer e.executeMu.Unlock()
+
+	for _, account := range accounts {
+		if _, err := e.evaluateRiskBreaker(account); err != nil {
+			e.logger.Errorf("Failed to check risk breaker of account %s: %v", account.Name, err)
+		}
+	}
+}
+
+// checkRiskBreaker returns an error when the account's risk breaker is
+// tripped, evaluating its limits first. Callers hold executeMu.
+func (e *Engine) checkRiskBreaker(account *models.BinanceAccount) error {
+	breaker, err := e.evaluateRiskBreaker(account)
+	if err != nil {
+		// A breaker that cannot be checked does not stop trading
+		e.logger.Errorf("Failed to check risk breaker of account %s: %v", account.Name, err)
+		return nil
+	}
+	if breaker != nil && breaker.Tripped {
+		return fmt.Errorf("risk breaker tripped: %s", breaker.Reason)
+	}
+	return nil
+}
+
+// hasRiskLimits reports whether any risk breaker limit is set on an account
+func hasRiskLimits(account *models.BinanceAccount) bool {
+	return account.MaxDailyLoss > 0 || account.MaxLossStreak > 0 || account.MaxDrawdown > 0
+}
+
+// evaluateRiskBreaker updates an account's breaker counters, resetting them
+// at the daily reset hour, and trips the breaker when a limit is breached.
+// The peak equity is kept across daily resets.
+// It returns nil for accounts without limits and no breaker. Callers hold
+// executeMu.
+func (e *Engine) evaluateRiskBreaker(account *models.BinanceAccount) (*models.RiskBreaker, error) {
+	breaker, err := e.repo.GetRiskBreaker(account.ID)
+	if err != nil {
+		return nil, err
+	}
+	if breaker == nil && !hasRiskLimits(account) {
+		return nil, nil
+	}
+
+	now := time.Now()
+	windowStart := e.config.Trading.RiskBreaker.WindowStart(now)
+	if breaker == nil || breaker.WindowStart.Before(windowStart) {
+		if breaker != nil && breaker.Tripped {
+			e.logger.WithField("account", account.Name).Info("Risk breaker reset, account takes signals again")
+			if e.webapi != nil {
+				e.webapi.BroadcastUpdate("risk_breaker_reset", &models.RiskBreaker{AccountID: account.ID, AccountName: account.Name})
+			}
+		}
+		// The drawdown is measured from the peak across windows
+		var peakEquity float64
+		if breaker != nil {
+			peakEquity = breaker.PeakEquity
+		}
+		breaker = &models.RiskBreaker{AccountID: account.ID, WindowStart: windowStart, PeakEquity: peakEquity}
+	}
+	breaker.AccountName = account.Name
+
+	breaker.DailyPnL, breaker.LossStreak, err = e.repo.GetRiskCounters(account.ID, breaker.WindowStart)
+	if err != nil {
+		return nil, err
+	}
+
+	if client, exists := e.binanceClients[account.ID]; exists && account.MaxDrawdown > 0 {
+		if equity, err := marginBalance(client); err != nil {
+			e.logger.Warnf("Failed to get equity of account %s, drawdown not checked: %v", account.Name, err)
+		} else {
+			breaker.Equity = equity
+			if equity > breaker.PeakEquity {
+				breaker.PeakEquity = equity
+			}
+			breaker.Drawdown = 0
+			if breaker.PeakEquity > 0 {
+				breaker.Drawdown = (breaker.PeakEquity - equity) / breaker.PeakEquity
+			}
+		}
+	}
+	breaker.UpdatedAt = now
+
+	tripped := false
+	if !breaker.Tripped {
+		if reason := riskLimitBreached(account, breaker); reason != "" {
+			breaker.Tripped = true
+			breaker.Reason = reason
+			breaker.TrippedAt = &now
+			tripped = true
+		}
+	}
+
+	if err := e.repo.SaveRiskBreaker(breaker); err != nil {
+		return nil, err
+	}
+
+	if tripped {
+		e.tripRiskBreaker(account, breaker)
+	}
+
+	return breaker, nil
+}
+
+// riskLimitBreached returns the first breached limit of an account, or ""
+func riskLimitBreached(account *models.BinanceAccount, breaker *models.RiskBreaker) string {
+	if account.MaxDailyLoss > 0 && -breaker.DailyPnL >= account.MaxDailyLoss {
+		return fmt.Sprintf("daily loss %.2f USDT reached the %.2f USDT limit", -breaker.DailyPnL, account.MaxDailyLoss)
+	}
+	if account.MaxLossStreak > 0 && breaker.LossStreak >= account.MaxLossStreak {
+		return fmt.Sprintf("%d losses in a row reached the limit of %d", breaker.LossStreak, account.MaxLossStreak)
+	}
+	if account.MaxDrawdown > 0 && breaker.Drawdown >= account.MaxDrawdown {
+		return fmt.Sprintf("equity drawdown %.2f%% reached the %.2f%% limit", breaker.Drawdown*100, account.MaxDrawdown*100)
+	}
+	return ""
+}
+
+// tripRiskBreaker reports a tripped breaker and, when configured, flattens
+// the account
+func (e *Engine) tripRiskBreaker(account *models.BinanceAccount, breaker *models.RiskBreaker) {
+	e.logger.WithFields(logrus.Fields{
+		"account": account.Name,
+		"reason":  breaker.Reason,
+	}).Warn("Risk breaker tripped, account takes no new signals until the daily reset")
+
+	e.events.Publish(&events.Event{
+		Type:        events.RiskGateTripped,
+		AccountID:   account.ID,
+		AccountName: account.Name,
+		Reason:      fmt.Sprintf("risk breaker: %s", breaker.Reason),
+	})
+
+	update := map[string]interface{}{"breaker": breaker}
+
+	if e.config.Trading.RiskBreaker.Flatten {
+		if client, exists := e.binanceClients[account.ID]; exists {
+			update["flatten"] = e.executorFor(account, client).Flatten()
+		}
+	}
+
+	if e.webapi != nil {
+		e.webapi.BroadcastUpdate("risk_breaker_tripped", update)
+	}
+}
+
+// ResetRiskBreaker clears an account's tripped breaker. The counters and the
+// peak equity restart from now, so the losses that tripped it do not trip it
+// again.
+func (e *Engine) ResetRiskBreaker(accountID int64) (*models.RiskBreaker, error) {
+	account, err := e.repo.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, fmt.Errorf("%w: account %d not found", models.ErrTradeRejected, accountID)
+	}
+
+	e.executeMu.Lock()
+	defer e.executeMu.Unlock()
+
+	now := time.Now()
+	breaker := &models.RiskBreaker{AccountID: account.ID, AccountName: account.Name, WindowStart: now, UpdatedAt: now}
+	if err := e.repo.SaveRiskBreaker(breaker); err != nil {
+		return nil, err
+	}
+
+	e.logger.WithField("account", account.Name).Warn("Risk breaker reset manually")
+
+	if e.webapi != nil {
+		e.webapi.BroadcastUpdate("risk_breaker_reset", breaker)
+	}
+
+	return breaker, nil
+}
+
+// marginBalance returns an account's margin balance, its equity
+func marginBalance(client *binance.Client) (float64, error) {
+	info, err := client.GetAccount()
+	if err != nil {
+		return 0, err
+	}
+	equity, err := strconv.ParseFloat(info.TotalMarginBalance, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse margin balance: %w", err)
+	}
+	return equity, nil
+}
@@ -0,0 +1,45 @@
+package trading
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"tdlib-go/internal/binance"
+	"tdlib-go/internal/config"
+	"tdlib-go/pkg/models"
+)
+
+func TestRiskBreakerKeepsPeakEquityAcrossWindows(t *testing.T) {
+	te := newTestExecutor(t, &models.BinanceAccount{MaxDrawdown: 0.1})
+	engine := &Engine{
+		repo:           te.repo,
+		config:         &config.Config{},
+		logger:         te.logger,
+		binanceClients: map[int64]*binance.Client{te.account.ID: te.binanceClient},
+	}
+
+	te.srv.SetBalance(1000)
+	breaker, err := engine.evaluateRiskBreaker(te.account)
+	if err != nil || breaker == nil || breaker.PeakEquity != 1000 {
+		t.Fatalf("evaluateRiskBreaker = %+v, %v, want a peak of 1000", breaker, err)
+	}
+
+	// The next day starts below the previous day's peak
+	breaker.WindowStart = breaker.WindowStart.Add(-24 * time.Hour)
+	if err := te.repo.SaveRiskBreaker(breaker); err != nil {
+		t.Fatalf("SaveRiskBreaker: %v", err)
+	}
+	te.srv.SetBalance(880)
+
+	breaker, err = engine.evaluateRiskBreaker(te.account)
+	if err != nil {
+		t.Fatalf("evaluateRiskBreaker: %v", err)
+	}
+	if breaker.PeakEquity != 1000 || !approxEqual(breaker.Drawdown, 0.12) {
+		t.Errorf("peak = %v drawdown %v, want 1000 and 0.12", breaker.PeakEquity, breaker.Drawdown)
+	}
+	if !breaker.Tripped || !strings.Contains(breaker.Reason, "drawdown") {
+		t.Errorf("breaker tripped = %v (%s), want the drawdown limit reached", breaker.Tripped, breaker.Reason)
+	}
+}
@@ -0,0 +1,300 @@
+package trading
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"tdlib-go/internal/binance"
+	"tdlib-go/internal/events"
+	"tdlib-go/internal/storage"
+	"tdlib-go/pkg/models"
+)
+
+// PurposeEmergency marks the close orders placed when trading is halted
+const PurposeEmergency = "emergency"
+
+// Halt stops all new trading and flattens the given accounts, or all active
+// accounts when none are given: every open order is canceled and every
+// position closed at market. The halt is persisted and outlives restarts
+// until Resume is called. Halting again keeps the original halt time, so it
+// can be used to flatten further accounts.
+func (e *Engine) Halt(reason string, accountIDs []int64) (*models.HaltResult, error) {
+	state, err := e.repo.GetHaltState()
+	if err != nil {
+		return nil, err
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		reason = "manual halt"
+	}
+	if !state.Halted {
+		now := time.Now()
+		state.HaltedAt = &now
+	}
+	state.Halted = true
+	state.Reason = reason
+
+	if err := e.repo.SaveHaltState(state); err != nil {
+		return nil, err
+	}
+
+	e.logger.WithField("reason", reason).Warn("EMERGENCY HALT: trading halted, flattening accounts")
+
+	e.events.Publish(&events.Event{
+		Type:   events.RiskGateTripped,
+		Reason: fmt.Sprintf("emergency halt: %s", reason),
+	})
+
+	// Executions in flight are not waited for, a limit chase can take
+	// minutes: they check the halt before each account and each entry order
+
+	var accounts []*models.BinanceAccount
+	if len(accountIDs) == 0 {
+		accounts, err = e.repo.GetActiveAccounts()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for _, id := range accountIDs {
+			account, err := e.repo.GetAccount(id)
+			if err != nil {
+				return nil, err
+			}
+			if account == nil {
+				return nil, fmt.Errorf("%w: account %d not found", models.ErrTradeRejected, id)
+			}
+			accounts = append(accounts, account)
+		}
+	}
+
+	result := &models.HaltResult{State: state, Accounts: make([]*models.FlattenResult, 0, len(accounts))}
+	for _, account := range accounts {
+		client, exists := e.binanceClients[account.ID]
+		if !exists {
+			result.Accounts = append(result.Accounts, &models.FlattenResult{
+				AccountID:   account.ID,
+				AccountName: account.Name,
+				Error:       "no Binance client for this account, flatten it on the exchange",
+			})
+			continue
+		}
+
+		result.Accounts = append(result.Accounts, e.executorFor(account, client).Flatten())
+	}
+
+	if e.webapi != nil {
+		e.webapi.BroadcastUpdate("emergency_halt", result)
+	}
+
+	return result, nil
+}
+
+// Resume lifts the emergency halt
+func (e *Engine) Resume() (*models.HaltState, error) {
+	state := &models.HaltState{}
+	if err := e.repo.SaveHaltState(state); err != nil {
+		return nil, err
+	}
+
+	e.logger.Warn("Emergency halt lifted, trading resumed")
+
+	if e.webapi != nil {
+		e.webapi.BroadcastUpdate("emergency_resume", state)
+	}
+
+	return state, nil
+}
+
+// HaltStatus returns the emergency halt state
+func (e *Engine) HaltStatus() (*models.HaltState, error) {
+	return e.repo.GetHaltState()
+}
+
+// checkHalt returns an error while trading is halted
+func (e *Engine) checkHalt() error {
+	return checkHalt(e.repo)
+}
+
+// checkHalt returns an error while the executor's trading is halted
+func (e *OrderExecutor) checkHalt() error {
+	return checkHalt(e.repo)
+}
+
+// checkHalt returns an error while trading is halted. The state is read from
+// the database so a halt from the halt subcommand applies to a running process.
+func checkHalt(repo *storage.Repository) error {
+	state, err := repo.GetHaltState()
+	if err != nil {
+		return fmt.Errorf("failed to check halt state: %w", err)
+	}
+	if state.Halted {
+		return fmt.Errorf("trading is halted: %s", state.Reason)
+	}
+	return nil
+}
+
+// Flatten cancels every open order of the account and closes every position
+// at market, then closes the matching open positions in the database
+func (e *OrderExecutor) Flatten() *models.FlattenResult {
+	result := &models.FlattenResult{AccountID: e.accountID, AccountName: e.accountName, ClosedPositions: []string{}}
+	var errs []string
+
+	// Cancel the open orders first so no TP/SL fires during the close
+	open, err := e.binanceClient.GetOpenOrders("")
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	symbols := make(map[string]bool)
+	for _, order := range open {
+		symbols[order.Symbol] = true
+	}
+	for symbol := range symbols {
+		canceled, err := e.CancelAllOrders(symbol)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", symbol, err))
+		}
+		result.CanceledOrders += canceled
+	}
+
+	positions, err := e.repo.GetOpenPositions()
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	// A symbol can have several positions, e.g. a manual and a signal trade
+	bySymbol := make(map[string][]*models.Position)
+	for _, position := range positions {
+		if position.AccountID == e.accountID {
+			bySymbol[position.Symbol] = append(bySymbol[position.Symbol], position)
+		}
+	}
+
+	exchangePositions, err := e.binanceClient.GetPositions()
+	if err != nil {
+		errs = append(errs, err.Error())
+		// Leave the database alone when the exchange state is unknown
+		bySymbol = nil
+	}
+
+	// Each position gets its own close order, so its exit price and fills
+	// are its own; an amount the database does not know is closed last
+	placeClose := func(symbol, side string, quantity float64, positionID int64) (*binance.OrderResponse, error) {
+		resp, err := e.binanceClient.PlaceOrder(&binance.NewOrder{
+			Symbol:           symbol,
+			Side:             side,
+			Type:             "MARKET",
+			Quantity:         quantity,
+			ReduceOnly:       true,
+			NewClientOrderID: e.clientOrderID(positionRef(positionID), orderKindClose),
+		})
+		if err != nil {
+			return nil, err
+		}
+		e.asyncLogOrder(positionID, resp, PurposeEmergency)
+		return resp, nil
+	}
+
+	exitPrices := make(map[int64]float64) // By position ID
+	closeOrders := make(map[int64]int64)
+	failed := make(map[int64]bool)
+	for _, pos := range exchangePositions {
+		amount, _ := strconv.ParseFloat(pos.PositionAmt, 64)
+		if amount == 0 {
+			continue
+		}
+
+		side := "SELL"
+		if amount < 0 {
+			side = "BUY"
+		}
+
+		remaining := math.Abs(amount)
+		closed := false
+		for _, position := range bySymbol[pos.Symbol] {
+			quantity := math.Min(position.Quantity, remaining)
+			remaining -= quantity
+			if quantity <= 0 {
+				continue // The exchange holds less than the database
+			}
+
+			resp, err := placeClose(pos.Symbol, side, quantity, position.ID)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: failed to place close order of position %d: %v", pos.Symbol, position.ID, err))
+				failed[position.ID] = true
+				continue
+			}
+			exitPrices[position.ID], _ = strconv.ParseFloat(resp.AvgPrice, 64)
+			closeOrders[position.ID] = resp.OrderID
+			closed = true
+		}
+
+		if remaining > 1e-9 {
+			if _, err := placeClose(pos.Symbol, side, remaining, 0); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: failed to place close order: %v", pos.Symbol, err))
+			} else {
+				closed = true
+			}
+		}
+
+		if closed {
+			result.ClosedPositions = append(result.ClosedPositions, pos.Symbol)
+		}
+	}
+
+	// Positions whose close order failed stay open, the rest are flat now
+	for symbol, open := range bySymbol {
+		var tickerPrice float64
+		for _, position := range open {
+			if failed[position.ID] {
+				continue
+			}
+
+			positionExit := exitPrices[position.ID]
+			if positionExit <= 0 && tickerPrice <= 0 {
+				if ticker, err := e.binanceClient.GetSymbolPriceTicker(symbol); err == nil {
+					tickerPrice, _ = strconv.ParseFloat(ticker.Price, 64)
+				}
+			}
+			if positionExit <= 0 {
+				positionExit = tickerPrice
+			}
+			if positionExit <= 0 {
+				positionExit = position.EntryPrice
+			}
+
+			if err := e.repo.ClosePosition(position.ID, positionExit, time.Now()); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", symbol, err))
+				continue
+			}
+			e.syncFills(position, closeOrders[position.ID])
+
+			e.events.Publish(&events.Event{
+				Type:        events.PositionClosed,
+				AccountID:   e.accountID,
+				AccountName: e.accountName,
+				Symbol:      symbol,
+				Side:        position.Side,
+				Price:       positionExit,
+				Quantity:    position.Quantity,
+				Reason:      PurposeEmergency,
+			})
+		}
+	}
+
+	if len(errs) > 0 {
+		result.Error = strings.Join(errs, "; ")
+	}
+
+	e.logger.WithFields(logrus.Fields{
+		"account":          e.accountName,
+		"canceled_orders":  result.CanceledOrders,
+		"closed_positions": result.ClosedPositions,
+		"error":            result.Error,
+	}).Warn("Account flattened")
+
+	return result
+}
@@ -0,0 +1,63 @@
+package trading
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"tdlib-go/pkg/models"
+)
+
+func TestFlattenClosesEveryPositionOfASymbol(t *testing.T) {
+	te := newTestExecutor(t, &models.BinanceAccount{})
+	te.ensureSymbolConfig = func(symbol string, leverage int, marginType string) error { return nil }
+
+	// A signal trade and a manual trade on the same symbol
+	te.executeSignal(t)
+	if _, err := te.ExecuteManual(&models.ManualTrade{Symbol: "BTCUSDT", Side: SideLong, Quantity: 0.5}, te.account); err != nil {
+		t.Fatalf("ExecuteManual: %v", err)
+	}
+	if positions := te.openPositions(t); len(positions) != 2 {
+		t.Fatalf("got %d open positions, want 2", len(positions))
+	}
+
+	te.srv.SetPrice("BTCUSDT", 101)
+	result := te.Flatten()
+	if result.Error != "" {
+		t.Fatalf("Flatten error: %s", result.Error)
+	}
+
+	if result.CanceledOrders != 4 || len(result.ClosedPositions) != 1 {
+		t.Errorf("flattened %d orders and %v, want 4 orders and BTCUSDT", result.CanceledOrders, result.ClosedPositions)
+	}
+	if amount := te.srv.Position("BTCUSDT").Amount; amount != 0 {
+		t.Errorf("exchange position = %v, want 0", amount)
+	}
+	if positions := te.openPositions(t); len(positions) != 0 {
+		t.Errorf("got %d open positions after flattening, want both closed", len(positions))
+	}
+
+	// Each position is closed by its own order and gets only its fills
+	te.close()
+	positions, err := te.repo.GetAllPositions(10)
+	if err != nil || len(positions) != 2 {
+		t.Fatalf("GetAllPositions = %d positions, %v", len(positions), err)
+	}
+	closes := te.ordersOfType("MARKET")[2:]
+	for _, position := range positions {
+		var closedBy []float64
+		prefix := fmt.Sprintf("tg-p%d.", position.ID)
+		for _, order := range closes {
+			if strings.HasPrefix(order.ClientOrderID, prefix) {
+				closedBy = append(closedBy, order.Quantity)
+			}
+		}
+		if len(closedBy) != 1 || !approxEqual(closedBy[0], position.Quantity) {
+			t.Errorf("position %d of %v closed by orders of %v, want one of its quantity", position.ID, position.Quantity, closedBy)
+		}
+		// Entered at 100 and closed at 101
+		if position.RealizedPnL == nil || !approxEqual(*position.RealizedPnL, position.Quantity) {
+			t.Errorf("position %d of %v realized %v, want %v", position.ID, position.Quantity, position.RealizedPnL, position.Quantity)
+		}
+	}
+}
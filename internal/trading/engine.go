@@ -2,11 +2,15 @@ package trading
 
 import (
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"tdlib-go/internal/analytics"
 	"tdlib-go/internal/binance"
 	"tdlib-go/internal/config"
+	"tdlib-go/internal/events"
 	"tdlib-go/internal/storage"
 	"tdlib-go/internal/webapi"
 	"tdlib-go/pkg/models"
@@ -15,18 +19,35 @@ import (
 // Engine is the main trading engine
 type Engine struct {
 	parser         *SignalParser
-	executor       *OrderExecutor
 	binance        *binance.Client
 	repo           *storage.Repository
 	webapi         *webapi.Server
+	events         *events.Bus
 	config         *config.Config
 	logger         *logrus.Logger
 	binanceClients map[int64]*binance.Client // Added missing field
 
+	// Executors are kept per account so duplicate tracking and order
+	// timeouts outlive a single signal
+	executors   map[int64]*OrderExecutor
+	executorsMu sync.Mutex
+
+	// executeMu serializes signal execution so the duplicate check sees
+	// signals from Telegram and the ingest API in order
+	executeMu sync.Mutex
+
+	// Background executions of ingested signals, waited for by Stop.
+	// Once stopping is set no execution starts.
+	executions sync.WaitGroup
+	stopMu     sync.Mutex
+	stopping   bool
+
 	// Symbol configuration cache (leverage and margin type) per account
 	// Key format: "accountID:symbol"
 	symbolConfigs map[string]*SymbolConfig
 	configMu      sync.RWMutex
+
+	stopCh chan struct{}
 }
 
 // SymbolConfig tracks the configured leverage and margin type for a symbol
@@ -39,12 +60,22 @@ type SymbolConfig struct {
 func NewEngine(repo *storage.Repository, cfg *config.Config, logger *logrus.Logger) (*Engine, error) {
 	if !cfg.Trading.Enabled {
 		logger.Info("Trading is disabled in configuration")
+
+		// The parser is still used to pick signal candidates for the message archive
+		parser, err := NewSignalParser(cfg, logger)
+		if err != nil {
+			logger.Warnf("Signal parser unavailable: %v", err)
+		}
+
 		return &Engine{
+			parser:         parser,
 			repo:           repo,
 			config:         cfg,
 			logger:         logger,
 			binanceClients: make(map[int64]*binance.Client),
+			executors:      make(map[int64]*OrderExecutor),
 			symbolConfigs:  make(map[string]*SymbolConfig),
+			stopCh:         make(chan struct{}),
 		}, nil
 	}
 
@@ -68,43 +99,86 @@ func NewEngine(repo *storage.Repository, cfg *config.Config, logger *logrus.Logg
 	// Create Binance clients for each account
 	binanceClients := make(map[int64]*binance.Client)
 	for _, account := range accounts {
-		client := binance.NewClient(account.APIKey, account.APISecret, account.IsTestnet, logger)
+		client := newBinanceClient(cfg, account, logger)
 		binanceClients[account.ID] = client
 		logger.Infof("Initialized Binance client for account: %s (ID: %d, Testnet: %v)",
 			account.Name, account.ID, account.IsTestnet)
 	}
 
-	// Get default account for executor
-	defaultAccount, err := repo.GetDefaultAccount()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get default account: %w", err)
-	}
-
-	var executor *OrderExecutor
-	if defaultAccount != nil && binanceClients[defaultAccount.ID] != nil {
-		executor = NewOrderExecutor(binanceClients[defaultAccount.ID], repo, cfg, logger)
-		executor.accountID = defaultAccount.ID // Set account ID in executor
-	}
-
 	engine := &Engine{
 		parser:         parser,
-		executor:       executor,
 		binanceClients: binanceClients,
+		executors:      make(map[int64]*OrderExecutor),
 		repo:           repo,
 		webapi:         nil, // Will be set later via SetWebAPI
 		config:         cfg,
 		logger:         logger,
 		symbolConfigs:  make(map[string]*SymbolConfig),
+		stopCh:         make(chan struct{}),
 	}
 
 	return engine, nil
 }
 
+// newBinanceClient creates the client for an account, honouring custom
+// endpoints from the config (e.g. a local mock server)
+func newBinanceClient(cfg *config.Config, account *models.BinanceAccount, logger *logrus.Logger) *binance.Client {
+	var client *binance.Client
+	if cfg.Binance.BaseURL == "" {
+		client = binance.NewClient(account.APIKey, account.APISecret, account.IsTestnet, logger)
+	} else {
+		wsBaseURL := cfg.Binance.WSBaseURL
+		if wsBaseURL == "" {
+			wsBaseURL = binance.DefaultWSBaseURL
+			if account.IsTestnet {
+				wsBaseURL = binance.TestnetWSBaseURL
+			}
+		}
+		client = binance.NewClientWithConfig(account.APIKey, account.APISecret, cfg.Binance.BaseURL, wsBaseURL, logger)
+	}
+
+	client.SetRecvWindow(cfg.Binance.RecvWindow)
+	return client
+}
+
 // SetWebAPI sets the web API server for broadcasting updates
 func (e *Engine) SetWebAPI(webapi *webapi.Server) {
 	e.webapi = webapi
 }
 
+// SetEventBus sets the bus that trade events are published on
+func (e *Engine) SetEventBus(bus *events.Bus) {
+	e.events = bus
+
+	e.executorsMu.Lock()
+	defer e.executorsMu.Unlock()
+	for _, executor := range e.executors {
+		executor.events = bus
+	}
+}
+
+// executorFor returns the account's executor, creating it on first use
+func (e *Engine) executorFor(account *models.BinanceAccount, client *binance.Client) *OrderExecutor {
+	e.executorsMu.Lock()
+	defer e.executorsMu.Unlock()
+
+	executor, exists := e.executors[account.ID]
+	if !exists {
+		accountID := account.ID
+		executor = NewOrderExecutor(client, e.repo, e.config, e.logger)
+		executor.accountID = accountID
+		executor.events = e.events
+		// Set the ensureSymbolConfig function to use engine's shared cache
+		executor.ensureSymbolConfig = func(symbol string, leverage int, marginType string) error {
+			return e.ensureSymbolConfig(accountID, symbol, leverage, marginType, client)
+		}
+		e.executors[account.ID] = executor
+	}
+	executor.accountName = account.Name
+
+	return executor
+}
+
 // ensureSymbolConfig ensures the symbol has the correct leverage and margin type configured for an account.
 // It caches the configuration to avoid redundant API calls on subsequent orders.
 func (e *Engine) ensureSymbolConfig(accountID int64, symbol string, leverage int, marginType string, client *binance.Client) error {
@@ -149,11 +223,35 @@ func (e *Engine) Start() error {
 	}
 
 	e.logger.Info("Starting trading engine...")
+
+	if state, err := e.repo.GetHaltState(); err != nil {
+		e.logger.Errorf("Failed to load halt state: %v", err)
+	} else if state.Halted {
+		e.logger.WithField("reason", state.Reason).Warn("Trading is halted, no positions will be opened until it is resumed")
+	}
+
+	if e.config.Trading.ChannelScoring.AutoDisable {
+		go e.runChannelScoring()
+	}
+
+	go e.runTimeSync()
+	go e.runUserDataStreams()
+	go e.runIncomeSync()
+	go e.runRiskBreakers()
+
 	e.logger.Info("Trading engine started successfully")
 
 	return nil
 }
 
+// IsSignalCandidate reports whether a message matches the signal pattern
+func (e *Engine) IsSignalCandidate(msg *models.Message) bool {
+	if e.parser == nil || msg.Text == "" {
+		return false
+	}
+	return e.parser.Matches(msg.Text)
+}
+
 // ProcessMessage processes a Telegram message for trading signals
 func (e *Engine) ProcessMessage(msg *models.Message) error {
 	if !e.config.Trading.Enabled {
@@ -178,24 +276,179 @@ func (e *Engine) ProcessMessage(msg *models.Message) error {
 		return nil
 	}
 
-	// Validate symbol
-	if !e.parser.IsValidSymbol(signal.Symbol) {
-		e.logger.Warnf("Invalid symbol detected: %s", signal.Symbol)
+	signal.Source = models.SignalSourceTelegram
+
+	if err := e.checkSignal(signal); err != nil {
+		e.logger.WithFields(logrus.Fields{
+			"symbol":     signal.Symbol,
+			"channel_id": msg.ChannelID,
+		}).Infof("Skipping signal: %v", err)
 		return nil
 	}
 
-	// Check if token is in ignore list
-	if e.config.Trading.IsTokenIgnored(signal.Symbol) {
+	return e.executeSignal(signal, msg.ChannelName)
+}
+
+// IngestSignal accepts a signal from outside Telegram, parsing raw text with
+// the signal parser or taking the symbol as given. It runs the same checks
+// as Telegram signals, saves the signal and executes it in the background.
+// Signals failing the checks are returned as models.ErrSignalRejected.
+func (e *Engine) IngestSignal(req *models.SignalIngest) (*models.Signal, error) {
+	if !e.config.Trading.Enabled {
+		return nil, fmt.Errorf("%w: trading is disabled", models.ErrSignalRejected)
+	}
+	if e.parser == nil {
+		return nil, fmt.Errorf("signal parser is not initialized")
+	}
+
+	var signal *models.Signal
+	if strings.TrimSpace(req.Text) != "" {
+		parsed, err := e.parser.Parse(&models.Message{Text: req.Text, Timestamp: time.Now()})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", models.ErrSignalRejected, err)
+		}
+		if parsed == nil {
+			return nil, fmt.Errorf("%w: no signal found in text", models.ErrSignalRejected)
+		}
+		signal = parsed
+	} else {
+		symbol := strings.ToUpper(strings.TrimSpace(req.Symbol))
+		signal = &models.Signal{
+			Symbol:     e.parser.normalizeSymbol(symbol),
+			RawMessage: symbol,
+			EntryPrice: req.EntryPrice,
+			PostedAt:   time.Now(),
+			ParsedAt:   time.Now(),
+			Status:     "pending",
+		}
+	}
+
+	signal.Source = strings.ToLower(strings.TrimSpace(req.Source))
+	if signal.Source == "" {
+		signal.Source = models.SignalSourceAPI
+	}
+
+	if err := e.checkSignal(signal); err != nil {
 		e.logger.WithFields(logrus.Fields{
 			"symbol": signal.Symbol,
-		}).Info("Token is in ignore list, skipping signal")
+			"source": signal.Source,
+		}).Infof("Rejected ingested signal: %v", err)
+		return nil, fmt.Errorf("%w: %v", models.ErrSignalRejected, err)
+	}
+
+	if err := e.repo.SaveSignal(signal); err != nil {
+		return nil, err
+	}
+
+	e.logger.WithFields(logrus.Fields{
+		"signal_id": signal.ID,
+		"symbol":    signal.Symbol,
+		"source":    signal.Source,
+	}).Info("Signal ingested")
+
+	if !e.beginExecution() {
+		return nil, fmt.Errorf("%w: trading engine is stopping", models.ErrSignalRejected)
+	}
+	go func() {
+		defer e.executions.Done()
+		if err := e.executeSignal(signal, signal.Source); err != nil {
+			e.logger.Errorf("Failed to execute ingested signal %d: %v", signal.ID, err)
+		}
+	}()
+
+	return signal, nil
+}
+
+// beginExecution registers a background execution with Stop. It reports
+// false once the engine is stopping.
+func (e *Engine) beginExecution() bool {
+	e.stopMu.Lock()
+	defer e.stopMu.Unlock()
+
+	if e.stopping {
+		return false
+	}
+	e.executions.Add(1)
+	return true
+}
+
+// isStopping reports whether Stop was called
+func (e *Engine) isStopping() bool {
+	e.stopMu.Lock()
+	defer e.stopMu.Unlock()
+	return e.stopping
+}
+
+// checkSignal applies the checks every signal must pass before execution:
+// trading not halted, a recent message, a valid symbol, not ignored, and a
+// channel with trading enabled
+func (e *Engine) checkSignal(signal *models.Signal) error {
+	if err := e.checkHalt(); err != nil {
+		return err
+	}
+
+	if err := checkSignalAge(&e.config.Trading.PriceGuard, signal); err != nil {
+		return err
+	}
+
+	if !e.parser.IsValidSymbol(signal.Symbol) {
+		return fmt.Errorf("invalid symbol %s", signal.Symbol)
+	}
+
+	if e.config.Trading.IsTokenIgnored(signal.Symbol) {
+		return fmt.Errorf("token %s is in the ignore list", signal.Symbol)
+	}
+
+	// Channels can be excluded from trading while still being monitored
+	if signal.Source == models.SignalSourceTelegram {
+		tradingEnabled, err := e.repo.IsChannelTradingEnabled(signal.ChannelID)
+		if err != nil {
+			e.logger.Errorf("Failed to check channel trading state: %v", err)
+			return fmt.Errorf("failed to check channel trading state: %w", err)
+		}
+		if !tradingEnabled {
+			return fmt.Errorf("trading is disabled for channel %d", signal.ChannelID)
+		}
+	}
+
+	return nil
+}
+
+// executeSignal saves a checked signal unless it already is, and executes it
+// on all active accounts
+func (e *Engine) executeSignal(signal *models.Signal, channelName string) error {
+	e.executeMu.Lock()
+	defer e.executeMu.Unlock()
+
+	if e.isStopping() {
+		e.logger.WithField("symbol", signal.Symbol).Warn("Skipping signal: trading engine is stopping")
+		if signal.ID != 0 {
+			e.updateSignalStatus(signal, "failed", "trading engine stopped")
+		}
+		return nil
+	}
+
+	// The halt may have come in while the signal waited
+	if err := e.checkHalt(); err != nil {
+		e.logger.WithField("symbol", signal.Symbol).Warnf("Skipping signal: %v", err)
+		if signal.ID != 0 {
+			e.updateSignalStatus(signal, "failed", err.Error())
+		}
 		return nil
 	}
 
 	e.logger.WithFields(logrus.Fields{
 		"symbol": signal.Symbol,
+		"source": signal.Source,
 	}).Info("New trading signal detected")
 
+	e.events.Publish(&events.Event{
+		Type:        events.SignalDetected,
+		ChannelID:   signal.ChannelID,
+		ChannelName: channelName,
+		Symbol:      signal.Symbol,
+	})
+
 	// Get all active accounts
 	accounts, err := e.repo.GetActiveAccounts()
 	if err != nil {
@@ -206,14 +459,30 @@ func (e *Engine) ProcessMessage(msg *models.Message) error {
 	if len(accounts) == 0 {
 		err := fmt.Errorf("no active Binance accounts configured")
 		e.logger.Error(err.Error())
+		e.events.Publish(&events.Event{Type: events.ExecutionError, Symbol: signal.Symbol, Error: err.Error()})
+		e.updateSignalStatus(signal, "failed", err.Error())
 		return err
 	}
 
+	// Persist the signal so positions can be traced back to their source
+	if signal.ID == 0 {
+		if err := e.repo.SaveSignal(signal); err != nil {
+			e.logger.Errorf("Failed to save signal: %v", err)
+		}
+	}
+
 	// Execute the signal on ALL active accounts
 	var executionErrors []error
 	successCount := 0
 
 	for _, account := range accounts {
+		// A halt stops the fan-out, Halt does not wait for it
+		if err := e.checkHalt(); err != nil {
+			e.logger.Warnf("Skipping account %s: %v", account.Name, err)
+			executionErrors = append(executionErrors, fmt.Errorf("account %s: %w", account.Name, err))
+			continue
+		}
+
 		// Get the Binance client for this account
 		client, exists := e.binanceClients[account.ID]
 		if !exists {
@@ -221,20 +490,28 @@ func (e *Engine) ProcessMessage(msg *models.Message) error {
 			continue
 		}
 
-		// Create executor for this account
-		executor := NewOrderExecutor(client, e.repo, e.config, e.logger)
-		executor.accountID = account.ID
-		// Set the ensureSymbolConfig function to use engine's shared cache
-		executor.ensureSymbolConfig = func(symbol string, leverage int, marginType string) error {
-			return e.ensureSymbolConfig(account.ID, symbol, leverage, marginType, client)
+		// Accounts past their loss limits sit out until the breaker resets
+		if err := e.checkRiskBreaker(account); err != nil {
+			e.logger.Warnf("Skipping account %s: %v", account.Name, err)
+			executionErrors = append(executionErrors, fmt.Errorf("account %s: %w", account.Name, err))
+			continue
 		}
 
+		executor := e.executorFor(account, client)
+
 		e.logger.Infof("Executing signal on account: %s (ID: %d)", account.Name, account.ID)
 
 		// Execute the signal with this account's configuration
 		if err := executor.ExecuteSignal(signal, account); err != nil {
 			e.logger.Errorf("Failed to execute signal on account %s: %v", account.Name, err)
 			executionErrors = append(executionErrors, fmt.Errorf("account %s: %w", account.Name, err))
+			e.events.Publish(&events.Event{
+				Type:        events.ExecutionError,
+				AccountID:   account.ID,
+				AccountName: account.Name,
+				Symbol:      signal.Symbol,
+				Error:       err.Error(),
+			})
 		} else {
 			successCount++
 			e.logger.Infof("Successfully executed signal on account: %s", account.Name)
@@ -246,12 +523,64 @@ func (e *Engine) ProcessMessage(msg *models.Message) error {
 
 	if len(executionErrors) > 0 && successCount == 0 {
 		// All accounts failed
-		return fmt.Errorf("signal execution failed on all accounts: %v", executionErrors)
+		err := fmt.Errorf("signal execution failed on all accounts: %v", executionErrors)
+		e.updateSignalStatus(signal, "failed", err.Error())
+		return err
 	}
 
+	e.updateSignalStatus(signal, "processed", "")
+
 	return nil
 }
 
+// ProcessHistoricalMessage records the signal in an imported history message
+// without executing it. It reports whether a signal was stored.
+func (e *Engine) ProcessHistoricalMessage(msg *models.Message) (bool, error) {
+	if e.parser == nil {
+		return false, nil
+	}
+
+	signal, err := e.parser.ParseHistorical(msg)
+	if err != nil || signal == nil {
+		return false, err
+	}
+
+	if !e.parser.IsValidSymbol(signal.Symbol) || e.config.Trading.IsTokenIgnored(signal.Symbol) {
+		return false, nil
+	}
+
+	// Re-importing the same range must not duplicate signals
+	exists, err := e.repo.SignalExists(msg.ChannelID, msg.MessageID)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+
+	if err := e.repo.SaveSignal(signal); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// updateSignalStatus records the outcome of a persisted signal
+func (e *Engine) updateSignalStatus(signal *models.Signal, status, errorMsg string) {
+	if signal.ID == 0 {
+		return
+	}
+
+	now := time.Now()
+	signal.Status = status
+	signal.ProcessedAt = &now
+	signal.Error = errorMsg
+
+	if err := e.repo.UpdateSignalStatus(signal.ID, status, &now, errorMsg); err != nil {
+		e.logger.Errorf("Failed to update signal %d status: %v", signal.ID, err)
+	}
+}
+
 // Stop stops the trading engine
 func (e *Engine) Stop() error {
 	if !e.config.Trading.Enabled {
@@ -260,17 +589,30 @@ func (e *Engine) Stop() error {
 
 	e.logger.Info("Stopping trading engine...")
 
-	if e.executor != nil {
-		e.executor.Close()
-	}
+	close(e.stopCh)
+
+	// No execution starts from here on; wait for the ingested ones and for
+	// the one in flight, so no order is placed on a closed executor
+	e.stopMu.Lock()
+	e.stopping = true
+	e.stopMu.Unlock()
+	e.executions.Wait()
+	e.executeMu.Lock()
+	e.executeMu.Unlock()
 
-	// Close all Binance clients
+	// Close all Binance clients, which ends their order updates
 	for accountID, client := range e.binanceClients {
 		if err := client.Close(); err != nil {
 			e.logger.Errorf("Error closing Binance client for account %d: %v", accountID, err)
 		}
 	}
 
+	e.executorsMu.Lock()
+	for _, executor := range e.executors {
+		executor.Close()
+	}
+	e.executorsMu.Unlock()
+
 	if e.webapi != nil {
 		e.webapi.Stop()
 	}
@@ -280,11 +622,187 @@ func (e *Engine) Stop() error {
 	return nil
 }
 
+// runChannelScoring periodically disables trading for channels whose score
+// falls below the configured threshold
+func (e *Engine) runChannelScoring() {
+	scoring := &e.config.Trading.ChannelScoring
+
+	ticker := time.NewTicker(scoring.Interval())
+	defer ticker.Stop()
+
+	for {
+		e.disableLowScoringChannels()
+
+		select {
+		case <-ticker.C:
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+// runTimeSync periodically syncs every client's clock offset with the
+// Binance server time, so a drifting clock does not get signed requests
+// rejected
+func (e *Engine) runTimeSync() {
+	ticker := time.NewTicker(e.config.Binance.TimeSync())
+	defer ticker.Stop()
+
+	for {
+		for accountID, client := range e.binanceClients {
+			offset, err := client.SyncTime()
+			if err != nil {
+				e.logger.Errorf("Failed to sync Binance server time for account %d: %v", accountID, err)
+				continue
+			}
+			if offset > time.Second || offset < -time.Second {
+				e.logger.Warnf("Binance server time is %s ahead of the local clock for account %d", offset, accountID)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+// runUserDataStreams keeps every active account's user data stream
+// connected, so fills and take profit or stop loss hits are recorded
+func (e *Engine) runUserDataStreams() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		e.connectUserDataStreams()
+
+		select {
+		case <-ticker.C:
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+// connectUserDataStreams connects the user data streams that are down
+func (e *Engine) connectUserDataStreams() {
+	accounts, err := e.repo.GetActiveAccounts()
+	if err != nil {
+		e.logger.Errorf("Failed to get active accounts: %v", err)
+		return
+	}
+
+	for _, account := range accounts {
+		client, exists := e.binanceClients[account.ID]
+		if !exists || client.IsStreamConnected() {
+			continue
+		}
+
+		client.SetOrderUpdateCallback(e.executorFor(account, client).HandleOrderUpdate)
+
+		listenKey, err := client.StartUserDataStream()
+		if err == nil {
+			err = client.ConnectUserDataStream(listenKey)
+		}
+		if err != nil {
+			e.logger.Warnf("Failed to connect user data stream for account %s: %v", account.Name, err)
+		}
+	}
+}
+
+// disableLowScoringChannels evaluates the leaderboard and disables trading
+// on channels with enough trades and a score below the threshold
+func (e *Engine) disableLowScoringChannels() {
+	scoring := &e.config.Trading.ChannelScoring
+
+	leaderboard, err := e.GetChannelLeaderboard()
+	if err != nil {
+		e.logger.Errorf("Failed to compute channel leaderboard: %v", err)
+		return
+	}
+
+	for _, score := range leaderboard {
+		if !score.TradingEnabled || score.Trades < scoring.MinTradesOrDefault() || score.Score >= scoring.MinScore {
+			continue
+		}
+
+		if err := e.repo.SetChannelTradingEnabled(score.ChannelID, false); err != nil {
+			e.logger.Errorf("Failed to disable trading for channel %d: %v", score.ChannelID, err)
+			continue
+		}
+
+		e.logger.WithFields(logrus.Fields{
+			"channel_id": score.ChannelID,
+			"title":      score.Title,
+			"score":      score.Score,
+			"trades":     score.Trades,
+			"min_score":  scoring.MinScore,
+		}).Warn("Channel score below threshold, trading disabled (monitoring continues)")
+
+		if e.webapi != nil {
+			score.TradingEnabled = false
+			e.webapi.BroadcastUpdate("channel_trading_disabled", score)
+		}
+		e.events.Publish(&events.Event{
+			Type:        events.RiskGateTripped,
+			ChannelID:   score.ChannelID,
+			ChannelName: score.Title,
+			Reason:      fmt.Sprintf("channel score %.2f below %.2f after %d trades, trading disabled", score.Score, scoring.MinScore, score.Trades),
+		})
+	}
+}
+
+// GetChannelLeaderboard returns channel scores over the configured window
+func (e *Engine) GetChannelLeaderboard() ([]*models.ChannelScore, error) {
+	scoring := &e.config.Trading.ChannelScoring
+	return e.repo.GetChannelLeaderboard(scoring.Window(), analytics.ScoreParams{
+		SlippageRate: scoring.SlippagePercent,
+		MinTrades:    scoring.MinTradesOrDefault(),
+	})
+}
+
 // GetStats returns trading statistics
 func (e *Engine) GetStats() (*models.TradingStats, error) {
 	return e.repo.GetTradingStats()
 }
 
+// RateLimits returns the Binance rate limit usage of every account with a client
+func (e *Engine) RateLimits() ([]*models.RateLimitStatus, error) {
+	accounts, err := e.repo.GetAllAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*models.RateLimitStatus, 0, len(accounts))
+	for _, account := range accounts {
+		client, exists := e.binanceClients[account.ID]
+		if !exists {
+			continue
+		}
+
+		stats := client.RateLimitStats()
+		status := &models.RateLimitStatus{
+			AccountID:     account.ID,
+			AccountName:   account.Name,
+			UsedWeight1m:  stats.UsedWeight1m,
+			WeightLimit1m: stats.WeightLimit1m,
+			OrderCount10s: stats.OrderCount10s,
+			OrderCount1m:  stats.OrderCount1m,
+			Throttled:     stats.Throttled,
+			Retries:       stats.Retries,
+			RateLimited:   stats.RateLimited,
+			Banned:        stats.Banned,
+		}
+		if !stats.BlockedUntil.IsZero() {
+			status.BlockedUntil = &stats.BlockedUntil
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
 // GetOpenPositions returns all open positions
 func (e *Engine) GetOpenPositions() ([]*models.Position, error) {
 	return e.repo.GetOpenPositions()
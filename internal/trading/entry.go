@@ -0,0 +1,247 @@
+package trading
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"tdlib-go/internal/binance"
+	"tdlib-go/pkg/models"
+)
+
+// entryPollInterval is how often a resting limit entry is checked for fills
+const entryPollInterval = time.Second
+
+// entryFill is the outcome of an entry: its orders and what they filled
+type entryFill struct {
+	orders   []*binance.OrderResponse
+	quantity float64
+	price    float64 // Average fill price, 0 when unknown
+}
+
+// lastOrder returns the most recent entry order
+func (f *entryFill) lastOrder() *binance.OrderResponse {
+	return f.orders[len(f.orders)-1]
+}
+
+// isLimitEntry reports whether an account enters with limit orders
+func isLimitEntry(account *models.BinanceAccount) bool {
+	return account.EntryMode == models.EntryLimit || account.EntryMode == models.EntryPostOnly
+}
+
+// marketEntry places a market entry and confirms its fill. The exchange may
+// acknowledge a market order before it fills, so an unfilled order is polled
+// for the protection's fill wait and what filled by then is the entry.
+func (e *OrderExecutor) marketEntry(params *tradeParams, entrySide string, quantity float64) (*entryFill, error) {
+	resp, err := e.placeEntry(&binance.NewOrder{
+		Symbol:           params.symbol,
+		Side:             entrySide,
+		Type:             "MARKET",
+		Quantity:         quantity,
+		NewClientOrderID: e.clientOrderID(params.ref, orderKindEntry),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("entry order failed: %w", err)
+	}
+	resp = e.awaitEntry(params.symbol, resp, e.config.Trading.Protection.FillWait())
+
+	fill := &entryFill{orders: []*binance.OrderResponse{resp}}
+	fill.quantity, _ = strconv.ParseFloat(resp.ExecutedQty, 64)
+	if fill.quantity <= 0 {
+		e.asyncLogOrder(0, resp, params.orderPurpose("entry"))
+		return nil, fmt.Errorf("market entry on %s not filled (%s)", params.symbol, resp.Status)
+	}
+	if avg, err := strconv.ParseFloat(resp.AvgPrice, 64); err == nil && avg > 0 {
+		fill.price = avg
+	}
+	if resp.Status != "FILLED" {
+		e.logger.Warnf("Market entry on %s filled %.8f of %.8f, protecting the filled quantity only",
+			params.symbol, fill.quantity, quantity)
+	}
+
+	return fill, nil
+}
+
+// chaseEntry fills an entry with limit orders. The first order rests at the
+// signal's entry price, or at the best bid (ask for a SHORT). Unfilled
+// orders are repriced to the book up to EntryChases times, never more than
+// EntrySlippage away from the first price, and whatever is unfilled after
+// EntryTimeout is canceled. Post-only orders never cross the book.
+func (e *OrderExecutor) chaseEntry(params *tradeParams, account *models.BinanceAccount, filters *symbolFilters,
+	entrySide string, quantity, minNotional float64) (*entryFill, error) {
+	timeInForce := "GTC"
+	if account.EntryMode == models.EntryPostOnly {
+		timeInForce = "GTX"
+	}
+	direction := 1.0
+	if entrySide == "SELL" {
+		direction = -1.0
+	}
+
+	attempts := account.EntryChases + 1
+	wait := time.Duration(account.EntryTimeout) * time.Second / time.Duration(attempts)
+
+	limitPrice, err := e.bookPrice(params.symbol, entrySide)
+	if err != nil {
+		return nil, err
+	}
+	if params.entryPrice > 0 && (timeInForce != "GTX" || (limitPrice-params.entryPrice)*direction > 0) {
+		limitPrice = params.entryPrice
+	}
+	bandPrice := limitPrice * (1 + direction*account.EntrySlippage)
+
+	fill := &entryFill{}
+	var cost float64
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			// A halt stops the chase, what filled so far is protected
+			if err := e.checkHalt(); err != nil {
+				e.logger.Warnf("Not chasing entry on %s: %v", params.symbol, err)
+				break
+			}
+			quote, err := e.bookPrice(params.symbol, entrySide)
+			if err != nil {
+				e.logger.Warnf("Failed to reprice entry on %s, not chasing: %v", params.symbol, err)
+				break
+			}
+			limitPrice = quote
+			if (limitPrice-bandPrice)*direction > 0 {
+				limitPrice = bandPrice
+			}
+		}
+		limitPrice = e.roundPrice(filters, limitPrice)
+
+		// A residual below the minimum quantity cannot be ordered, the entry is done
+		remaining := e.floorQuantity(filters, quantity-fill.quantity)
+		if remaining <= 0 || remaining < e.minQuantity(filters) || remaining*limitPrice < minNotional {
+			break
+		}
+
+		resp, err := e.placeEntry(&binance.NewOrder{
+			Symbol:           params.symbol,
+			Side:             entrySide,
+			Type:             "LIMIT",
+			TimeInForce:      timeInForce,
+			Quantity:         remaining,
+			Price:            limitPrice,
+			NewClientOrderID: e.clientOrderID(params.ref, orderKindEntry+strconv.Itoa(attempt+1)),
+		})
+		if err != nil {
+			if attempt == 0 {
+				return nil, fmt.Errorf("entry order failed: %w", err)
+			}
+			e.logger.Warnf("Failed to reprice entry on %s, not chasing: %v", params.symbol, err)
+			break
+		}
+
+		e.logger.WithFields(logrus.Fields{
+			"order_id": resp.OrderID,
+			"symbol":   params.symbol,
+			"side":     entrySide,
+			"price":    limitPrice,
+			"qty":      remaining,
+			"attempt":  attempt + 1,
+		}).Info("Limit entry order placed")
+
+		resp = e.awaitEntry(params.symbol, resp, wait)
+		fill.orders = append(fill.orders, resp)
+
+		executed, _ := strconv.ParseFloat(resp.ExecutedQty, 64)
+		if executed > 0 {
+			price, _ := strconv.ParseFloat(resp.AvgPrice, 64)
+			if price <= 0 {
+				price = limitPrice
+			}
+			fill.quantity += executed
+			cost += executed * price
+		}
+		if resp.Status == "FILLED" {
+			break
+		}
+	}
+
+	if fill.quantity <= 0 {
+		// Canceled entries are kept in the orders table without a position
+		for _, order := range fill.orders {
+			e.asyncLogOrder(0, order, params.orderPurpose("entry"))
+		}
+		return nil, fmt.Errorf("limit entry on %s not filled after %d attempts", params.symbol, len(fill.orders))
+	}
+	fill.price = cost / fill.quantity
+
+	if e.floorQuantity(filters, fill.quantity) < e.floorQuantity(filters, quantity) {
+		e.logger.Warnf("Limit entry on %s filled %.8f of %.8f, protecting the filled quantity only",
+			params.symbol, fill.quantity, quantity)
+	}
+
+	return fill, nil
+}
+
+// placeEntry places an entry order. An entry whose outcome stays unknown is
+// looked up once more before the trade is given up, as it may have been
+// placed and left a position without TP/SL on the exchange.
+func (e *OrderExecutor) placeEntry(order *binance.NewOrder) (*binance.OrderResponse, error) {
+	resp, err := e.binanceClient.PlaceOrder(order)
+	if !errors.Is(err, binance.ErrOrderUnknown) {
+		return resp, err
+	}
+
+	existing, queryErr := e.binanceClient.QueryOrderByClientID(order.Symbol, order.NewClientOrderID)
+	if queryErr == nil {
+		e.logger.Warnf("Entry %s on %s was found on the exchange after its status was unknown", order.NewClientOrderID, order.Symbol)
+		return existing, nil
+	}
+	return nil, fmt.Errorf("%w, check %s on the exchange for an unprotected position", err, order.Symbol)
+}
+
+// awaitEntry waits for a limit entry to fill and cancels it when it has not
+// filled in time. It returns the order's final state.
+func (e *OrderExecutor) awaitEntry(symbol string, order *binance.OrderResponse, wait time.Duration) *binance.OrderResponse {
+	deadline := time.Now().Add(wait)
+	for order.Status != "FILLED" && time.Now().Before(deadline) {
+		time.Sleep(entryPollInterval)
+
+		resp, err := e.binanceClient.QueryOrder(symbol, order.OrderID)
+		if err != nil {
+			e.logger.Warnf("Failed to query entry order %d on %s: %v", order.OrderID, symbol, err)
+			continue
+		}
+		order = resp
+	}
+	if order.Status == "FILLED" || order.Status == "CANCELED" || order.Status == "EXPIRED" {
+		return order
+	}
+
+	if resp, err := e.binanceClient.CancelOrder(symbol, order.OrderID); err == nil {
+		return resp
+	}
+
+	// The cancel fails when the order filled meanwhile
+	resp, err := e.binanceClient.QueryOrder(symbol, order.OrderID)
+	if err != nil {
+		e.logger.Errorf("Failed to query entry order %d on %s after cancel: %v", order.OrderID, symbol, err)
+		return order
+	}
+	return resp
+}
+
+// bookPrice returns the best bid for a BUY and the best ask for a SELL,
+// where a limit order rests without crossing the book
+func (e *OrderExecutor) bookPrice(symbol, side string) (float64, error) {
+	ticker, err := e.binanceClient.GetBookTicker(symbol)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get book ticker for %s: %w", symbol, err)
+	}
+
+	quote := ticker.BidPrice
+	if side == "SELL" {
+		quote = ticker.AskPrice
+	}
+	price, err := strconv.ParseFloat(quote, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse book price: %w", err)
+	}
+	return price, nil
+}
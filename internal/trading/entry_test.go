@@ -0,0 +1,93 @@
+package trading
+
+import (
+	"testing"
+	"time"
+
+	"tdlib-go/internal/binance/binancetest"
+	"tdlib-go/pkg/models"
+)
+
+// newChaseExecutor returns an executor entering ETHUSDT, which has a minimum
+// quantity of 10 steps, with a limit order chased once after a second
+func newChaseExecutor(t *testing.T) *testExecutor {
+	t.Helper()
+	te := newTestExecutor(t, &models.BinanceAccount{
+		EntryMode:    models.EntryLimit,
+		EntryChases:  1,
+		EntryTimeout: 2,
+	})
+	te.srv.AddSymbol(binancetest.Symbol{Symbol: "ETHUSDT", MinQty: 0.01, MinNotional: 0.01})
+	return te
+}
+
+// fillFirstEntry partially fills the first limit entry once it rests on the
+// book. The returned channel is closed once it is filled.
+func fillFirstEntry(t *testing.T, te *testExecutor, qty float64) <-chan struct{} {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			for _, order := range te.ordersOfType("LIMIT") {
+				if order.Status == binancetest.StatusNew {
+					if err := te.srv.FillOrderPartially(order.OrderID, qty, 0); err != nil {
+						t.Errorf("FillOrderPartially: %v", err)
+					}
+					return
+				}
+			}
+		}
+		t.Error("no limit entry was placed")
+	}()
+	return done
+}
+
+func TestChaseEntryRepostsOnlyTheResidual(t *testing.T) {
+	te := newChaseExecutor(t)
+	filled := fillFirstEntry(t, te, 0.6)
+
+	if err := te.ExecuteSignal(&models.Signal{ID: 1, Symbol: "ETHUSDT"}, te.account); err != nil {
+		t.Fatalf("ExecuteSignal: %v", err)
+	}
+	<-filled
+
+	entries := te.ordersOfType("LIMIT")
+	if len(entries) != 2 {
+		t.Fatalf("got %d limit entries, want the first and one chase", len(entries))
+	}
+	if !approxEqual(entries[0].Quantity, 1) || !approxEqual(entries[1].Quantity, 0.4) {
+		t.Errorf("entries of %v and %v, want 1 and the residual 0.4", entries[0].Quantity, entries[1].Quantity)
+	}
+	if amount := te.srv.Position("ETHUSDT").Amount; !approxEqual(amount, 0.6) {
+		t.Errorf("exchange position = %v, want 0.6 with the chase unfilled", amount)
+	}
+}
+
+func TestChaseEntryStopsBelowMinQuantity(t *testing.T) {
+	te := newChaseExecutor(t)
+	filled := fillFirstEntry(t, te, 0.995)
+
+	if err := te.ExecuteSignal(&models.Signal{ID: 1, Symbol: "ETHUSDT"}, te.account); err != nil {
+		t.Fatalf("ExecuteSignal: %v", err)
+	}
+	<-filled
+
+	// The residual of 0.005 cannot be ordered, raising it to 0.01 would overfill
+	if entries := te.ordersOfType("LIMIT"); len(entries) != 1 {
+		t.Errorf("got %d limit entries, want no chase of the residual", len(entries))
+	}
+	if amount := te.srv.Position("ETHUSDT").Amount; !approxEqual(amount, 0.995) {
+		t.Errorf("exchange position = %v, want 0.995", amount)
+	}
+
+	positions := te.openPositions(t)
+	if len(positions) != 1 || !approxEqual(positions[0].Quantity, 0.995) {
+		t.Fatalf("open positions = %+v, want one of 0.995", positions)
+	}
+	for _, order := range append(te.ordersOfType("TAKE_PROFIT_MARKET"), te.ordersOfType("STOP_MARKET")...) {
+		if !approxEqual(order.Quantity, 0.995) {
+			t.Errorf("%s order of %v, want the filled 0.995", order.Type, order.Quantity)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"tdlib-go/internal/binance"
 	"tdlib-go/internal/config"
+	"tdlib-go/internal/events"
 	"tdlib-go/internal/storage"
 	"tdlib-go/pkg/models"
 )
@@ -21,9 +22,19 @@ type OrderExecutor struct {
 	config        *config.Config
 	logger        *logrus.Logger
 	accountID     int64 // Binance account ID
+	accountName   string
+	events        *events.Bus // Trade events for notifications, may be nil
 
-	// Async logging channel
+	// Async logging channel, logDone is closed once it is drained. logMu
+	// guards sends against Close, orders logged after it are saved directly.
 	logQueue chan *LogEntry
+	logDone  chan struct{}
+	logMu    sync.RWMutex
+	closed   bool
+
+	// Background monitors, stopped by Close
+	stopCh chan struct{}
+	wg     sync.WaitGroup
 
 	// Order timeout tracking
 	pendingOrders map[string]*OrderTimeout
@@ -50,6 +61,8 @@ type OrderTimeout struct {
 	Symbol          string
 	OrderType       string
 	Quantity        float64 // Position quantity for closing when timeout
+	PositionID      int64   // Position of the order, 0 when it was not recorded
+	Ref             string  // Client order ID ref of the order's trade
 	CreatedAt       time.Time
 	TimeoutDuration time.Duration
 }
@@ -62,6 +75,8 @@ func NewOrderExecutor(binanceClient *binance.Client, repo *storage.Repository, c
 		config:        cfg,
 		logger:        logger,
 		logQueue:      make(chan *LogEntry, 1000),
+		logDone:       make(chan struct{}),
+		stopCh:        make(chan struct{}),
 		pendingOrders: make(map[string]*OrderTimeout),
 		recentSignals: make(map[string]time.Time),
 	}
@@ -70,6 +85,7 @@ func NewOrderExecutor(binanceClient *binance.Client, repo *storage.Repository, c
 	go executor.runAsyncLogger()
 
 	// Start order timeout monitor
+	executor.wg.Add(2)
 	go executor.monitorOrderTimeouts()
 
 	// Start signal cleanup monitor (remove signals older than 48h)
@@ -78,6 +94,52 @@ func NewOrderExecutor(binanceClient *binance.Client, repo *storage.Repository, c
 	return executor
 }
 
+// Position sides
+const (
+	SideLong  = "LONG"
+	SideShort = "SHORT"
+)
+
+// PurposeManual marks orders placed from the dashboard or API
+const PurposeManual = "manual"
+
+// PurposeTimeout marks the close orders placed when an order times out
+const PurposeTimeout = "timeout"
+
+// PurposeFlatten marks the close orders of entries whose TP/SL failed
+const PurposeFlatten = "flatten"
+
+// tradeParams describes a position to open
+type tradeParams struct {
+	symbol     string
+	side       string  // LONG or SHORT
+	amount     float64 // Order size in USDT, used when quantity is 0, else the account's sizing mode
+	quantity   float64
+	leverage   int
+	takeProfit float64 // Price, derived from the account's target percent when 0
+	stopLoss   float64 // Price, derived from the account's stop loss percent when 0
+	purpose    string  // Order purpose for all orders, or "" for entry, take_profit and stop_loss
+	entryPrice float64 // Limit entry price, 0 for the best bid or ask
+	ref        string  // Reference of the trade's client order IDs, see tradeRef
+	signal     *models.Signal
+}
+
+// channelID returns the channel of the trade's signal, if any
+func (p *tradeParams) channelID() int64 {
+	if p.signal == nil {
+		return 0
+	}
+	return p.signal.ChannelID
+}
+
+// orderPurpose returns the purpose to record for an order of a trade
+func (p *tradeParams) orderPurpose(kind string) string {
+	if p.purpose != "" {
+		return p.purpose
+	}
+	return kind
+}
+
 // ExecuteSignal executes a trading signal with account-specific configuration
 func (e *OrderExecutor) ExecuteSignal(signal *models.Signal, account *models.BinanceAccount) error {
 	// Check if we've recently executed this signal (within 48 hours)
@@ -94,97 +156,141 @@ func (e *OrderExecutor) ExecuteSignal(signal *models.Signal, account *models.Bin
 		}
 	}
 
+	_, err := e.openPosition(&tradeParams{
+		symbol:     signal.Symbol,
+		side:       SideLong,
+		leverage:   account.Leverage,
+		entryPrice: signal.EntryPrice,
+		signal:     signal,
+	}, account)
+	if err != nil {
+		return err
+	}
+
+	// Record this signal to prevent duplicates within 48 hours
+	e.signalsMu.Lock()
+	e.recentSignals[signal.Symbol] = time.Now()
+	e.signalsMu.Unlock()
+
+	return nil
+}
+
+// ExecuteManual opens a position from a manual trade request. Unset size,
+// leverage and TP/SL fall back to the account's configuration.
+func (e *OrderExecutor) ExecuteManual(trade *models.ManualTrade, account *models.BinanceAccount) (*models.Position, error) {
+	params := &tradeParams{
+		symbol:     trade.Symbol,
+		side:       trade.Side,
+		amount:     trade.Amount,
+		quantity:   trade.Quantity,
+		leverage:   trade.Leverage,
+		takeProfit: trade.TakeProfit,
+		stopLoss:   trade.StopLoss,
+		purpose:    PurposeManual,
+	}
+	if params.leverage == 0 {
+		params.leverage = account.Leverage
+	}
+
+	return e.openPosition(params, account)
+}
+
+// openPosition places the entry, take profit and stop loss orders of a
+// trade and records the position
+func (e *OrderExecutor) openPosition(params *tradeParams, account *models.BinanceAccount) (*models.Position, error) {
+	if params.side != SideLong && params.side != SideShort {
+		return nil, fmt.Errorf("invalid side %q (must be LONG or SHORT)", params.side)
+	}
+	if params.ref == "" {
+		params.ref = tradeRef(params.signal)
+	}
+
 	// Get current price
-	ticker, err := e.binanceClient.GetSymbolPriceTicker(signal.Symbol)
+	ticker, err := e.binanceClient.GetSymbolPriceTicker(params.symbol)
 	if err != nil {
-		return fmt.Errorf("failed to get price for %s: %w", signal.Symbol, err)
+		return nil, fmt.Errorf("failed to get price for %s: %w", params.symbol, err)
 	}
 
 	entryPrice, err := strconv.ParseFloat(ticker.Price, 64)
 	if err != nil {
-		return fmt.Errorf("failed to parse price: %w", err)
+		return nil, fmt.Errorf("failed to parse price: %w", err)
+	}
+
+	// Signals arriving after the move are skipped or downsized
+	sizeFactor, err := e.checkPriceGuard(params, entryPrice)
+	if err != nil {
+		return nil, err
+	}
+
+	// Limit entries at the signal's price size and protect from that price
+	limitEntry := isLimitEntry(account)
+	if limitEntry && params.entryPrice > 0 {
+		entryPrice = params.entryPrice
 	}
 
 	// Use account-specific configuration
-	leverage := account.Leverage
-	orderAmount := account.OrderAmount
+	leverage := params.leverage
 	targetPercent := account.TargetPercent
 	stopLossPercent := account.StopLossPercent
 
 	// Validate account configuration
 	if leverage <= 0 || leverage > 125 {
-		return fmt.Errorf("invalid leverage %d for account %s (must be between 1 and 125)", leverage, account.Name)
+		return nil, fmt.Errorf("invalid leverage %d for account %s (must be between 1 and 125)", leverage, account.Name)
 	}
-	if orderAmount <= 0 {
-		return fmt.Errorf("invalid order amount %.2f for account %s (must be greater than 0)", orderAmount, account.Name)
+	if targetPercent <= 0 && params.takeProfit == 0 {
+		return nil, fmt.Errorf("invalid target percent %.4f for account %s (must be greater than 0)", targetPercent, account.Name)
 	}
-	if targetPercent <= 0 {
-		return fmt.Errorf("invalid target percent %.4f for account %s (must be greater than 0)", targetPercent, account.Name)
-	}
-	if stopLossPercent <= 0 {
-		return fmt.Errorf("invalid stop loss percent %.4f for account %s (must be greater than 0)", stopLossPercent, account.Name)
+	if stopLossPercent <= 0 && params.stopLoss == 0 {
+		return nil, fmt.Errorf("invalid stop loss percent %.4f for account %s (must be greater than 0)", stopLossPercent, account.Name)
 	}
 
 	// Calculate prices (divide by leverage since price movement is amplified)
 	// e.g., 20% target with 10x leverage = 2% price change needed
-	takeProfitPrice := entryPrice * (1 + targetPercent/float64(leverage))
-	stopLossPrice := entryPrice * (1 - stopLossPercent/float64(leverage))
-
-	// Calculate quantity based on order amount
-	quantity := orderAmount / entryPrice
-
-	// Get exchange info to determine precision
-	exchangeInfo, err := e.binanceClient.GetExchangeInfo()
-	if err != nil {
-		return fmt.Errorf("failed to get exchange info: %w", err)
+	direction := 1.0
+	if params.side == SideShort {
+		direction = -1.0
 	}
-
-	// Find symbol info
-	var symbolInfo *binance.SymbolInfo
-	for i := range exchangeInfo.Symbols {
-		if exchangeInfo.Symbols[i].Symbol == signal.Symbol {
-			symbolInfo = &exchangeInfo.Symbols[i]
-			break
-		}
+	takeProfitPrice := params.takeProfit
+	if takeProfitPrice == 0 {
+		takeProfitPrice = entryPrice * (1 + direction*targetPercent/float64(leverage))
 	}
-
-	if symbolInfo == nil {
-		return fmt.Errorf("symbol %s not found in exchange info", signal.Symbol)
+	stopLossPrice := params.stopLoss
+	if stopLossPrice == 0 {
+		stopLossPrice = entryPrice * (1 - direction*stopLossPercent/float64(leverage))
 	}
 
-	// Get filters for precise rounding
-	var lotFilter, priceFilter, minNotionalFilter *binance.FilterInfo
-	for i := range symbolInfo.Filters {
-		filter := &symbolInfo.Filters[i]
-		switch filter.FilterType {
-		case "MARKET_LOT_SIZE":
-			lotFilter = filter
-		case "LOT_SIZE":
-			if lotFilter == nil { // Prefer MARKET_LOT_SIZE over LOT_SIZE
-				lotFilter = filter
-			}
-		case "PRICE_FILTER":
-			priceFilter = filter
-		case "MIN_NOTIONAL":
-			minNotionalFilter = filter
-		}
+	// Protective orders on the wrong side of the price would trigger immediately
+	if (takeProfitPrice-entryPrice)*direction <= 0 {
+		return nil, fmt.Errorf("take profit %.8g is on the wrong side of the price %.8g for a %s", takeProfitPrice, entryPrice, params.side)
+	}
+	if (entryPrice-stopLossPrice)*direction <= 0 {
+		return nil, fmt.Errorf("stop loss %.8g is on the wrong side of the price %.8g for a %s", stopLossPrice, entryPrice, params.side)
 	}
 
-	// Round quantity and prices using filter-based precision
-	if lotFilter != nil && lotFilter.StepSize != "" {
-		quantity = e.roundToStepSize(quantity, lotFilter.StepSize, lotFilter.MinQty, lotFilter.MaxQty)
-	} else {
-		// Fallback to precision-based rounding
-		quantity = e.roundToPrecision(quantity, symbolInfo.QuantityPrecision)
+	// Get exchange filters to determine precision
+	filters, err := e.getSymbolFilters(params.symbol)
+	if err != nil {
+		return nil, err
 	}
+	lotFilter, minNotionalFilter := filters.lot, filters.minNotional
 
-	if priceFilter != nil && priceFilter.TickSize != "" {
-		takeProfitPrice = e.roundToStepSize(takeProfitPrice, priceFilter.TickSize, priceFilter.MinPrice, priceFilter.MaxPrice)
-		stopLossPrice = e.roundToStepSize(stopLossPrice, priceFilter.TickSize, priceFilter.MinPrice, priceFilter.MaxPrice)
-	} else {
-		// Fallback to precision-based rounding
-		takeProfitPrice = e.roundToPrecision(takeProfitPrice, symbolInfo.PricePrecision)
-		stopLossPrice = e.roundToPrecision(stopLossPrice, symbolInfo.PricePrecision)
+	// Round prices using filter-based precision, the stop distance sizes fixed risk trades
+	takeProfitPrice = e.roundPrice(filters, takeProfitPrice)
+	stopLossPrice = e.roundPrice(filters, stopLossPrice)
+
+	size, err := e.sizePosition(params, account, entryPrice, stopLossPrice, leverage)
+	if err != nil {
+		return nil, err
+	}
+	size.quantity *= sizeFactor
+	quantity := e.roundQuantity(filters, size.quantity)
+	if size.mode == models.SizingFixedRisk {
+		// Rounding up would lose more than the risk amount on a stop-out
+		quantity = e.floorQuantity(filters, size.quantity)
+		if minQty := e.minQuantity(filters); quantity <= 0 || quantity < minQty {
+			return nil, fmt.Errorf("risk of %.2f USDT on %s is below the minimum quantity of %.8g (quantity: %.8f)",
+				size.input, params.symbol, minQty, size.quantity)
+		}
 	}
 
 	// Check and adjust for MIN_NOTIONAL requirement
@@ -207,10 +313,20 @@ func (e *OrderExecutor) ExecuteSignal(signal *models.Signal, account *models.Bin
 	// If no MIN_NOTIONAL filter found or it has no value, use a conservative default
 	if minNotional == 0 {
 		minNotional = 5.0 // Conservative default for most symbols
-		e.logger.Warnf("No MIN_NOTIONAL filter found for %s, using default: %.2f USD", signal.Symbol, minNotional)
+		e.logger.Warnf("No MIN_NOTIONAL filter found for %s, using default: %.2f USD", params.symbol, minNotional)
 	}
 
 	notional := quantity * entryPrice
+	if notional < minNotional && size.mode == models.SizingFixedRisk {
+		// Raising the size would risk more than the account allows
+		return nil, fmt.Errorf("risk of %.2f USDT on %s is below the minimum notional of %.2f USD (notional: %.2f USD)",
+			size.input, params.symbol, minNotional, notional)
+	}
+	if notional < minNotional && sizeFactor < 1 {
+		// Raising the size would undo the price guard's downsize
+		return nil, fmt.Errorf("price guard downsized %s to %.2f USD, below the minimum notional of %.2f USD",
+			params.symbol, notional, minNotional)
+	}
 	if notional < minNotional {
 		// Increase quantity to meet minimum notional, with 1% buffer to account for price movement
 		quantity = (minNotional * 1.01) / entryPrice
@@ -235,123 +351,128 @@ func (e *OrderExecutor) ExecuteSignal(signal *models.Signal, account *models.Bin
 	}
 
 	e.logger.WithFields(logrus.Fields{
-		"symbol":            signal.Symbol,
+		"symbol":            params.symbol,
 		"entry_price":       entryPrice,
 		"take_profit_price": takeProfitPrice,
 		"stop_loss_price":   stopLossPrice,
 		"quantity":          quantity,
 		"leverage":          leverage,
+		"sizing_mode":       size.mode,
 	}).Info("Executing trading signal")
 
 	// Ensure symbol is configured (leverage and margin type) - only set if not already configured
 	// Use the provided function if available, otherwise set directly (for backward compatibility)
 	if e.ensureSymbolConfig != nil {
-		if err := e.ensureSymbolConfig(signal.Symbol, leverage, "ISOLATED"); err != nil {
-			return fmt.Errorf("failed to configure symbol: %w", err)
+		if err := e.ensureSymbolConfig(params.symbol, leverage, "ISOLATED"); err != nil {
+			return nil, fmt.Errorf("failed to configure symbol: %w", err)
 		}
 	} else {
 		// Fallback: set directly if no function provided (shouldn't happen in normal operation)
-		if err := e.binanceClient.SetLeverage(signal.Symbol, leverage); err != nil {
-			return fmt.Errorf("failed to set leverage: %w", err)
+		if err := e.binanceClient.SetLeverage(params.symbol, leverage); err != nil {
+			return nil, fmt.Errorf("failed to set leverage: %w", err)
 		}
-		if err := e.binanceClient.SetMarginType(signal.Symbol, "ISOLATED"); err != nil {
-			return fmt.Errorf("failed to set margin type: %w", err)
+		if err := e.binanceClient.SetMarginType(params.symbol, "ISOLATED"); err != nil {
+			return nil, fmt.Errorf("failed to set margin type: %w", err)
 		}
 	}
 
-	// Execute 3 orders in parallel for speed
-	var wg sync.WaitGroup
-	errChan := make(chan error, 3)
-
-	var entryResp, tpResp, slResp *binance.OrderResponse
-	var entryErr, tpErr, slErr error
-
-	// 1. Entry order (MARKET BUY)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		entryResp, entryErr = e.binanceClient.PlaceOrder(&binance.NewOrder{
-			Symbol:   signal.Symbol,
-			Side:     "BUY",
-			Type:     "MARKET",
-			Quantity: quantity,
-		})
-		if entryErr != nil {
-			errChan <- fmt.Errorf("entry order failed: %w", entryErr)
-		}
-	}()
-
-	// 2. Take Profit order (TAKE_PROFIT_MARKET)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		tpResp, tpErr = e.binanceClient.PlaceOrder(&binance.NewOrder{
-			Symbol:     signal.Symbol,
-			Side:       "SELL",
-			Type:       "TAKE_PROFIT_MARKET",
-			StopPrice:  takeProfitPrice,
-			Quantity:   quantity,
-			ReduceOnly: true,
-		})
-		if tpErr != nil {
-			errChan <- fmt.Errorf("take profit order failed: %w", tpErr)
-		}
-	}()
-
-	// 3. Stop Loss order (STOP_MARKET)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		slResp, slErr = e.binanceClient.PlaceOrder(&binance.NewOrder{
-			Symbol:     signal.Symbol,
-			Side:       "SELL",
-			Type:       "STOP_MARKET",
-			StopPrice:  stopLossPrice,
-			Quantity:   quantity,
-			ReduceOnly: true,
-		})
-		if slErr != nil {
-			errChan <- fmt.Errorf("stop loss order failed: %w", slErr)
-		}
-	}()
-
-	// Wait for all orders to complete
-	wg.Wait()
-	close(errChan)
+	// Entry buys for a LONG and sells for a SHORT; TP/SL close it
+	entrySide, exitSide := "BUY", "SELL"
+	if params.side == SideShort {
+		entrySide, exitSide = "SELL", "BUY"
+	}
 
-	// Check for errors
-	var errors []error
-	for err := range errChan {
-		errors = append(errors, err)
+	// A halt while the trade was sized must not open it
+	if err := e.checkHalt(); err != nil {
+		return nil, err
 	}
 
-	if len(errors) > 0 {
-		// If entry failed, cancel TP/SL if they were placed
-		if entryErr != nil {
-			e.logger.Error("Entry order failed, canceling TP/SL orders")
-			if tpResp != nil {
-				e.binanceClient.CancelOrder(signal.Symbol, tpResp.OrderID)
-			}
-			if slResp != nil {
-				e.binanceClient.CancelOrder(signal.Symbol, slResp.OrderID)
-			}
-			return fmt.Errorf("order execution failed: %v", errors)
-		}
+	// The entry fills before its TP/SL are placed: reduce-only orders are
+	// rejected while there is no position, and protect what actually filled
+	var fill *entryFill
+	if limitEntry {
+		// Limit entries may fill partially
+		fill, err = e.chaseEntry(params, account, filters, entrySide, quantity, minNotional)
+	} else {
+		fill, err = e.marketEntry(params, entrySide, quantity)
+	}
+	if err != nil {
+		return nil, err
 	}
+	// Rounding up to the minimum quantity would size the TP/SL above the fill
+	fill.quantity = e.floorQuantity(filters, fill.quantity)
+	quantity = fill.quantity
+	entryResp := fill.lastOrder()
 
 	// Log order details
-	if entryResp != nil {
-		e.logger.WithFields(logrus.Fields{
-			"order_id": entryResp.OrderID,
-			"symbol":   entryResp.Symbol,
-			"side":     entryResp.Side,
-			"type":     entryResp.Type,
-			"status":   entryResp.Status,
-			"qty":      entryResp.OrigQty,
-		}).Info("Entry order placed")
+	e.logger.WithFields(logrus.Fields{
+		"order_id": entryResp.OrderID,
+		"symbol":   entryResp.Symbol,
+		"side":     entryResp.Side,
+		"type":     entryResp.Type,
+		"status":   entryResp.Status,
+		"qty":      entryResp.ExecutedQty,
+	}).Info("Entry order filled")
+
+	tpResp, slResp, protectErrs := e.placeProtectiveOrders(params.ref, params.symbol, exitSide, quantity,
+		takeProfitPrice, stopLossPrice)
+
+	// Record the position, linked to its signal, and its orders
+	position := e.recordPosition(params, account, fill, entryPrice, leverage, takeProfitPrice, stopLossPrice, size)
+
+	// A position is never left open without its TP/SL
+	if len(protectErrs) > 0 {
+		return nil, e.flattenUnprotected(params, account, position, fill, exitSide, tpResp, slResp, protectErrs)
+	}
+
+	filledPrice := entryPrice
+	if position != nil {
+		filledPrice = position.EntryPrice
+	}
+	e.events.Publish(&events.Event{
+		Type:        events.OrdersPlaced,
+		AccountID:   account.ID,
+		AccountName: account.Name,
+		ChannelID:   params.channelID(),
+		Symbol:      params.symbol,
+		Side:        params.side,
+		OrderID:     entryResp.OrderID,
+		Price:       filledPrice,
+		Quantity:    quantity,
+		TakeProfit:  takeProfitPrice,
+		StopLoss:    stopLossPrice,
+	})
+
+	if position != nil {
+		e.events.Publish(&events.Event{
+			Type:        events.PositionOpened,
+			AccountID:   account.ID,
+			AccountName: account.Name,
+			ChannelID:   params.channelID(),
+			Symbol:      position.Symbol,
+			Side:        position.Side,
+			Price:       position.EntryPrice,
+			Quantity:    position.Quantity,
+			TakeProfit:  takeProfitPrice,
+			StopLoss:    stopLossPrice,
+		})
+
+		for _, order := range fill.orders {
+			e.asyncLogOrder(position.ID, order, params.orderPurpose("entry"))
+		}
+		if tpResp != nil {
+			e.asyncLogOrder(position.ID, tpResp, params.orderPurpose("take_profit"))
+		}
+		if slResp != nil {
+			e.asyncLogOrder(position.ID, slResp, params.orderPurpose("stop_loss"))
+		}
 	}
 
 	// Track TP/SL orders for timeout cancellation
+	var positionID int64
+	if position != nil {
+		positionID = position.ID
+	}
 	if tpResp != nil {
 		e.logger.WithFields(logrus.Fields{
 			"order_id":   tpResp.OrderID,
@@ -363,7 +484,8 @@ func (e *OrderExecutor) ExecuteSignal(signal *models.Signal, account *models.Bin
 		}).Info("Take profit order placed")
 
 		// Add to timeout tracker with quantity for position closing
-		e.addOrderTimeout(strconv.FormatInt(tpResp.OrderID, 10), signal.Symbol, "take_profit", quantity, account.OrderTimeout)
+		e.addOrderTimeout(strconv.FormatInt(tpResp.OrderID, 10), params.symbol, "take_profit", quantity,
+			positionID, params.ref, account.OrderTimeout)
 	}
 
 	if slResp != nil {
@@ -377,20 +499,156 @@ func (e *OrderExecutor) ExecuteSignal(signal *models.Signal, account *models.Bin
 		}).Info("Stop loss order placed")
 
 		// Add to timeout tracker with quantity for position closing
-		e.addOrderTimeout(strconv.FormatInt(slResp.OrderID, 10), signal.Symbol, "stop_loss", quantity, account.OrderTimeout)
+		e.addOrderTimeout(strconv.FormatInt(slResp.OrderID, 10), params.symbol, "stop_loss", quantity,
+			positionID, params.ref, account.OrderTimeout)
 	}
 
 	e.logger.WithFields(logrus.Fields{
-		"symbol": signal.Symbol,
+		"symbol":       params.symbol,
+		"side":         params.side,
 		"entry_status": entryResp.Status,
-	}).Info("Signal executed successfully")
+	}).Info("Trade executed successfully")
 
-	// Record this signal to prevent duplicates within 48 hours
-	e.signalsMu.Lock()
-	e.recentSignals[signal.Symbol] = time.Now()
-	e.signalsMu.Unlock()
+	return position, nil
+}
 
-	return nil
+// recordPosition saves the opened position. The fill price of the entry is
+// preferred over the ticker price when available.
+func (e *OrderExecutor) recordPosition(params *tradeParams, account *models.BinanceAccount, fill *entryFill,
+	tickerPrice float64, leverage int, takeProfitPrice, stopLossPrice float64, size *positionSize) *models.Position {
+	entryPrice := tickerPrice
+	if fill.price > 0 {
+		entryPrice = fill.price
+	}
+
+	var signalID int64
+	if params.signal != nil {
+		signalID = params.signal.ID
+	}
+
+	position := &models.Position{
+		SignalID:        signalID,
+		AccountID:       account.ID,
+		Symbol:          params.symbol,
+		Side:            params.side,
+		EntryPrice:      entryPrice,
+		Quantity:        fill.quantity,
+		Leverage:        leverage,
+		TakeProfitPrice: takeProfitPrice,
+		StopLossPrice:   stopLossPrice,
+		Status:          "open",
+		OpenedAt:        time.Now(),
+		SizingMode:      size.mode,
+		SizingInput:     size.input,
+		SizingBalance:   size.balance,
+	}
+
+	if err := e.repo.SavePosition(position); err != nil {
+		e.logger.Errorf("Failed to save position for %s: %v", params.symbol, err)
+		return nil
+	}
+
+	// Entry fills from the user data stream may arrive before the position
+	for _, order := range fill.orders {
+		if err := e.repo.AssignFillsToPosition(account.ID, strconv.FormatInt(order.OrderID, 10), position.ID); err != nil {
+			e.logger.Errorf("Failed to assign entry fills of %s: %v", params.symbol, err)
+		}
+	}
+
+	return position
+}
+
+// symbolFilters holds a symbol's exchange info and the filters used for rounding
+type symbolFilters struct {
+	info        *binance.SymbolInfo
+	lot         *binance.FilterInfo
+	price       *binance.FilterInfo
+	minNotional *binance.FilterInfo
+}
+
+// getSymbolFilters looks up a symbol's exchange info and filters
+func (e *OrderExecutor) getSymbolFilters(symbol string) (*symbolFilters, error) {
+	exchangeInfo, err := e.binanceClient.GetExchangeInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exchange info: %w", err)
+	}
+
+	// Find symbol info
+	filters := &symbolFilters{}
+	for i := range exchangeInfo.Symbols {
+		if exchangeInfo.Symbols[i].Symbol == symbol {
+			filters.info = &exchangeInfo.Symbols[i]
+			break
+		}
+	}
+
+	if filters.info == nil {
+		return nil, fmt.Errorf("symbol %s not found in exchange info", symbol)
+	}
+
+	for i := range filters.info.Filters {
+		filter := &filters.info.Filters[i]
+		switch filter.FilterType {
+		case "MARKET_LOT_SIZE":
+			filters.lot = filter
+		case "LOT_SIZE":
+			if filters.lot == nil { // Prefer MARKET_LOT_SIZE over LOT_SIZE
+				filters.lot = filter
+			}
+		case "PRICE_FILTER":
+			filters.price = filter
+		case "MIN_NOTIONAL":
+			filters.minNotional = filter
+		}
+	}
+
+	return filters, nil
+}
+
+// roundQuantity rounds a quantity to the symbol's lot size
+func (e *OrderExecutor) roundQuantity(filters *symbolFilters, quantity float64) float64 {
+	if filters.lot != nil && filters.lot.StepSize != "" {
+		return e.roundToStepSize(quantity, filters.lot.StepSize, filters.lot.MinQty, filters.lot.MaxQty)
+	}
+	return e.roundToPrecision(quantity, filters.info.QuantityPrecision)
+}
+
+// floorQuantity rounds a quantity down to the symbol's lot size without
+// raising it to the minimum quantity, see minQuantity
+func (e *OrderExecutor) floorQuantity(filters *symbolFilters, quantity float64) float64 {
+	if filters.lot == nil || filters.lot.StepSize == "" {
+		return e.roundToPrecision(quantity, filters.info.QuantityPrecision)
+	}
+
+	step, err := strconv.ParseFloat(filters.lot.StepSize, 64)
+	if err != nil || step == 0 {
+		e.logger.Warnf("Invalid stepSize: %s, using original value", filters.lot.StepSize)
+		return quantity
+	}
+
+	// The epsilon keeps exact multiples from flooring a step down through float error
+	floored := math.Floor(quantity/step+1e-9) * step
+	if max, _ := strconv.ParseFloat(filters.lot.MaxQty, 64); max > 0 && floored > max {
+		floored = max
+	}
+	return floored
+}
+
+// minQuantity returns the symbol's minimum order quantity, 0 when unknown
+func (e *OrderExecutor) minQuantity(filters *symbolFilters) float64 {
+	if filters.lot == nil {
+		return 0
+	}
+	min, _ := strconv.ParseFloat(filters.lot.MinQty, 64)
+	return min
+}
+
+// roundPrice rounds a price to the symbol's tick size
+func (e *OrderExecutor) roundPrice(filters *symbolFilters, price float64) float64 {
+	if filters.price != nil && filters.price.TickSize != "" {
+		return e.roundToStepSize(price, filters.price.TickSize, filters.price.MinPrice, filters.price.MaxPrice)
+	}
+	return e.roundToPrecision(price, filters.info.PricePrecision)
 }
 
 // asyncLogOrder logs an order asynchronously
@@ -405,20 +663,31 @@ func (e *OrderExecutor) asyncLogOrder(positionID int64, orderResp *binance.Order
 	}
 
 	order := &models.Order{
-		PositionID:      positionID,
-		BinanceOrderID:  strconv.FormatInt(orderResp.OrderID, 10),
-		Symbol:          orderResp.Symbol,
-		Side:            orderResp.Side,
-		Type:            orderResp.Type,
-		OrigQty:         origQty,
-		ExecutedQty:     executedQty,
-		Price:           price,
-		StopPrice:       stopPrice,
-		Status:          orderResp.Status,
-		TimeInForce:     orderResp.TimeInForce,
-		CreatedAt:       time.Now(),
-		UpdatedAt:       time.Now(),
-		OrderPurpose:    purpose,
+		PositionID:     positionID,
+		BinanceOrderID: strconv.FormatInt(orderResp.OrderID, 10),
+		ClientOrderID:  orderResp.ClientOrderID,
+		Symbol:         orderResp.Symbol,
+		Side:           orderResp.Side,
+		Type:           orderResp.Type,
+		OrigQty:        origQty,
+		ExecutedQty:    executedQty,
+		Price:          price,
+		StopPrice:      stopPrice,
+		Status:         orderResp.Status,
+		TimeInForce:    orderResp.TimeInForce,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+		OrderPurpose:   purpose,
+	}
+
+	e.logMu.RLock()
+	defer e.logMu.RUnlock()
+
+	if e.closed {
+		if err := e.repo.SaveOrder(order); err != nil {
+			e.logger.Errorf("Failed to save order: %v", err)
+		}
+		return
 	}
 
 	e.logQueue <- &LogEntry{
@@ -429,6 +698,8 @@ func (e *OrderExecutor) asyncLogOrder(positionID int64, orderResp *binance.Order
 
 // runAsyncLogger processes log entries asynchronously
 func (e *OrderExecutor) runAsyncLogger() {
+	defer close(e.logDone)
+
 	for entry := range e.logQueue {
 		switch entry.Type {
 		case "order":
@@ -442,14 +713,16 @@ func (e *OrderExecutor) runAsyncLogger() {
 	}
 }
 
-
 // addOrderTimeout adds an order to the timeout tracker
-func (e *OrderExecutor) addOrderTimeout(orderID string, symbol string, orderType string, quantity float64, timeoutSeconds int) {
+func (e *OrderExecutor) addOrderTimeout(orderID string, symbol string, orderType string, quantity float64,
+	positionID int64, ref string, timeoutSeconds int) {
 	timeout := &OrderTimeout{
 		OrderID:         orderID,
 		Symbol:          symbol,
 		OrderType:       orderType,
 		Quantity:        quantity,
+		PositionID:      positionID,
+		Ref:             ref,
 		CreatedAt:       time.Now(),
 		TimeoutDuration: time.Duration(timeoutSeconds) * time.Second,
 	}
@@ -461,91 +734,133 @@ func (e *OrderExecutor) addOrderTimeout(orderID string, symbol string, orderType
 
 // monitorOrderTimeouts monitors and cancels timed-out orders
 func (e *OrderExecutor) monitorOrderTimeouts() {
+	defer e.wg.Done()
+
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		e.ordersMu.Lock()
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.stopCh:
+			return
+		}
 
-		// Track which positions we've already attempted to close in this tick
-		closedPositions := make(map[string]bool)
+		e.checkOrderTimeouts()
+	}
+}
 
-		for orderID, timeout := range e.pendingOrders {
-			if time.Since(timeout.CreatedAt) > timeout.TimeoutDuration {
-				// Timeout reached, cancel order
-				e.logger.Infof("Order %s timed out after %v, canceling...", orderID, timeout.TimeoutDuration)
+// checkOrderTimeouts cancels every pending order past its timeout and closes
+// any position left open on its symbol
+func (e *OrderExecutor) checkOrderTimeouts() {
+	e.ordersMu.Lock()
 
-				binanceOrderID, _ := strconv.ParseInt(orderID, 10, 64)
-				_, err := e.binanceClient.CancelOrder(timeout.Symbol, binanceOrderID)
-				if err != nil {
-					// Log error but continue - order might already be filled/canceled
-					e.logger.Warnf("Failed to cancel timed-out order %s: %v", orderID, err)
-				}
+	// Track which positions we've already attempted to close in this tick
+	closedPositions := make(map[string]bool)
 
-				// Check if there's an actual open position before trying to close it
-				positionKey := timeout.Symbol
-				if !closedPositions[positionKey] {
-					// Get current positions to check if position exists
-					positions, err := e.binanceClient.GetPositions()
-					if err != nil {
-						e.logger.Errorf("Failed to get positions for %s: %v", timeout.Symbol, err)
-					} else {
-						// Find the position for this symbol
-						var positionAmt float64
-						for _, pos := range positions {
-							if pos.Symbol == timeout.Symbol {
-								positionAmt, _ = strconv.ParseFloat(pos.PositionAmt, 64)
-								break
-							}
+	for orderID, timeout := range e.pendingOrders {
+		if time.Since(timeout.CreatedAt) > timeout.TimeoutDuration {
+			// Timeout reached, cancel order
+			e.logger.Infof("Order %s timed out after %v, canceling...", orderID, timeout.TimeoutDuration)
+
+			binanceOrderID, _ := strconv.ParseInt(orderID, 10, 64)
+			_, err := e.binanceClient.CancelOrder(timeout.Symbol, binanceOrderID)
+			if err != nil {
+				// Log error but continue - order might already be filled/canceled
+				e.logger.Warnf("Failed to cancel timed-out order %s: %v", orderID, err)
+			}
+
+			// Check if there's an actual open position before trying to close it
+			positionKey := timeout.Symbol
+			if !closedPositions[positionKey] {
+				// Get current positions to check if position exists
+				positions, err := e.binanceClient.GetPositions()
+				if err != nil {
+					e.logger.Errorf("Failed to get positions for %s: %v", timeout.Symbol, err)
+				} else {
+					// Find the position for this symbol
+					var positionAmt float64
+					for _, pos := range positions {
+						if pos.Symbol == timeout.Symbol {
+							positionAmt, _ = strconv.ParseFloat(pos.PositionAmt, 64)
+							break
 						}
+					}
 
-						// Only try to close if there's an actual position
-						if positionAmt != 0 {
-							e.logger.Infof("Closing open position for %s due to timeout (amount: %.8f)", timeout.Symbol, positionAmt)
+					// Only try to close if there's an actual position
+					if positionAmt != 0 {
+						e.logger.Infof("Closing open position for %s due to timeout (amount: %.8f)", timeout.Symbol, positionAmt)
 
-							// Determine the side based on position amount
-							side := "SELL"
-							qty := positionAmt
-							if positionAmt < 0 {
-								side = "BUY"
-								qty = -positionAmt // Make it positive
-							}
+						// Determine the side based on position amount
+						side := "SELL"
+						qty := positionAmt
+						if positionAmt < 0 {
+							side = "BUY"
+							qty = -positionAmt // Make it positive
+						}
 
-							// Place market order to close position
-							_, err := e.binanceClient.PlaceOrder(&binance.NewOrder{
-								Symbol:     timeout.Symbol,
-								Side:       side,
-								Type:       "MARKET",
-								Quantity:   qty,
-								ReduceOnly: true,
-							})
-
-							if err != nil {
-								e.logger.Errorf("Failed to close position for %s: %v", timeout.Symbol, err)
-							} else {
-								e.logger.Infof("Successfully closed position for %s (qty: %.8f)", timeout.Symbol, qty)
-								closedPositions[positionKey] = true
-							}
+						// The close is attributed to the timed-out order's position
+						ref := timeout.Ref
+						if timeout.PositionID != 0 {
+							ref = positionRef(timeout.PositionID)
+						}
+
+						// Place market order to close position
+						resp, err := e.binanceClient.PlaceOrder(&binance.NewOrder{
+							Symbol:           timeout.Symbol,
+							Side:             side,
+							Type:             "MARKET",
+							Quantity:         qty,
+							ReduceOnly:       true,
+							NewClientOrderID: e.clientOrderID(ref, orderKindClose),
+						})
+
+						if err != nil {
+							e.logger.Errorf("Failed to close position for %s: %v", timeout.Symbol, err)
+							e.publishError(timeout.Symbol, fmt.Errorf("failed to close position after order timeout: %w", err))
 						} else {
-							e.logger.Infof("No open position found for %s, skipping close", timeout.Symbol)
+							e.logger.Infof("Successfully closed position for %s (qty: %.8f)", timeout.Symbol, qty)
+							closedPositions[positionKey] = true
+							e.recordTimeoutClose(timeout.Symbol, resp)
+							for _, eventType := range []string{events.TimeoutClosed, events.PositionClosed} {
+								e.events.Publish(&events.Event{
+									Type:        eventType,
+									AccountID:   e.accountID,
+									AccountName: e.accountName,
+									Symbol:      timeout.Symbol,
+									Side:        side,
+									Quantity:    qty,
+									Reason:      "timeout",
+								})
+							}
 						}
+					} else {
+						e.logger.Infof("No open position found for %s, skipping close", timeout.Symbol)
 					}
 				}
-
-				// Remove from pending
-				delete(e.pendingOrders, orderID)
 			}
+
+			// Remove from pending
+			delete(e.pendingOrders, orderID)
 		}
-		e.ordersMu.Unlock()
 	}
+	e.ordersMu.Unlock()
 }
 
 // cleanupOldSignals removes signals older than 48 hours from tracking
 func (e *OrderExecutor) cleanupOldSignals() {
+	defer e.wg.Done()
+
 	ticker := time.NewTicker(1 * time.Hour)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.stopCh:
+			return
+		}
+
 		e.signalsMu.Lock()
 		now := time.Now()
 		for symbol, executedAt := range e.recentSignals {
@@ -569,6 +884,9 @@ func (e *OrderExecutor) HandleOrderUpdate(update *binance.OrderUpdate) {
 		"type":     update.Order.ExecutionType,
 	}).Info("Order update received")
 
+	e.recordOrderUpdate(update)
+	e.publishOrderUpdate(update)
+
 	// Remove from pending timeout tracker if filled or canceled
 	if update.Order.OrderStatus == "FILLED" || update.Order.OrderStatus == "CANCELED" || update.Order.OrderStatus == "EXPIRED" {
 		e.ordersMu.Lock()
@@ -580,9 +898,71 @@ func (e *OrderExecutor) HandleOrderUpdate(update *binance.OrderUpdate) {
 	}
 }
 
-// Close shuts down the executor
+// publishOrderUpdate publishes partial fills and filled take profit or stop loss orders
+func (e *OrderExecutor) publishOrderUpdate(update *binance.OrderUpdate) {
+	order := &update.Order
+	if order.ExecutionType != "TRADE" {
+		return
+	}
+
+	event := &events.Event{
+		AccountID:   e.accountID,
+		AccountName: e.accountName,
+		Symbol:      order.Symbol,
+		Side:        order.Side,
+		OrderID:     order.OrderID,
+	}
+	event.Price, _ = strconv.ParseFloat(order.AvgPrice, 64)
+	event.Quantity, _ = strconv.ParseFloat(order.FilledQty, 64)
+
+	switch {
+	case order.OrderStatus == "PARTIALLY_FILLED":
+		event.Type = events.PartialFill
+	case order.OrderStatus == "FILLED" && order.OrigType == "TAKE_PROFIT_MARKET":
+		event.Type = events.TakeProfitHit
+		event.Reason = "take_profit"
+		event.PnL, _ = strconv.ParseFloat(order.RealizedProfit, 64)
+	case order.OrderStatus == "FILLED" && order.OrigType == "STOP_MARKET":
+		event.Type = events.StopLossHit
+		event.Reason = "stop_loss"
+		event.PnL, _ = strconv.ParseFloat(order.RealizedProfit, 64)
+	default:
+		return
+	}
+
+	e.events.Publish(event)
+
+	// A filled protective order closes the position
+	if event.Type != events.PartialFill {
+		closed := *event
+		closed.Type = events.PositionClosed
+		e.events.Publish(&closed)
+	}
+}
+
+// publishError publishes an execution error for this account
+func (e *OrderExecutor) publishError(symbol string, err error) {
+	e.events.Publish(&events.Event{
+		Type:        events.ExecutionError,
+		AccountID:   e.accountID,
+		AccountName: e.accountName,
+		Symbol:      symbol,
+		Error:       err.Error(),
+	})
+}
+
+// Close stops the executor's monitors and shuts it down once the queued
+// orders are saved. Orders logged afterwards are saved synchronously.
 func (e *OrderExecutor) Close() {
+	close(e.stopCh)
+	e.wg.Wait()
+
+	e.logMu.Lock()
+	e.closed = true
 	close(e.logQueue)
+	e.logMu.Unlock()
+
+	<-e.logDone
 }
 
 // roundToPrecision rounds a number to the specified decimal precision
@@ -0,0 +1,296 @@
+package trading
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"tdlib-go/internal/binance"
+	"tdlib-go/internal/binance/binancetest"
+	"tdlib-go/internal/config"
+	"tdlib-go/internal/storage"
+	"tdlib-go/pkg/models"
+)
+
+// testExecutor is an order executor trading an account on a fake exchange
+type testExecutor struct {
+	*OrderExecutor
+	srv       *binancetest.Server
+	repo      *storage.Repository
+	account   *models.BinanceAccount
+	closeOnce sync.Once
+}
+
+// newTestExecutor returns an executor for account on a fake exchange with
+// BTCUSDT at 100. Zero account settings get a 10x market entry account
+// trading 100 USDT with a 20% target and 10% stop loss.
+func newTestExecutor(t *testing.T, account *models.BinanceAccount) *testExecutor {
+	t.Helper()
+
+	srv := binancetest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.AddSymbol(binancetest.Symbol{Symbol: "BTCUSDT"})
+
+	repo, err := storage.NewRepository(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewRepository: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	if account.Name == "" {
+		account.Name = "test"
+	}
+	if account.Leverage == 0 {
+		account.Leverage = 10
+	}
+	if account.OrderAmount == 0 {
+		account.OrderAmount = 100
+	}
+	if account.TargetPercent == 0 {
+		account.TargetPercent = 0.2
+	}
+	if account.StopLossPercent == 0 {
+		account.StopLossPercent = 0.1
+	}
+	if account.OrderTimeout == 0 {
+		account.OrderTimeout = 3600
+	}
+	account.IsActive = true
+	if err := repo.SaveAccount(account); err != nil {
+		t.Fatalf("SaveAccount: %v", err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	client := binance.NewClientWithConfig("key", "secret", srv.URL, srv.WSURL(), logger)
+
+	te := &testExecutor{
+		OrderExecutor: NewOrderExecutor(client, repo, &config.Config{}, logger),
+		srv:           srv,
+		repo:          repo,
+		account:       account,
+	}
+	te.accountID = account.ID
+	te.accountName = account.Name
+	t.Cleanup(te.close)
+	return te
+}
+
+// close stops the executor, saving the orders it logged
+func (te *testExecutor) close() {
+	te.closeOnce.Do(te.OrderExecutor.Close)
+}
+
+// openPositions returns the account's open positions
+func (te *testExecutor) openPositions(t *testing.T) []*models.Position {
+	t.Helper()
+	positions, err := te.repo.GetOpenPositions()
+	if err != nil {
+		t.Fatalf("GetOpenPositions: %v", err)
+	}
+	return positions
+}
+
+// ordersOfType returns the fake exchange's orders of an order type
+func (te *testExecutor) ordersOfType(orderType string) []binancetest.Order {
+	var orders []binancetest.Order
+	for _, order := range te.srv.Orders() {
+		if order.Type == orderType {
+			orders = append(orders, order)
+		}
+	}
+	return orders
+}
+
+// executeSignal executes a BTCUSDT signal and returns its open position
+func (te *testExecutor) executeSignal(t *testing.T) *models.Position {
+	t.Helper()
+	if err := te.ExecuteSignal(&models.Signal{ID: 1, Symbol: "BTCUSDT"}, te.account); err != nil {
+		t.Fatalf("ExecuteSignal: %v", err)
+	}
+	positions := te.openPositions(t)
+	if len(positions) != 1 {
+		t.Fatalf("got %d open positions, want 1", len(positions))
+	}
+	return positions[0]
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestExecuteSignalOpensProtectedPosition(t *testing.T) {
+	te := newTestExecutor(t, &models.BinanceAccount{})
+
+	position := te.executeSignal(t)
+
+	// 100 USDT at 100, TP 2% and SL 1% away at 10x
+	if position.Side != SideLong || !approxEqual(position.Quantity, 1) || !approxEqual(position.EntryPrice, 100) {
+		t.Errorf("position = %s %v @ %v, want LONG 1 @ 100", position.Side, position.Quantity, position.EntryPrice)
+	}
+	if !approxEqual(position.TakeProfitPrice, 102) || !approxEqual(position.StopLossPrice, 99) {
+		t.Errorf("TP/SL = %v/%v, want 102/99", position.TakeProfitPrice, position.StopLossPrice)
+	}
+	if amount := te.srv.Position("BTCUSDT").Amount; !approxEqual(amount, 1) {
+		t.Errorf("exchange position = %v, want 1", amount)
+	}
+
+	tp, sl := te.ordersOfType("TAKE_PROFIT_MARKET"), te.ordersOfType("STOP_MARKET")
+	if len(tp) != 1 || len(sl) != 1 {
+		t.Fatalf("got %d take profit and %d stop loss orders, want 1 each", len(tp), len(sl))
+	}
+	for _, order := range []binancetest.Order{tp[0], sl[0]} {
+		if order.Side != "SELL" || !order.ReduceOnly || !approxEqual(order.Quantity, 1) || order.Status != binancetest.StatusNew {
+			t.Errorf("protective order = %+v, want a resting reduce-only SELL of 1", order)
+		}
+	}
+
+	te.close()
+	orders, err := te.repo.GetOrdersByPosition(position.ID)
+	if err != nil {
+		t.Fatalf("GetOrdersByPosition: %v", err)
+	}
+	purposes := make(map[string]bool)
+	for _, order := range orders {
+		purposes[order.OrderPurpose] = true
+	}
+	for _, purpose := range []string{"entry", "take_profit", "stop_loss"} {
+		if !purposes[purpose] {
+			t.Errorf("no %s order recorded for the position, got %v", purpose, purposes)
+		}
+	}
+}
+
+func TestExecuteSignalSkipsDuplicates(t *testing.T) {
+	te := newTestExecutor(t, &models.BinanceAccount{})
+
+	te.executeSignal(t)
+	if err := te.ExecuteSignal(&models.Signal{ID: 2, Symbol: "BTCUSDT"}, te.account); err != nil {
+		t.Fatalf("ExecuteSignal: %v", err)
+	}
+
+	if entries := te.ordersOfType("MARKET"); len(entries) != 1 {
+		t.Errorf("got %d entries, want the duplicate signal skipped", len(entries))
+	}
+}
+
+func TestOrderTimeoutClosesPosition(t *testing.T) {
+	te := newTestExecutor(t, &models.BinanceAccount{})
+	position := te.executeSignal(t)
+
+	// Nothing has timed out yet
+	te.checkOrderTimeouts()
+	if len(te.openPositions(t)) != 1 {
+		t.Fatal("position closed before its orders timed out")
+	}
+
+	te.ordersMu.Lock()
+	for _, timeout := range te.pendingOrders {
+		timeout.TimeoutDuration = 0
+	}
+	te.ordersMu.Unlock()
+	te.srv.SetPrice("BTCUSDT", 101)
+	te.checkOrderTimeouts()
+
+	if amount := te.srv.Position("BTCUSDT").Amount; amount != 0 {
+		t.Errorf("exchange position = %v after timeout, want 0", amount)
+	}
+	for _, order := range append(te.ordersOfType("TAKE_PROFIT_MARKET"), te.ordersOfType("STOP_MARKET")...) {
+		if order.Status == binancetest.StatusNew {
+			t.Errorf("%s order is still open after timeout", order.Type)
+		}
+	}
+	if len(te.pendingOrders) != 0 {
+		t.Errorf("%d orders still tracked after timeout", len(te.pendingOrders))
+	}
+	closes := te.ordersOfType("MARKET")[1:]
+	if prefix := fmt.Sprintf("tg-p%d.", position.ID); len(closes) != 1 || !strings.HasPrefix(closes[0].ClientOrderID, prefix) {
+		t.Errorf("close orders = %+v, want one with a client order ID of the position", closes)
+	}
+
+	closed, err := te.repo.GetPosition(position.ID)
+	if err != nil {
+		t.Fatalf("GetPosition: %v", err)
+	}
+	if closed.Status != "closed" || closed.ExitPrice == nil || !approxEqual(*closed.ExitPrice, 101) {
+		t.Errorf("position = %s at %v, want closed at 101", closed.Status, closed.ExitPrice)
+	}
+}
+
+func TestClosePosition(t *testing.T) {
+	te := newTestExecutor(t, &models.BinanceAccount{})
+	position := te.executeSignal(t)
+
+	// A partial close leaves the rest open
+	te.srv.SetPrice("BTCUSDT", 101.5)
+	position, err := te.ClosePosition(position, 0.4)
+	if err != nil {
+		t.Fatalf("ClosePosition: %v", err)
+	}
+	if position.Status != "open" || !approxEqual(position.Quantity, 0.6) {
+		t.Errorf("position = %s %v after a partial close, want open 0.6", position.Status, position.Quantity)
+	}
+	if amount := te.srv.Position("BTCUSDT").Amount; !approxEqual(amount, 0.6) {
+		t.Errorf("exchange position = %v after a partial close, want 0.6", amount)
+	}
+
+	te.srv.SetPrice("BTCUSDT", 99.5)
+	position, err = te.ClosePosition(position, 0)
+	if err != nil {
+		t.Fatalf("ClosePosition: %v", err)
+	}
+	if position.Status != "closed" || position.ExitPrice == nil || !approxEqual(*position.ExitPrice, 99.5) {
+		t.Errorf("position = %s at %v, want closed at 99.5", position.Status, position.ExitPrice)
+	}
+	// 0.4 closed 1.5 up and 0.6 closed 0.5 down
+	if position.PnL == nil {
+		t.Error("closed position has no PnL")
+	} else if math.Abs(*position.PnL-0.3) > 1e-6 {
+		t.Errorf("PnL = %v, want 0.3 from both closes", *position.PnL)
+	}
+	if amount := te.srv.Position("BTCUSDT").Amount; amount != 0 {
+		t.Errorf("exchange position = %v after closing, want 0", amount)
+	}
+	for _, order := range append(te.ordersOfType("TAKE_PROFIT_MARKET"), te.ordersOfType("STOP_MARKET")...) {
+		if order.Status == binancetest.StatusNew {
+			t.Errorf("%s order is still open after closing", order.Type)
+		}
+	}
+
+	if _, err := te.ClosePosition(position, 0); err == nil {
+		t.Error("closing a closed position succeeded")
+	}
+}
+
+func TestUpdateTPSLSavesAppliedLeg(t *testing.T) {
+	te := newTestExecutor(t, &models.BinanceAccount{})
+	position := te.executeSignal(t)
+	te.srv.InjectOrderError("STOP_MARKET", 1, -2021, "Order would immediately trigger.")
+
+	_, err := te.UpdateTPSL(position, 103, 98.5)
+	if err == nil || !strings.Contains(err.Error(), "take profit updated to 103") {
+		t.Fatalf("UpdateTPSL error = %v, want the applied take profit named", err)
+	}
+
+	saved, err := te.repo.GetPosition(position.ID)
+	if err != nil {
+		t.Fatalf("GetPosition: %v", err)
+	}
+	if saved.TakeProfitPrice != 103 || saved.StopLossPrice != 99 {
+		t.Errorf("saved TP/SL = %v/%v, want the new 103 and the old 99", saved.TakeProfitPrice, saved.StopLossPrice)
+	}
+	var open []float64
+	for _, order := range te.ordersOfType("TAKE_PROFIT_MARKET") {
+		if order.Status == binancetest.StatusNew {
+			open = append(open, order.StopPrice)
+		}
+	}
+	if len(open) != 1 || open[0] != 103 {
+		t.Errorf("open take profit orders at %v, want only 103", open)
+	}
+}
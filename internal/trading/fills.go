@@ -0,0 +1,192 @@
+package trading
+
+import (
+	"strconv"
+	"time"
+
+	"tdlib-go/internal/binance"
+	"tdlib-go/pkg/models"
+)
+
+// fillLookback caps how far back syncFills asks the exchange for trades
+const fillLookback = 7 * 24 * time.Hour
+
+// recordOrderUpdate records an order's status and, for trades, the fill with
+// its realized PnL and commission. A filled take profit or stop loss closes
+// its position.
+func (e *OrderExecutor) recordOrderUpdate(update *binance.OrderUpdate) {
+	order := &update.Order
+	orderID := strconv.FormatInt(order.OrderID, 10)
+
+	filledQty, _ := strconv.ParseFloat(order.FilledQty, 64)
+	if err := e.repo.UpdateOrderStatus(orderID, order.OrderStatus, filledQty); err != nil {
+		e.logger.Errorf("Failed to update order %s: %v", orderID, err)
+	}
+
+	if order.ExecutionType != "TRADE" {
+		return
+	}
+
+	protective := order.OrigType == "TAKE_PROFIT_MARKET" || order.OrigType == "STOP_MARKET"
+	position := e.positionForOrder(orderID, order.ClientOrderID, order.Symbol, order.ReduceOnly || protective)
+
+	tradeTime := time.UnixMilli(order.OrderTradeTime)
+	fill := &models.Fill{
+		AccountID:       e.accountID,
+		BinanceOrderID:  orderID,
+		TradeID:         order.TradeID,
+		Symbol:          order.Symbol,
+		Side:            order.Side,
+		CommissionAsset: order.CommissionAsset,
+		TradeTime:       tradeTime,
+	}
+	if position != nil {
+		fill.PositionID = position.ID
+	}
+	fill.Price, _ = strconv.ParseFloat(order.LastFilledPrice, 64)
+	fill.Quantity, _ = strconv.ParseFloat(order.LastFilledQty, 64)
+	fill.RealizedPnL, _ = strconv.ParseFloat(order.RealizedProfit, 64)
+	fill.Commission, _ = strconv.ParseFloat(order.Commission, 64)
+
+	if err := e.repo.SaveFill(fill); err != nil {
+		e.logger.Errorf("Failed to save fill of order %s: %v", orderID, err)
+	}
+
+	if !protective || order.OrderStatus != "FILLED" || position == nil || position.Status != "open" {
+		return
+	}
+
+	// The other protective order is left without a position
+	e.cancelProtectiveOrders(position)
+
+	exitPrice, _ := strconv.ParseFloat(order.AvgPrice, 64)
+	if exitPrice <= 0 {
+		exitPrice = fill.Price
+	}
+	if err := e.repo.ClosePosition(position.ID, exitPrice, tradeTime); err != nil {
+		e.logger.Errorf("Failed to close position %d: %v", position.ID, err)
+	}
+}
+
+// positionForOrder returns the position an order belongs to, or nil. Orders
+// are recorded asynchronously, so an order that is not recorded yet is
+// matched by its client order ID, and a closing order placed outside the
+// bot falls back to the account's open position in the symbol.
+func (e *OrderExecutor) positionForOrder(orderID, clientOrderID, symbol string, closing bool) *models.Position {
+	positionID, err := e.repo.GetOrderPositionID(orderID)
+	if err != nil {
+		e.logger.Errorf("Failed to get position of order %s: %v", orderID, err)
+	}
+	if positionID != 0 {
+		position, err := e.repo.GetPosition(positionID)
+		if err != nil {
+			e.logger.Errorf("Failed to get position %d: %v", positionID, err)
+		}
+		return position
+	}
+
+	if position := e.positionForClientOrderID(clientOrderID); position != nil {
+		return position
+	}
+
+	if !closing {
+		return nil
+	}
+
+	positions, err := e.repo.GetOpenPositions()
+	if err != nil {
+		e.logger.Errorf("Failed to get open positions: %v", err)
+		return nil
+	}
+	for _, position := range positions {
+		if position.AccountID == e.accountID && position.Symbol == symbol {
+			return position
+		}
+	}
+	return nil
+}
+
+// syncFills records a position's trades from the exchange, so its PnL and
+// commission come from the actual fills even when the user data stream is
+// down. orderIDs adds orders that may not be recorded yet, e.g. the close.
+func (e *OrderExecutor) syncFills(position *models.Position, orderIDs ...int64) {
+	ids := make(map[int64]bool)
+	for _, id := range orderIDs {
+		ids[id] = true
+	}
+
+	orders, err := e.repo.GetOrdersByPosition(position.ID)
+	if err != nil {
+		e.logger.Errorf("Failed to get orders of position %d: %v", position.ID, err)
+	}
+	for _, order := range orders {
+		if id, err := strconv.ParseInt(order.BinanceOrderID, 10, 64); err == nil {
+			ids[id] = true
+		}
+	}
+
+	end := time.Now()
+	start := position.OpenedAt.Add(-time.Minute)
+	if end.Sub(start) > fillLookback {
+		start = end.Add(-fillLookback)
+	}
+
+	trades, err := e.binanceClient.GetUserTrades(position.Symbol, start, end)
+	if err != nil {
+		e.logger.Warnf("Failed to get trades of position %d, PnL is estimated: %v", position.ID, err)
+		return
+	}
+
+	for _, trade := range trades {
+		if !ids[trade.OrderID] {
+			continue
+		}
+
+		fill := &models.Fill{
+			AccountID:       e.accountID,
+			PositionID:      position.ID,
+			BinanceOrderID:  strconv.FormatInt(trade.OrderID, 10),
+			TradeID:         trade.ID,
+			Symbol:          trade.Symbol,
+			Side:            trade.Side,
+			CommissionAsset: trade.CommissionAsset,
+			TradeTime:       time.UnixMilli(trade.Time),
+		}
+		fill.Price, _ = strconv.ParseFloat(trade.Price, 64)
+		fill.Quantity, _ = strconv.ParseFloat(trade.Qty, 64)
+		fill.RealizedPnL, _ = strconv.ParseFloat(trade.RealizedPnl, 64)
+		fill.Commission, _ = strconv.ParseFloat(trade.Commission, 64)
+
+		if err := e.repo.SaveFill(fill); err != nil {
+			e.logger.Errorf("Failed to save fill %d: %v", trade.ID, err)
+		}
+	}
+}
+
+// recordTimeoutClose closes the account's open position in a symbol after
+// the exchange position was closed because an order timed out. It runs with
+// ordersMu held, so the remaining orders are left to their own timeouts.
+func (e *OrderExecutor) recordTimeoutClose(symbol string, resp *binance.OrderResponse) {
+	position := e.positionForOrder(strconv.FormatInt(resp.OrderID, 10), resp.ClientOrderID, symbol, true)
+	if position == nil {
+		e.asyncLogOrder(0, resp, PurposeTimeout)
+		return
+	}
+	e.asyncLogOrder(position.ID, resp, PurposeTimeout)
+
+	exitPrice, _ := strconv.ParseFloat(resp.AvgPrice, 64)
+	if exitPrice <= 0 {
+		exitPrice = position.EntryPrice
+		if ticker, err := e.binanceClient.GetSymbolPriceTicker(symbol); err == nil {
+			if price, err := strconv.ParseFloat(ticker.Price, 64); err == nil {
+				exitPrice = price
+			}
+		}
+	}
+
+	if err := e.repo.ClosePosition(position.ID, exitPrice, time.Now()); err != nil {
+		e.logger.Errorf("Failed to close position %d: %v", position.ID, err)
+		return
+	}
+	e.syncFills(position, resp.OrderID)
+}
@@ -0,0 +1,133 @@
+package trading
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"tdlib-go/internal/binance"
+	"tdlib-go/pkg/models"
+)
+
+// incomeTypes are the income history types recorded for accounting
+var incomeTypes = []string{
+	models.IncomeRealizedPnL,
+	models.IncomeCommission,
+	models.IncomeFundingFee,
+	models.IncomeTransfer,
+}
+
+// incomePageSize is the largest page the income endpoint returns
+const incomePageSize = 1000
+
+// runIncomeSync periodically pulls the income history of every active account
+func (e *Engine) runIncomeSync() {
+	ticker := time.NewTicker(e.config.Trading.IncomeSync.SyncInterval())
+	defer ticker.Stop()
+
+	for {
+		e.SyncIncome()
+
+		select {
+		case <-ticker.C:
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+// SyncIncome pulls the new income history entries of every active account
+// and attributes them to positions. It returns the number of new entries.
+func (e *Engine) SyncIncome() int {
+	accounts, err := e.repo.GetActiveAccounts()
+	if err != nil {
+		e.logger.Errorf("Failed to get active accounts: %v", err)
+		return 0
+	}
+
+	total := 0
+	for _, account := range accounts {
+		client, exists := e.binanceClients[account.ID]
+		if !exists {
+			continue
+		}
+
+		saved, err := e.syncAccountIncome(account, client)
+		total += saved
+		if err != nil {
+			e.logger.Warnf("Failed to sync income of account %s: %v", account.Name, err)
+			continue
+		}
+
+		if saved > 0 {
+			e.logger.WithFields(logrus.Fields{
+				"account": account.Name,
+				"entries": saved,
+			}).Info("Income history synced")
+		}
+	}
+
+	return total
+}
+
+// syncAccountIncome pulls an account's income history of each recorded type
+// from its latest recorded entry on. Entries are recorded by tranId, so the
+// overlap at the page boundaries is ignored.
+func (e *Engine) syncAccountIncome(account *models.BinanceAccount, client *binance.Client) (int, error) {
+	saved := 0
+	for _, incomeType := range incomeTypes {
+		start, err := e.repo.GetLatestIncomeTime(account.ID, incomeType)
+		if err != nil {
+			return saved, err
+		}
+		if start.IsZero() {
+			start = time.Now().Add(-e.config.Trading.IncomeSync.Backfill())
+		}
+
+		for {
+			page, err := client.GetIncomeHistory(incomeType, start, incomePageSize)
+			if err != nil {
+				return saved, err
+			}
+
+			entries := make([]*models.Income, 0, len(page))
+			for _, entry := range page {
+				entries = append(entries, incomeFromBinance(account.ID, entry))
+			}
+
+			n, err := e.repo.SaveIncome(entries)
+			saved += n
+			if err != nil {
+				return saved, err
+			}
+
+			if len(page) < incomePageSize {
+				break
+			}
+			next := time.UnixMilli(page[len(page)-1].Time)
+			if !next.After(start) {
+				return saved, fmt.Errorf("more than %d %s entries at %s", incomePageSize, incomeType, start)
+			}
+			start = next
+		}
+	}
+
+	return saved, e.repo.AttributeIncome(account.ID)
+}
+
+// incomeFromBinance converts an income history entry of an account
+func incomeFromBinance(accountID int64, entry binance.Income) *models.Income {
+	income := &models.Income{
+		AccountID:  accountID,
+		TranID:     entry.TranID,
+		IncomeType: entry.IncomeType,
+		Symbol:     entry.Symbol,
+		Asset:      entry.Asset,
+		Info:       entry.Info,
+		TradeID:    entry.TradeID,
+		Time:       time.UnixMilli(entry.Time),
+	}
+	income.Income, _ = strconv.ParseFloat(entry.Income, 64)
+	return income
+}
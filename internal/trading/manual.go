@@ -0,0 +1,534 @@
+package trading
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"tdlib-go/internal/binance"
+	"tdlib-go/internal/events"
+	"tdlib-go/pkg/models"
+)
+
+// OpenManualTrade opens a position on each requested account, or on all
+// active accounts when none are given, through the same order path as
+// signals. Every order is recorded with the manual purpose.
+func (e *Engine) OpenManualTrade(trade *models.ManualTrade) ([]*models.ManualTradeResult, error) {
+	if !e.config.Trading.Enabled {
+		return nil, fmt.Errorf("%w: trading is disabled", models.ErrTradeRejected)
+	}
+
+	trade.Symbol = strings.ToUpper(strings.TrimSpace(trade.Symbol))
+	trade.Side = strings.ToUpper(strings.TrimSpace(trade.Side))
+	if e.parser != nil {
+		trade.Symbol = e.parser.normalizeSymbol(trade.Symbol)
+	}
+	if trade.Symbol == "" {
+		return nil, fmt.Errorf("%w: symbol is required", models.ErrTradeRejected)
+	}
+	if trade.Side != SideLong && trade.Side != SideShort {
+		return nil, fmt.Errorf("%w: side must be LONG or SHORT", models.ErrTradeRejected)
+	}
+	if trade.Amount < 0 || trade.Quantity < 0 || trade.TakeProfit < 0 || trade.StopLoss < 0 {
+		return nil, fmt.Errorf("%w: amount, quantity and prices must not be negative", models.ErrTradeRejected)
+	}
+	if trade.Leverage < 0 || trade.Leverage > 125 {
+		return nil, fmt.Errorf("%w: leverage must be between 1 and 125", models.ErrTradeRejected)
+	}
+
+	var accounts []*models.BinanceAccount
+	if len(trade.AccountIDs) == 0 {
+		active, err := e.repo.GetActiveAccounts()
+		if err != nil {
+			return nil, err
+		}
+		accounts = active
+	} else {
+		for _, id := range trade.AccountIDs {
+			account, err := e.repo.GetAccount(id)
+			if err != nil {
+				return nil, err
+			}
+			if account == nil {
+				return nil, fmt.Errorf("%w: account %d not found", models.ErrTradeRejected, id)
+			}
+			accounts = append(accounts, account)
+		}
+	}
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("%w: no active Binance accounts configured", models.ErrTradeRejected)
+	}
+
+	e.executeMu.Lock()
+	defer e.executeMu.Unlock()
+
+	if e.isStopping() {
+		return nil, fmt.Errorf("%w: trading engine is stopping", models.ErrTradeRejected)
+	}
+	if err := e.checkHalt(); err != nil {
+		return nil, fmt.Errorf("%w: %v", models.ErrTradeRejected, err)
+	}
+
+	results := make([]*models.ManualTradeResult, 0, len(accounts))
+	for _, account := range accounts {
+		result := &models.ManualTradeResult{AccountID: account.ID, AccountName: account.Name}
+		results = append(results, result)
+
+		if err := e.checkHalt(); err != nil {
+			result.Error = err.Error()
+			continue
+		}
+
+		executor, err := e.accountExecutor(account)
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+
+		e.logger.WithFields(logrus.Fields{
+			"account": account.Name,
+			"symbol":  trade.Symbol,
+			"side":    trade.Side,
+		}).Info("Opening manual trade")
+
+		position, err := executor.ExecuteManual(trade, account)
+		if err != nil {
+			e.logger.Errorf("Manual trade on account %s failed: %v", account.Name, err)
+			result.Error = err.Error()
+			executor.publishError(trade.Symbol, err)
+			continue
+		}
+		result.Position = position
+	}
+
+	return results, nil
+}
+
+// ClosePosition closes a position at market; a quantity below the
+// position's closes it partially
+func (e *Engine) ClosePosition(positionID int64, quantity float64) (*models.Position, error) {
+	position, executor, err := e.positionExecutor(positionID)
+	if err != nil {
+		return nil, err
+	}
+	return executor.ClosePosition(position, quantity)
+}
+
+// UpdatePositionTPSL replaces a position's take profit and/or stop loss
+func (e *Engine) UpdatePositionTPSL(positionID int64, takeProfit, stopLoss float64) (*models.Position, error) {
+	position, executor, err := e.positionExecutor(positionID)
+	if err != nil {
+		return nil, err
+	}
+	return executor.UpdateTPSL(position, takeProfit, stopLoss)
+}
+
+// CancelAllOrders cancels all open orders of a symbol on an account
+func (e *Engine) CancelAllOrders(accountID int64, symbol string) (int, error) {
+	account, err := e.repo.GetAccount(accountID)
+	if err != nil {
+		return 0, err
+	}
+	if account == nil {
+		return 0, fmt.Errorf("%w: account %d not found", models.ErrTradeRejected, accountID)
+	}
+
+	executor, err := e.accountExecutor(account)
+	if err != nil {
+		return 0, err
+	}
+
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if e.parser != nil {
+		symbol = e.parser.normalizeSymbol(symbol)
+	}
+	return executor.CancelAllOrders(symbol)
+}
+
+// positionExecutor loads a position and the executor of its account
+func (e *Engine) positionExecutor(positionID int64) (*models.Position, *OrderExecutor, error) {
+	if !e.config.Trading.Enabled {
+		return nil, nil, fmt.Errorf("%w: trading is disabled", models.ErrTradeRejected)
+	}
+
+	position, err := e.repo.GetPosition(positionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if position == nil {
+		return nil, nil, fmt.Errorf("%w: position %d not found", models.ErrTradeRejected, positionID)
+	}
+
+	account, err := e.repo.GetAccount(position.AccountID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if account == nil {
+		return nil, nil, fmt.Errorf("%w: account %d of position %d not found", models.ErrTradeRejected, position.AccountID, positionID)
+	}
+
+	executor, err := e.accountExecutor(account)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return position, executor, nil
+}
+
+// accountExecutor returns the executor of an account with a Binance client
+func (e *Engine) accountExecutor(account *models.BinanceAccount) (*OrderExecutor, error) {
+	client, exists := e.binanceClients[account.ID]
+	if !exists {
+		return nil, fmt.Errorf("%w: no Binance client for account %s (ID: %d), restart after adding accounts",
+			models.ErrTradeRejected, account.Name, account.ID)
+	}
+	return e.executorFor(account, client), nil
+}
+
+// ClosePosition closes part of a position at market, or all of it when
+// quantity is 0 or covers the position. A full close also cancels the
+// position's take profit and stop loss orders.
+func (e *OrderExecutor) ClosePosition(position *models.Position, quantity float64) (*models.Position, error) {
+	if position.Status != "open" {
+		return nil, fmt.Errorf("%w: position %d is %s", models.ErrTradeRejected, position.ID, position.Status)
+	}
+
+	filters, err := e.getSymbolFilters(position.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	full := quantity <= 0 || quantity >= position.Quantity
+	if full {
+		quantity = position.Quantity
+	} else {
+		quantity = e.roundQuantity(filters, quantity)
+		if quantity <= 0 {
+			return nil, fmt.Errorf("%w: quantity is below the lot size of %s", models.ErrTradeRejected, position.Symbol)
+		}
+	}
+
+	// Never close more than is open on the exchange
+	openAmt, err := e.exchangePositionAmount(position.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	quantity = math.Min(quantity, math.Abs(openAmt))
+
+	exitSide := "SELL"
+	if position.Side == SideShort {
+		exitSide = "BUY"
+	}
+
+	var exitPrice float64
+	var closeOrderID int64
+	if quantity > 0 {
+		resp, err := e.binanceClient.PlaceOrder(&binance.NewOrder{
+			Symbol:           position.Symbol,
+			Side:             exitSide,
+			Type:             "MARKET",
+			Quantity:         quantity,
+			ReduceOnly:       true,
+			NewClientOrderID: e.clientOrderID(positionRef(position.ID), orderKindClose),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to place close order: %w", err)
+		}
+		e.asyncLogOrder(position.ID, resp, PurposeManual)
+		exitPrice, _ = strconv.ParseFloat(resp.AvgPrice, 64)
+		closeOrderID = resp.OrderID
+	} else {
+		e.logger.Warnf("No open %s position on the exchange, closing position %d in the database only", position.Symbol, position.ID)
+	}
+
+	if exitPrice <= 0 {
+		ticker, err := e.binanceClient.GetSymbolPriceTicker(position.Symbol)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get price for %s: %w", position.Symbol, err)
+		}
+		exitPrice, _ = strconv.ParseFloat(ticker.Price, 64)
+	}
+
+	e.logger.WithFields(logrus.Fields{
+		"position_id": position.ID,
+		"symbol":      position.Symbol,
+		"quantity":    quantity,
+		"exit_price":  exitPrice,
+		"full":        full,
+	}).Info("Position closed manually")
+
+	if !full {
+		// The closed part's fills record its realized PnL on the position
+		remaining := e.roundQuantity(filters, position.Quantity-quantity)
+		if err := e.repo.PartiallyClosePosition(position.ID, remaining, exitPrice); err != nil {
+			return nil, err
+		}
+		e.syncFills(position, closeOrderID)
+		return e.repo.GetPosition(position.ID)
+	}
+
+	e.cancelProtectiveOrders(position)
+
+	closedAt := time.Now()
+	if err := e.repo.ClosePosition(position.ID, exitPrice, closedAt); err != nil {
+		return nil, err
+	}
+	e.syncFills(position, closeOrderID)
+
+	e.events.Publish(&events.Event{
+		Type:        events.PositionClosed,
+		AccountID:   e.accountID,
+		AccountName: e.accountName,
+		Symbol:      position.Symbol,
+		Side:        position.Side,
+		Price:       exitPrice,
+		Quantity:    quantity,
+		Reason:      PurposeManual,
+	})
+
+	return e.repo.GetPosition(position.ID)
+}
+
+// UpdateTPSL replaces a position's take profit and/or stop loss orders.
+// A zero price leaves that order unchanged. The new orders are placed
+// before the old ones are canceled so the position stays protected.
+func (e *OrderExecutor) UpdateTPSL(position *models.Position, takeProfit, stopLoss float64) (*models.Position, error) {
+	if position.Status != "open" {
+		return nil, fmt.Errorf("%w: position %d is %s", models.ErrTradeRejected, position.ID, position.Status)
+	}
+	if takeProfit == 0 && stopLoss == 0 {
+		return nil, fmt.Errorf("%w: take_profit or stop_loss is required", models.ErrTradeRejected)
+	}
+
+	ticker, err := e.binanceClient.GetSymbolPriceTicker(position.Symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price for %s: %w", position.Symbol, err)
+	}
+	price, err := strconv.ParseFloat(ticker.Price, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse price: %w", err)
+	}
+
+	direction, exitSide := 1.0, "SELL"
+	if position.Side == SideShort {
+		direction, exitSide = -1.0, "BUY"
+	}
+	if takeProfit != 0 && (takeProfit-price)*direction <= 0 {
+		return nil, fmt.Errorf("%w: take profit %.8g is on the wrong side of the price %.8g for a %s",
+			models.ErrTradeRejected, takeProfit, price, position.Side)
+	}
+	if stopLoss != 0 && (price-stopLoss)*direction <= 0 {
+		return nil, fmt.Errorf("%w: stop loss %.8g is on the wrong side of the price %.8g for a %s",
+			models.ErrTradeRejected, stopLoss, price, position.Side)
+	}
+
+	filters, err := e.getSymbolFilters(position.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := e.repo.GetOrdersByPosition(position.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	replace := []struct {
+		name      string
+		orderType string
+		kind      string
+		price     *float64
+		newPrice  float64
+	}{
+		{"take profit", "TAKE_PROFIT_MARKET", orderKindTakeProfit, &position.TakeProfitPrice, takeProfit},
+		{"stop loss", "STOP_MARKET", orderKindStopLoss, &position.StopLossPrice, stopLoss},
+	}
+	ref := positionRef(position.ID)
+
+	// Each replaced leg is saved before the next, so a failure leaves the
+	// database matching the orders on the exchange
+	var applied string
+
+	for _, r := range replace {
+		if r.newPrice == 0 {
+			continue
+		}
+		newPrice := e.roundPrice(filters, r.newPrice)
+
+		resp, err := e.binanceClient.PlaceOrder(&binance.NewOrder{
+			Symbol:           position.Symbol,
+			Side:             exitSide,
+			Type:             r.orderType,
+			StopPrice:        newPrice,
+			Quantity:         position.Quantity,
+			ReduceOnly:       true,
+			NewClientOrderID: e.clientOrderID(ref, r.kind),
+		})
+		if err != nil {
+			if applied != "" {
+				return nil, fmt.Errorf("%s, but failed to place %s order: %w", applied, r.orderType, err)
+			}
+			return nil, fmt.Errorf("failed to place %s order: %w", r.orderType, err)
+		}
+		e.asyncLogOrder(position.ID, resp, PurposeManual)
+		newID := strconv.FormatInt(resp.OrderID, 10)
+
+		for _, order := range orders {
+			if order.Type != r.orderType || !isOpenStatus(order.Status) || order.BinanceOrderID == newID {
+				continue
+			}
+			e.cancelRecordedOrder(order)
+			e.retrackOrder(order.BinanceOrderID, newID)
+		}
+
+		*r.price = newPrice
+		if err := e.repo.UpdatePositionTPSL(position.ID, position.TakeProfitPrice, position.StopLossPrice); err != nil {
+			return nil, fmt.Errorf("%s order placed, but failed to save it: %w", r.name, err)
+		}
+		applied = fmt.Sprintf("%s updated to %.8g", r.name, newPrice)
+	}
+
+	e.logger.WithFields(logrus.Fields{
+		"position_id": position.ID,
+		"symbol":      position.Symbol,
+		"take_profit": position.TakeProfitPrice,
+		"stop_loss":   position.StopLossPrice,
+	}).Info("Position TP/SL updated")
+
+	return position, nil
+}
+
+// CancelAllOrders cancels all open orders of a symbol. Orders already in
+// the orders table are marked canceled; others are recorded as manual.
+func (e *OrderExecutor) CancelAllOrders(symbol string) (int, error) {
+	open, err := e.binanceClient.GetOpenOrders(symbol)
+	if err != nil {
+		return 0, err
+	}
+	if len(open) == 0 {
+		return 0, nil
+	}
+
+	if err := e.binanceClient.CancelAllOpenOrders(symbol); err != nil {
+		return 0, err
+	}
+
+	// Link unrecorded orders to the account's open position, if any
+	var positionID int64
+	if positions, err := e.repo.GetOpenPositions(); err == nil {
+		for _, position := range positions {
+			if position.AccountID == e.accountID && position.Symbol == symbol {
+				positionID = position.ID
+				break
+			}
+		}
+	}
+
+	for i := range open {
+		order := &open[i]
+		orderID := strconv.FormatInt(order.OrderID, 10)
+		executedQty, _ := strconv.ParseFloat(order.ExecutedQty, 64)
+
+		exists, err := e.repo.OrderExists(orderID)
+		if err != nil {
+			e.logger.Errorf("Failed to check order %s: %v", orderID, err)
+		} else if exists {
+			if err := e.repo.UpdateOrderStatus(orderID, "CANCELED", executedQty); err != nil {
+				e.logger.Errorf("Failed to update order %s: %v", orderID, err)
+			}
+		} else {
+			order.Status = "CANCELED"
+			e.asyncLogOrder(positionID, order, PurposeManual)
+		}
+
+		e.untrackOrder(orderID)
+	}
+
+	e.logger.Infof("Canceled %d open orders for %s", len(open), symbol)
+
+	return len(open), nil
+}
+
+// exchangePositionAmount returns the signed position amount of a symbol
+func (e *OrderExecutor) exchangePositionAmount(symbol string) (float64, error) {
+	positions, err := e.binanceClient.GetPositions()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get positions: %w", err)
+	}
+
+	for _, pos := range positions {
+		if pos.Symbol == symbol {
+			amount, _ := strconv.ParseFloat(pos.PositionAmt, 64)
+			return amount, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// cancelProtectiveOrders cancels the open take profit and stop loss orders of a position
+func (e *OrderExecutor) cancelProtectiveOrders(position *models.Position) {
+	orders, err := e.repo.GetOrdersByPosition(position.ID)
+	if err != nil {
+		e.logger.Errorf("Failed to get orders of position %d: %v", position.ID, err)
+		return
+	}
+
+	for _, order := range orders {
+		if (order.Type == "TAKE_PROFIT_MARKET" || order.Type == "STOP_MARKET") && isOpenStatus(order.Status) {
+			e.cancelRecordedOrder(order)
+			e.untrackOrder(order.BinanceOrderID)
+		}
+	}
+}
+
+// cancelRecordedOrder cancels an order from the orders table and records
+// its resulting status
+func (e *OrderExecutor) cancelRecordedOrder(order *models.Order) {
+	orderID, _ := strconv.ParseInt(order.BinanceOrderID, 10, 64)
+	resp, err := e.binanceClient.CancelOrder(order.Symbol, orderID)
+	if err != nil {
+		// Record the order's final state instead, e.g. EXPIRED once the position closed
+		resp, err = e.binanceClient.QueryOrder(order.Symbol, orderID)
+		if err != nil {
+			e.logger.Warnf("Failed to cancel order %s: %v", order.BinanceOrderID, err)
+			return
+		}
+	}
+
+	executedQty, _ := strconv.ParseFloat(resp.ExecutedQty, 64)
+	if err := e.repo.UpdateOrderStatus(order.BinanceOrderID, resp.Status, executedQty); err != nil {
+		e.logger.Errorf("Failed to update order %s: %v", order.BinanceOrderID, err)
+	}
+}
+
+// untrackOrder removes an order from the timeout tracker
+func (e *OrderExecutor) untrackOrder(orderID string) {
+	e.ordersMu.Lock()
+	delete(e.pendingOrders, orderID)
+	e.ordersMu.Unlock()
+}
+
+// retrackOrder moves an order's timeout to its replacement, keeping the
+// original deadline
+func (e *OrderExecutor) retrackOrder(oldID, newID string) {
+	e.ordersMu.Lock()
+	defer e.ordersMu.Unlock()
+
+	timeout, exists := e.pendingOrders[oldID]
+	if !exists {
+		return
+	}
+	delete(e.pendingOrders, oldID)
+
+	replacement := *timeout
+	replacement.OrderID = newID
+	e.pendingOrders[newID] = &replacement
+}
+
+// isOpenStatus reports whether an order status is still working
+func isOpenStatus(status string) bool {
+	return status == "NEW" || status == "PARTIALLY_FILLED"
+}
@@ -0,0 +1,86 @@
+package trading
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tdlib-go/pkg/models"
+)
+
+// clientOrderPrefix starts the client order IDs of orders placed by the bot
+const clientOrderPrefix = "tg"
+
+// Kinds of orders in client order IDs
+const (
+	orderKindEntry      = "en"
+	orderKindTakeProfit = "tp"
+	orderKindStopLoss   = "sl"
+	orderKindClose      = "cl"
+	orderKindFlatten    = "fl"
+)
+
+// tradeRef returns the reference of a trade's orders: its signal, or a
+// timestamp for manual trades and signals that were not saved
+func tradeRef(signal *models.Signal) string {
+	if signal != nil && signal.ID != 0 {
+		return "s" + strconv.FormatInt(signal.ID, 10)
+	}
+	return "t" + strconv.FormatInt(time.Now().UnixMilli(), 36)
+}
+
+// positionRef returns the reference of an order placed for an existing
+// position. Actions on a position repeat, so it is made unique with a
+// timestamp.
+func positionRef(positionID int64) string {
+	if positionID == 0 {
+		return tradeRef(nil)
+	}
+	return "p" + strconv.FormatInt(positionID, 10) + "." + strconv.FormatInt(time.Now().UnixMilli(), 36)
+}
+
+// clientOrderID returns the client order ID of an order: the same trade,
+// account and kind always give the same ID, so a placement whose outcome is
+// unknown is looked up instead of repeated. IDs stay within Binance's 36
+// characters of [.A-Z:/a-z0-9_-].
+func (e *OrderExecutor) clientOrderID(ref, kind string) string {
+	return fmt.Sprintf("%s-%s-a%d-%s", clientOrderPrefix, ref, e.accountID, kind)
+}
+
+// positionForClientOrderID returns the position of one of the account's
+// orders from its client order ID, or nil when the ID was not made by
+// clientOrderID or its position is not recorded yet
+func (e *OrderExecutor) positionForClientOrderID(clientOrderID string) *models.Position {
+	parts := strings.Split(clientOrderID, "-")
+	if len(parts) != 4 || parts[0] != clientOrderPrefix || parts[2] != "a"+strconv.FormatInt(e.accountID, 10) {
+		return nil
+	}
+
+	ref, _, _ := strings.Cut(parts[1], ".")
+	if len(ref) < 2 {
+		return nil
+	}
+	id, err := strconv.ParseInt(ref[1:], 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	var position *models.Position
+	switch ref[0] {
+	case 'p':
+		position, err = e.repo.GetPosition(id)
+	case 's':
+		position, err = e.repo.GetSignalPosition(e.accountID, id)
+	default:
+		return nil
+	}
+	if err != nil {
+		e.logger.Errorf("Failed to get position of order %s: %v", clientOrderID, err)
+		return nil
+	}
+	if position != nil && position.AccountID != e.accountID {
+		return nil
+	}
+	return position
+}
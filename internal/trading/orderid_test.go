@@ -0,0 +1,33 @@
+package trading
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"tdlib-go/pkg/models"
+)
+
+func TestLostEntryResponseIsNotPlacedTwice(t *testing.T) {
+	te := newTestExecutor(t, &models.BinanceAccount{})
+	te.srv.InjectLostResponse(http.MethodPost, "/fapi/v1/order", 1)
+
+	position := te.executeSignal(t)
+
+	entries := te.ordersOfType("MARKET")
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want the lost one looked up and not placed again", len(entries))
+	}
+
+	prefix := fmt.Sprintf("tg-s1-a%d-", te.account.ID)
+	for kind, orderType := range map[string]string{"en": "MARKET", "tp": "TAKE_PROFIT_MARKET", "sl": "STOP_MARKET"} {
+		orders := te.ordersOfType(orderType)
+		if len(orders) != 1 || orders[0].ClientOrderID != prefix+kind {
+			t.Errorf("%s orders = %+v, want one with client order ID %s", orderType, orders, prefix+kind)
+			continue
+		}
+		if found := te.positionForClientOrderID(orders[0].ClientOrderID); found == nil || found.ID != position.ID {
+			t.Errorf("position of %s = %+v, want position %d", orders[0].ClientOrderID, found, position.ID)
+		}
+	}
+}
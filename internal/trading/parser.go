@@ -3,6 +3,7 @@ package trading
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -32,6 +33,43 @@ func NewSignalParser(cfg *config.Config, logger *logrus.Logger) (*SignalParser,
 
 // Parse attempts to parse a trading signal from a message
 func (p *SignalParser) Parse(msg *models.Message) (*models.Signal, error) {
+	signal, err := p.parse(msg)
+	if signal == nil || err != nil {
+		return signal, err
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"channel_id": msg.ChannelID,
+		"message_id": msg.MessageID,
+		"symbol":     signal.Symbol,
+	}).Info("Trading signal detected")
+
+	return signal, nil
+}
+
+// ParseHistorical parses a signal from an imported history message. The
+// signal is dated at the message time and marked as historical so it is
+// never executed.
+func (p *SignalParser) ParseHistorical(msg *models.Message) (*models.Signal, error) {
+	signal, err := p.parse(msg)
+	if signal == nil || err != nil {
+		return signal, err
+	}
+
+	signal.ParsedAt = msg.Timestamp
+	signal.Status = "historical"
+
+	p.logger.WithFields(logrus.Fields{
+		"channel_id": msg.ChannelID,
+		"message_id": msg.MessageID,
+		"symbol":     signal.Symbol,
+	}).Debug("Historical signal detected")
+
+	return signal, nil
+}
+
+// parse extracts a signal from a message without logging
+func (p *SignalParser) parse(msg *models.Message) (*models.Signal, error) {
 	if msg.Text == "" {
 		return nil, nil
 	}
@@ -53,24 +91,33 @@ func (p *SignalParser) Parse(msg *models.Message) (*models.Signal, error) {
 	// Normalize symbol for Binance Futures (ensure it ends with USDT)
 	symbol = p.normalizeSymbol(symbol)
 
-	p.logger.WithFields(logrus.Fields{
-		"channel_id": msg.ChannelID,
-		"message_id": msg.MessageID,
-		"symbol":     symbol,
-	}).Info("Trading signal detected")
-
 	signal := &models.Signal{
 		MessageID:  msg.MessageID,
 		ChannelID:  msg.ChannelID,
 		Symbol:     symbol,
 		RawMessage: msg.Text,
+		PostedAt:   msg.Timestamp,
 		ParsedAt:   time.Now(),
 		Status:     "pending",
 	}
 
+	// An optional (?P<entry>...) group gives the entry price of limit entries
+	if i := p.pattern.SubexpIndex("entry"); i > 0 && i < len(matches) {
+		price := strings.ReplaceAll(strings.TrimSpace(matches[i]), ",", "")
+		if entry, err := strconv.ParseFloat(price, 64); err == nil && entry > 0 {
+			signal.EntryPrice = entry
+		}
+	}
+
 	return signal, nil
 }
 
+// Matches reports whether text contains a signal symbol, without logging it
+func (p *SignalParser) Matches(text string) bool {
+	matches := p.pattern.FindStringSubmatch(text)
+	return len(matches) > 1 && strings.TrimSpace(matches[1]) != ""
+}
+
 // normalizeSymbol normalizes a symbol for Binance Futures
 func (p *SignalParser) normalizeSymbol(symbol string) string {
 	// Remove common prefixes/suffixes
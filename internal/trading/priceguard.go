@@ -0,0 +1,102 @@
+package trading
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"tdlib-go/internal/config"
+	"tdlib-go/pkg/models"
+)
+
+// checkSignalAge returns an error when a signal's message is older than the
+// price guard's max age, e.g. after catching up on a backlog
+func checkSignalAge(guard *config.PriceGuardConfig, signal *models.Signal) error {
+	maxAge := guard.MaxAge()
+	if maxAge == 0 || signal.PostedAt.IsZero() {
+		return nil
+	}
+	if age := time.Since(signal.PostedAt); age > maxAge {
+		return fmt.Errorf("signal message is %s old, over the max age of %s", age.Round(time.Second), maxAge)
+	}
+	return nil
+}
+
+// checkPriceGuard compares the current price of a signal trade with the
+// price when its message was posted and with the signal's entry price. It
+// returns the fraction of the size to trade: 1 when the price has not run
+// further than the guard allows, smaller when downsizing, or an error when
+// the signal is skipped.
+func (e *OrderExecutor) checkPriceGuard(params *tradeParams, price float64) (float64, error) {
+	guard := &e.config.Trading.PriceGuard
+	if params.signal == nil {
+		return 1, nil
+	}
+
+	// Signals can wait for earlier ones, so the age is checked again
+	if err := checkSignalAge(guard, params.signal); err != nil {
+		return 0, err
+	}
+	if guard.MaxMove <= 0 {
+		return 1, nil
+	}
+
+	direction := 1.0
+	if params.side == SideShort {
+		direction = -1.0
+	}
+
+	var move float64
+	var from string
+	if params.signal.EntryPrice > 0 {
+		move = (price - params.signal.EntryPrice) / params.signal.EntryPrice * direction
+		from = "the signal's entry price"
+	}
+	if !params.signal.PostedAt.IsZero() {
+		// The price guard does not block trading when history is unavailable
+		if posted, err := e.priceAt(params.symbol, params.signal.PostedAt); err != nil {
+			e.logger.Warnf("Failed to get the price of %s when the signal was posted: %v", params.symbol, err)
+		} else if posted > 0 {
+			if m := (price - posted) / posted * direction; m > move {
+				move, from = m, "the signal"
+			}
+		}
+	}
+
+	if move <= guard.MaxMove {
+		return 1, nil
+	}
+
+	if guard.Action != config.PriceGuardDownsize {
+		return 0, fmt.Errorf("price of %s moved %.2f%% since %s, over the %.2f%% limit",
+			params.symbol, move*100, from, guard.MaxMove*100)
+	}
+
+	factor := guard.MaxMove / move
+	e.logger.WithFields(logrus.Fields{
+		"symbol": params.symbol,
+		"move":   fmt.Sprintf("%.2f%%", move*100),
+		"factor": factor,
+	}).Warnf("Price moved since %s, downsizing the position", from)
+	return factor, nil
+}
+
+// priceAt returns the price at a time: the close of the 1m kline before the
+// one containing it, or 0 when the exchange has no kline for it. The open of
+// the containing kline would overstate the move of a message posted late in
+// its minute; the previous close is the last price known when it was posted.
+func (e *OrderExecutor) priceAt(symbol string, at time.Time) (float64, error) {
+	klines, err := e.binanceClient.GetKlines(symbol, "1m", at.Truncate(time.Minute).Add(-time.Minute), time.Time{}, 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(klines) == 0 {
+		return 0, nil
+	}
+	price, err := strconv.ParseFloat(klines[0].Close, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse kline price: %w", err)
+	}
+	return price, nil
+}
@@ -0,0 +1,64 @@
+package trading
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"tdlib-go/internal/config"
+	"tdlib-go/pkg/models"
+)
+
+// postedSignal returns a BTCUSDT signal posted 30s into a minute ten minutes
+// ago, when the price had moved from 100 to 101 by the close of the minute
+// before. The price is 105.05 now, a 4% move since the signal.
+func postedSignal(te *testExecutor) *models.Signal {
+	posted := time.Now().Add(-10 * time.Minute).Truncate(time.Minute).Add(30 * time.Second)
+	previous := posted.Truncate(time.Minute).Add(-time.Minute)
+	te.srv.SetPriceHistory("BTCUSDT", previous.Add(10*time.Second), 100)
+	te.srv.SetPriceHistory("BTCUSDT", previous.Add(50*time.Second), 101)
+	te.srv.SetPrice("BTCUSDT", 105.05)
+	return &models.Signal{ID: 1, Symbol: "BTCUSDT", PostedAt: posted}
+}
+
+func TestPriceGuardSkipsSignalAfterTheMove(t *testing.T) {
+	te := newTestExecutor(t, &models.BinanceAccount{})
+	te.config.Trading.PriceGuard = config.PriceGuardConfig{MaxMove: 0.02}
+
+	err := te.ExecuteSignal(postedSignal(te), te.account)
+	if err == nil || !strings.Contains(err.Error(), "moved 4.01%") {
+		t.Fatalf("ExecuteSignal error = %v, want the 4.01%% move from 101 rejected", err)
+	}
+	if orders := te.srv.Orders(); len(orders) != 0 {
+		t.Errorf("placed %d orders, want none", len(orders))
+	}
+}
+
+func TestPriceGuardDownsizesSignalAfterTheMove(t *testing.T) {
+	te := newTestExecutor(t, &models.BinanceAccount{})
+	te.config.Trading.PriceGuard = config.PriceGuardConfig{MaxMove: 0.02, Action: config.PriceGuardDownsize}
+
+	if err := te.ExecuteSignal(postedSignal(te), te.account); err != nil {
+		t.Fatalf("ExecuteSignal: %v", err)
+	}
+
+	// 100 USDT at 105.05 is 0.952, half of it for a move twice the limit
+	positions := te.openPositions(t)
+	if len(positions) != 1 || !approxEqual(positions[0].Quantity, 0.475) {
+		t.Fatalf("open positions = %+v, want one of 0.475", positions)
+	}
+}
+
+func TestPriceGuardSkipsDownsizeBelowMinNotional(t *testing.T) {
+	te := newTestExecutor(t, &models.BinanceAccount{OrderAmount: 8})
+	te.config.Trading.PriceGuard = config.PriceGuardConfig{MaxMove: 0.02, Action: config.PriceGuardDownsize}
+
+	// Half of 8 USDT is below the minimum notional of 5
+	err := te.ExecuteSignal(postedSignal(te), te.account)
+	if err == nil || !strings.Contains(err.Error(), "below the minimum notional") {
+		t.Fatalf("ExecuteSignal error = %v, want the downsized trade skipped", err)
+	}
+	if orders := te.srv.Orders(); len(orders) != 0 {
+		t.Errorf("placed %d orders, want none", len(orders))
+	}
+}
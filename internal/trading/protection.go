@@ -0,0 +1,201 @@
+package trading
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"tdlib-go/internal/binance"
+	"tdlib-go/internal/events"
+	"tdlib-go/pkg/models"
+)
+
+// protectRetryDelay is the delay before the first retry of a failed TP/SL or
+// flatten order, growing with each attempt
+const protectRetryDelay = 500 * time.Millisecond
+
+// protectiveOrder returns a take profit or stop loss order of a filled entry
+func (e *OrderExecutor) protectiveOrder(ref, kind, symbol, exitSide, orderType string, stopPrice, quantity float64) *binance.NewOrder {
+	return &binance.NewOrder{
+		Symbol:           symbol,
+		Side:             exitSide,
+		Type:             orderType,
+		StopPrice:        stopPrice,
+		Quantity:         quantity,
+		ReduceOnly:       true,
+		ClosePosition:    e.config.Trading.Protection.ClosePosition,
+		NewClientOrderID: e.clientOrderID(ref, kind),
+	}
+}
+
+// placeProtectiveOrders places the take profit and stop loss orders of a
+// filled entry in parallel, retrying the failed ones. The orders that were
+// placed are returned with the errors of those that could not be.
+func (e *OrderExecutor) placeProtectiveOrders(ref, symbol, exitSide string, quantity, takeProfitPrice, stopLossPrice float64) (
+	tpResp, slResp *binance.OrderResponse, errors []error) {
+	attempts := e.config.Trading.Protection.Attempts()
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			e.logger.Warnf("Retrying protective orders on %s (attempt %d of %d): %v", symbol, attempt+1, attempts, errors)
+			time.Sleep(time.Duration(attempt) * protectRetryDelay)
+		}
+
+		var wg sync.WaitGroup
+		var tpErr, slErr error
+
+		// Take Profit order (TAKE_PROFIT_MARKET)
+		if tpResp == nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				tpResp, tpErr = e.binanceClient.PlaceOrder(e.protectiveOrder(ref, orderKindTakeProfit, symbol, exitSide,
+					"TAKE_PROFIT_MARKET", takeProfitPrice, quantity))
+			}()
+		}
+
+		// Stop Loss order (STOP_MARKET)
+		if slResp == nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				slResp, slErr = e.binanceClient.PlaceOrder(e.protectiveOrder(ref, orderKindStopLoss, symbol, exitSide,
+					"STOP_MARKET", stopLossPrice, quantity))
+			}()
+		}
+
+		wg.Wait()
+
+		errors = nil
+		if tpErr != nil {
+			errors = append(errors, fmt.Errorf("take profit order failed: %w", tpErr))
+		}
+		if slErr != nil {
+			errors = append(errors, fmt.Errorf("stop loss order failed: %w", slErr))
+		}
+		if len(errors) == 0 {
+			break
+		}
+	}
+	return tpResp, slResp, errors
+}
+
+// flattenUnprotected closes a filled entry whose take profit or stop loss
+// could not be placed, rather than leave it open without protection, and
+// records the close on its position. When the close fails as well, the
+// position stays open with whatever protection was placed.
+func (e *OrderExecutor) flattenUnprotected(params *tradeParams, account *models.BinanceAccount, position *models.Position,
+	fill *entryFill, exitSide string, tpResp, slResp *binance.OrderResponse, protectErrs []error) error {
+	protectErr := fmt.Errorf("protective orders failed: %v", protectErrs)
+	e.logger.WithFields(logrus.Fields{
+		"symbol":   params.symbol,
+		"quantity": fill.quantity,
+	}).Errorf("Flattening unprotected position: %v", protectErr)
+
+	var positionID int64
+	if position != nil {
+		positionID = position.ID
+	}
+	for _, order := range fill.orders {
+		e.asyncLogOrder(positionID, order, params.orderPurpose("entry"))
+	}
+
+	placed := []struct {
+		resp    *binance.OrderResponse
+		purpose string
+	}{{tpResp, "take_profit"}, {slResp, "stop_loss"}}
+
+	attempts := e.config.Trading.Protection.Attempts()
+	var closeResp *binance.OrderResponse
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * protectRetryDelay)
+		}
+		closeResp, err = e.binanceClient.PlaceOrder(&binance.NewOrder{
+			Symbol:           params.symbol,
+			Side:             exitSide,
+			Type:             "MARKET",
+			Quantity:         fill.quantity,
+			ReduceOnly:       true,
+			NewClientOrderID: e.clientOrderID(params.ref, orderKindFlatten),
+		})
+		if err == nil {
+			break
+		}
+		e.logger.Errorf("Failed to flatten %s (attempt %d of %d): %v", params.symbol, attempt+1, attempts, err)
+	}
+
+	if err != nil {
+		// The protective order that was placed guards the position until it is closed by hand
+		for _, protective := range placed {
+			if protective.resp == nil {
+				continue
+			}
+			e.asyncLogOrder(positionID, protective.resp, params.orderPurpose(protective.purpose))
+			e.addOrderTimeout(strconv.FormatInt(protective.resp.OrderID, 10), params.symbol, protective.purpose,
+				fill.quantity, positionID, params.ref, account.OrderTimeout)
+		}
+		err = fmt.Errorf("%w, and flattening the position failed, it is still open: %v", protectErr, err)
+		e.publishError(params.symbol, err)
+		return err
+	}
+	e.asyncLogOrder(positionID, closeResp, PurposeFlatten)
+
+	// The protective order that was placed would be left without a position
+	for _, protective := range placed {
+		if protective.resp == nil {
+			continue
+		}
+		if resp, err := e.binanceClient.CancelOrder(params.symbol, protective.resp.OrderID); err != nil {
+			e.logger.Errorf("Failed to cancel %s order %d on %s: %v", protective.purpose, protective.resp.OrderID, params.symbol, err)
+		} else {
+			protective.resp = resp
+		}
+		e.asyncLogOrder(positionID, protective.resp, params.orderPurpose(protective.purpose))
+	}
+
+	exitPrice, _ := strconv.ParseFloat(closeResp.AvgPrice, 64)
+	if exitPrice <= 0 {
+		exitPrice = fill.price
+		if ticker, err := e.binanceClient.GetSymbolPriceTicker(params.symbol); err == nil {
+			if price, err := strconv.ParseFloat(ticker.Price, 64); err == nil {
+				exitPrice = price
+			}
+		}
+	}
+
+	e.logger.WithFields(logrus.Fields{
+		"symbol":     params.symbol,
+		"quantity":   fill.quantity,
+		"exit_price": exitPrice,
+	}).Warn("Unprotected position flattened")
+
+	if position != nil {
+		if err := e.repo.ClosePosition(position.ID, exitPrice, time.Now()); err != nil {
+			e.logger.Errorf("Failed to close position %d: %v", position.ID, err)
+		} else {
+			orderIDs := []int64{closeResp.OrderID}
+			for _, order := range fill.orders {
+				orderIDs = append(orderIDs, order.OrderID)
+			}
+			e.syncFills(position, orderIDs...)
+		}
+	}
+
+	e.events.Publish(&events.Event{
+		Type:        events.PositionClosed,
+		AccountID:   account.ID,
+		AccountName: account.Name,
+		ChannelID:   params.channelID(),
+		Symbol:      params.symbol,
+		Side:        params.side,
+		Price:       exitPrice,
+		Quantity:    fill.quantity,
+		Reason:      PurposeFlatten,
+	})
+
+	return fmt.Errorf("%w, position flattened", protectErr)
+}
@@ -0,0 +1,92 @@
+package trading
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"tdlib-go/internal/binance/binancetest"
+	"tdlib-go/pkg/models"
+)
+
+// rejectProtection makes the take profit and stop loss orders of the next
+// market entry fail times times each. Fills are held until the entry is
+// placed, so only the orders placed after it are rejected.
+func rejectProtection(t *testing.T, te *testExecutor, times int) <-chan struct{} {
+	t.Helper()
+	te.srv.HoldFills(true)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			for _, order := range te.ordersOfType("MARKET") {
+				if order.Status == binancetest.StatusNew {
+					te.srv.HoldFills(false)
+					te.srv.InjectError("POST", "/fapi/v1/order", 2*times, -2021, "Order would immediately trigger.")
+					if err := te.srv.FillOrder(order.OrderID, 0); err != nil {
+						t.Errorf("FillOrder: %v", err)
+					}
+					return
+				}
+			}
+		}
+		t.Error("no market entry was placed")
+	}()
+	return done
+}
+
+func TestFailedProtectionFlattensPosition(t *testing.T) {
+	te := newTestExecutor(t, &models.BinanceAccount{})
+	te.config.Trading.Protection.Retries = 1
+	filled := rejectProtection(t, te, 2)
+
+	err := te.ExecuteSignal(&models.Signal{ID: 1, Symbol: "BTCUSDT"}, te.account)
+	<-filled
+	if err == nil || !strings.Contains(err.Error(), "position flattened") {
+		t.Fatalf("ExecuteSignal error = %v, want the position flattened", err)
+	}
+
+	if amount := te.srv.Position("BTCUSDT").Amount; amount != 0 {
+		t.Errorf("exchange position = %v, want it flattened", amount)
+	}
+	if protective := append(te.ordersOfType("TAKE_PROFIT_MARKET"), te.ordersOfType("STOP_MARKET")...); len(protective) != 0 {
+		t.Errorf("got %d protective orders, want all rejected", len(protective))
+	}
+	if positions := te.openPositions(t); len(positions) != 0 {
+		t.Errorf("got %d open positions, want the flattened position closed", len(positions))
+	}
+
+	te.close()
+	positions, err := te.repo.GetAllPositions(10)
+	if err != nil || len(positions) != 1 {
+		t.Fatalf("GetAllPositions = %d positions, %v", len(positions), err)
+	}
+	orders, err := te.repo.GetOrdersByPosition(positions[0].ID)
+	if err != nil {
+		t.Fatalf("GetOrdersByPosition: %v", err)
+	}
+	var flattened bool
+	for _, order := range orders {
+		flattened = flattened || order.OrderPurpose == PurposeFlatten
+	}
+	if !flattened {
+		t.Error("no flatten order recorded for the position")
+	}
+}
+
+func TestRetriedProtectionKeepsPosition(t *testing.T) {
+	te := newTestExecutor(t, &models.BinanceAccount{})
+	te.config.Trading.Protection.Retries = 1
+	filled := rejectProtection(t, te, 1)
+
+	position := te.executeSignal(t)
+	<-filled
+
+	if amount := te.srv.Position("BTCUSDT").Amount; !approxEqual(amount, position.Quantity) {
+		t.Errorf("exchange position = %v, want %v", amount, position.Quantity)
+	}
+	tp, sl := te.ordersOfType("TAKE_PROFIT_MARKET"), te.ordersOfType("STOP_MARKET")
+	if len(tp) != 1 || len(sl) != 1 {
+		t.Errorf("got %d take profit and %d stop loss orders, want both placed on retry", len(tp), len(sl))
+	}
+}
@@ -0,0 +1,121 @@
+package trading
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"tdlib-go/pkg/models"
+)
+
+// positionSize is the unrounded quantity of a trade and how it was sized
+type positionSize struct {
+	quantity float64
+	mode     string
+	input    float64 // Order amount, balance fraction, risk or quantity, per mode
+	balance  float64 // Available balance the size was taken from, 0 when not used
+}
+
+// sizePosition computes the quantity of a trade. An explicit quantity or
+// amount takes precedence, otherwise the account's sizing mode decides.
+// The size is capped by the notional the leverage bracket allows.
+func (e *OrderExecutor) sizePosition(params *tradeParams, account *models.BinanceAccount,
+	entryPrice, stopLossPrice float64, leverage int) (*positionSize, error) {
+	mode := account.SizingMode
+	if mode == "" {
+		mode = models.SizingFixedNotional
+	}
+
+	var size *positionSize
+	switch {
+	case params.quantity > 0:
+		size = &positionSize{quantity: params.quantity, mode: models.SizingQuantity, input: params.quantity}
+	case params.amount > 0:
+		size = &positionSize{quantity: params.amount / entryPrice, mode: models.SizingFixedNotional, input: params.amount}
+	case mode == models.SizingFixedNotional:
+		if account.OrderAmount <= 0 {
+			return nil, fmt.Errorf("invalid order amount %.2f for account %s (must be greater than 0)", account.OrderAmount, account.Name)
+		}
+		size = &positionSize{quantity: account.OrderAmount / entryPrice, mode: mode, input: account.OrderAmount}
+	case mode == models.SizingBalancePercent:
+		if account.BalancePercent <= 0 || account.BalancePercent > 1 {
+			return nil, fmt.Errorf("invalid balance percent %.4f for account %s (must be between 0 and 1)", account.BalancePercent, account.Name)
+		}
+		balance, err := e.availableBalance()
+		if err != nil {
+			return nil, err
+		}
+		margin := balance * account.BalancePercent
+		size = &positionSize{
+			quantity: margin * float64(leverage) / entryPrice,
+			mode:     mode,
+			input:    account.BalancePercent,
+			balance:  balance,
+		}
+	case mode == models.SizingFixedRisk:
+		if account.RiskAmount <= 0 {
+			return nil, fmt.Errorf("invalid risk amount %.2f for account %s (must be greater than 0)", account.RiskAmount, account.Name)
+		}
+		balance, err := e.availableBalance()
+		if err != nil {
+			return nil, err
+		}
+		size = &positionSize{
+			quantity: account.RiskAmount / math.Abs(entryPrice-stopLossPrice),
+			mode:     mode,
+			input:    account.RiskAmount,
+			balance:  balance,
+		}
+		// A stop-out loses less than the risk when the margin is not there
+		if maxQty := balance * float64(leverage) / entryPrice; size.quantity > maxQty {
+			e.logger.Warnf("Risk of %.2f USDT on %s needs more than the available balance of %.2f USDT, reducing to %.8f",
+				account.RiskAmount, params.symbol, balance, maxQty)
+			size.quantity = maxQty
+		}
+	default:
+		return nil, fmt.Errorf("invalid sizing mode %q for account %s", mode, account.Name)
+	}
+
+	if notionalCap := e.bracketNotionalCap(params.symbol, leverage); notionalCap > 0 && size.quantity*entryPrice > notionalCap {
+		e.logger.Warnf("Notional of %.2f USDT on %s exceeds the %.0f USDT cap at %dx leverage, reducing",
+			size.quantity*entryPrice, params.symbol, notionalCap, leverage)
+		size.quantity = notionalCap / entryPrice
+	}
+
+	return size, nil
+}
+
+// availableBalance returns the account's available USDT balance
+func (e *OrderExecutor) availableBalance() (float64, error) {
+	info, err := e.binanceClient.GetAccount()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get available balance: %w", err)
+	}
+	balance, err := strconv.ParseFloat(info.AvailableBalance, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse available balance: %w", err)
+	}
+	if balance <= 0 {
+		return 0, fmt.Errorf("no available balance")
+	}
+	return balance, nil
+}
+
+// bracketNotionalCap returns the largest notional a symbol's leverage
+// brackets allow at a leverage, or 0 when unknown. Sizing is not blocked
+// when the brackets cannot be fetched, the exchange still enforces them.
+func (e *OrderExecutor) bracketNotionalCap(symbol string, leverage int) float64 {
+	brackets, err := e.binanceClient.GetLeverageBrackets(symbol)
+	if err != nil {
+		e.logger.Warnf("Failed to get leverage brackets of %s: %v", symbol, err)
+		return 0
+	}
+
+	var notionalCap float64
+	for _, bracket := range brackets {
+		if bracket.InitialLeverage >= leverage && bracket.NotionalCap > notionalCap {
+			notionalCap = bracket.NotionalCap
+		}
+	}
+	return notionalCap
+}
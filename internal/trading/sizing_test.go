@@ -0,0 +1,43 @@
+package trading
+
+import (
+	"strings"
+	"testing"
+
+	"tdlib-go/pkg/models"
+)
+
+func TestFixedRiskSizingNeverRisksMoreThanTheRiskAmount(t *testing.T) {
+	te := newTestExecutor(t, &models.BinanceAccount{
+		SizingMode: models.SizingFixedRisk,
+		RiskAmount: 1.2348,
+	})
+
+	position := te.executeSignal(t)
+
+	// The stop 1 USDT away sizes 1.2348, which rounds to 1.235 but floors to 1.234
+	if !approxEqual(position.Quantity, 1.234) {
+		t.Errorf("quantity = %v, want 1.234", position.Quantity)
+	}
+	if loss := (position.EntryPrice - position.StopLossPrice) * position.Quantity; loss > te.account.RiskAmount {
+		t.Errorf("stop-out loses %v, more than the risk of %v", loss, te.account.RiskAmount)
+	}
+	if position.SizingMode != models.SizingFixedRisk || position.SizingInput != te.account.RiskAmount {
+		t.Errorf("sizing = %s %v, want fixed_risk %v", position.SizingMode, position.SizingInput, te.account.RiskAmount)
+	}
+}
+
+func TestFixedRiskSizingBelowMinQuantity(t *testing.T) {
+	te := newTestExecutor(t, &models.BinanceAccount{
+		SizingMode: models.SizingFixedRisk,
+		RiskAmount: 0.0009,
+	})
+
+	err := te.ExecuteSignal(&models.Signal{ID: 1, Symbol: "BTCUSDT"}, te.account)
+	if err == nil || !strings.Contains(err.Error(), "minimum quantity") {
+		t.Fatalf("ExecuteSignal error = %v, want the minimum quantity error", err)
+	}
+	if orders := te.srv.Orders(); len(orders) != 0 {
+		t.Errorf("placed %d orders, want none", len(orders))
+	}
+}
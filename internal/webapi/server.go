@@ -2,8 +2,11 @@ package webapi
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -14,6 +17,7 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/rs/cors"
 	"github.com/sirupsen/logrus"
+	"tdlib-go/internal/analytics"
 	"tdlib-go/internal/config"
 	"tdlib-go/internal/storage"
 	"tdlib-go/pkg/models"
@@ -21,18 +25,26 @@ import (
 
 // Server represents the web API server
 type Server struct {
-	router  *mux.Router
-	server  *http.Server
-	repo    *storage.Repository
-	config  *config.Config
-	logger  *logrus.Logger
-	monitor Monitor
+	router   *mux.Router
+	server   *http.Server
+	repo     *storage.Repository
+	config   *config.Config
+	logger   *logrus.Logger
+	monitor  Monitor
+	auth     TelegramAuth
+	webhooks Webhooks
+	ingester SignalIngester
+	trader   Trader
 
 	// WebSocket clients
 	wsClients   map[*websocket.Conn]bool
 	wsClientsMu sync.RWMutex
 	wsBroadcast chan interface{}
 	upgrader    websocket.Upgrader
+
+	// History import jobs by channel ID
+	historyJobs   map[int64]*models.HistoryProgress
+	historyJobsMu sync.Mutex
 }
 
 // Monitor interface for telegram operations
@@ -40,8 +52,43 @@ type Monitor interface {
 	SubscribeChannel(identifier string) error
 	UnsubscribeChannel(channelID int64) error
 	ListChannels() []*models.Channel
+	FetchHistory(channelID int64, opts models.HistoryOptions) (*models.HistoryProgress, error)
+}
+
+// TelegramAuth interface for the Telegram login flow
+type TelegramAuth interface {
+	Status() models.AuthStatus
+	SubmitPhoneNumber(phoneNumber string) error
+	SubmitCode(code string) error
+	SubmitPassword(password string) error
+	SubmitRegistration(firstName, lastName string) error
+}
+
+// Webhooks interface for sending test webhook deliveries
+type Webhooks interface {
+	SendTest(webhookID int64) error
+}
+
+// Trader interface for manual trading from the dashboard
+type Trader interface {
+	OpenManualTrade(trade *models.ManualTrade) ([]*models.ManualTradeResult, error)
+	ClosePosition(positionID int64, quantity float64) (*models.Position, error)
+	UpdatePositionTPSL(positionID int64, takeProfit, stopLoss float64) (*models.Position, error)
+	CancelAllOrders(accountID int64, symbol string) (int, error)
+	Halt(reason string, accountIDs []int64) (*models.HaltResult, error)
+	Resume() (*models.HaltState, error)
+	ResetRiskBreaker(accountID int64) (*models.RiskBreaker, error)
+	RateLimits() ([]*models.RateLimitStatus, error)
+}
+
+// SignalIngester interface for signals from outside Telegram
+type SignalIngester interface {
+	IngestSignal(req *models.SignalIngest) (*models.Signal, error)
 }
 
+// maxIngestBodySize limits the body of signal ingest requests
+const maxIngestBodySize = 64 << 10
+
 // NewServer creates a new web API server
 func NewServer(repo *storage.Repository, cfg *config.Config, logger *logrus.Logger) *Server {
 	s := &Server{
@@ -51,6 +98,7 @@ func NewServer(repo *storage.Repository, cfg *config.Config, logger *logrus.Logg
 		logger:      logger,
 		wsClients:   make(map[*websocket.Conn]bool),
 		wsBroadcast: make(chan interface{}, 100),
+		historyJobs: make(map[int64]*models.HistoryProgress),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true // Allow all origins in development
@@ -75,16 +123,45 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/positions/{id}", s.handleGetPosition).Methods("GET")
 	api.HandleFunc("/positions/open", s.handleGetOpenPositions).Methods("GET")
 
+	// Manual trading (requires webapi.auth_token)
+	api.HandleFunc("/trades", s.requireAuthToken(s.handleOpenTrade)).Methods("POST")
+	api.HandleFunc("/positions/{id}/close", s.requireAuthToken(s.handleClosePosition)).Methods("POST")
+	api.HandleFunc("/positions/{id}/tpsl", s.requireAuthToken(s.handleUpdatePositionTPSL)).Methods("PUT")
+	api.HandleFunc("/accounts/{id}/orders/{symbol}", s.requireAuthToken(s.handleCancelAllOrders)).Methods("DELETE")
+
+	// Emergency halt (halt and resume require webapi.auth_token)
+	api.HandleFunc("/emergency", s.handleGetHaltState).Methods("GET")
+	api.HandleFunc("/emergency/halt", s.requireAuthToken(s.handleHalt)).Methods("POST")
+	api.HandleFunc("/emergency/resume", s.requireAuthToken(s.handleResume)).Methods("POST")
+
+	// Per-account risk breakers (reset requires webapi.auth_token)
+	api.HandleFunc("/risk-breakers", s.handleGetRiskBreakers).Methods("GET")
+	api.HandleFunc("/accounts/{id}/risk-breaker/reset", s.requireAuthToken(s.handleResetRiskBreaker)).Methods("POST")
+
+	// Binance rate limits
+	api.HandleFunc("/rate-limits", s.handleGetRateLimits).Methods("GET")
+
 	// Orders
 	api.HandleFunc("/orders/position/{id}", s.handleGetOrdersByPosition).Methods("GET")
 
+	// Income history
+	api.HandleFunc("/income", s.handleGetIncome).Methods("GET")
+
 	// Signals
 	api.HandleFunc("/signals", s.handleGetSignals).Methods("GET")
+	api.HandleFunc("/signals/ingest", s.requireIngestToken(s.handleIngestSignal)).Methods("POST")
+
+	// Archived messages
+	api.HandleFunc("/messages", s.handleSearchMessages).Methods("GET")
 
 	// Channels
 	api.HandleFunc("/channels", s.handleGetChannels).Methods("GET")
 	api.HandleFunc("/channels", s.handleSubscribeChannel).Methods("POST")
+	api.HandleFunc("/channels/leaderboard", s.handleGetChannelLeaderboard).Methods("GET")
 	api.HandleFunc("/channels/{id}", s.handleUnsubscribeChannel).Methods("DELETE")
+	api.HandleFunc("/channels/{id}/trading", s.handleSetChannelTrading).Methods("PUT")
+	api.HandleFunc("/channels/{id}/history", s.handleGetHistoryImport).Methods("GET")
+	api.HandleFunc("/channels/{id}/history", s.handleStartHistoryImport).Methods("POST")
 
 	// Configuration
 	api.HandleFunc("/config", s.handleGetConfig).Methods("GET")
@@ -98,6 +175,19 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/accounts/{id}", s.handleDeleteAccount).Methods("DELETE")
 	api.HandleFunc("/accounts/{id}/set-default", s.handleSetDefaultAccount).Methods("POST")
 
+	// Telegram login (requires webapi.auth_token)
+	api.HandleFunc("/telegram/auth", s.requireAuthToken(s.handleGetTelegramAuth)).Methods("GET")
+	api.HandleFunc("/telegram/auth/{step}", s.requireAuthToken(s.handleSubmitTelegramAuth)).Methods("POST")
+
+	// Outbound webhooks (require webapi.auth_token)
+	api.HandleFunc("/webhooks", s.requireAuthToken(s.handleGetWebhooks)).Methods("GET")
+	api.HandleFunc("/webhooks", s.requireAuthToken(s.handleCreateWebhook)).Methods("POST")
+	api.HandleFunc("/webhooks/{id}", s.requireAuthToken(s.handleGetWebhook)).Methods("GET")
+	api.HandleFunc("/webhooks/{id}", s.requireAuthToken(s.handleUpdateWebhook)).Methods("PUT")
+	api.HandleFunc("/webhooks/{id}", s.requireAuthToken(s.handleDeleteWebhook)).Methods("DELETE")
+	api.HandleFunc("/webhooks/{id}/deliveries", s.requireAuthToken(s.handleGetWebhookDeliveries)).Methods("GET")
+	api.HandleFunc("/webhooks/{id}/test", s.requireAuthToken(s.handleTestWebhook)).Methods("POST")
+
 	// WebSocket
 	api.HandleFunc("/ws", s.handleWebSocket)
 
@@ -164,20 +254,368 @@ func (s *Server) SetMonitor(monitor Monitor) {
 	s.monitor = monitor
 }
 
+// SetTrader sets the handler for the manual trading endpoints
+func (s *Server) SetTrader(trader Trader) {
+	s.trader = trader
+}
+
+// SetSignalIngester sets the handler for POST /api/signals/ingest
+func (s *Server) SetSignalIngester(ingester SignalIngester) {
+	s.ingester = ingester
+}
+
+// SetWebhooks sets the dispatcher used for webhook test deliveries
+func (s *Server) SetWebhooks(webhooks Webhooks) {
+	s.webhooks = webhooks
+}
+
+// SetTelegramAuth sets the authenticator for the Telegram login endpoints
+func (s *Server) SetTelegramAuth(auth TelegramAuth) {
+	s.auth = auth
+}
+
+// requireAuthToken only lets requests through that carry webapi.auth_token,
+// as "Authorization: Bearer <token>" or "X-Auth-Token: <token>". Protected
+// endpoints are disabled while no token is configured.
+func (s *Server) requireAuthToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.checkToken(w, r, s.config.WebAPI.AuthToken, "webapi.auth_token", requestToken(r)) {
+			next(w, r)
+		}
+	}
+}
+
+// requireIngestToken protects signal ingestion with webapi.ingest_token
+// (or auth_token). Alert services that can't set headers, like TradingView,
+// may pass it as the "token" query parameter instead.
+func (s *Server) requireIngestToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := requestToken(r)
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if s.checkToken(w, r, s.config.WebAPI.IngestTokenOrDefault(), "webapi.ingest_token", token) {
+			next(w, r)
+		}
+	}
+}
+
+// checkToken compares a request token with the expected one, responding
+// with an error when they don't match
+func (s *Server) checkToken(w http.ResponseWriter, r *http.Request, expected, setting, token string) bool {
+	if expected == "" {
+		s.respondError(w, http.StatusForbidden, fmt.Sprintf("Set %s to use this endpoint", setting))
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		s.respondError(w, http.StatusUnauthorized, "Invalid or missing auth token")
+		return false
+	}
+	return true
+}
+
+// requestToken returns the token from the Authorization or X-Auth-Token header
+func requestToken(r *http.Request) string {
+	if bearer := r.Header.Get("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+		return strings.TrimPrefix(bearer, "Bearer ")
+	}
+	return r.Header.Get("X-Auth-Token")
+}
+
 // Handler functions
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	s.respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	health := map[string]interface{}{"status": "ok"}
+	if s.auth != nil {
+		health["telegram"] = map[string]string{"auth_state": s.auth.Status().State}
+	}
+	if state, err := s.repo.GetHaltState(); err != nil {
+		s.logger.Errorf("Failed to get halt state: %v", err)
+	} else {
+		health["trading"] = state
+	}
+
+	s.respondJSON(w, http.StatusOK, health)
 }
 
+// handleGetTelegramAuth returns the Telegram login status, including the
+// QR link and password hint
+func (s *Server) handleGetTelegramAuth(w http.ResponseWriter, r *http.Request) {
+	if s.auth == nil {
+		s.respondError(w, http.StatusNotFound, "Telegram login is not used by this message source")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, s.auth.Status())
+}
+
+// handleSubmitTelegramAuth submits a login step: phone, code, password or registration
+func (s *Server) handleSubmitTelegramAuth(w http.ResponseWriter, r *http.Request) {
+	if s.auth == nil {
+		s.respondError(w, http.StatusNotFound, "Telegram login is not used by this message source")
+		return
+	}
+
+	var req struct {
+		PhoneNumber string `json:"phone_number"`
+		Code        string `json:"code"`
+		Password    string `json:"password"`
+		FirstName   string `json:"first_name"`
+		LastName    string `json:"last_name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	var err error
+	switch mux.Vars(r)["step"] {
+	case "phone":
+		err = s.auth.SubmitPhoneNumber(req.PhoneNumber)
+	case "code":
+		err = s.auth.SubmitCode(req.Code)
+	case "password":
+		err = s.auth.SubmitPassword(req.Password)
+	case "registration":
+		err = s.auth.SubmitRegistration(req.FirstName, req.LastName)
+	default:
+		s.respondError(w, http.StatusNotFound, "step must be one of: phone, code, password, registration")
+		return
+	}
+	if err != nil {
+		s.respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	s.respondJSON(w, http.StatusAccepted, s.auth.Status())
+}
+
+// handleGetStats returns trading statistics. Supported query parameters:
+// account_id, channel_id, symbol, from, to (RFC3339 or YYYY-MM-DD) and
+// group_by (account, channel, symbol, day, week, month).
 func (s *Server) handleGetStats(w http.ResponseWriter, r *http.Request) {
-	stats, err := s.repo.GetTradingStats()
+	filter, err := parseStatsFilter(r)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stats, err := s.repo.GetTradingStatsFiltered(filter)
 	if err != nil {
 		s.respondError(w, http.StatusInternalServerError, "Failed to get statistics")
 		return
 	}
 
-	s.respondJSON(w, http.StatusOK, stats)
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		s.respondJSON(w, http.StatusOK, stats)
+		return
+	}
+
+	if !analytics.IsValidGroupBy(groupBy) {
+		s.respondError(w, http.StatusBadRequest, "group_by must be one of: account, channel, symbol, day, week, month")
+		return
+	}
+
+	buckets, err := s.repo.GetTradingStatsBreakdown(filter, groupBy)
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Failed to get statistics breakdown")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"stats":    stats,
+		"group_by": groupBy,
+		"buckets":  buckets,
+	})
+}
+
+// parseStatsFilter builds a stats filter from request query parameters
+func parseStatsFilter(r *http.Request) (models.StatsFilter, error) {
+	query := r.URL.Query()
+	var filter models.StatsFilter
+
+	if v := query.Get("account_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid account_id")
+		}
+		filter.AccountID = id
+	}
+	if v := query.Get("channel_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid channel_id")
+		}
+		filter.ChannelID = id
+	}
+	if v := query.Get("symbol"); v != "" {
+		filter.Symbol = strings.ToUpper(v)
+	}
+	if v := query.Get("from"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from: %v", err)
+		}
+		filter.From = t
+	}
+	if v := query.Get("to"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to: %v", err)
+		}
+		filter.To = t
+	}
+
+	return filter, nil
+}
+
+// parseTimeParam parses an RFC3339 timestamp or a YYYY-MM-DD date (UTC)
+func parseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// handleSearchMessages searches archived channel messages.
+// Query parameters: q, channel_id, from, to, around + window (e.g. a trade's
+// open time and 30m), limit, offset.
+func (s *Server) handleSearchMessages(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.MessageFilter{
+		Query: strings.TrimSpace(query.Get("q")),
+		Limit: 100,
+	}
+
+	if v := query.Get("channel_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.respondError(w, http.StatusBadRequest, "invalid channel_id")
+			return
+		}
+		filter.ChannelID = id
+	}
+	if v := query.Get("from"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			s.respondError(w, http.StatusBadRequest, "invalid from")
+			return
+		}
+		filter.From = t
+	}
+	if v := query.Get("to"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			s.respondError(w, http.StatusBadRequest, "invalid to")
+			return
+		}
+		filter.To = t
+	}
+	if v := query.Get("around"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			s.respondError(w, http.StatusBadRequest, "invalid around")
+			return
+		}
+		window := 30 * time.Minute
+		if wv := query.Get("window"); wv != "" {
+			window, err = time.ParseDuration(wv)
+			if err != nil || window <= 0 {
+				s.respondError(w, http.StatusBadRequest, "invalid window")
+				return
+			}
+		}
+		filter.From = t.Add(-window)
+		filter.To = t.Add(window)
+	}
+	if v := query.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 {
+			s.respondError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		if l > 1000 {
+			l = 1000
+		}
+		filter.Limit = l
+	}
+	if v := query.Get("offset"); v != "" {
+		o, err := strconv.Atoi(v)
+		if err != nil || o < 0 {
+			s.respondError(w, http.StatusBadRequest, "invalid offset")
+			return
+		}
+		filter.Offset = o
+	}
+
+	messages, err := s.repo.SearchMessages(filter)
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Failed to search messages")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"messages":  messages,
+		"full_text": s.repo.IsMessageSearchIndexed(),
+	})
+}
+
+// handleGetIncome lists the Binance income history with its totals.
+// Query parameters: account_id, type, symbol, from, to, limit, offset.
+func (s *Server) handleGetIncome(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	statsFilter, err := parseStatsFilter(r)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter := models.IncomeFilter{
+		AccountID:  statsFilter.AccountID,
+		IncomeType: strings.ToUpper(query.Get("type")),
+		Symbol:     statsFilter.Symbol,
+		From:       statsFilter.From,
+		To:         statsFilter.To,
+		Limit:      100,
+	}
+
+	if v := query.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 {
+			s.respondError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		if l > 1000 {
+			l = 1000
+		}
+		filter.Limit = l
+	}
+	if v := query.Get("offset"); v != "" {
+		o, err := strconv.Atoi(v)
+		if err != nil || o < 0 {
+			s.respondError(w, http.StatusBadRequest, "invalid offset")
+			return
+		}
+		filter.Offset = o
+	}
+
+	income, err := s.repo.GetIncome(filter)
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Failed to get income history")
+		return
+	}
+
+	summary, err := s.repo.GetIncomeSummary(statsFilter)
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Failed to get income totals")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, map[string]interface{}{
+		"income":  income,
+		"summary": summary,
+	})
 }
 
 func (s *Server) handleGetPositions(w http.ResponseWriter, r *http.Request) {
@@ -251,10 +689,74 @@ func (s *Server) handleGetOrdersByPosition(w http.ResponseWriter, r *http.Reques
 	s.respondJSON(w, http.StatusOK, orders)
 }
 
+// handleGetSignals returns recent signals, optionally filtered by ?source=
 func (s *Server) handleGetSignals(w http.ResponseWriter, r *http.Request) {
-	// This would require a new repository method
-	// For now, return empty array
-	s.respondJSON(w, http.StatusOK, []interface{}{})
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+
+	signals, err := s.repo.GetRecentSignals(r.URL.Query().Get("source"), limit)
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Failed to get signals")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, signals)
+}
+
+// handleIngestSignal accepts a signal from outside Telegram. JSON bodies are
+// decoded as models.SignalIngest; any other body is treated as signal text,
+// with the source taken from ?source=. The signal is executed in the
+// background, so the response only reports whether it was accepted.
+func (s *Server) handleIngestSignal(w http.ResponseWriter, r *http.Request) {
+	if s.ingester == nil {
+		s.respondError(w, http.StatusServiceUnavailable, "Signal ingestion is not available")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxIngestBodySize))
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+
+	var req models.SignalIngest
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	} else {
+		req.Text = string(body)
+	}
+	if req.Source == "" {
+		req.Source = r.URL.Query().Get("source")
+	}
+
+	if strings.TrimSpace(req.Text) == "" && strings.TrimSpace(req.Symbol) == "" {
+		s.respondError(w, http.StatusBadRequest, "Either text or symbol is required")
+		return
+	}
+	if req.EntryPrice < 0 {
+		s.respondError(w, http.StatusBadRequest, "Entry price must not be negative")
+		return
+	}
+
+	signal, err := s.ingester.IngestSignal(&req)
+	if err != nil {
+		if errors.Is(err, models.ErrSignalRejected) {
+			s.respondError(w, http.StatusUnprocessableEntity, err.Error())
+		} else {
+			s.logger.Errorf("Failed to ingest signal: %v", err)
+			s.respondError(w, http.StatusInternalServerError, "Failed to ingest signal")
+		}
+		return
+	}
+
+	s.respondJSON(w, http.StatusAccepted, signal)
 }
 
 func (s *Server) handleGetChannels(w http.ResponseWriter, r *http.Request) {
@@ -318,6 +820,151 @@ func (s *Server) handleUnsubscribeChannel(w http.ResponseWriter, r *http.Request
 	s.respondJSON(w, http.StatusOK, map[string]string{"status": "unsubscribed"})
 }
 
+// handleGetChannelLeaderboard returns channels ranked by score. The window
+// defaults to trading.channel_scoring.window_days and can be overridden
+// with the window_days query parameter.
+func (s *Server) handleGetChannelLeaderboard(w http.ResponseWriter, r *http.Request) {
+	scoring := s.config.Trading.ChannelScoring
+	if v := r.URL.Query().Get("window_days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days <= 0 {
+			s.respondError(w, http.StatusBadRequest, "Invalid window_days")
+			return
+		}
+		scoring.WindowDays = days
+	}
+
+	leaderboard, err := s.repo.GetChannelLeaderboard(scoring.Window(), analytics.ScoreParams{
+		SlippageRate: scoring.SlippagePercent,
+		MinTrades:    scoring.MinTradesOrDefault(),
+	})
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Failed to get channel leaderboard")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, leaderboard)
+}
+
+func (s *Server) handleSetChannelTrading(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	channelID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "Invalid channel ID")
+		return
+	}
+
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := s.repo.SetChannelTradingEnabled(channelID, req.Enabled); err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Failed to update channel")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, map[string]interface{}{"channel_id": channelID, "trading_enabled": req.Enabled})
+}
+
+// handleStartHistoryImport starts a background history import for a channel.
+// Progress is broadcast over WebSocket as "history_import" updates.
+func (s *Server) handleStartHistoryImport(w http.ResponseWriter, r *http.Request) {
+	if s.monitor == nil {
+		s.respondError(w, http.StatusServiceUnavailable, "Monitor not initialized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	channelID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "Invalid channel ID")
+		return
+	}
+
+	var req struct {
+		Limit int    `json:"limit"`
+		Since string `json:"since"` // RFC3339 or YYYY-MM-DD
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	opts := models.HistoryOptions{Limit: req.Limit}
+	if req.Since != "" {
+		since, err := parseTimeParam(req.Since)
+		if err != nil {
+			s.respondError(w, http.StatusBadRequest, "invalid since")
+			return
+		}
+		opts.Since = since
+	}
+	if opts.Limit < 0 || (opts.Limit == 0 && opts.Since.IsZero()) {
+		s.respondError(w, http.StatusBadRequest, "limit must be positive, or 0 with a since date")
+		return
+	}
+
+	job := &models.HistoryProgress{
+		ChannelID: channelID,
+		Status:    models.HistoryRunning,
+		StartedAt: time.Now(),
+	}
+
+	s.historyJobsMu.Lock()
+	if existing, ok := s.historyJobs[channelID]; ok && existing.Status == models.HistoryRunning {
+		s.historyJobsMu.Unlock()
+		s.respondError(w, http.StatusConflict, "History import already running for this channel")
+		return
+	}
+	s.historyJobs[channelID] = job
+	s.historyJobsMu.Unlock()
+
+	opts.Progress = func(p *models.HistoryProgress) {
+		snapshot := *p
+		s.historyJobsMu.Lock()
+		s.historyJobs[channelID] = &snapshot
+		s.historyJobsMu.Unlock()
+		s.BroadcastUpdate("history_import", &snapshot)
+	}
+
+	go func() {
+		if _, err := s.monitor.FetchHistory(channelID, opts); err != nil {
+			s.logger.Errorf("History import for channel %d failed: %v", channelID, err)
+		}
+	}()
+
+	s.respondJSON(w, http.StatusAccepted, job)
+}
+
+// handleGetHistoryImport returns the latest history import of a channel
+func (s *Server) handleGetHistoryImport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	channelID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "Invalid channel ID")
+		return
+	}
+
+	s.historyJobsMu.Lock()
+	job, ok := s.historyJobs[channelID]
+	var snapshot models.HistoryProgress
+	if ok {
+		snapshot = *job
+	}
+	s.historyJobsMu.Unlock()
+
+	if !ok {
+		s.respondError(w, http.StatusNotFound, "No history import for this channel")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, snapshot)
+}
+
 func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
 	// Try to get settings from database first
 	dbSettings, err := s.repo.GetAllSettings()
@@ -456,8 +1103,25 @@ func validateAccountConfig(account *models.BinanceAccount) error {
 	if account.Leverage <= 0 || account.Leverage > 125 {
 		return fmt.Errorf("leverage must be between 1 and 125, got %d", account.Leverage)
 	}
-	if account.OrderAmount <= 0 {
-		return fmt.Errorf("order amount must be greater than 0, got %.2f", account.OrderAmount)
+	switch account.SizingMode {
+	case "", models.SizingFixedNotional:
+		if account.OrderAmount <= 0 {
+			return fmt.Errorf("order amount must be greater than 0, got %.2f", account.OrderAmount)
+		}
+	case models.SizingBalancePercent:
+		if account.BalancePercent <= 0 || account.BalancePercent > 1 {
+			return fmt.Errorf("balance percent must be between 0 and 1, got %.4f", account.BalancePercent)
+		}
+	case models.SizingFixedRisk:
+		if account.RiskAmount <= 0 {
+			return fmt.Errorf("risk amount must be greater than 0, got %.2f", account.RiskAmount)
+		}
+	default:
+		return fmt.Errorf("sizing mode must be %s, %s or %s, got %q",
+			models.SizingFixedNotional, models.SizingBalancePercent, models.SizingFixedRisk, account.SizingMode)
+	}
+	if account.OrderAmount < 0 || account.BalancePercent < 0 || account.RiskAmount < 0 {
+		return fmt.Errorf("order amount, balance percent and risk amount must not be negative")
 	}
 	if account.TargetPercent <= 0 {
 		return fmt.Errorf("target percent must be greater than 0, got %.4f", account.TargetPercent)
@@ -468,6 +1132,28 @@ func validateAccountConfig(account *models.BinanceAccount) error {
 	if account.OrderTimeout < 0 {
 		return fmt.Errorf("order timeout must be non-negative, got %d", account.OrderTimeout)
 	}
+	if account.MaxDailyLoss < 0 || account.MaxLossStreak < 0 {
+		return fmt.Errorf("max daily loss and max loss streak must be non-negative")
+	}
+	if account.MaxDrawdown < 0 || account.MaxDrawdown >= 1 {
+		return fmt.Errorf("max drawdown must be between 0 and 1, got %.4f", account.MaxDrawdown)
+	}
+	switch account.EntryMode {
+	case "", models.EntryMarket:
+	case models.EntryLimit, models.EntryPostOnly:
+		if account.EntryTimeout <= 0 {
+			return fmt.Errorf("entry timeout must be greater than 0 for limit entries, got %d", account.EntryTimeout)
+		}
+	default:
+		return fmt.Errorf("entry mode must be %s, %s or %s, got %q",
+			models.EntryMarket, models.EntryLimit, models.EntryPostOnly, account.EntryMode)
+	}
+	if account.EntryChases < 0 || account.EntryTimeout < 0 {
+		return fmt.Errorf("entry chases and entry timeout must be non-negative")
+	}
+	if account.EntrySlippage < 0 || account.EntrySlippage >= 1 {
+		return fmt.Errorf("entry slippage must be between 0 and 1, got %.4f", account.EntrySlippage)
+	}
 	return nil
 }
 
@@ -493,7 +1179,10 @@ func (s *Server) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
 	if account.Leverage == 0 {
 		account.Leverage = 10
 	}
-	if account.OrderAmount == 0 {
+	if account.SizingMode == "" {
+		account.SizingMode = models.SizingFixedNotional
+	}
+	if account.OrderAmount == 0 && account.SizingMode == models.SizingFixedNotional {
 		account.OrderAmount = 100
 	}
 	if account.TargetPercent == 0 {
@@ -505,6 +1194,9 @@ func (s *Server) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
 	if account.OrderTimeout == 0 {
 		account.OrderTimeout = 600
 	}
+	if account.EntryMode == "" {
+		account.EntryMode = models.EntryMarket
+	}
 
 	// Validate account configuration
 	if err := validateAccountConfig(&account); err != nil {
@@ -741,7 +1433,7 @@ func (s *Server) formatIgnoreTokens(tokens []string) string {
 	if len(tokens) == 0 {
 		return ""
 	}
-	
+
 	// Remove USDT suffix for display (users can input with or without it)
 	result := make([]string, 0, len(tokens))
 	for _, token := range tokens {
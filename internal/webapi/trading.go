@@ -0,0 +1,275 @@
+package webapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"tdlib-go/pkg/models"
+)
+
+// Manual trading handlers
+
+// handleOpenTrade opens a manual trade and reports the result per account
+func (s *Server) handleOpenTrade(w http.ResponseWriter, r *http.Request) {
+	if s.trader == nil {
+		s.respondError(w, http.StatusServiceUnavailable, "Trading is not available")
+		return
+	}
+
+	var trade models.ManualTrade
+	if err := json.NewDecoder(r.Body).Decode(&trade); err != nil {
+		s.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	results, err := s.trader.OpenManualTrade(&trade)
+	if err != nil {
+		s.respondTradeError(w, err)
+		return
+	}
+
+	// Created when at least one account opened the position; the results
+	// carry each account's error otherwise
+	status := http.StatusUnprocessableEntity
+	for _, result := range results {
+		if result.Position != nil {
+			status = http.StatusCreated
+			break
+		}
+	}
+
+	s.BroadcastUpdate("manual_trade", results)
+	s.respondJSON(w, status, results)
+}
+
+// handleClosePosition closes a position at market. The body may give a
+// quantity or a percent of the position to close it partially.
+func (s *Server) handleClosePosition(w http.ResponseWriter, r *http.Request) {
+	if s.trader == nil {
+		s.respondError(w, http.StatusServiceUnavailable, "Trading is not available")
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "Invalid position ID")
+		return
+	}
+
+	var req struct {
+		Quantity float64 `json:"quantity"`
+		Percent  float64 `json:"percent"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+	if req.Quantity < 0 || req.Percent < 0 || req.Percent > 100 {
+		s.respondError(w, http.StatusBadRequest, "quantity must be positive and percent between 0 and 100")
+		return
+	}
+
+	quantity := req.Quantity
+	if req.Percent > 0 && req.Percent < 100 {
+		position, err := s.repo.GetPosition(id)
+		if err != nil {
+			s.respondError(w, http.StatusInternalServerError, "Failed to get position")
+			return
+		}
+		if position == nil {
+			s.respondError(w, http.StatusNotFound, "Position not found")
+			return
+		}
+		quantity = position.Quantity * req.Percent / 100
+	}
+
+	position, err := s.trader.ClosePosition(id, quantity)
+	if err != nil {
+		s.respondTradeError(w, err)
+		return
+	}
+
+	s.BroadcastUpdate("position_update", position)
+	s.respondJSON(w, http.StatusOK, position)
+}
+
+// handleUpdatePositionTPSL replaces a position's take profit and/or stop loss
+func (s *Server) handleUpdatePositionTPSL(w http.ResponseWriter, r *http.Request) {
+	if s.trader == nil {
+		s.respondError(w, http.StatusServiceUnavailable, "Trading is not available")
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "Invalid position ID")
+		return
+	}
+
+	var req struct {
+		TakeProfit float64 `json:"take_profit"`
+		StopLoss   float64 `json:"stop_loss"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.TakeProfit < 0 || req.StopLoss < 0 {
+		s.respondError(w, http.StatusBadRequest, "Prices must not be negative")
+		return
+	}
+
+	position, err := s.trader.UpdatePositionTPSL(id, req.TakeProfit, req.StopLoss)
+	if err != nil {
+		s.respondTradeError(w, err)
+		return
+	}
+
+	s.BroadcastUpdate("position_update", position)
+	s.respondJSON(w, http.StatusOK, position)
+}
+
+// handleCancelAllOrders cancels all open orders of a symbol on an account
+func (s *Server) handleCancelAllOrders(w http.ResponseWriter, r *http.Request) {
+	if s.trader == nil {
+		s.respondError(w, http.StatusServiceUnavailable, "Trading is not available")
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "Invalid account ID")
+		return
+	}
+
+	canceled, err := s.trader.CancelAllOrders(id, vars["symbol"])
+	if err != nil {
+		s.respondTradeError(w, err)
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, map[string]interface{}{"status": "canceled", "canceled": canceled})
+}
+
+// handleGetHaltState returns the emergency halt state
+func (s *Server) handleGetHaltState(w http.ResponseWriter, r *http.Request) {
+	state, err := s.repo.GetHaltState()
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Failed to get halt state")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, state)
+}
+
+// handleHalt halts trading and flattens the given accounts, or all active
+// accounts when none are given
+func (s *Server) handleHalt(w http.ResponseWriter, r *http.Request) {
+	if s.trader == nil {
+		s.respondError(w, http.StatusServiceUnavailable, "Trading is not available")
+		return
+	}
+
+	var req struct {
+		Reason     string  `json:"reason"`
+		AccountIDs []int64 `json:"account_ids"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	result, err := s.trader.Halt(req.Reason, req.AccountIDs)
+	if err != nil {
+		s.respondTradeError(w, err)
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, result)
+}
+
+// handleResume lifts the emergency halt
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	if s.trader == nil {
+		s.respondError(w, http.StatusServiceUnavailable, "Trading is not available")
+		return
+	}
+
+	state, err := s.trader.Resume()
+	if err != nil {
+		s.respondTradeError(w, err)
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, state)
+}
+
+// handleGetRiskBreakers returns the risk breakers of all accounts
+func (s *Server) handleGetRiskBreakers(w http.ResponseWriter, r *http.Request) {
+	breakers, err := s.repo.GetRiskBreakers()
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Failed to get risk breakers")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, breakers)
+}
+
+// handleGetRateLimits returns the Binance request weight and order rate
+// usage of every account
+func (s *Server) handleGetRateLimits(w http.ResponseWriter, r *http.Request) {
+	if s.trader == nil {
+		s.respondError(w, http.StatusServiceUnavailable, "Trading is not available")
+		return
+	}
+
+	limits, err := s.trader.RateLimits()
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Failed to get rate limits")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, limits)
+}
+
+// handleResetRiskBreaker clears an account's tripped risk breaker
+func (s *Server) handleResetRiskBreaker(w http.ResponseWriter, r *http.Request) {
+	if s.trader == nil {
+		s.respondError(w, http.StatusServiceUnavailable, "Trading is not available")
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "Invalid account ID")
+		return
+	}
+
+	breaker, err := s.trader.ResetRiskBreaker(id)
+	if err != nil {
+		s.respondTradeError(w, err)
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, breaker)
+}
+
+// respondTradeError maps rejected trades to 422 and anything else, usually
+// an exchange error, to 502
+func (s *Server) respondTradeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, models.ErrTradeRejected) {
+		s.respondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	s.logger.Errorf("Manual trade action failed: %v", err)
+	s.respondError(w, http.StatusBadGateway, err.Error())
+}
@@ -0,0 +1,243 @@
+package webapi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"tdlib-go/internal/events"
+	"tdlib-go/pkg/models"
+)
+
+// Webhook handlers
+
+func (s *Server) handleGetWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := s.repo.GetWebhooks(false)
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Failed to get webhooks")
+		return
+	}
+
+	for _, webhook := range webhooks {
+		maskWebhookSecret(webhook)
+	}
+
+	s.respondJSON(w, http.StatusOK, webhooks)
+}
+
+func (s *Server) handleGetWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := s.webhookFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	maskWebhookSecret(webhook)
+	s.respondJSON(w, http.StatusOK, webhook)
+}
+
+// handleCreateWebhook creates a webhook. A secret is generated when none is
+// given; the response is the only place it is returned unmasked.
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook := models.Webhook{IsActive: true}
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		s.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if webhook.Format == "" {
+		webhook.Format = models.WebhookFormatJSON
+	}
+	if webhook.Secret == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			s.respondError(w, http.StatusInternalServerError, "Failed to generate webhook secret")
+			return
+		}
+		webhook.Secret = secret
+	}
+
+	if err := validateWebhook(&webhook); err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	webhook.CreatedAt = time.Now()
+	webhook.UpdatedAt = time.Now()
+
+	if err := s.repo.SaveWebhook(&webhook); err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Failed to create webhook")
+		return
+	}
+
+	s.respondJSON(w, http.StatusCreated, webhook)
+}
+
+// handleUpdateWebhook updates the fields present in the request body
+func (s *Server) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := s.webhookFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	id, secret := webhook.ID, webhook.Secret
+	if err := json.NewDecoder(r.Body).Decode(webhook); err != nil {
+		s.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	webhook.ID = id
+
+	// Keep the secret when the client sends back the masked value
+	if webhook.Secret == "" || webhook.Secret == maskSecret(secret) {
+		webhook.Secret = secret
+	}
+
+	if err := validateWebhook(webhook); err != nil {
+		s.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.repo.UpdateWebhook(webhook); err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Failed to update webhook")
+		return
+	}
+
+	maskWebhookSecret(webhook)
+	s.respondJSON(w, http.StatusOK, webhook)
+}
+
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := s.webhookFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.repo.DeleteWebhook(webhook.ID); err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// handleGetWebhookDeliveries returns recent deliveries, newest first
+func (s *Server) handleGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := s.webhookFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	deliveries, err := s.repo.GetWebhookDeliveries(webhook.ID, limit)
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Failed to get webhook deliveries")
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, deliveries)
+}
+
+// handleTestWebhook sends a test event to the webhook and reports the result
+func (s *Server) handleTestWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		s.respondError(w, http.StatusServiceUnavailable, "Webhook delivery is not running")
+		return
+	}
+
+	webhook, ok := s.webhookFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.webhooks.SendTest(webhook.ID); err != nil {
+		s.respondError(w, http.StatusBadGateway, fmt.Sprintf("Test delivery failed: %v", err))
+		return
+	}
+
+	s.respondJSON(w, http.StatusOK, map[string]string{"status": "delivered"})
+}
+
+// webhookFromRequest loads the webhook named by the {id} route variable,
+// responding with an error when it can't
+func (s *Server) webhookFromRequest(w http.ResponseWriter, r *http.Request) (*models.Webhook, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return nil, false
+	}
+
+	webhook, err := s.repo.GetWebhook(id)
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, "Failed to get webhook")
+		return nil, false
+	}
+	if webhook == nil {
+		s.respondError(w, http.StatusNotFound, "Webhook not found")
+		return nil, false
+	}
+
+	return webhook, true
+}
+
+// validateWebhook validates a webhook's URL, format and event types
+func validateWebhook(webhook *models.Webhook) error {
+	if webhook.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+
+	switch webhook.Format {
+	case models.WebhookFormatJSON, models.WebhookFormatDiscord, models.WebhookFormatSlack:
+	default:
+		return fmt.Errorf("format must be json, discord or slack, got %q", webhook.Format)
+	}
+
+	known := make(map[string]bool, len(events.Types))
+	for _, eventType := range events.Types {
+		known[eventType] = true
+	}
+	for _, eventType := range webhook.Events {
+		if !known[eventType] {
+			return fmt.Errorf("unknown event type %q", eventType)
+		}
+	}
+
+	return nil
+}
+
+// generateWebhookSecret returns a random 32-byte hex secret
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// maskWebhookSecret hides all but the ends of a webhook's secret
+func maskWebhookSecret(webhook *models.Webhook) {
+	webhook.Secret = maskSecret(webhook.Secret)
+}
+
+// maskSecret keeps the first and last four characters of a secret
+func maskSecret(secret string) string {
+	if len(secret) <= 8 {
+		return "********"[:len(secret)]
+	}
+	return secret[:4] + "..." + secret[len(secret)-4:]
+}
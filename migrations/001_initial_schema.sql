@@ -9,8 +9,8 @@ CREATE TABLE IF NOT EXISTS channels (
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
-CREATE INDEX idx_channels_channel_id ON channels(channel_id);
-CREATE INDEX idx_channels_username ON channels(username);
+CREATE INDEX IF NOT EXISTS idx_channels_channel_id ON channels(channel_id);
+CREATE INDEX IF NOT EXISTS idx_channels_username ON channels(username);
 
 -- Create messages table
 CREATE TABLE IF NOT EXISTS messages (
@@ -28,6 +28,6 @@ CREATE TABLE IF NOT EXISTS messages (
     UNIQUE(message_id, channel_id)
 );
 
-CREATE INDEX idx_messages_channel_id ON messages(channel_id);
-CREATE INDEX idx_messages_timestamp ON messages(timestamp);
-CREATE INDEX idx_messages_sender_id ON messages(sender_id);
+CREATE INDEX IF NOT EXISTS idx_messages_channel_id ON messages(channel_id);
+CREATE INDEX IF NOT EXISTS idx_messages_timestamp ON messages(timestamp);
+CREATE INDEX IF NOT EXISTS idx_messages_sender_id ON messages(sender_id);
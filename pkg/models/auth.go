@@ -0,0 +1,23 @@
+package models
+
+// Telegram authorization states
+const (
+	AuthStateStarting         = "starting"
+	AuthStateWaitPhoneNumber  = "wait_phone_number"
+	AuthStateWaitCode         = "wait_code"
+	AuthStateWaitPassword     = "wait_password"
+	AuthStateWaitRegistration = "wait_registration"
+	AuthStateWaitQRScan       = "wait_qr_scan"
+	AuthStateReady            = "ready"
+	AuthStateClosed           = "closed"
+)
+
+// AuthStatus describes the progress of the Telegram login
+type AuthStatus struct {
+	State        string `json:"state"`
+	PhoneNumber  string `json:"phone_number,omitempty"`  // Masked
+	CodeType     string `json:"code_type,omitempty"`     // Where the code was sent: app, sms, call, ...
+	PasswordHint string `json:"password_hint,omitempty"` // 2FA password hint
+	QRLink       string `json:"qr_link,omitempty"`       // tg:// link to render as a QR code
+	LastError    string `json:"last_error,omitempty"`    // Error of the last submitted value
+}
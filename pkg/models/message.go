@@ -4,26 +4,67 @@ import "time"
 
 // Message represents a Telegram message stored in the database
 type Message struct {
-	ID          int64     `db:"id"`
-	MessageID   int64     `db:"message_id"`
-	ChannelID   int64     `db:"channel_id"`
-	ChannelName string    `db:"channel_name"`
-	SenderID    int64     `db:"sender_id"`
-	SenderName  string    `db:"sender_name"`
-	Text        string    `db:"text"`
-	MediaType   string    `db:"media_type"`
-	IsForwarded bool      `db:"is_forwarded"`
-	Timestamp   time.Time `db:"timestamp"`
-	CreatedAt   time.Time `db:"created_at"`
+	ID          int64     `db:"id" json:"id"`
+	MessageID   int64     `db:"message_id" json:"message_id"`
+	ChannelID   int64     `db:"channel_id" json:"channel_id"`
+	ChannelName string    `db:"channel_name" json:"channel_name"`
+	SenderID    int64     `db:"sender_id" json:"sender_id"`
+	SenderName  string    `db:"sender_name" json:"sender_name"`
+	Text        string    `db:"text" json:"text"`
+	MediaType   string    `db:"media_type" json:"media_type"`
+	IsForwarded bool      `db:"is_forwarded" json:"is_forwarded"`
+	Timestamp   time.Time `db:"timestamp" json:"timestamp"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
 }
 
 // Channel represents a subscribed Telegram channel
 type Channel struct {
-	ID        int64     `db:"id" json:"id"`
-	ChannelID int64     `db:"channel_id" json:"channel_id"`
-	Username  string    `db:"username" json:"username"`
-	Title     string    `db:"title" json:"title"`
-	IsActive  bool      `db:"is_active" json:"is_active"`
-	CreatedAt time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	ID             int64     `db:"id" json:"id"`
+	ChannelID      int64     `db:"channel_id" json:"channel_id"`
+	Username       string    `db:"username" json:"username"`
+	Title          string    `db:"title" json:"title"`
+	IsActive       bool      `db:"is_active" json:"is_active"`
+	TradingEnabled bool      `db:"trading_enabled" json:"trading_enabled"` // False when signals are monitored but not traded
+	LastMessageID  int64     `db:"last_message_id" json:"last_message_id"` // Newest message seen, used to catch up after reconnects
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// MessageFilter narrows a search over archived messages.
+// Zero values mean "no restriction".
+type MessageFilter struct {
+	Query     string // Full-text query
+	ChannelID int64
+	From      time.Time // Messages posted at or after this time
+	To        time.Time // Messages posted before this time
+	Limit     int
+	Offset    int
+}
+
+// History import statuses
+const (
+	HistoryRunning   = "running"
+	HistoryCompleted = "completed"
+	HistoryFailed    = "failed"
+)
+
+// HistoryOptions limits a channel history import. At least one of Limit
+// and Since should be set; with neither the full history is imported.
+type HistoryOptions struct {
+	Limit    int                    // Maximum number of messages, 0 for no limit
+	Since    time.Time              // Stop at messages posted before this time
+	Progress func(*HistoryProgress) // Called after each fetched page
+}
+
+// HistoryProgress reports the state of a channel history import
+type HistoryProgress struct {
+	ChannelID       int64      `json:"channel_id"`
+	Status          string     `json:"status"` // running, completed, failed
+	Fetched         int        `json:"fetched"`
+	Saved           int        `json:"saved"`
+	Signals         int        `json:"signals"`
+	OldestMessageAt time.Time  `json:"oldest_message_at"`
+	StartedAt       time.Time  `json:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	Error           string     `json:"error,omitempty"`
 }
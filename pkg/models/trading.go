@@ -1,43 +1,94 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 // BinanceAccount represents a Binance account configuration
 type BinanceAccount struct {
 	ID              int64     `db:"id" json:"id"`
-	Name            string    `db:"name" json:"name"`                         // Friendly name for the account
-	APIKey          string    `db:"api_key" json:"api_key"`                   // Encrypted in production
-	APISecret       string    `db:"api_secret" json:"api_secret"`             // Encrypted in production
+	Name            string    `db:"name" json:"name"`             // Friendly name for the account
+	APIKey          string    `db:"api_key" json:"api_key"`       // Encrypted in production
+	APISecret       string    `db:"api_secret" json:"api_secret"` // Encrypted in production
 	IsTestnet       bool      `db:"is_testnet" json:"is_testnet"`
 	IsActive        bool      `db:"is_active" json:"is_active"`
 	IsDefault       bool      `db:"is_default" json:"is_default"`             // Default account for new trades
 	Leverage        int       `db:"leverage" json:"leverage"`                 // Trading leverage (1-125)
 	OrderAmount     float64   `db:"order_amount" json:"order_amount"`         // Order size in USDT
+	SizingMode      string    `db:"sizing_mode" json:"sizing_mode"`           // Position sizing mode, fixed notional when empty
+	BalancePercent  float64   `db:"balance_percent" json:"balance_percent"`   // Margin as a fraction of the available balance
+	RiskAmount      float64   `db:"risk_amount" json:"risk_amount"`           // USDT lost when the stop loss fills
 	TargetPercent   float64   `db:"target_percent" json:"target_percent"`     // Take profit %
 	StopLossPercent float64   `db:"stoploss_percent" json:"stoploss_percent"` // Stop loss %
 	OrderTimeout    int       `db:"order_timeout" json:"order_timeout"`       // Timeout in seconds
+	MaxDailyLoss    float64   `db:"max_daily_loss" json:"max_daily_loss"`     // Net realized loss in USDT per day that trips the breaker, 0 disables
+	MaxLossStreak   int       `db:"max_loss_streak" json:"max_loss_streak"`   // Consecutive losing positions that trip the breaker, 0 disables
+	MaxDrawdown     float64   `db:"max_drawdown" json:"max_drawdown"`         // Equity drawdown from the day's peak (0.1 = 10%), 0 disables
+	EntryMode       string    `db:"entry_mode" json:"entry_mode"`             // Entry order type, market when empty
+	EntryChases     int       `db:"entry_chases" json:"entry_chases"`         // Times an unfilled limit entry is repriced
+	EntrySlippage   float64   `db:"entry_slippage" json:"entry_slippage"`     // Max reprice away from the first limit price (0.005 = 0.5%)
+	EntryTimeout    int       `db:"entry_timeout" json:"entry_timeout"`       // Seconds before an unfilled limit entry is canceled
 	CreatedAt       time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt       time.Time `db:"updated_at" json:"updated_at"`
 }
 
-// Signal represents a parsed trading signal from Telegram
+// Position sizing modes
+const (
+	SizingFixedNotional  = "fixed_notional"  // OrderAmount USDT of notional
+	SizingBalancePercent = "balance_percent" // BalancePercent of the available balance as margin
+	SizingFixedRisk      = "fixed_risk"      // Sized from the stop distance so a stop-out loses RiskAmount USDT
+	SizingQuantity       = "quantity"        // Quantity given with a manual trade
+)
+
+// Entry modes
+const (
+	EntryMarket   = "market"    // MARKET order
+	EntryLimit    = "limit"     // GTC LIMIT order, chased when unfilled
+	EntryPostOnly = "post_only" // GTX LIMIT order that only rests on the book, chased when unfilled
+)
+
+// Signal sources
+const (
+	SignalSourceTelegram = "telegram"
+	SignalSourceAPI      = "api" // Default for POST /api/signals/ingest
+)
+
+// Signal represents a parsed trading signal from Telegram or the ingest API
 type Signal struct {
-	ID          int64     `db:"id"`
-	MessageID   int64     `db:"message_id"`
-	ChannelID   int64     `db:"channel_id"`
-	Symbol      string    `db:"symbol"`
-	RawMessage  string    `db:"raw_message"`
-	ParsedAt    time.Time `db:"parsed_at"`
-	ProcessedAt *time.Time `db:"processed_at"`
-	Status      string    `db:"status"` // pending, processed, failed
-	Error       string    `db:"error"`
+	ID          int64      `db:"id" json:"id"`
+	MessageID   int64      `db:"message_id" json:"message_id,omitempty"`
+	ChannelID   int64      `db:"channel_id" json:"channel_id,omitempty"`
+	Symbol      string     `db:"symbol" json:"symbol"`
+	RawMessage  string     `db:"raw_message" json:"raw_message"`
+	EntryPrice  float64    `db:"entry_price" json:"entry_price,omitempty"` // Limit entry price from the signal, 0 for none
+	Source      string     `db:"source" json:"source"`                     // telegram, api or the name given on ingest (e.g. tradingview)
+	PostedAt    time.Time  `db:"posted_at" json:"posted_at"`               // Time of the message, or of the ingest request
+	ParsedAt    time.Time  `db:"parsed_at" json:"parsed_at"`
+	ProcessedAt *time.Time `db:"processed_at" json:"processed_at,omitempty"`
+	Status      string     `db:"status" json:"status"` // pending, processed, failed, historical (imported, never executed)
+	Error       string     `db:"error" json:"error,omitempty"`
+}
+
+// SignalIngest is a signal submitted through the ingest API, either as raw
+// text for the signal parser or as a structured symbol
+type SignalIngest struct {
+	Source string `json:"source"` // Recorded on the signal, defaults to "api"
+	Text   string `json:"text"`   // Parsed with the configured signal pattern
+	Symbol string `json:"symbol"` // Used when no text is given
+
+	EntryPrice float64 `json:"entry_price"` // Limit entry price, used when no text is given
 }
 
+// ErrSignalRejected is returned for ingested signals that fail validation
+// or the risk checks
+var ErrSignalRejected = errors.New("signal rejected")
+
 // Position represents an open trading position
 type Position struct {
 	ID              int64      `db:"id" json:"id"`
 	SignalID        int64      `db:"signal_id" json:"signal_id"`
-	AccountID       int64      `db:"account_id" json:"account_id"`      // Which Binance account
+	AccountID       int64      `db:"account_id" json:"account_id"` // Which Binance account
 	Symbol          string     `db:"symbol" json:"symbol"`
 	Side            string     `db:"side" json:"side"` // LONG, SHORT
 	EntryPrice      float64    `db:"entry_price" json:"entry_price"`
@@ -49,41 +100,244 @@ type Position struct {
 	OpenedAt        time.Time  `db:"opened_at" json:"opened_at"`
 	ClosedAt        *time.Time `db:"closed_at" json:"closed_at"`
 	ExitPrice       *float64   `db:"exit_price" json:"exit_price"`
-	PnL             *float64   `db:"pnl" json:"pnl"`
-	PnLPercent      *float64   `db:"pnl_percent" json:"pnl_percent"`
+	PnL             *float64   `db:"pnl" json:"pnl"`                         // Gross PnL, from fills when known
+	PnLPercent      *float64   `db:"pnl_percent" json:"pnl_percent"`         // PnL on margin
+	RealizedPnL     *float64   `db:"realized_pnl" json:"realized_pnl"`       // Sum of the fills' realized PnL, nil without fills
+	Commission      float64    `db:"commission" json:"commission"`           // Sum of the fills' commission (USDT)
+	Funding         float64    `db:"funding" json:"funding"`                 // Funding received (negative when paid)
+	SizingMode      string     `db:"sizing_mode" json:"sizing_mode"`         // How the quantity was sized
+	SizingInput     float64    `db:"sizing_input" json:"sizing_input"`       // Order amount, balance fraction, risk or quantity, per mode
+	SizingBalance   float64    `db:"sizing_balance" json:"sizing_balance"`   // Available balance at sizing, 0 when not used
+	ClosedQuantity  float64    `db:"closed_quantity" json:"closed_quantity"` // Quantity closed by partial closes, not included in Quantity
+	ClosedPnL       float64    `db:"closed_pnl" json:"closed_pnl"`           // Estimated PnL of the partial closes
+}
+
+// NetPnL returns the PnL after commission and funding, or nil while the
+// position is open
+func (p *Position) NetPnL() *float64 {
+	if p.PnL == nil {
+		return nil
+	}
+	net := *p.PnL - p.Commission + p.Funding
+	return &net
+}
+
+// ManualTrade is a trade opened from the dashboard or API instead of a signal
+type ManualTrade struct {
+	AccountIDs []int64 `json:"account_ids"` // Accounts to trade on, all active accounts when empty
+	Symbol     string  `json:"symbol"`
+	Side       string  `json:"side"`        // LONG or SHORT
+	Amount     float64 `json:"amount"`      // Order size in USDT, defaults to the account's sizing mode
+	Quantity   float64 `json:"quantity"`    // Base asset quantity, overrides amount when set
+	Leverage   int     `json:"leverage"`    // Defaults to the account's leverage
+	TakeProfit float64 `json:"take_profit"` // Price, defaults to the account's target percent
+	StopLoss   float64 `json:"stop_loss"`   // Price, defaults to the account's stop loss percent
+}
+
+// ManualTradeResult is the outcome of a manual trade on one account
+type ManualTradeResult struct {
+	AccountID   int64     `json:"account_id"`
+	AccountName string    `json:"account_name"`
+	Position    *Position `json:"position,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// ErrTradeRejected is returned for manual trade actions with invalid input
+var ErrTradeRejected = errors.New("trade rejected")
+
+// HaltState is the emergency halt. While halted no new positions are
+// opened, from signals or manually, until trading is resumed.
+type HaltState struct {
+	Halted   bool       `json:"halted"`
+	Reason   string     `json:"reason,omitempty"`
+	HaltedAt *time.Time `json:"halted_at,omitempty"`
+}
+
+// RiskBreaker is an account's circuit breaker. Once tripped the account takes
+// no new signals until the breaker resets at the daily reset hour.
+type RiskBreaker struct {
+	AccountID   int64      `db:"account_id" json:"account_id"`
+	AccountName string     `json:"account_name,omitempty"`
+	Tripped     bool       `db:"tripped" json:"tripped"`
+	Reason      string     `db:"reason" json:"reason,omitempty"`
+	TrippedAt   *time.Time `db:"tripped_at" json:"tripped_at,omitempty"`
+	WindowStart time.Time  `db:"window_start" json:"window_start"` // Last reset, the counters cover the time since
+	DailyPnL    float64    `db:"daily_pnl" json:"daily_pnl"`       // Net PnL of the positions closed since the window start
+	LossStreak  int        `db:"loss_streak" json:"loss_streak"`   // Losing positions in a row since the window start
+	Equity      float64    `db:"equity" json:"equity"`             // Margin balance at the last check
+	PeakEquity  float64    `db:"peak_equity" json:"peak_equity"`   // Highest equity since the breaker was created or reset by hand
+	Drawdown    float64    `db:"drawdown" json:"drawdown"`         // Fraction of the peak equity lost
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// RateLimitStatus is an account's Binance rate limit usage. The request
+// weight and its counters are per IP, shared by accounts on the same host.
+type RateLimitStatus struct {
+	AccountID     int64      `json:"account_id"`
+	AccountName   string     `json:"account_name"`
+	UsedWeight1m  int        `json:"used_weight_1m"`
+	WeightLimit1m int        `json:"weight_limit_1m"`
+	OrderCount10s int        `json:"order_count_10s"`
+	OrderCount1m  int        `json:"order_count_1m"`
+	Throttled     int64      `json:"throttled"`    // Requests delayed to stay under a limit
+	Retries       int64      `json:"retries"`      // Idempotent requests retried
+	RateLimited   int64      `json:"rate_limited"` // 429 responses
+	Banned        int64      `json:"banned"`       // 418 responses
+	BlockedUntil  *time.Time `json:"blocked_until,omitempty"`
+}
+
+// FlattenResult is the outcome of flattening one account on halt
+type FlattenResult struct {
+	AccountID       int64    `json:"account_id"`
+	AccountName     string   `json:"account_name"`
+	CanceledOrders  int      `json:"canceled_orders"`
+	ClosedPositions []string `json:"closed_positions"` // Symbols closed at market
+	Error           string   `json:"error,omitempty"`
+}
+
+// HaltResult is the halt state and the flattening results per account
+type HaltResult struct {
+	State    *HaltState       `json:"state"`
+	Accounts []*FlattenResult `json:"accounts"`
+}
+
+// Fill is an execution of an order, from the user-data stream or the
+// account trade list
+type Fill struct {
+	ID              int64     `db:"id" json:"id"`
+	AccountID       int64     `db:"account_id" json:"account_id"`
+	PositionID      int64     `db:"position_id" json:"position_id"` // 0 until attributed to a position
+	BinanceOrderID  string    `db:"binance_order_id" json:"binance_order_id"`
+	TradeID         int64     `db:"trade_id" json:"trade_id"`
+	Symbol          string    `db:"symbol" json:"symbol"`
+	Side            string    `db:"side" json:"side"`
+	Price           float64   `db:"price" json:"price"`
+	Quantity        float64   `db:"quantity" json:"quantity"`
+	RealizedPnL     float64   `db:"realized_pnl" json:"realized_pnl"`
+	Commission      float64   `db:"commission" json:"commission"`
+	CommissionAsset string    `db:"commission_asset" json:"commission_asset"`
+	TradeTime       time.Time `db:"trade_time" json:"trade_time"`
+}
+
+// Income types recorded from the Binance income history
+const (
+	IncomeRealizedPnL = "REALIZED_PNL"
+	IncomeCommission  = "COMMISSION"
+	IncomeFundingFee  = "FUNDING_FEE"
+	IncomeTransfer    = "TRANSFER"
+)
+
+// Income is an entry of an account's Binance income history
+type Income struct {
+	ID         int64     `db:"id" json:"id"`
+	AccountID  int64     `db:"account_id" json:"account_id"`
+	PositionID int64     `db:"position_id" json:"position_id"` // 0 until attributed to a position
+	TranID     int64     `db:"tran_id" json:"tran_id"`
+	IncomeType string    `db:"income_type" json:"income_type"`
+	Symbol     string    `db:"symbol" json:"symbol"` // Empty for transfers
+	Income     float64   `db:"income" json:"income"` // Negative for commission and paid funding
+	Asset      string    `db:"asset" json:"asset"`
+	Info       string    `db:"info" json:"info"`
+	TradeID    string    `db:"trade_id" json:"trade_id"`
+	Time       time.Time `db:"time" json:"time"`
+}
+
+// IncomeFilter narrows an income history query. Zero values mean "no restriction".
+type IncomeFilter struct {
+	AccountID  int64
+	IncomeType string
+	Symbol     string
+	From       time.Time // Entries at or after this time
+	To         time.Time // Entries before this time
+	Limit      int
+	Offset     int
+}
+
+// IncomeSummary totals an account's income history by type, in the
+// settlement asset
+type IncomeSummary struct {
+	RealizedPnL float64 `json:"realized_pnl"`
+	Commission  float64 `json:"commission"` // Commission paid
+	Funding     float64 `json:"funding"`    // Funding received (negative when paid)
+	Transfers   float64 `json:"transfers"`  // Net transfers into the futures wallet
+	NetPnL      float64 `json:"net_pnl"`    // Realized PnL after commission and funding
 }
 
 // Order represents a Binance order
 type Order struct {
-	ID              int64      `db:"id" json:"id"`
-	PositionID      int64      `db:"position_id" json:"position_id"`
-	BinanceOrderID  string     `db:"binance_order_id" json:"binance_order_id"`
-	Symbol          string     `db:"symbol" json:"symbol"`
-	Side            string     `db:"side" json:"side"` // BUY, SELL
-	Type            string     `db:"type" json:"type"` // MARKET, LIMIT, STOP_MARKET, TAKE_PROFIT_MARKET
-	OrigQty         float64    `db:"orig_qty" json:"orig_qty"`
-	ExecutedQty     float64    `db:"executed_qty" json:"executed_qty"`
-	Price           float64    `db:"price" json:"price"`
-	StopPrice       *float64   `db:"stop_price" json:"stop_price"`
-	Status          string     `db:"status" json:"status"` // NEW, FILLED, PARTIALLY_FILLED, CANCELED, EXPIRED
-	TimeInForce     string     `db:"time_in_force" json:"time_in_force"`
-	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
-	UpdatedAt       time.Time  `db:"updated_at" json:"updated_at"`
-	FilledAt        *time.Time `db:"filled_at" json:"filled_at"`
-	CanceledAt      *time.Time `db:"canceled_at" json:"canceled_at"`
-	OrderPurpose    string     `db:"order_purpose" json:"order_purpose"` // entry, take_profit, stop_loss
+	ID             int64      `db:"id" json:"id"`
+	PositionID     int64      `db:"position_id" json:"position_id"`
+	BinanceOrderID string     `db:"binance_order_id" json:"binance_order_id"`
+	ClientOrderID  string     `db:"client_order_id" json:"client_order_id"`
+	Symbol         string     `db:"symbol" json:"symbol"`
+	Side           string     `db:"side" json:"side"` // BUY, SELL
+	Type           string     `db:"type" json:"type"` // MARKET, LIMIT, STOP_MARKET, TAKE_PROFIT_MARKET
+	OrigQty        float64    `db:"orig_qty" json:"orig_qty"`
+	ExecutedQty    float64    `db:"executed_qty" json:"executed_qty"`
+	Price          float64    `db:"price" json:"price"`
+	StopPrice      *float64   `db:"stop_price" json:"stop_price"`
+	Status         string     `db:"status" json:"status"` // NEW, FILLED, PARTIALLY_FILLED, CANCELED, EXPIRED
+	TimeInForce    string     `db:"time_in_force" json:"time_in_force"`
+	CreatedAt      time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at" json:"updated_at"`
+	FilledAt       *time.Time `db:"filled_at" json:"filled_at"`
+	CanceledAt     *time.Time `db:"canceled_at" json:"canceled_at"`
+	OrderPurpose   string     `db:"order_purpose" json:"order_purpose"` // entry, take_profit, stop_loss, manual
 }
 
 // TradingStats represents trading statistics
 type TradingStats struct {
-	TotalTrades     int
-	WinningTrades   int
-	LosingTrades    int
-	TotalPnL        float64
-	WinRate         float64
-	AverageWin      float64
-	AverageLoss     float64
-	LargestWin      float64
-	LargestLoss     float64
-	OpenPositions   int
+	TotalTrades    int     `json:"total_trades"`
+	WinningTrades  int     `json:"winning_trades"`
+	LosingTrades   int     `json:"losing_trades"`
+	TotalPnL       float64 `json:"total_pnl"`
+	WinRate        float64 `json:"win_rate"`
+	AverageWin     float64 `json:"average_win"`
+	AverageLoss    float64 `json:"average_loss"`
+	LargestWin     float64 `json:"largest_win"`
+	LargestLoss    float64 `json:"largest_loss"`
+	OpenPositions  int     `json:"open_positions"`
+	TotalFees      float64 `json:"total_fees"`       // Commission from fills, estimated for positions without
+	TotalFunding   float64 `json:"total_funding"`    // Funding received (negative when paid)
+	NetPnL         float64 `json:"net_pnl"`          // PnL after fees and funding
+	ProfitFactor   float64 `json:"profit_factor"`    // Gross net profit / gross net loss (0 when there are no losses)
+	Expectancy     float64 `json:"expectancy"`       // Average net PnL per trade
+	MaxDrawdown    float64 `json:"max_drawdown"`     // Largest peak-to-trough drop of cumulative net PnL (USDT)
+	SharpeRatio    float64 `json:"sharpe_ratio"`     // Mean / stddev of per-trade net PnL (not annualized)
+	AvgHoldSeconds float64 `json:"avg_hold_seconds"` // Average time between open and close
+
+	// Income is the account's income history over the same period, set for
+	// account stats that are not narrowed to a channel
+	Income *IncomeSummary `json:"income,omitempty"`
+}
+
+// StatsFilter narrows the set of positions used to compute statistics.
+// Zero values mean "no restriction".
+type StatsFilter struct {
+	AccountID int64
+	ChannelID int64
+	Symbol    string
+	From      time.Time // Positions closed at or after this time
+	To        time.Time // Positions closed before this time
+}
+
+// StatsBucket holds statistics for one group of a breakdown (account, channel, symbol or time bucket)
+type StatsBucket struct {
+	Key   string        `json:"key"`
+	Label string        `json:"label"`
+	Stats *TradingStats `json:"stats"`
+}
+
+// ChannelScore is a channel's entry on the leaderboard, computed over a rolling window
+type ChannelScore struct {
+	ChannelID               int64   `json:"channel_id"`
+	Title                   string  `json:"title"`
+	SignalCount             int     `json:"signal_count"`
+	Trades                  int     `json:"trades"`
+	WinRate                 float64 `json:"win_rate"`
+	AvgRMultiple            float64 `json:"avg_r_multiple"`
+	SlippageAdjustedHitRate float64 `json:"slippage_adjusted_hit_rate"`
+	NetPnL                  float64 `json:"net_pnl"`
+	Score                   float64 `json:"score"` // Slippage-adjusted expectancy in R, shrunk for small samples
+	TradingEnabled          bool    `json:"trading_enabled"`
 }
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Webhook payload formats
+const (
+	WebhookFormatJSON    = "json"    // Signed JSON envelope with the full event
+	WebhookFormatDiscord = "discord" // {"content": "..."} for Discord webhooks
+	WebhookFormatSlack   = "slack"   // {"text": "..."} for Slack-compatible webhooks
+)
+
+// Webhook delivery states
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed" // Gave up after the maximum number of attempts
+)
+
+// Webhook is an outbound HTTP subscription to trading events
+type Webhook struct {
+	ID        int64     `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	URL       string    `db:"url" json:"url"`
+	Format    string    `db:"format" json:"format"` // json, discord or slack
+	Secret    string    `db:"secret" json:"secret"` // HMAC-SHA256 key for the X-Webhook-Signature header
+	Events    []string  `db:"events" json:"events"` // Event types to deliver, empty for all
+	IsActive  bool      `db:"is_active" json:"is_active"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Subscribes reports whether the webhook receives an event type
+func (w *Webhook) Subscribes(eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, subscribed := range w.Events {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is a queued or finished webhook request
+type WebhookDelivery struct {
+	ID            int64      `db:"id" json:"id"`
+	WebhookID     int64      `db:"webhook_id" json:"webhook_id"`
+	EventType     string     `db:"event_type" json:"event_type"`
+	Payload       string     `db:"payload" json:"payload"`
+	Status        string     `db:"status" json:"status"` // pending, delivered, failed
+	Attempts      int        `db:"attempts" json:"attempts"`
+	NextAttemptAt time.Time  `db:"next_attempt_at" json:"next_attempt_at"`
+	LastError     string     `db:"last_error" json:"last_error,omitempty"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+	DeliveredAt   *time.Time `db:"delivered_at" json:"delivered_at,omitempty"`
+}
@@ -2,11 +2,13 @@ import { createRouter, createWebHistory } from 'vue-router'
 import Accounts from './views/Accounts.vue'
 import Channels from './views/Channels.vue'
 import Settings from './views/Settings.vue'
+import Trade from './views/Trade.vue'
 
 const routes = [
   { path: '/', redirect: '/accounts' },
   { path: '/accounts', name: 'Accounts', component: Accounts },
   { path: '/channels', name: 'Channels', component: Channels },
+  { path: '/trade', name: 'Trade', component: Trade },
   { path: '/settings', name: 'Settings', component: Settings }
 ]
 